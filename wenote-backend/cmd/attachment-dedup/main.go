@@ -0,0 +1,218 @@
+// attachment-dedup 将去重前上传的附件迁移为按内容寻址存储
+//
+// 用法：
+//
+//	go run ./cmd/attachment-dedup [-dry-run]
+//
+// 遍历 blob_hash 为空的旧附件，计算文件的 SHA-256，复制到 blobs/ 下并引用计数，
+// 缩略图一并迁移；内容相同的附件只保留一份文件，旧文件在记录更新后删除。
+// 去重前先将笔记正文中的旧版附件地址改写为规范形式，去重后旧地址无法再识别。
+// 使用配置中的 storage.driver，可重复执行，已迁移的附件会跳过。
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+const batchSize = 200
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只统计可节省的空间，不修改数据")
+	flag.Parse()
+
+	if err := config.InitConfig(); err != nil {
+		fmt.Println("初始化配置失败:", err)
+		os.Exit(1)
+	}
+	logger.Init(config.GlobalConfig.Server.Mode)
+
+	if err := repo.InitDB(); err != nil {
+		logger.Error("初始化数据库失败", "error", err)
+		os.Exit(1)
+	}
+	defer repo.CloseDB()
+
+	// 去重后旧附件的存储路径改为内容键，先将笔记正文中的旧地址改写为规范形式
+	if !*dryRun {
+		rewritten, err := service.NewNoteService().RewriteLegacyAttachmentRefs()
+		if err != nil {
+			logger.Error("改写笔记中的旧附件地址失败", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("笔记中的旧附件地址改写完成", "notes", rewritten)
+	}
+
+	ctx := context.Background()
+
+	store, err := storage.New(ctx, config.GlobalConfig.Storage.Backend(""))
+	if err != nil {
+		logger.Error("初始化附件存储失败", "driver", config.GlobalConfig.Storage.Driver, "error", err)
+		os.Exit(1)
+	}
+
+	var processed, duplicates, failed int
+	var savedBytes int64
+	attachmentRepo := repo.NewAttachmentRepo()
+	// 试运行时不写入内容记录，用于识别本次遍历中重复的内容
+	seen := make(map[string]bool)
+	var lastID uint64
+
+	for {
+		attachments, err := attachmentRepo.ListWithoutBlobAfterID(lastID, batchSize)
+		if err != nil {
+			logger.Error("查询附件失败", "error", err)
+			os.Exit(1)
+		}
+		if len(attachments) == 0 {
+			break
+		}
+
+		for _, attachment := range attachments {
+			lastID = attachment.ID
+			// 已隔离的文件不能移回内容目录，待扫描的附件等扫描完成后再处理
+			if attachment.ScanStatus != model.ScanStatusClean {
+				continue
+			}
+			key := storage.NormalizeKey(attachment.StoragePath)
+
+			hash, size, err := hashObject(ctx, store, key)
+			if err != nil {
+				// 文件丢失的附件交给附件垃圾回收处理
+				logger.Error("读取附件失败", "attachment_id", attachment.ID, "key", key, "error", err)
+				failed++
+				continue
+			}
+
+			existing, err := attachmentRepo.GetBlob(hash)
+			if err != nil {
+				logger.Error("查询内容记录失败", "attachment_id", attachment.ID, "error", err)
+				failed++
+				continue
+			}
+			duplicate := existing != nil || seen[hash]
+			seen[hash] = true
+			if duplicate {
+				duplicates++
+				savedBytes += size
+			}
+
+			if *dryRun {
+				fmt.Printf("[dry-run] #%d %s -> %s duplicate=%v\n", attachment.ID, key, service.BlobKey(hash), duplicate)
+				processed++
+				continue
+			}
+
+			if err := dedupAttachment(ctx, store, attachmentRepo, attachment, hash, size); err != nil {
+				logger.Error("迁移附件失败", "attachment_id", attachment.ID, "error", err)
+				failed++
+				continue
+			}
+			processed++
+		}
+	}
+
+	logger.Info("附件去重完成", "dry_run", *dryRun, "processed", processed, "duplicates", duplicates, "saved_bytes", savedBytes, "failed", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// dedupAttachment 将单个旧附件及其缩略图迁移到内容文件
+// 先引用内容并复制文件，记录更新成功后再删除旧文件，中途失败不会丢失数据
+func dedupAttachment(ctx context.Context, store storage.Storage, attachmentRepo *repo.AttachmentRepo, attachment *model.NoteAttachment, hash string, size int64) error {
+	key := storage.NormalizeKey(attachment.StoragePath)
+	blobKey := service.BlobKey(hash)
+
+	err := attachmentRepo.AcquireBlob(&model.AttachmentBlob{
+		Hash:        hash,
+		Size:        size,
+		MimeType:    attachment.MimeType,
+		StoragePath: blobKey,
+	})
+	if err != nil {
+		return err
+	}
+	if err := copyIfMissing(ctx, store, key, blobKey, attachment.MimeType); err != nil {
+		attachmentRepo.ReleaseBlob(hash, nil)
+		return err
+	}
+
+	// 缩略图复制失败时保留原位置，旧文件不删除
+	oldKeys := []string{key}
+	variants, err := attachmentRepo.ListVariants(attachment.ID)
+	if err != nil {
+		attachmentRepo.ReleaseBlob(hash, nil)
+		return err
+	}
+	for _, variant := range variants {
+		variantKey := service.VariantKey(blobKey, variant.Name, variant.MimeType)
+		if err := copyIfMissing(ctx, store, variant.StoragePath, variantKey, variant.MimeType); err != nil {
+			logger.Warn("迁移缩略图失败", "attachment_id", attachment.ID, "variant", variant.Name, "error", err)
+			continue
+		}
+		if err := attachmentRepo.UpdateVariantStorage(variant.ID, variantKey); err != nil {
+			logger.Warn("更新缩略图记录失败", "attachment_id", attachment.ID, "variant", variant.Name, "error", err)
+			continue
+		}
+		oldKeys = append(oldKeys, variant.StoragePath)
+	}
+
+	if err := attachmentRepo.SetBlob(attachment.ID, hash, blobKey); err != nil {
+		// 附件在迁移过程中被删除，撤销引用
+		attachmentRepo.ReleaseBlob(hash, nil)
+		return err
+	}
+
+	for _, k := range oldKeys {
+		if err := store.Delete(ctx, k); err != nil {
+			logger.Warn("删除旧文件失败", "key", k, "error", err)
+		}
+	}
+	return nil
+}
+
+// hashObject 计算对象内容的 SHA-256 和大小
+func hashObject(ctx context.Context, store storage.Storage, key string) (string, int64, error) {
+	obj, err := store.Open(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, obj)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// copyIfMissing 目标对象不存在时从 src 复制
+func copyIfMissing(ctx context.Context, store storage.Storage, src, dst, contentType string) error {
+	_, err := store.Stat(ctx, dst)
+	if err == nil {
+		return nil
+	}
+	if err != storage.ErrNotExist {
+		return err
+	}
+
+	obj, err := store.Open(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	return store.Put(ctx, dst, obj, obj.Info().Size, contentType)
+}
@@ -0,0 +1,70 @@
+// search-reindex 重建笔记搜索索引
+//
+// 用法：
+//
+//	go run ./cmd/search-reindex [-engine bleve]
+//
+// 清空索引后按 ID 顺序写入全部未删除的笔记（含标签和附件提取文本）。
+// 默认使用配置中的 search.engine；mysql 的全文索引由数据库维护，无需重建。
+// bleve 索引同一时间只能被一个进程打开，需先停止服务。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/repo"
+	"wenote-backend/internal/search"
+	"wenote-backend/pkg/logger"
+)
+
+func main() {
+	engineName := flag.String("engine", "", "要重建的搜索后端，默认使用配置中的 search.engine")
+	flag.Parse()
+
+	if err := config.InitConfig(); err != nil {
+		fmt.Println("初始化配置失败:", err)
+		os.Exit(1)
+	}
+	logger.Init(config.GlobalConfig.Server.Mode)
+
+	if err := repo.InitDB(); err != nil {
+		logger.Error("初始化数据库失败", "error", err)
+		os.Exit(1)
+	}
+	defer repo.CloseDB()
+
+	cfg := config.GlobalConfig.Search
+	if *engineName == "" {
+		*engineName = cfg.Engine
+	}
+	engine, err := search.New(*engineName, search.Options{
+		Fuzziness: cfg.Fuzziness,
+		FacetSize: cfg.FacetSize,
+		BlevePath: cfg.Bleve.Path,
+	})
+	if err != nil {
+		logger.Error("初始化搜索后端失败", "engine", *engineName, "error", err)
+		os.Exit(1)
+	}
+	defer engine.Close()
+
+	if engine.Name() == search.EngineMySQL {
+		logger.Info("MySQL 全文索引由数据库维护，无需重建")
+		return
+	}
+
+	started := time.Now()
+	indexed, err := engine.Reindex(context.Background(), func(n int) {
+		logger.Info("重建搜索索引中", "indexed", n)
+	})
+	if err != nil {
+		logger.Error("重建搜索索引失败", "engine", engine.Name(), "indexed", indexed, "error", err)
+		engine.Close()
+		os.Exit(1)
+	}
+	logger.Info("重建搜索索引完成", "engine", engine.Name(), "indexed", indexed, "duration", time.Since(started).String())
+}
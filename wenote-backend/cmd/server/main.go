@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"wenote-backend/config"
 	"wenote-backend/internal/repo"
 	"wenote-backend/internal/router"
+	"wenote-backend/internal/search"
 	"wenote-backend/internal/service"
 	"wenote-backend/pkg/ai"
+	"wenote-backend/pkg/broadcast"
+	"wenote-backend/pkg/extract"
 	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/ratelimit"
+	"wenote-backend/pkg/scanner"
+	"wenote-backend/pkg/storage"
 	"fmt"
 	"os"
 	"os/signal"
@@ -23,6 +30,9 @@ func main() {
 
 	logger.Init(config.GlobalConfig.Server.Mode)
 	logger.Info("配置加载成功")
+	if config.GlobalConfig.Storage.SigningKeyDerived {
+		logger.Warn("未配置 storage.signing_key，下载地址签名密钥由 JWT 密钥派生，建议单独配置")
+	}
 
 	if err := repo.InitDB(); err != nil {
 		logger.Error("初始化数据库失败", "error", err)
@@ -39,12 +49,74 @@ func main() {
 	})
 	logger.Info("AI 客户端初始化成功")
 
-	service.InitGlobalDeps(aiClient)
+	limitStore, err := newLimitStore()
+	if err != nil {
+		logger.Error("初始化限流存储失败", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("限流存储初始化成功", "store", config.GlobalConfig.RateLimit.Store)
+
+	store, err := storage.New(context.Background(), config.GlobalConfig.Storage.Backend(""))
+	if err != nil {
+		logger.Error("初始化附件存储失败", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("附件存储初始化成功", "driver", config.GlobalConfig.Storage.Driver)
+
+	ocrCfg := config.GlobalConfig.Attachment.Extract.OCR
+	ocr, err := extract.NewOCR(ocrCfg.Driver, ocrCfg.Command, ocrCfg.Languages)
+	if err != nil {
+		logger.Error("初始化 OCR 失败，图片将不识别文字", "driver", ocrCfg.Driver, "error", err)
+	}
+
+	scanCfg := config.GlobalConfig.Attachment.Scan
+	virusScanner, err := scanner.New(scanCfg.Driver, scanCfg.Address, time.Duration(scanCfg.Timeout)*time.Second)
+	if err != nil {
+		logger.Error("初始化病毒扫描失败", "driver", scanCfg.Driver, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("病毒扫描初始化成功", "driver", scanCfg.Driver)
+
+	searchCfg := config.GlobalConfig.Search
+	searchEngine, err := search.New(searchCfg.Engine, search.Options{
+		Fuzziness: searchCfg.Fuzziness,
+		FacetSize: searchCfg.FacetSize,
+		BlevePath: searchCfg.Bleve.Path,
+	})
+	if err != nil {
+		logger.Error("初始化搜索后端失败", "engine", searchCfg.Engine, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("搜索后端初始化成功", "engine", searchEngine.Name())
+
+	service.InitGlobalDeps(aiClient, limitStore, store, ocr, virusScanner, searchEngine)
+	stopAudit := service.StartAuditWriter(config.GlobalConfig.Audit.QueueSize)
+	stopSearchIndexer := service.StartSearchIndexer(searchCfg.QueueSize)
+	suggestBroadcaster, err := newSuggestBroadcaster()
+	if err != nil {
+		logger.Error("初始化联想索引失效通知失败", "sync", searchCfg.Suggest.Sync, "error", err)
+		os.Exit(1)
+	}
+	service.InitSuggestCache(searchCfg.Suggest.MaxUsers, time.Duration(searchCfg.Suggest.TTL)*time.Minute, suggestBroadcaster)
+
+	workerCfg := config.GlobalConfig.Worker
+	stopAttachmentWorker := service.StartAttachmentWorker(workerCfg.MaxWorkers, workerCfg.QueueSize, time.Duration(workerCfg.TaskTimeout)*time.Second)
+
+	if err := service.NewAdminService().BootstrapAdmins(config.GlobalConfig.Admin.Usernames); err != nil {
+		logger.Error("初始化管理员账号失败", "error", err)
+	}
 
 	noteService := service.NewNoteService()
-	stopCleanup := startCleanupScheduler(noteService)
+	if rewritten, err := noteService.RewriteLegacyAttachmentRefs(); err != nil {
+		logger.Error("改写笔记中的旧附件地址失败", "error", err)
+	} else if rewritten > 0 {
+		logger.Info("笔记中的旧附件地址改写完成", "notes", rewritten)
+	}
+	userService := service.NewUserService()
+	attachmentService := service.NewAttachmentService()
+	stopCleanup := startCleanupScheduler(noteService, userService, attachmentService, service.NewUploadService())
 
-	r := router.SetupRouter()
+	r := router.SetupRouter(limitStore)
 
 	addr := fmt.Sprintf(":%d", config.GlobalConfig.Server.Port)
 	logger.Info("服务器启动中", "addr", addr)
@@ -64,6 +136,26 @@ func main() {
 
 	close(stopCleanup)
 
+	// 等待进行中的扫描、缩略图和文本提取任务完成，队列中的任务保持 pending，下次启动时继续
+	stopAttachmentWorker()
+
+	// 先写完队列中的审计日志和索引变更，再关闭数据库
+	stopAudit()
+	stopSearchIndexer()
+	if err := searchEngine.Close(); err != nil {
+		logger.Error("关闭搜索索引失败", "error", err)
+	}
+
+	if suggestBroadcaster != nil {
+		if err := suggestBroadcaster.Close(); err != nil {
+			logger.Error("关闭联想索引失效通知失败", "error", err)
+		}
+	}
+
+	if err := limitStore.Close(); err != nil {
+		logger.Error("关闭限流存储失败", "error", err)
+	}
+
 	if err := repo.CloseDB(); err != nil {
 		logger.Error("关闭数据库连接失败，服务未正常关闭", "error", err)
 		os.Exit(1)
@@ -72,12 +164,101 @@ func main() {
 	}
 }
 
-func startCleanupScheduler(noteService *service.NoteService) chan struct{} {
+// newLimitStore 根据配置创建限流/锁定共享存储
+func newLimitStore() (ratelimit.Store, error) {
+	switch config.GlobalConfig.RateLimit.Store {
+	case "", "memory":
+		return ratelimit.NewMemoryStore(time.Minute), nil
+	case "redis":
+		cfg := config.GlobalConfig.Redis
+		return ratelimit.NewRedisStore(ratelimit.RedisConfig{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+			Prefix:   cfg.Prefix,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的限流存储类型: %s", config.GlobalConfig.RateLimit.Store)
+	}
+}
+
+// newSuggestBroadcaster 根据配置创建联想索引的实例间失效通知，单实例部署时返回 nil
+func newSuggestBroadcaster() (broadcast.Broadcaster, error) {
+	switch config.GlobalConfig.Search.Suggest.Sync {
+	case "", "none":
+		return nil, nil
+	case "redis":
+		cfg := config.GlobalConfig.Redis
+		prefix := cfg.Prefix
+		if prefix == "" {
+			prefix = "wenote:"
+		}
+		b, err := broadcast.NewRedis(broadcast.RedisConfig{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+			Channel:  prefix + "suggest:invalidate",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("不支持的联想索引同步方式: %s", config.GlobalConfig.Search.Suggest.Sync)
+	}
+}
+
+// startCleanupScheduler 启动每日凌晨 2 点的维护任务
+// 包括清理回收站过期笔记（可通过 cleanup.enabled 关闭）、清除注销宽限期已到期的账号、
+// 清理过期的分片上传会话，以及附件垃圾回收（可通过 attachment.gc.enabled 关闭）
+func startCleanupScheduler(noteService *service.NoteService, userService *service.UserService, attachmentService *service.AttachmentService, uploadService *service.UploadService) chan struct{} {
 	stop := make(chan struct{})
 	cfg := config.GlobalConfig.Cleanup
 	if !cfg.Enabled {
-		logger.Info("清理任务已禁用")
-		return stop
+		logger.Info("回收站清理任务已禁用")
+	}
+
+	runMaintenance := func() {
+		if cfg.Enabled {
+			count, err := noteService.CleanupDeletedNotes(cfg.Days)
+			if err != nil {
+				logger.Error("清理任务失败", "error", err)
+			} else {
+				logger.Info("清理任务完成", "deleted_count", count)
+			}
+		}
+
+		purged, err := userService.PurgeDueAccounts()
+		if err != nil {
+			logger.Error("账号清除任务失败", "error", err)
+		} else if purged > 0 {
+			logger.Info("账号清除任务完成", "purged_count", purged)
+		}
+
+		uploads, err := uploadService.CleanupExpiredUploads()
+		if err != nil {
+			logger.Error("上传会话清理任务失败", "error", err)
+		} else if uploads > 0 {
+			logger.Info("上传会话清理任务完成", "cleaned_count", uploads)
+		}
+
+		unreferenced, err := attachmentService.CleanupUnreferencedAttachments()
+		if err != nil {
+			logger.Error("未引用附件清理任务失败", "error", err)
+		} else if unreferenced > 0 {
+			logger.Info("未引用附件清理任务完成", "deleted_count", unreferenced)
+		}
+
+		// 扫描器临时不可用时附件会停留在待扫描状态，每天重新提交一次
+		service.RetryPendingScans()
+
+		// 放在回收站清理和账号清除之后，回收本轮硬删除笔记留下的附件
+		gcCfg := config.GlobalConfig.Attachment.GC
+		if gcCfg.Enabled {
+			if _, err := attachmentService.CollectGarbage(context.Background(), gcCfg.DryRun); err != nil {
+				logger.Error("附件垃圾回收失败", "error", err)
+			}
+		}
 	}
 
 	go func() {
@@ -90,7 +271,7 @@ func startCleanupScheduler(noteService *service.NoteService) chan struct{} {
 			firstRunTime = todayAt2am.Add(24 * time.Hour)
 		}
 
-		logger.Info("清理任务已启动", "next_run", firstRunTime, "days", cfg.Days)
+		logger.Info("维护任务已启动", "next_run", firstRunTime, "days", cfg.Days)
 
 		waitDuration := firstRunTime.Sub(now)
 		firstTimer := time.NewTimer(waitDuration)
@@ -98,15 +279,10 @@ func startCleanupScheduler(noteService *service.NoteService) chan struct{} {
 
 		select {
 		case <-stop:
-			logger.Info("清理任务已停止")
+			logger.Info("维护任务已停止")
 			return
 		case <-firstTimer.C:
-			count, err := noteService.CleanupDeletedNotes(cfg.Days)
-			if err != nil {
-				logger.Error("清理任务失败", "error", err)
-			} else {
-				logger.Info("清理任务完成", "deleted_count", count)
-			}
+			runMaintenance()
 		}
 
 		ticker := time.NewTicker(24 * time.Hour)
@@ -115,15 +291,10 @@ func startCleanupScheduler(noteService *service.NoteService) chan struct{} {
 		for {
 			select {
 			case <-stop:
-				logger.Info("清理任务已停止")
+				logger.Info("维护任务已停止")
 				return
 			case <-ticker.C:
-				count, err := noteService.CleanupDeletedNotes(cfg.Days)
-				if err != nil {
-					logger.Error("清理任务失败", "error", err)
-				} else {
-					logger.Info("清理任务完成", "deleted_count", count)
-				}
+				runMaintenance()
 			}
 		}
 	}()
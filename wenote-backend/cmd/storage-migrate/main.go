@@ -0,0 +1,167 @@
+// storage-migrate 在存储后端之间迁移附件文件
+//
+// 用法：
+//
+//	go run ./cmd/storage-migrate -from local -to s3 [-dry-run] [-delete-source]
+//
+// 遍历数据库中的全部附件，将文件及缩略图复制到目标存储，并把 storage_path 统一为对象键。
+// 目标存储中已存在且大小一致的对象会跳过，可重复执行。
+// 迁移完成后需将配置中的 storage.driver 切换为目标存储并重启服务。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"wenote-backend/config"
+	"wenote-backend/internal/repo"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+const batchSize = 200
+
+func main() {
+	from := flag.String("from", "local", "源存储类型（local | s3）")
+	to := flag.String("to", "s3", "目标存储类型（local | s3）")
+	dryRun := flag.Bool("dry-run", false, "只打印迁移计划，不实际复制")
+	deleteSource := flag.Bool("delete-source", false, "复制成功后删除源文件")
+	flag.Parse()
+
+	if *from == *to {
+		fmt.Println("源存储与目标存储不能相同")
+		os.Exit(1)
+	}
+
+	if err := config.InitConfig(); err != nil {
+		fmt.Println("初始化配置失败:", err)
+		os.Exit(1)
+	}
+	logger.Init(config.GlobalConfig.Server.Mode)
+
+	if err := repo.InitDB(); err != nil {
+		logger.Error("初始化数据库失败", "error", err)
+		os.Exit(1)
+	}
+	defer repo.CloseDB()
+
+	ctx := context.Background()
+
+	src, err := storage.New(ctx, config.GlobalConfig.Storage.Backend(*from))
+	if err != nil {
+		logger.Error("初始化源存储失败", "driver", *from, "error", err)
+		os.Exit(1)
+	}
+	dst, err := storage.New(ctx, config.GlobalConfig.Storage.Backend(*to))
+	if err != nil {
+		logger.Error("初始化目标存储失败", "driver", *to, "error", err)
+		os.Exit(1)
+	}
+
+	var copied, skipped, failed int
+	attachmentRepo := repo.NewAttachmentRepo()
+	var lastID uint64
+	// 内容相同的附件共享文件和缩略图，同一个键只迁移一次
+	migrated := make(map[string]bool)
+
+	for {
+		attachments, err := attachmentRepo.ListAfterID(lastID, batchSize)
+		if err != nil {
+			logger.Error("查询附件失败", "error", err)
+			os.Exit(1)
+		}
+		if len(attachments) == 0 {
+			break
+		}
+
+		for _, attachment := range attachments {
+			lastID = attachment.ID
+			key := storage.NormalizeKey(attachment.StoragePath)
+
+			if *dryRun {
+				fmt.Printf("[dry-run] #%d %s -> %s:%s\n", attachment.ID, attachment.StoragePath, *to, key)
+				continue
+			}
+
+			// 原文件和缩略图一起迁移
+			keys := []string{key}
+			variants, err := attachmentRepo.ListVariants(attachment.ID)
+			if err != nil {
+				logger.Error("查询缩略图失败", "attachment_id", attachment.ID, "error", err)
+				failed++
+				continue
+			}
+			for _, variant := range variants {
+				keys = append(keys, variant.StoragePath)
+			}
+			pending := keys[:0]
+			for _, k := range keys {
+				if !migrated[k] {
+					pending = append(pending, k)
+				}
+			}
+			keys = pending
+
+			ok := true
+			for _, k := range keys {
+				done, err := migrateObject(ctx, src, dst, k)
+				if err != nil {
+					logger.Error("迁移附件失败", "attachment_id", attachment.ID, "key", k, "error", err)
+					ok = false
+					continue
+				}
+				if done {
+					copied++
+				} else {
+					skipped++
+				}
+			}
+			if !ok {
+				failed++
+				continue
+			}
+			for _, k := range keys {
+				migrated[k] = true
+			}
+
+			if err := attachmentRepo.UpdateStorage(attachment.ID, key, service.ContentPath(attachment.ID)); err != nil {
+				logger.Error("更新附件记录失败", "attachment_id", attachment.ID, "error", err)
+				failed++
+				continue
+			}
+
+			if *deleteSource {
+				for _, k := range keys {
+					if err := src.Delete(ctx, k); err != nil {
+						logger.Warn("删除源文件失败", "key", k, "error", err)
+					}
+				}
+			}
+		}
+	}
+
+	logger.Info("附件迁移完成", "from", *from, "to", *to, "copied", copied, "skipped", skipped, "failed", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// migrateObject 复制单个对象，目标已存在且大小一致时跳过，返回是否实际复制
+func migrateObject(ctx context.Context, src, dst storage.Storage, key string) (bool, error) {
+	srcInfo, err := src.Stat(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	dstInfo, err := dst.Stat(ctx, key)
+	if err == nil && dstInfo.Size == srcInfo.Size {
+		return false, nil
+	}
+	if err != nil && err != storage.ErrNotExist {
+		return false, err
+	}
+
+	return true, storage.Copy(ctx, src, dst, key)
+}
@@ -1,21 +1,36 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"wenote-backend/pkg/storage"
 
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/hkdf"
 )
 
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	JWT       JWTConfig       `mapstructure:"jwt"`
-	AI        AIConfig        `mapstructure:"ai"`
-	Worker    WorkerConfig    `mapstructure:"worker"`
-	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
-	Cleanup   CleanupConfig   `mapstructure:"cleanup"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	JWT        JWTConfig        `mapstructure:"jwt"`
+	AI         AIConfig         `mapstructure:"ai"`
+	Worker     WorkerConfig     `mapstructure:"worker"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Lockout    LockoutConfig    `mapstructure:"lockout"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Cleanup    CleanupConfig    `mapstructure:"cleanup"`
+	Audit      AuditConfig      `mapstructure:"audit"`
+	Admin      AdminConfig      `mapstructure:"admin"`
+	Account    AccountConfig    `mapstructure:"account"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Attachment AttachmentConfig `mapstructure:"attachment"`
+	Search     SearchConfig     `mapstructure:"search"`
 }
 
 type ServerConfig struct {
@@ -60,12 +75,51 @@ type WorkerConfig struct {
 }
 
 type RateLimitConfig struct {
+	Store       string  `mapstructure:"store"` // memory, redis
 	GlobalRate  float64 `mapstructure:"global_rate"`
 	GlobalBurst int     `mapstructure:"global_burst"`
 	UserRate    float64 `mapstructure:"user_rate"`
 	UserBurst   int     `mapstructure:"user_burst"`
 	IPRate      float64 `mapstructure:"ip_rate"`
 	IPBurst     int     `mapstructure:"ip_burst"`
+
+	// 按路由分组的限流策略（认证后按用户计数，未认证按 IP 计数）
+	Policies map[string]RateLimitPolicy `mapstructure:"policies"`
+	// 按用户等级覆盖的策略：tier -> policy -> 参数
+	Tiers map[string]map[string]RateLimitPolicy `mapstructure:"tiers"`
+}
+
+type RateLimitPolicy struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒请求数
+	Burst int     `mapstructure:"burst"` // 突发容量
+}
+
+// Policy 获取指定等级下的限流策略
+// 优先级：等级覆盖 > 分组策略 > user_rate/user_burst
+func (c *RateLimitConfig) Policy(name, tier string) RateLimitPolicy {
+	if tier != "" {
+		if p, ok := c.Tiers[tier][name]; ok {
+			return p
+		}
+	}
+	if p, ok := c.Policies[name]; ok {
+		return p
+	}
+	return RateLimitPolicy{Rate: c.UserRate, Burst: c.UserBurst}
+}
+
+// LockoutConfig 登录失败锁定配置
+type LockoutConfig struct {
+	MaxAttempts int `mapstructure:"max_attempts"` // 窗口期内允许的最大失败次数
+	Window      int `mapstructure:"window"`       // 失败次数统计窗口（分钟）
+	Duration    int `mapstructure:"duration"`     // 锁定时长（分钟）
+}
+
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"`
 }
 
 type CleanupConfig struct {
@@ -73,6 +127,181 @@ type CleanupConfig struct {
 	Days    int  `mapstructure:"days"`
 }
 
+type AuditConfig struct {
+	QueueSize int `mapstructure:"queue_size"` // 异步写入队列容量，队列满时丢弃
+}
+
+type AdminConfig struct {
+	Usernames []string `mapstructure:"usernames"` // 启动时提升为管理员的用户名
+}
+
+type AccountConfig struct {
+	DeletionGraceDays int    `mapstructure:"deletion_grace_days"` // 注销宽限期（天），0 表示立即清除
+	ExportBeforePurge bool   `mapstructure:"export_before_purge"` // 清除前是否自动导出数据
+	ExportDir         string `mapstructure:"export_dir"`          // 导出文件目录
+}
+
+type StorageConfig struct {
+	Driver     string             `mapstructure:"driver"`      // local | s3 | memory
+	URLExpiry  int                `mapstructure:"url_expiry"`  // 签名/预签名地址有效期（分钟）
+	SigningKey string             `mapstructure:"signing_key"` // 签名下载地址的 HMAC 密钥，应与 JWT 密钥不同
+	Local      LocalStorageConfig `mapstructure:"local"`
+	S3         S3StorageConfig    `mapstructure:"s3"`
+
+	// SigningKeyDerived 未配置 signing_key，签名密钥由 JWT 密钥派生
+	SigningKeyDerived bool `mapstructure:"-"`
+}
+
+type LocalStorageConfig struct {
+	Root    string `mapstructure:"root"`     // 存储根目录
+	BaseURL string `mapstructure:"base_url"` // 对外访问前缀
+}
+
+type S3StorageConfig struct {
+	Endpoint      string `mapstructure:"endpoint"`
+	AccessKey     string `mapstructure:"access_key"`
+	SecretKey     string `mapstructure:"secret_key"`
+	Bucket        string `mapstructure:"bucket"`
+	Region        string `mapstructure:"region"`
+	UseSSL        bool   `mapstructure:"use_ssl"`
+	Prefix        string `mapstructure:"prefix"`          // 对象键前缀
+	PublicBaseURL string `mapstructure:"public_base_url"` // 公开读桶的访问地址，为空时使用预签名地址
+}
+
+// Backend 转换为指定驱动的存储后端配置，driver 为空时使用 Driver
+// 服务和命令行工具都通过它创建存储后端，迁移工具可以用同一份配置打开不同的驱动
+func (c *StorageConfig) Backend(driver string) storage.Config {
+	if driver == "" {
+		driver = c.Driver
+	}
+	return storage.Config{
+		Driver:       driver,
+		LocalRoot:    c.Local.Root,
+		LocalBaseURL: c.Local.BaseURL,
+		S3: storage.S3Config{
+			Endpoint:      c.S3.Endpoint,
+			AccessKey:     c.S3.AccessKey,
+			SecretKey:     c.S3.SecretKey,
+			Bucket:        c.S3.Bucket,
+			Region:        c.S3.Region,
+			UseSSL:        c.S3.UseSSL,
+			Prefix:        c.S3.Prefix,
+			PublicBaseURL: c.S3.PublicBaseURL,
+		},
+	}
+}
+
+// SearchConfig 笔记全文搜索配置
+type SearchConfig struct {
+	Engine    string            `mapstructure:"engine"`     // mysql（ngram 全文索引）或 bleve（内嵌索引）
+	Fuzziness int               `mapstructure:"fuzziness"`  // bleve 模糊匹配允许的编辑距离（1 或 2），-1 关闭
+	FacetSize int               `mapstructure:"facet_size"` // 标签、笔记本分布各返回的最大项数
+	QueueSize int               `mapstructure:"queue_size"` // 索引更新队列容量，队满时丢弃，需重建索引补齐
+	Bleve     BleveSearchConfig `mapstructure:"bleve"`
+	Suggest   SuggestConfig     `mapstructure:"suggest"`
+}
+
+type BleveSearchConfig struct {
+	Path string `mapstructure:"path"` // 索引目录
+}
+
+// SuggestConfig 输入联想配置，索引保存在各实例内存中
+type SuggestConfig struct {
+	MaxUsers int    `mapstructure:"max_users"` // 最多缓存的用户索引数，超出时淘汰最久未使用的
+	TTL      int    `mapstructure:"ttl"`       // 索引有效期（分钟），过期后重新加载，作为失效通知丢失时的兜底
+	Sync     string `mapstructure:"sync"`      // 实例间的失效通知：none（单实例）, redis（多副本，通过 Redis 发布订阅）
+}
+
+type AttachmentConfig struct {
+	DefaultQuota int64 `mapstructure:"default_quota"` // 每个用户的默认存储配额（MB），0 表示不限制
+	// 按类别配置允许上传的类型：category -> 策略
+	Policies map[string]AttachmentPolicy `mapstructure:"policies"`
+	Image    ImageConfig                 `mapstructure:"image"`
+	GC       AttachmentGCConfig          `mapstructure:"gc"`
+	Upload   UploadConfig                `mapstructure:"upload"`
+	Extract  ExtractConfig               `mapstructure:"extract"`
+	Scan     ScanConfig                  `mapstructure:"scan"`
+	Refs     AttachmentRefConfig         `mapstructure:"references"`
+}
+
+// AttachmentRefConfig 笔记正文对附件的引用配置
+type AttachmentRefConfig struct {
+	ExpireUnreferenced bool `mapstructure:"expire_unreferenced"` // 是否由每日维护任务删除长期未被引用的附件
+	Grace              int  `mapstructure:"grace"`               // 附件未被正文引用多久后删除（小时）
+}
+
+// ScanConfig 附件病毒扫描配置
+type ScanConfig struct {
+	Driver  string `mapstructure:"driver"`  // none（不扫描）、clamd、noop 或 fake（测试用）
+	Address string `mapstructure:"address"` // clamd 地址，如 tcp://127.0.0.1:3310 或 unix:///var/run/clamav/clamd.ctl
+	Timeout int    `mapstructure:"timeout"` // 单个文件的扫描超时（秒）
+}
+
+// ExtractConfig 附件文本提取配置，提取结果参与笔记搜索
+type ExtractConfig struct {
+	Enabled       bool      `mapstructure:"enabled"`
+	MaxTextLength int       `mapstructure:"max_text_length"` // 每个附件保存的文本上限（字符数）
+	OCR           OCRConfig `mapstructure:"ocr"`
+}
+
+// OCRConfig 图片文字识别配置
+type OCRConfig struct {
+	Driver    string `mapstructure:"driver"`    // none 或 tesseract
+	Command   string `mapstructure:"command"`   // tesseract 可执行文件路径
+	Languages string `mapstructure:"languages"` // 识别语言，如 chi_sim+eng
+}
+
+// UploadConfig 分片续传配置
+type UploadConfig struct {
+	MaxChunkSize int64 `mapstructure:"max_chunk_size"` // 单个分片大小上限（MB）
+	Expire       int   `mapstructure:"expire"`         // 会话无新分片后的过期时间（小时）
+}
+
+// AttachmentGCConfig 附件垃圾回收配置，随每日维护任务执行
+type AttachmentGCConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	DryRun      bool `mapstructure:"dry_run"`      // 只生成报告，不删除任何数据
+	OrphanGrace int  `mapstructure:"orphan_grace"` // 无记录文件的保留时间（分钟），避免误删正在上传的文件
+}
+
+type ImageConfig struct {
+	MaxDimension int            `mapstructure:"max_dimension"` // 原图长边上限（像素），超出时等比缩小
+	Thumbnails   map[string]int `mapstructure:"thumbnails"`    // 缩略图尺寸：名称 -> 长边像素
+}
+
+type AttachmentPolicy struct {
+	Types   []string `mapstructure:"types"`    // 允许的 MIME 类型
+	MaxSize int64    `mapstructure:"max_size"` // 单个文件大小上限（MB）
+}
+
+// DefaultAttachmentPolicies 未配置时的默认策略，与早期只允许图片的行为一致
+var DefaultAttachmentPolicies = map[string]AttachmentPolicy{
+	"image": {
+		Types:   []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+		MaxSize: 5,
+	},
+}
+
+// Match 查找允许该 MIME 类型的策略，返回类别名
+// 同一类型出现在多个类别中时按类别名排序取第一个，保证结果稳定
+func (c *AttachmentConfig) Match(mimeType string) (string, AttachmentPolicy, bool) {
+	categories := make([]string, 0, len(c.Policies))
+	for category := range c.Policies {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		policy := c.Policies[category]
+		for _, t := range policy.Types {
+			if strings.EqualFold(t, mimeType) {
+				return category, policy, true
+			}
+		}
+	}
+	return "", AttachmentPolicy{}, false
+}
+
 var GlobalConfig *Config
 
 func InitConfig() error {
@@ -133,9 +362,140 @@ func InitConfig() error {
 		GlobalConfig.AI.Zhipu.Model = model
 	}
 
+	// Redis环境变量覆盖
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		GlobalConfig.Redis.Addr = addr
+	}
+	if pass := os.Getenv("REDIS_PASSWORD"); pass != "" {
+		GlobalConfig.Redis.Password = pass
+	}
+
+	// S3环境变量覆盖
+	if key := os.Getenv("S3_ACCESS_KEY"); key != "" {
+		GlobalConfig.Storage.S3.AccessKey = key
+	}
+	if secret := os.Getenv("S3_SECRET_KEY"); secret != "" {
+		GlobalConfig.Storage.S3.SecretKey = secret
+	}
+
+	if key := os.Getenv("STORAGE_SIGNING_KEY"); key != "" {
+		GlobalConfig.Storage.SigningKey = key
+	}
+
+	// 存储默认值
+	if GlobalConfig.Storage.Driver == "" {
+		GlobalConfig.Storage.Driver = "local"
+	}
+	if GlobalConfig.Storage.URLExpiry <= 0 {
+		GlobalConfig.Storage.URLExpiry = 15
+	}
+	if GlobalConfig.Storage.SigningKey == "" {
+		// 不直接复用 JWT 密钥，泄露其中一个不会同时危及登录令牌和下载地址
+		key, err := deriveKey(GlobalConfig.JWT.Secret, storageSigningKeyLabel)
+		if err != nil {
+			return fmt.Errorf("派生下载地址签名密钥失败: %w", err)
+		}
+		GlobalConfig.Storage.SigningKey = key
+		GlobalConfig.Storage.SigningKeyDerived = true
+	}
+	if GlobalConfig.Storage.Local.Root == "" {
+		GlobalConfig.Storage.Local.Root = "./uploads"
+	}
+	if GlobalConfig.Storage.Local.BaseURL == "" {
+		GlobalConfig.Storage.Local.BaseURL = "/uploads"
+	}
+
+	// 附件策略默认值
+	if len(GlobalConfig.Attachment.Policies) == 0 {
+		GlobalConfig.Attachment.Policies = DefaultAttachmentPolicies
+	}
+
+	if GlobalConfig.Attachment.Image.MaxDimension <= 0 {
+		GlobalConfig.Attachment.Image.MaxDimension = 4096
+	}
+	if len(GlobalConfig.Attachment.Image.Thumbnails) == 0 {
+		GlobalConfig.Attachment.Image.Thumbnails = map[string]int{"small": 160, "medium": 480, "large": 1280}
+	}
+
+	if GlobalConfig.Attachment.GC.OrphanGrace <= 0 {
+		GlobalConfig.Attachment.GC.OrphanGrace = 60
+	}
+	if GlobalConfig.Attachment.Upload.MaxChunkSize <= 0 {
+		GlobalConfig.Attachment.Upload.MaxChunkSize = 8
+	}
+	if GlobalConfig.Attachment.Upload.Expire <= 0 {
+		GlobalConfig.Attachment.Upload.Expire = 24
+	}
+	if GlobalConfig.Attachment.Extract.MaxTextLength <= 0 {
+		GlobalConfig.Attachment.Extract.MaxTextLength = 200000
+	}
+	if GlobalConfig.Attachment.Extract.OCR.Driver == "" {
+		GlobalConfig.Attachment.Extract.OCR.Driver = "none"
+	}
+	if GlobalConfig.Attachment.Scan.Driver == "" {
+		GlobalConfig.Attachment.Scan.Driver = "none"
+	}
+	if GlobalConfig.Attachment.Scan.Timeout <= 0 {
+		GlobalConfig.Attachment.Scan.Timeout = 60
+	}
+	if GlobalConfig.Attachment.Refs.Grace <= 0 {
+		GlobalConfig.Attachment.Refs.Grace = 72
+	}
+
+	// 搜索默认值
+	if GlobalConfig.Search.Engine == "" {
+		GlobalConfig.Search.Engine = "mysql"
+	}
+	if GlobalConfig.Search.Fuzziness == 0 {
+		GlobalConfig.Search.Fuzziness = 1
+	}
+	if GlobalConfig.Search.FacetSize <= 0 {
+		GlobalConfig.Search.FacetSize = 20
+	}
+	if GlobalConfig.Search.QueueSize <= 0 {
+		GlobalConfig.Search.QueueSize = 1000
+	}
+	if GlobalConfig.Search.Bleve.Path == "" {
+		GlobalConfig.Search.Bleve.Path = "./data/search.bleve"
+	}
+	if GlobalConfig.Search.Suggest.MaxUsers <= 0 {
+		GlobalConfig.Search.Suggest.MaxUsers = 10000
+	}
+	if GlobalConfig.Search.Suggest.TTL <= 0 {
+		GlobalConfig.Search.Suggest.TTL = 10
+	}
+
+	// 账号注销默认值
+	if GlobalConfig.Account.ExportDir == "" {
+		GlobalConfig.Account.ExportDir = "./exports"
+	}
+
+	// 锁定策略默认值
+	if GlobalConfig.Lockout.MaxAttempts <= 0 {
+		GlobalConfig.Lockout.MaxAttempts = 5
+	}
+	if GlobalConfig.Lockout.Window <= 0 {
+		GlobalConfig.Lockout.Window = 15
+	}
+	if GlobalConfig.Lockout.Duration <= 0 {
+		GlobalConfig.Lockout.Duration = 15
+	}
+
 	return nil
 }
 
+// storageSigningKeyLabel 由 JWT 密钥派生下载地址签名密钥时使用的 HKDF info
+const storageSigningKeyLabel = "wenote storage signed url v1"
+
+// deriveKey 使用 HKDF-SHA256 由 secret 派生用途为 label 的 32 字节密钥，返回十六进制字符串
+func deriveKey(secret, label string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(label)), key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
 		c.Username,
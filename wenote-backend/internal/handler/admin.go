@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"strconv"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler 管理后台处理器
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+// NewAdminHandler 创建管理后台处理器实例
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{
+		adminService: service.NewAdminService(),
+	}
+}
+
+// ListUsers 查询/搜索用户
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var req model.AdminUserListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.adminService.ListUsers(&req)
+	if err != nil {
+		response.InternalError(c, "获取用户列表失败")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// GetUsage 查看用户的存储和 AI 用量
+func (h *AdminHandler) GetUsage(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	usage, err := h.adminService.GetUsage(userID)
+	if err != nil {
+		if err == service.ErrUserNotFound {
+			response.NotFound(c, "用户不存在")
+			return
+		}
+		response.InternalError(c, "获取用量失败")
+		return
+	}
+
+	response.Success(c, usage)
+}
+
+// LockUser 锁定用户
+func (h *AdminHandler) LockUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	var req model.AdminLockUserReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	if err := h.adminService.LockUser(c.GetUint64("userID"), userID, req.Reason); err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			response.NotFound(c, "用户不存在")
+		case service.ErrCannotLockSelf:
+			response.BadRequest(c, err.Error())
+		case service.ErrTargetIsAdmin:
+			response.Forbidden(c, err.Error())
+		default:
+			response.InternalError(c, "锁定失败")
+		}
+		return
+	}
+
+	recordAudit(c, model.AuditActionAdminLockUser, model.AuditResourceUser, userID, map[string]interface{}{
+		"reason": req.Reason,
+	})
+
+	response.SuccessWithMessage(c, "用户已锁定", nil)
+}
+
+// UnlockUser 解锁用户
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	if err := h.adminService.UnlockUser(userID); err != nil {
+		if err == service.ErrUserNotFound {
+			response.NotFound(c, "用户不存在")
+			return
+		}
+		response.InternalError(c, "解锁失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionAdminUnlockUser, model.AuditResourceUser, userID, nil)
+
+	response.SuccessWithMessage(c, "用户已解锁", nil)
+}
+
+// ResetPassword 强制重置用户密码
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	resp, err := h.adminService.ResetPassword(userID)
+	if err != nil {
+		switch err {
+		case service.ErrUserNotFound:
+			response.NotFound(c, "用户不存在")
+		case service.ErrTargetIsAdmin:
+			response.Forbidden(c, err.Error())
+		default:
+			response.InternalError(c, "重置密码失败")
+		}
+		return
+	}
+
+	recordAudit(c, model.AuditActionAdminResetPassword, model.AuditResourceUser, userID, nil)
+
+	response.SuccessWithMessage(c, "密码已重置，用户下次登录后需修改密码", resp)
+}
+
+// ListAudit 查询全局审计日志
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	var req model.AdminAuditListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.adminService.ListAudit(&req)
+	if err != nil {
+		response.InternalError(c, "获取审计日志失败")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// CleanupTrash 手动触发回收站清理
+func (h *AdminHandler) CleanupTrash(c *gin.Context) {
+	var req model.AdminCleanupReq
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		response.ValidationError(c, err)
+		return
+	}
+
+	count, days, err := h.adminService.CleanupTrash(req.Days)
+	if err != nil {
+		response.InternalError(c, "清理失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionAdminCleanupTrash, model.AuditResourceNote, 0, map[string]interface{}{
+		"days":  days,
+		"count": count,
+	})
+
+	response.SuccessWithMessage(c, "回收站清理完成", gin.H{
+		"days":  days,
+		"count": count,
+	})
+}
+
+// CollectAttachmentGarbage 手动触发附件垃圾回收
+func (h *AdminHandler) CollectAttachmentGarbage(c *gin.Context) {
+	var req model.AdminAttachmentGCReq
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		response.ValidationError(c, err)
+		return
+	}
+
+	report, err := h.adminService.CollectAttachmentGarbage(req.DryRun)
+	if err != nil {
+		if err == service.ErrAttachmentGCRunning {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "附件垃圾回收失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionAdminAttachmentGC, model.AuditResourceAttachment, 0, map[string]interface{}{
+		"dry_run":          report.DryRun,
+		"orphan_rows":      report.OrphanRows,
+		"missing_files":    report.MissingFiles,
+		"missing_variants": report.MissingVariants,
+		"orphan_files":     report.OrphanFiles,
+		"freed_bytes":      report.FreedBytes,
+	})
+
+	response.SuccessWithMessage(c, "附件垃圾回收完成", report)
+}
+
+// GetAttachmentGCReport 查看最近一次附件垃圾回收的报告
+func (h *AdminHandler) GetAttachmentGCReport(c *gin.Context) {
+	report := service.LastAttachmentGCReport()
+	if report == nil {
+		response.NotFound(c, "附件垃圾回收尚未执行")
+		return
+	}
+	response.Success(c, report)
+}
+
+// GetAIBreaker 查看 AI 熔断器状态
+func (h *AdminHandler) GetAIBreaker(c *gin.Context) {
+	status, err := h.adminService.AIBreakerStatus()
+	if err != nil {
+		if err == service.ErrBreakerNotSupported {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "获取熔断器状态失败")
+		return
+	}
+
+	response.Success(c, status)
+}
@@ -1,9 +1,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"wenote-backend/internal/model"
 	"wenote-backend/internal/service"
 	"wenote-backend/pkg/response"
+	"wenote-backend/pkg/signedurl"
+	"wenote-backend/pkg/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,9 +29,9 @@ func NewAttachmentHandler() *AttachmentHandler {
 	}
 }
 
-// UploadImage 上传图片
+// Upload 上传附件
 // POST /api/v1/notes/:id/attachments
-func (h *AttachmentHandler) UploadImage(c *gin.Context) {
+func (h *AttachmentHandler) Upload(c *gin.Context) {
 	// 获取用户ID
 	userID, _ := c.Get("userID")
 
@@ -42,9 +51,16 @@ func (h *AttachmentHandler) UploadImage(c *gin.Context) {
 	}
 
 	// 上传文件
-	result, err := h.service.UploadImage(userID.(uint64), noteID, file)
+	result, err := h.service.Upload(userID.(uint64), noteID, file)
 	if err != nil {
-		response.InternalError(c, err.Error())
+		switch {
+		case errors.Is(err, service.ErrFileTypeNotAllowed),
+			errors.Is(err, service.ErrFileTooLarge),
+			errors.Is(err, service.ErrStorageQuotaExceeded):
+			response.BadRequest(c, err.Error())
+		default:
+			response.InternalError(c, err.Error())
+		}
 		return
 	}
 
@@ -76,7 +92,8 @@ func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
 }
 
 // DeleteAttachment 删除附件
-// DELETE /api/v1/attachments/:id
+// DELETE /api/v1/attachments/:id?force=true
+// 附件仍被笔记正文引用时返回 409，force=true 时强制删除并从正文中移除引用
 func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
 	// 获取用户ID
 	userID, _ := c.Get("userID")
@@ -89,12 +106,143 @@ func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
 		return
 	}
 
+	force, _ := strconv.ParseBool(c.Query("force"))
+
 	// 删除附件
-	if err := h.service.DeleteAttachment(userID.(uint64), attachmentID); err != nil {
+	if err := h.service.DeleteAttachment(userID.(uint64), attachmentID, force); err != nil {
+		if errors.Is(err, service.ErrAttachmentReferenced) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.InternalError(c, err.Error())
 		return
 	}
 
+	var details map[string]interface{}
+	if force {
+		details = map[string]interface{}{"force": true}
+	}
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceAttachment, attachmentID, details)
+
 	response.Success(c, nil)
 }
 
+
+// Download 下载附件内容
+// GET /api/v1/attachments/:id/content
+//
+// 支持两种凭证：
+//   - Authorization 头中的 JWT，校验附件所有权
+//   - 签名地址参数 ?expires=&sig=，供 <img> 等无法携带请求头的场景使用
+//
+// 支持 Range 请求；?size=small 等获取图片缩略图；?download=1 时强制以附件形式下载
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	attachmentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的附件ID")
+		return
+	}
+
+	var (
+		attachment *model.NoteAttachment
+		obj        storage.Object
+		expires    int64
+	)
+	if sig := c.Query("sig"); sig != "" {
+		expires, err = strconv.ParseInt(c.Query("expires"), 10, 64)
+		if err != nil {
+			response.Forbidden(c, "无效的签名")
+			return
+		}
+		attachment, obj, err = h.service.OpenSignedContent(attachmentID, c.Query("size"), expires, sig)
+	} else {
+		userID := c.GetUint64("userID")
+		if userID == 0 {
+			response.Unauthorized(c, "请先登录")
+			return
+		}
+		attachment, obj, err = h.service.OpenContent(userID, attachmentID, c.Query("size"))
+	}
+	serveContent(c, attachment, obj, expires, err)
+}
+
+// LegacyDownload 按升级前的静态文件地址下载附件，兼容尚未改写的笔记正文和外部链接
+// GET /uploads/*path
+//
+// 需要 JWT，与 Download 相同校验附件所有权和扫描状态
+func (h *AttachmentHandler) LegacyDownload(c *gin.Context) {
+	attachment, obj, err := h.service.OpenLegacyContent(c.GetUint64("userID"), strings.TrimPrefix(c.Param("path"), "/"))
+	serveContent(c, attachment, obj, 0, err)
+}
+
+// serveContent 输出附件内容，err 不为空时返回对应的错误响应
+func serveContent(c *gin.Context, attachment *model.NoteAttachment, obj storage.Object, expires int64, err error) {
+	if err != nil {
+		switch err {
+		case service.ErrAttachmentNotFound:
+			response.NotFound(c, "附件不存在")
+		case service.ErrAttachmentForbidden:
+			response.Forbidden(c, "无权限访问该附件")
+		case service.ErrAttachmentScanPending:
+			response.Conflict(c, err.Error())
+		case service.ErrAttachmentInfected:
+			response.Forbidden(c, err.Error())
+		case signedurl.ErrExpired:
+			response.Forbidden(c, "链接已过期")
+		case signedurl.ErrInvalidSignature:
+			response.Forbidden(c, "无效的签名")
+		default:
+			response.InternalError(c, "读取附件失败")
+		}
+		return
+	}
+	defer obj.Close()
+
+	setDownloadHeaders(c, attachment, expires)
+	http.ServeContent(c.Writer, c.Request, attachment.Filename, obj.Info().LastModified, obj)
+}
+
+// inlineTypePrefixes 可在浏览器中直接预览的类型，其余类型一律作为附件下载
+var inlineTypePrefixes = []string{"image/", "audio/", "video/", "application/pdf"}
+
+// setDownloadHeaders 设置下载响应头
+// expires 为签名地址的过期时间戳，JWT 访问时为 0
+func setDownloadHeaders(c *gin.Context, attachment *model.NoteAttachment, expires int64) {
+	header := c.Writer.Header()
+
+	contentType := attachment.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+
+	disposition := "attachment"
+	if c.Query("download") == "" {
+		for _, prefix := range inlineTypePrefixes {
+			if strings.HasPrefix(contentType, prefix) {
+				disposition = "inline"
+				break
+			}
+		}
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{
+		"filename": attachment.Filename,
+	}))
+
+	// 附件内容上传后不会变化，签名地址在有效期内可由浏览器缓存；
+	// 涉及用户数据，禁止共享缓存
+	if expires > 0 {
+		maxAge := expires - time.Now().Unix()
+		if maxAge < 0 {
+			maxAge = 0
+		}
+		header.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	} else {
+		header.Set("Cache-Control", "private, no-cache")
+	}
+	header.Set("ETag", fmt.Sprintf(`"attachment-%d-%s-%d"`, attachment.ID, c.Query("size"), attachment.FileSize))
+
+	// 防止浏览器嗅探类型或执行上传内容中的脚本
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; media-src 'self'; style-src 'unsafe-inline'; sandbox")
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit 记录当前用户的审计日志
+// 自动附带客户端 IP，写入为异步操作，不影响请求耗时
+func recordAudit(c *gin.Context, action, resourceType string, resourceID uint64, details map[string]interface{}) {
+	service.RecordAudit(&model.AuditLog{
+		UserID:       c.GetUint64("userID"),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Details:      details,
+		IPAddress:    c.ClientIP(),
+	})
+}
@@ -45,13 +45,15 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.authService.Login(&req)
+	resp, err := h.authService.Login(&req, c.ClientIP())
 	if err != nil {
 		switch err {
 		case service.ErrUserNotFound:
 			response.BadRequest(c, "用户不存在")
 		case service.ErrPasswordIncorrect:
 			response.BadRequest(c, "密码错误")
+		case service.ErrUserLocked:
+			response.Forbidden(c, "账号已被锁定，请联系管理员")
 		default:
 			response.InternalError(c, "登录失败: "+err.Error())
 		}
@@ -63,10 +65,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	userID := c.GetUint64("userID")
-	username := c.GetString("username")
 
-	token, err := h.authService.RefreshToken(userID, username)
+	token, err := h.authService.RefreshToken(userID)
 	if err != nil {
+		if err == service.ErrUserLocked {
+			response.Forbidden(c, "账号已被锁定，请联系管理员")
+			return
+		}
 		response.InternalError(c, "刷新令牌失败")
 		return
 	}
@@ -1,10 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"wenote-backend/internal/model"
-	"wenote-backend/internal/repo"
 	"wenote-backend/internal/service"
 	"wenote-backend/pkg/response"
 
@@ -14,14 +14,12 @@ import (
 // NoteHandler 笔记处理器
 type NoteHandler struct {
 	noteService *service.NoteService
-	auditRepo   *repo.AuditRepo
 }
 
 // NewNoteHandler 创建笔记处理器实例
 func NewNoteHandler() *NoteHandler {
 	return &NoteHandler{
 		noteService: service.NewNoteService(),
-		auditRepo:   repo.NewAuditRepo(),
 	}
 }
 
@@ -41,6 +39,14 @@ func (h *NoteHandler) Create(c *gin.Context) {
 			response.BadRequest(c, "笔记本不存在")
 			return
 		}
+		if err == service.ErrTemplateNotFound {
+			response.BadRequest(c, "模板不存在")
+			return
+		}
+		if errors.Is(err, service.ErrTemplateVariableMissing) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "创建笔记失败: "+err.Error())
 		return
 	}
@@ -121,13 +127,7 @@ func (h *NoteHandler) Delete(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "delete",
-		ResourceType: "note",
-		ResourceID:   noteID,
-		IPAddress:    c.ClientIP(),
-	})
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceNote, noteID, nil)
 
 	response.SuccessWithMessage(c, "删除成功", nil)
 }
@@ -152,13 +152,7 @@ func (h *NoteHandler) Restore(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "restore",
-		ResourceType: "note",
-		ResourceID:   noteID,
-		IPAddress:    c.ClientIP(),
-	})
+	recordAudit(c, model.AuditActionRestore, model.AuditResourceNote, noteID, nil)
 
 	response.SuccessWithMessage(c, "恢复成功", note)
 }
@@ -175,6 +169,10 @@ func (h *NoteHandler) List(c *gin.Context) {
 
 	resp, err := h.noteService.List(userID, &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) || errors.Is(err, service.ErrInvalidCursor) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "获取笔记列表失败")
 		return
 	}
@@ -235,22 +233,18 @@ func (h *NoteHandler) ApplySuggestedTags(c *gin.Context) {
 func (h *NoteHandler) ListDeleted(c *gin.Context) {
 	userID := c.GetUint64("userID")
 
-	// 获取分页参数
-	page := 1
-	pageSize := 20
-	if p := c.Query("page"); p != "" {
-		if val, err := strconv.Atoi(p); err == nil && val > 0 {
-			page = val
-		}
-	}
-	if ps := c.Query("page_size"); ps != "" {
-		if val, err := strconv.Atoi(ps); err == nil && val > 0 {
-			pageSize = val
-		}
+	var req model.NoteTrashReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
 	}
 
-	resp, err := h.noteService.ListDeleted(userID, page, pageSize)
+	resp, err := h.noteService.ListDeleted(userID, &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "获取回收站列表失败")
 		return
 	}
@@ -275,15 +269,9 @@ func (h *NoteHandler) BatchDelete(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "batch_delete",
-		ResourceType: "note",
-		Details: map[string]interface{}{
-			"note_ids":      req.NoteIDs,
-			"deleted_count": count,
-		},
-		IPAddress: c.ClientIP(),
+	recordAudit(c, model.AuditActionBatchDelete, model.AuditResourceNote, 0, map[string]interface{}{
+		"note_ids":      req.NoteIDs,
+		"deleted_count": count,
 	})
 
 	response.SuccessWithMessage(c, "批量删除成功", map[string]interface{}{
@@ -308,15 +296,9 @@ func (h *NoteHandler) BatchRestore(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "batch_restore",
-		ResourceType: "note",
-		Details: map[string]interface{}{
-			"note_ids":       req.NoteIDs,
-			"restored_count": count,
-		},
-		IPAddress: c.ClientIP(),
+	recordAudit(c, model.AuditActionBatchRestore, model.AuditResourceNote, 0, map[string]interface{}{
+		"note_ids":       req.NoteIDs,
+		"restored_count": count,
 	})
 
 	response.SuccessWithMessage(c, "批量恢复成功", map[string]interface{}{
@@ -335,14 +317,8 @@ func (h *NoteHandler) EmptyTrash(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "empty_trash",
-		ResourceType: "note",
-		Details: map[string]interface{}{
-			"deleted_count": count,
-		},
-		IPAddress: c.ClientIP(),
+	recordAudit(c, model.AuditActionEmptyTrash, model.AuditResourceNote, 0, map[string]interface{}{
+		"deleted_count": count,
 	})
 
 	response.SuccessWithMessage(c, "回收站已清空", map[string]interface{}{
@@ -371,16 +347,10 @@ func (h *NoteHandler) BatchMove(c *gin.Context) {
 	}
 
 	// 记录审计日志
-	_ = h.auditRepo.Create(&model.AuditLog{
-		UserID:       userID,
-		Action:       "batch_move",
-		ResourceType: "note",
-		Details: map[string]interface{}{
-			"note_ids":    req.NoteIDs,
-			"notebook_id": req.NotebookID,
-			"moved_count": count,
-		},
-		IPAddress: c.ClientIP(),
+	recordAudit(c, model.AuditActionBatchMove, model.AuditResourceNote, 0, map[string]interface{}{
+		"note_ids":    req.NoteIDs,
+		"notebook_id": req.NotebookID,
+		"moved_count": count,
 	})
 
 	response.SuccessWithMessage(c, "批量移动成功", map[string]interface{}{
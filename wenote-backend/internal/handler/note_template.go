@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoteTemplateHandler 笔记模板处理器
+type NoteTemplateHandler struct {
+	templateService *service.NoteTemplateService
+}
+
+// NewNoteTemplateHandler 创建笔记模板处理器实例
+func NewNoteTemplateHandler() *NoteTemplateHandler {
+	return &NoteTemplateHandler{
+		templateService: service.NewNoteTemplateService(),
+	}
+}
+
+// Create 创建笔记模板
+func (h *NoteTemplateHandler) Create(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	var req model.NoteTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	template, err := h.templateService.Create(userID, &req)
+	if err != nil {
+		h.handleError(c, err, "创建模板失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "创建成功", template)
+}
+
+// List 获取笔记模板列表
+func (h *NoteTemplateHandler) List(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	templates, err := h.templateService.List(userID)
+	if err != nil {
+		response.InternalError(c, "获取模板列表失败")
+		return
+	}
+
+	response.Success(c, &model.NoteTemplateListResp{List: templates})
+}
+
+// GetByID 获取笔记模板详情
+func (h *NoteTemplateHandler) GetByID(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	template, err := h.templateService.GetByID(userID, templateID)
+	if err != nil {
+		h.handleError(c, err, "获取模板失败")
+		return
+	}
+
+	response.Success(c, template)
+}
+
+// Update 修改笔记模板
+func (h *NoteTemplateHandler) Update(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	var req model.NoteTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	template, err := h.templateService.Update(userID, templateID, &req)
+	if err != nil {
+		h.handleError(c, err, "更新模板失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "更新成功", template)
+}
+
+// Delete 删除笔记模板
+func (h *NoteTemplateHandler) Delete(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的模板ID")
+		return
+	}
+
+	if err := h.templateService.Delete(userID, templateID); err != nil {
+		h.handleError(c, err, "删除模板失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceNoteTemplate, templateID, nil)
+
+	response.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// handleError 将服务层错误转换为响应
+func (h *NoteTemplateHandler) handleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrTemplateNotFound):
+		response.NotFound(c, "模板不存在")
+	case errors.Is(err, service.ErrNotebookNotFound):
+		response.BadRequest(c, "笔记本不存在")
+	case errors.Is(err, service.ErrTemplateReadOnly),
+		errors.Is(err, service.ErrTemplateNameDuplicate),
+		errors.Is(err, service.ErrTemplateVariableInvalid):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
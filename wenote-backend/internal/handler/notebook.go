@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/service"
 	"wenote-backend/pkg/response"
@@ -11,13 +12,15 @@ import (
 
 // NotebookHandler 笔记本处理器
 type NotebookHandler struct {
-	notebookService *service.NotebookService
+	notebookService    *service.NotebookService
+	savedSearchService *service.SavedSearchService
 }
 
 // NewNotebookHandler 创建笔记本处理器实例
 func NewNotebookHandler() *NotebookHandler {
 	return &NotebookHandler{
-		notebookService: service.NewNotebookService(),
+		notebookService:    service.NewNotebookService(),
+		savedSearchService: service.NewSavedSearchService(),
 	}
 }
 
@@ -32,7 +35,7 @@ func (h *NotebookHandler) Create(c *gin.Context) {
 
 	notebook, err := h.notebookService.Create(userID, &req)
 	if err != nil {
-		response.InternalError(c, "创建笔记本失败: "+err.Error())
+		h.handleError(c, err, "创建笔记本失败")
 		return
 	}
 
@@ -42,12 +45,23 @@ func (h *NotebookHandler) Create(c *gin.Context) {
 // List 获取笔记本列表
 func (h *NotebookHandler) List(c *gin.Context) {
 	userID := c.GetUint64("userID")
-	notebooks, err := h.notebookService.List(userID)
+	var req model.NotebookListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	notebooks, err := h.notebookService.List(userID, req.View)
 	if err != nil {
 		response.InternalError(c, "获取笔记本列表失败")
 		return
 	}
-	response.Success(c, &model.NotebookListResp{List: notebooks})
+	searches, err := h.savedSearchService.List(userID)
+	if err != nil {
+		response.InternalError(c, "获取笔记本列表失败")
+		return
+	}
+	response.Success(c, &model.NotebookListResp{List: notebooks, SavedSearches: searches})
 }
 
 // GetByID 获取笔记本详情
@@ -89,17 +103,37 @@ func (h *NotebookHandler) Update(c *gin.Context) {
 
 	notebook, err := h.notebookService.Update(userID, notebookID, &req)
 	if err != nil {
-		if err == service.ErrNotebookNotFound {
-			response.NotFound(c, "笔记本不存在")
-			return
-		}
-		response.InternalError(c, "更新笔记本失败: "+err.Error())
+		h.handleError(c, err, "更新笔记本失败")
 		return
 	}
 
 	response.SuccessWithMessage(c, "更新成功", notebook)
 }
 
+// Move 移动笔记本
+func (h *NotebookHandler) Move(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	notebookID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的笔记本ID")
+		return
+	}
+
+	var req model.NotebookMoveReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	notebook, err := h.notebookService.Move(userID, notebookID, &req)
+	if err != nil {
+		h.handleError(c, err, "移动笔记本失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "移动成功", notebook)
+}
+
 // Delete 删除笔记本
 func (h *NotebookHandler) Delete(c *gin.Context) {
 	userID := c.GetUint64("userID")
@@ -109,19 +143,19 @@ func (h *NotebookHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.notebookService.Delete(userID, notebookID); err != nil {
-		if err == service.ErrNotebookNotFound {
-			response.NotFound(c, "笔记本不存在")
-			return
-		}
-		if err == service.ErrCannotDeleteDefault {
-			response.BadRequest(c, "默认笔记本不能删除")
-			return
-		}
-		response.InternalError(c, "删除笔记本失败")
+	var req model.NotebookDeleteReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
 		return
 	}
 
+	if err := h.notebookService.Delete(userID, notebookID, req.Children); err != nil {
+		h.handleError(c, err, "删除笔记本失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceNotebook, notebookID, nil)
+
 	response.SuccessWithMessage(c, "删除成功", nil)
 }
 
@@ -135,3 +169,18 @@ func (h *NotebookHandler) GetDefault(c *gin.Context) {
 	}
 	response.Success(c, notebook)
 }
+
+// handleError 将服务层错误转换为响应
+func (h *NotebookHandler) handleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrNotebookNotFound):
+		response.NotFound(c, "笔记本不存在")
+	case errors.Is(err, service.ErrCannotDeleteDefault),
+		errors.Is(err, service.ErrNotebookNameDuplicate),
+		errors.Is(err, service.ErrNotebookParentInvalid),
+		errors.Is(err, service.ErrNotebookCycle):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
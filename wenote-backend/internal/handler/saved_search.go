@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchHandler 保存的搜索处理器
+type SavedSearchHandler struct {
+	savedSearchService *service.SavedSearchService
+}
+
+// NewSavedSearchHandler 创建保存的搜索处理器实例
+func NewSavedSearchHandler() *SavedSearchHandler {
+	return &SavedSearchHandler{
+		savedSearchService: service.NewSavedSearchService(),
+	}
+}
+
+// Create 创建保存的搜索
+func (h *SavedSearchHandler) Create(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	var req model.SavedSearchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	search, err := h.savedSearchService.Create(userID, &req)
+	if err != nil {
+		h.handleError(c, err, "创建保存的搜索失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "创建成功", search)
+}
+
+// List 获取保存的搜索列表
+func (h *SavedSearchHandler) List(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	searches, err := h.savedSearchService.List(userID)
+	if err != nil {
+		response.InternalError(c, "获取保存的搜索失败")
+		return
+	}
+
+	response.Success(c, &model.SavedSearchListResp{List: searches})
+}
+
+// GetByID 获取保存的搜索详情
+func (h *SavedSearchHandler) GetByID(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	searchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的搜索ID")
+		return
+	}
+
+	search, err := h.savedSearchService.GetByID(userID, searchID)
+	if err != nil {
+		h.handleError(c, err, "获取保存的搜索失败")
+		return
+	}
+
+	response.Success(c, search)
+}
+
+// Update 修改保存的搜索
+func (h *SavedSearchHandler) Update(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	searchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的搜索ID")
+		return
+	}
+
+	var req model.SavedSearchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	search, err := h.savedSearchService.Update(userID, searchID, &req)
+	if err != nil {
+		h.handleError(c, err, "修改保存的搜索失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "更新成功", search)
+}
+
+// Delete 删除保存的搜索
+func (h *SavedSearchHandler) Delete(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	searchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的搜索ID")
+		return
+	}
+
+	if err := h.savedSearchService.Delete(userID, searchID); err != nil {
+		h.handleError(c, err, "删除保存的搜索失败")
+		return
+	}
+
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceSavedSearch, searchID, nil)
+
+	response.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// ListNotes 按保存的搜索查询笔记
+func (h *SavedSearchHandler) ListNotes(c *gin.Context) {
+	userID := c.GetUint64("userID")
+	searchID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的搜索ID")
+		return
+	}
+
+	var req model.SavedSearchNotesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.savedSearchService.ListNotes(userID, searchID, &req)
+	if err != nil {
+		h.handleError(c, err, "获取笔记列表失败")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// handleError 将服务层错误转换为响应
+func (h *SavedSearchHandler) handleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrSavedSearchNotFound):
+		response.NotFound(c, "保存的搜索不存在")
+	case errors.Is(err, service.ErrSavedSearchNameDuplicate):
+		response.BadRequest(c, "保存的搜索名称已存在")
+	case errors.Is(err, service.ErrInvalidQuery), errors.Is(err, service.ErrInvalidCursor):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, fallback)
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SuggestHandler 输入联想处理器
+type SuggestHandler struct {
+	suggestService *service.SuggestService
+}
+
+// NewSuggestHandler 创建输入联想处理器实例
+func NewSuggestHandler() *SuggestHandler {
+	return &SuggestHandler{
+		suggestService: service.NewSuggestService(),
+	}
+}
+
+// Suggest 根据已输入的前缀联想笔记标题、标签和笔记本
+func (h *SuggestHandler) Suggest(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	var req model.SuggestReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.suggestService.Suggest(userID, &req)
+	if err != nil {
+		response.InternalError(c, "获取联想结果失败")
+		return
+	}
+
+	response.Success(c, resp)
+}
@@ -33,8 +33,8 @@ func (h *TagHandler) Create(c *gin.Context) {
 
 	tag, err := h.tagService.Create(userID, &req)
 	if err != nil {
-		if err == service.ErrTagNameExists {
-			response.BadRequest(c, "标签名称已存在")
+		if err == service.ErrTagNameExists || err == service.ErrTagNameEmpty {
+			response.BadRequest(c, err.Error())
 			return
 		}
 		response.InternalError(c, "创建标签失败: "+err.Error())
@@ -78,8 +78,8 @@ func (h *TagHandler) Update(c *gin.Context) {
 			response.NotFound(c, "标签不存在")
 			return
 		}
-		if err == service.ErrTagNameExists {
-			response.BadRequest(c, "标签名称已存在")
+		if err == service.ErrTagNameExists || err == service.ErrTagNameEmpty {
+			response.BadRequest(c, err.Error())
 			return
 		}
 		response.InternalError(c, "更新标签失败: "+err.Error())
@@ -107,5 +107,51 @@ func (h *TagHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, model.AuditActionDelete, model.AuditResourceTag, tagID, nil)
+
 	response.SuccessWithMessage(c, "删除成功", nil)
 }
+
+// Duplicates 获取疑似重复的标签及合并建议
+func (h *TagHandler) Duplicates(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	list, err := h.tagService.Duplicates(userID)
+	if err != nil {
+		response.InternalError(c, "获取重复标签失败")
+		return
+	}
+
+	response.Success(c, &model.TagDuplicateResp{List: list})
+}
+
+// Merge 合并标签
+func (h *TagHandler) Merge(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	var req model.TagMergeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	tag, err := h.tagService.Merge(userID, &req)
+	if err != nil {
+		if err == service.ErrTagNotFound {
+			response.NotFound(c, "标签不存在")
+			return
+		}
+		if err == service.ErrTagMergeSelf {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "合并标签失败: "+err.Error())
+		return
+	}
+
+	recordAudit(c, model.AuditActionMerge, model.AuditResourceTag, req.TargetID, map[string]interface{}{
+		"source_ids": req.SourceIDs,
+	})
+
+	response.SuccessWithMessage(c, "合并成功", tag)
+}
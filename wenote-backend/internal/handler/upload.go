@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/service"
+	"wenote-backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadHandler 分片续传处理器
+//
+// 协议：
+//  1. POST /notes/:id/uploads 创建会话，返回 upload_id 和 max_chunk_size
+//  2. PATCH /uploads/:id 追加分片，请求头 Upload-Offset 为分片起始偏移量，
+//     可选 Upload-Checksum 为分片的 SHA-256（十六进制），请求体为分片原始内容
+//  3. 断线后 HEAD /uploads/:id 从响应头 Upload-Offset 获取已接收的字节数继续上传
+//  4. POST /uploads/:id/complete 合并分片并创建附件；DELETE /uploads/:id 取消上传
+type UploadHandler struct {
+	service *service.UploadService
+}
+
+// NewUploadHandler 创建分片续传处理器实例
+func NewUploadHandler() *UploadHandler {
+	return &UploadHandler{
+		service: service.NewUploadService(),
+	}
+}
+
+// Init 创建上传会话
+// POST /api/v1/notes/:id/uploads
+func (h *UploadHandler) Init(c *gin.Context) {
+	noteID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的笔记ID")
+		return
+	}
+
+	var req model.UploadInitReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	session, err := h.service.Init(c.GetUint64("userID"), noteID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	setUploadHeaders(c, session)
+	response.Success(c, session)
+}
+
+// Status 查询上传进度
+// GET/HEAD /api/v1/uploads/:id
+func (h *UploadHandler) Status(c *gin.Context) {
+	session, err := h.service.Get(c.GetUint64("userID"), c.Param("id"))
+	if err != nil {
+		if c.Request.Method == http.MethodHead {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		h.handleError(c, err)
+		return
+	}
+
+	setUploadHeaders(c, session)
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	response.Success(c, session)
+}
+
+// Append 追加分片
+// PATCH /api/v1/uploads/:id
+func (h *UploadHandler) Append(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		response.BadRequest(c, "缺少或无效的 Upload-Offset 请求头")
+		return
+	}
+
+	session, err := h.service.Append(c.GetUint64("userID"), c.Param("id"), offset, c.GetHeader("Upload-Checksum"), c.Request.Body)
+	if session != nil {
+		setUploadHeaders(c, session)
+	}
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, session)
+}
+
+// Complete 合并分片并创建附件
+// POST /api/v1/uploads/:id/complete
+func (h *UploadHandler) Complete(c *gin.Context) {
+	result, err := h.service.Complete(c.GetUint64("userID"), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Abort 取消上传
+// DELETE /api/v1/uploads/:id
+func (h *UploadHandler) Abort(c *gin.Context) {
+	if err := h.service.Abort(c.GetUint64("userID"), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *UploadHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, service.ErrUploadOffsetMismatch),
+		errors.Is(err, service.ErrUploadCompleting):
+		response.Conflict(c, err.Error())
+	case errors.Is(err, service.ErrUploadChecksumMismatch),
+		errors.Is(err, service.ErrUploadChunkTooLarge),
+		errors.Is(err, service.ErrUploadChunkEmpty),
+		errors.Is(err, service.ErrUploadExceedsSize),
+		errors.Is(err, service.ErrUploadIncomplete),
+		errors.Is(err, service.ErrFileTypeNotAllowed),
+		errors.Is(err, service.ErrFileTooLarge),
+		errors.Is(err, service.ErrInvalidImage),
+		errors.Is(err, service.ErrStorageQuotaExceeded):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err.Error())
+	}
+}
+
+// setUploadHeaders 写入续传进度响应头
+func setUploadHeaders(c *gin.Context, session *model.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	c.Header("Cache-Control", "no-store")
+}
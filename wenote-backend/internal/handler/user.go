@@ -10,13 +10,15 @@ import (
 
 // UserHandler 用户处理器
 type UserHandler struct {
-	userService *service.UserService
+	userService  *service.UserService
+	auditService *service.AuditService
 }
 
 // NewUserHandler 创建用户处理器实例
 func NewUserHandler() *UserHandler {
 	return &UserHandler{
-		userService: service.NewUserService(),
+		userService:  service.NewUserService(),
+		auditService: service.NewAuditService(),
 	}
 }
 
@@ -83,6 +85,9 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		case service.ErrUserNotFound:
 			response.NotFound(c, "用户不存在")
 		case service.ErrPasswordIncorrect:
+			recordAudit(c, model.AuditActionPasswordChangeFailure, model.AuditResourceUser, userID, map[string]interface{}{
+				"reason": "password_incorrect",
+			})
 			response.BadRequest(c, "当前密码错误")
 		case service.ErrPasswordSame:
 			response.BadRequest(c, "新密码不能与旧密码相同")
@@ -92,6 +97,8 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, model.AuditActionPasswordChange, model.AuditResourceUser, userID, nil)
+
 	response.SuccessWithMessage(c, "密码修改成功", nil)
 }
 
@@ -105,7 +112,7 @@ func (h *UserHandler) DeleteAccount(c *gin.Context) {
 		return
 	}
 
-	err := h.userService.DeleteAccount(userID, &req)
+	resp, err := h.userService.DeleteAccount(userID, &req)
 	if err != nil {
 		switch err {
 		case service.ErrUserNotFound:
@@ -120,5 +127,35 @@ func (h *UserHandler) DeleteAccount(c *gin.Context) {
 		return
 	}
 
-	response.SuccessWithMessage(c, "账号已删除", nil)
+	if resp.Purged {
+		// 立即清除时 account_purge 日志已由服务层记录
+		response.SuccessWithMessage(c, "账号已删除", resp)
+		return
+	}
+
+	recordAudit(c, model.AuditActionAccountDelete, model.AuditResourceUser, userID, map[string]interface{}{
+		"scheduled_at": resp.ScheduledAt,
+	})
+
+	response.SuccessWithMessage(c, "账号将在宽限期结束后删除，期间重新登录即可撤销", resp)
+}
+
+// ListAudit 获取当前用户的审计日志
+// GET /api/v1/users/me/audit?action=&resource_type=&page=&page_size=
+func (h *UserHandler) ListAudit(c *gin.Context) {
+	userID := c.GetUint64("userID")
+
+	var req model.AuditListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.auditService.ListByUser(userID, &req)
+	if err != nil {
+		response.InternalError(c, "获取审计日志失败")
+		return
+	}
+
+	response.Success(c, resp)
 }
@@ -21,36 +21,62 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			response.Unauthorized(c, "Token格式错误")
+		if !authenticate(c, jwtManager, authHeader) {
 			c.Abort()
 			return
 		}
 
-		tokenString := parts[1]
-
-		claims, err := jwtManager.ParseToken(tokenString)
-		if err != nil {
-			switch err {
-			case jwt.ErrTokenExpired:
-				response.Unauthorized(c, "Token已过期，请重新登录")
-			case jwt.ErrTokenMalformed:
-				response.Unauthorized(c, "Token格式错误")
-			default:
-				response.Unauthorized(c, "无效的Token")
-			}
+		c.Next()
+	}
+}
+
+// OptionalJWTAuth 可选认证中间件
+// 未携带 Authorization 头时直接放行（userID 为 0），由处理器决定是否接受其他凭证，
+// 如附件下载的签名地址；携带了无效令牌时仍然拒绝
+func OptionalJWTAuth() gin.HandlerFunc {
+	cfg := config.GlobalConfig.JWT
+	jwtManager := jwt.NewJWTManager(cfg.Secret, cfg.Expire)
+
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader != "" && !authenticate(c, jwtManager, authHeader) {
 			c.Abort()
 			return
 		}
 
-		c.Set("userID", claims.UserID)
-		c.Set("username", claims.Username)
-
 		c.Next()
 	}
 }
 
+// authenticate 解析 Bearer 令牌并写入上下文，失败时写入错误响应并返回 false
+func authenticate(c *gin.Context, jwtManager *jwt.JWTManager, authHeader string) bool {
+	parts := strings.SplitN(authHeader, " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") {
+		response.Unauthorized(c, "Token格式错误")
+		return false
+	}
+
+	tokenString := parts[1]
+
+	claims, err := jwtManager.ParseToken(tokenString)
+	if err != nil {
+		switch err {
+		case jwt.ErrTokenExpired:
+			response.Unauthorized(c, "Token已过期，请重新登录")
+		case jwt.ErrTokenMalformed:
+			response.Unauthorized(c, "Token格式错误")
+		default:
+			response.Unauthorized(c, "无效的Token")
+		}
+		return false
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("tier", claims.Tier)
+	return true
+}
+
 func GetUserID(c *gin.Context) uint64 {
 	if userID, exists := c.Get("userID"); exists {
 		return userID.(uint64)
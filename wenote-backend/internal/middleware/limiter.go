@@ -1,80 +1,134 @@
 package middleware
 
 import (
-	"sync"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
 	"wenote-backend/config"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/ratelimit"
 	"wenote-backend/pkg/response"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-// RateLimiter 限流中间件
-func RateLimiter() gin.HandlerFunc {
+// RateLimiter 全局限流中间件
+// 在认证之前执行，只做全局和 IP 维度的兜底保护
+// 令牌桶状态保存在共享存储中，多副本部署时各实例共用同一份配额
+func RateLimiter(store ratelimit.Limiter) gin.HandlerFunc {
 	cfg := config.GlobalConfig.RateLimit
 
-	globalLimiter := rate.NewLimiter(
-		rate.Limit(cfg.GlobalRate),
-		int(cfg.GlobalBurst),
-	)
-
-	userLimiters := &sync.Map{}
-	ipLimiters := &sync.Map{}
+	globalLimit := ratelimit.Limit{Rate: cfg.GlobalRate, Burst: cfg.GlobalBurst}
+	ipLimit := ratelimit.Limit{Rate: cfg.IPRate, Burst: cfg.IPBurst}
 
 	return func(c *gin.Context) {
-		if !globalLimiter.Allow() {
+		if res := allow(c, store, "global", globalLimit); !res.Allowed {
+			setRetryAfter(c, res)
 			response.TooManyRequests(c, "服务繁忙，请稍后重试")
 			c.Abort()
 			return
 		}
 
-		clientIP := c.ClientIP()
-		ipLimiter := getIPLimiter(ipLimiters, clientIP, cfg)
-		if !ipLimiter.Allow() {
+		if res := allow(c, store, "ip:"+c.ClientIP(), ipLimit); !res.Allowed {
+			setRetryAfter(c, res)
 			response.TooManyRequests(c, "请求过于频繁，请稍后再试")
 			c.Abort()
 			return
 		}
 
-		if userID, exists := c.Get("userID"); exists {
-			limiter := getUserLimiter(userLimiters, userID.(uint64), cfg)
-			if !limiter.Allow() {
-				response.TooManyRequests(c, "请求过于频繁，请稍后再试")
-				c.Abort()
-				return
-			}
+		c.Next()
+	}
+}
+
+// RateLimit 按策略限流中间件
+// 需注册在 JWTAuth 之后：已登录请求按用户计数，并应用用户等级的策略覆盖；
+// 未登录请求（如登录接口）按 IP 计数
+//
+// 响应头遵循 IETF RateLimit 头部草案：
+//   - RateLimit-Limit: 桶容量
+//   - RateLimit-Remaining: 剩余请求数
+//   - RateLimit-Reset: 配额恢复满额的秒数
+//   - RateLimit-Policy: 策略描述，如 "20;w=2"
+//   - Retry-After: 被拒绝时建议的重试秒数
+func RateLimit(store ratelimit.Limiter, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !applyPolicy(c, store, policy) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitByMethod 按请求方法区分读写策略的限流中间件
+// GET/HEAD 请求使用 readPolicy，其余请求使用 writePolicy
+func RateLimitByMethod(store ratelimit.Limiter, readPolicy, writePolicy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := writePolicy
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			policy = readPolicy
 		}
 
+		if !applyPolicy(c, store, policy) {
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
-// getUserLimiter 获取或创建用户限流器
-func getUserLimiter(userLimiters *sync.Map, userID uint64, cfg config.RateLimitConfig) *rate.Limiter {
-	if limiter, ok := userLimiters.Load(userID); ok {
-		return limiter.(*rate.Limiter)
+// applyPolicy 执行策略限流并写入响应头，返回是否放行
+func applyPolicy(c *gin.Context, store ratelimit.Limiter, policy string) bool {
+	cfg := config.GlobalConfig.RateLimit
+	p := cfg.Policy(policy, c.GetString("tier"))
+	limit := ratelimit.Limit{Rate: p.Rate, Burst: p.Burst}
+
+	key := "policy:" + policy + ":ip:" + c.ClientIP()
+	if userID := GetUserID(c); userID > 0 {
+		key = "policy:" + policy + ":user:" + strconv.FormatUint(userID, 10)
 	}
 
-	limiter := rate.NewLimiter(
-		rate.Limit(cfg.UserRate),
-		int(cfg.UserBurst),
-	)
+	res := allow(c, store, key, limit)
+	setRateLimitHeaders(c, res, limit)
 
-	actual, _ := userLimiters.LoadOrStore(userID, limiter)
-	return actual.(*rate.Limiter)
+	if !res.Allowed {
+		setRetryAfter(c, res)
+		response.TooManyRequests(c, "请求过于频繁，请稍后再试")
+		return false
+	}
+	return true
 }
 
-// getIPLimiter 获取或创建 IP 限流器
-func getIPLimiter(ipLimiters *sync.Map, ip string, cfg config.RateLimitConfig) *rate.Limiter {
-	if limiter, ok := ipLimiters.Load(ip); ok {
-		return limiter.(*rate.Limiter)
+// allow 判定是否放行，存储不可用时放行并记录日志
+func allow(c *gin.Context, store ratelimit.Limiter, key string, limit ratelimit.Limit) *ratelimit.Result {
+	res, err := store.Allow(c.Request.Context(), key, limit)
+	if err != nil {
+		logger.Error("限流存储不可用", "key", key, "error", err)
+		return &ratelimit.Result{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}
 	}
+	return res
+}
+
+func setRateLimitHeaders(c *gin.Context, res *ratelimit.Result, limit ratelimit.Limit) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	if limit.Rate > 0 {
+		window := int(math.Ceil(float64(limit.Burst) / limit.Rate))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, window))
+	}
+}
 
-	limiter := rate.NewLimiter(
-		rate.Limit(cfg.IPRate),
-		int(cfg.IPBurst),
-	)
+func setRetryAfter(c *gin.Context, res *ratelimit.Result) {
+	retryAfter := ceilSeconds(res.RetryAfter)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+}
 
-	actual, _ := ipLimiters.LoadOrStore(ip, limiter)
-	return actual.(*rate.Limiter)
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
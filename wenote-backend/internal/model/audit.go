@@ -2,6 +2,43 @@ package model
 
 import "time"
 
+// 审计操作类型
+const (
+	AuditActionLoginSuccess          = "login_success"
+	AuditActionLoginFailure          = "login_failure"
+	AuditActionPasswordChange        = "password_change"
+	AuditActionPasswordChangeFailure = "password_change_failure"
+	AuditActionAccountDelete         = "account_delete"
+	AuditActionAccountDeleteCancel   = "account_delete_cancel"
+	AuditActionAccountPurge          = "account_purge" // 账号清除，由系统记录，UserID 为 0，ResourceID 为被清除的用户
+	AuditActionDelete                = "delete"
+	AuditActionRestore               = "restore"
+	AuditActionEmptyTrash            = "empty_trash"
+	AuditActionBatchDelete           = "batch_delete"
+	AuditActionBatchRestore          = "batch_restore"
+	AuditActionBatchMove             = "batch_move"
+	AuditActionMerge                 = "merge"
+	AuditActionAttachmentScan        = "attachment_scan" // 附件病毒扫描结果，由系统记录
+
+	// 管理员操作，UserID 为操作的管理员
+	AuditActionAdminLockUser      = "admin_lock_user"
+	AuditActionAdminUnlockUser    = "admin_unlock_user"
+	AuditActionAdminResetPassword = "admin_reset_password"
+	AuditActionAdminCleanupTrash  = "admin_cleanup_trash"
+	AuditActionAdminAttachmentGC  = "admin_attachment_gc"
+)
+
+// 审计资源类型
+const (
+	AuditResourceUser         = "user"
+	AuditResourceNote         = "note"
+	AuditResourceNotebook     = "notebook"
+	AuditResourceTag          = "tag"
+	AuditResourceAttachment   = "attachment"
+	AuditResourceSavedSearch  = "saved_search"
+	AuditResourceNoteTemplate = "note_template"
+)
+
 // AuditLog 审计日志
 type AuditLog struct {
 	ID           uint64                 `json:"id" gorm:"primaryKey"`
@@ -17,3 +54,21 @@ type AuditLog struct {
 func (AuditLog) TableName() string {
 	return "audit_logs"
 }
+
+// AuditListReq 审计日志查询请求
+// 用于 GET /api/v1/users/me/audit
+type AuditListReq struct {
+	Action       string  `form:"action"`               // 按操作类型筛选
+	ResourceType string  `form:"resource_type"`        // 按资源类型筛选
+	ResourceID   *uint64 `form:"resource_id"`          // 按资源 ID 筛选
+	Page         int     `form:"page,default=1"`       // 页码，默认 1
+	PageSize     int     `form:"page_size,default=20"` // 每页数量，默认 20
+}
+
+// AuditListResp 审计日志列表响应
+type AuditListResp struct {
+	Total int64       `json:"total"`
+	List  []*AuditLog `json:"list"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+}
@@ -1,8 +1,11 @@
 package model
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
+	"sort"
 	"time"
 )
 
@@ -107,6 +110,32 @@ type Note struct {
 	// ===== 关联 =====
 	// 多对多关联，通过 note_tags 中间表实现
 	Tags []Tag `gorm:"many2many:note_tags" json:"tags,omitempty"`
+
+	// 关键词搜索时命中的附件，仅在列表接口中填充
+	MatchedAttachments []*AttachmentMatch `gorm:"-" json:"matched_attachments,omitempty"`
+
+	// 搜索时标题和正文的命中位置，仅在列表接口中填充
+	Highlight *NoteHighlight `gorm:"-" json:"highlight,omitempty"`
+}
+
+// NoteHighlight 搜索结果的高亮信息
+type NoteHighlight struct {
+	Title    *Snippet   `json:"title,omitempty"`    // 标题命中时为整个标题
+	Snippets []*Snippet `json:"snippets,omitempty"` // 正文中命中位置附近的片段
+}
+
+// Snippet 带命中位置的文本片段，位置均以字符为单位
+type Snippet struct {
+	Text        string       `json:"text"`        // 片段原文，换行替换为空格
+	Offset      int          `json:"offset"`      // 片段在全文中的起始位置
+	Matches     []MatchRange `json:"matches"`     // 命中位置，相对于 Text
+	Highlighted string       `json:"highlighted"` // 转义为 HTML 后用 <mark> 标出命中的文本
+}
+
+// MatchRange 命中位置，区间为 [Start, End)
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 // TableName 指定表名
@@ -118,12 +147,17 @@ func (Note) TableName() string {
 
 // NoteCreateReq 创建笔记请求
 // 用于 POST /api/v1/notes
+// 指定模板时，未传的标题和内容使用模板渲染的结果，标签为模板的默认标签与 TagIDs 的并集，
+// 未传笔记本时使用模板的目标笔记本
 type NoteCreateReq struct {
-	NotebookID uint64   `json:"notebook_id" binding:"required"` // 所属笔记本 ID，必填
-	Title      string   `json:"title" binding:"max=255"`        // 标题，最大 255 字符
-	Content    string   `json:"content"`                        // 内容
-	SummaryLen int      `json:"summary_len"`                    // 摘要长度，可选，默认 200
-	TagIDs     []uint64 `json:"tag_ids"`                        // 关联的标签 ID 列表
+	NotebookID uint64   `json:"notebook_id" binding:"required_without=TemplateID"` // 所属笔记本 ID，未使用模板时必填
+	Title      string   `json:"title" binding:"max=255"`                           // 标题，最大 255 字符
+	Content    string   `json:"content"`                                           // 内容
+	SummaryLen int      `json:"summary_len"`                                       // 摘要长度，可选，默认 200
+	TagIDs     []uint64 `json:"tag_ids"`                                           // 关联的标签 ID 列表
+
+	TemplateID *uint64           `json:"template_id"` // 笔记模板 ID
+	Variables  map[string]string `json:"variables"`   // 模板自定义变量的值
 }
 
 // NoteUpdateReq 更新笔记请求
@@ -153,8 +187,122 @@ type NoteListReq struct {
 	IsStarred  *bool   `form:"is_starred"`  // 只看星标
 	IsPinned   *bool   `form:"is_pinned"`   // 只看置顶
 	Keyword    string  `form:"keyword"`     // 关键词搜索（全文搜索）
+	Q          string  `form:"q"`           // 搜索语句，如 tag:go is:starred created:>2026-01-01 -draft "exact phrase"
 	Page       int     `form:"page,default=1"`      // 页码，默认 1
 	PageSize   int     `form:"page_size,default=20"` // 每页数量，默认 20
+
+	// 是否返回正文，默认返回；传 false 时列表只含标题、摘要和搜索高亮片段
+	IncludeContent *bool `form:"include_content"`
+	// 搜索时是否同时返回结果按标签、笔记本的分布
+	Facets bool `form:"facets"`
+
+	// 排序字段，搜索时默认按相关性，否则默认按更新时间；置顶笔记始终在前
+	Sort string `form:"sort" binding:"omitempty,oneof=created_at updated_at title relevance"`
+	// 排序方向 asc 或 desc，按标题排序时默认 asc，其余默认 desc
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
+	// 上一页响应中的 next_cursor，传入时忽略 page，从游标处继续
+	// 游标只能用于筛选条件和排序都相同的查询；按相关性排序时没有游标，使用 page 翻页
+	Cursor string `form:"cursor"`
+
+	// 多标签筛选，重复传参：tag_ids=1&tag_ids=2；与 tag_id 一样包含子标签
+	TagIDs []uint64 `form:"tag_ids" binding:"omitempty,max=20,dive,gt=0"`
+	// tag_ids 的组合方式：and 同时带有全部标签（默认），or 带有任一标签
+	TagMode string `form:"tag_mode" binding:"omitempty,oneof=and or"`
+	// 排除带有任一标签（含子标签）的笔记
+	ExcludeTagIDs []uint64 `form:"exclude_tag_ids" binding:"omitempty,max=20,dive,gt=0"`
+	// 只看没有标签的笔记
+	Untagged bool `form:"untagged"`
+}
+
+// tag_ids 的组合方式
+const (
+	TagModeAnd = "and"
+	TagModeOr  = "or"
+)
+
+// ContentIncluded 列表是否返回笔记正文
+func (r *NoteListReq) ContentIncluded() bool {
+	return r.IncludeContent == nil || *r.IncludeContent
+}
+
+// SortOrder 生效的排序字段和方向，searching 表示是否为关键词或搜索语句查询
+// 非搜索查询没有相关性得分，按相关性排序时改为按更新时间
+func (r *NoteListReq) SortOrder(searching bool) (sort, order string) {
+	sort = r.Sort
+	if sort == "" || (sort == NoteSortRelevance && !searching) {
+		sort = NoteSortUpdatedAt
+		if searching {
+			sort = NoteSortRelevance
+		}
+	}
+	return sort, defaultOrder(sort, r.Order)
+}
+
+// CursorScope 查询的摘要，由全部筛选条件和生效的排序 sort、order 计算
+// 游标中记录生成时的摘要，只能用于摘要相同的查询；页码、每页数量、是否返回正文等不影响结果和顺序的参数不参与计算
+func (r *NoteListReq) CursorScope(sort, order string) string {
+	tagMode := r.TagMode
+	if tagMode == "" {
+		tagMode = TagModeAnd
+	}
+	return cursorScope(struct {
+		NotebookID    *uint64  `json:"nb"`
+		TagID         *uint64  `json:"t"`
+		IsStarred     *bool    `json:"st"`
+		IsPinned      *bool    `json:"pi"`
+		Keyword       string   `json:"kw"`
+		Q             string   `json:"q"`
+		TagIDs        []uint64 `json:"ts"`
+		TagMode       string   `json:"tm"`
+		ExcludeTagIDs []uint64 `json:"xt"`
+		Untagged      bool     `json:"u"`
+		Sort          string   `json:"s"`
+		Order         string   `json:"o"`
+	}{
+		r.NotebookID, r.TagID, r.IsStarred, r.IsPinned, r.Keyword, r.Q,
+		sortedIDs(r.TagIDs), tagMode, sortedIDs(r.ExcludeTagIDs), r.Untagged,
+		sort, order,
+	})
+}
+
+// cursorScope 计算 v 的 JSON 的摘要
+func cursorScope(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// sortedIDs 排好序的 ID 副本，顺序不同的同一组标签得到相同的摘要
+func sortedIDs(ids []uint64) []uint64 {
+	sorted := append([]uint64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// 笔记列表的排序字段
+const (
+	NoteSortCreatedAt = "created_at"
+	NoteSortUpdatedAt = "updated_at"
+	NoteSortDeletedAt = "deleted_at" // 仅回收站
+	NoteSortTitle     = "title"
+	NoteSortRelevance = "relevance" // 仅搜索
+)
+
+// 排序方向
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// defaultOrder 未指定方向时按标题升序，其余降序
+func defaultOrder(sort, order string) string {
+	if order != "" {
+		return order
+	}
+	if sort == NoteSortTitle {
+		return SortAsc
+	}
+	return SortDesc
 }
 
 // NoteListResp 笔记列表响应
@@ -163,6 +311,52 @@ type NoteListResp struct {
 	List  []*Note `json:"list"`  // 笔记列表
 	Page  int     `json:"page"`  // 当前页码
 	Size  int     `json:"size"`  // 每页数量
+
+	Facets *SearchFacets `json:"facets,omitempty"` // 搜索结果的分布，仅在搜索且 facets=true 时返回
+
+	// 下一页的游标，没有更多结果时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NoteTrashReq 回收站列表查询请求
+// 用于 GET /api/v1/notes/trash
+type NoteTrashReq struct {
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=deleted_at created_at updated_at title"` // 默认按删除时间
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"`                             // 按标题排序时默认 asc，其余默认 desc
+	Cursor   string `form:"cursor"`                                                               // 上一页响应中的 next_cursor
+}
+
+// SortOrder 生效的排序字段和方向
+func (r *NoteTrashReq) SortOrder() (sort, order string) {
+	sort = r.Sort
+	if sort == "" {
+		sort = NoteSortDeletedAt
+	}
+	return sort, defaultOrder(sort, r.Order)
+}
+
+// CursorScope 查询的摘要，与 NoteListReq.CursorScope 相同，笔记列表的游标不能用于回收站
+func (r *NoteTrashReq) CursorScope(sort, order string) string {
+	return cursorScope(struct {
+		Trash bool   `json:"trash"`
+		Sort  string `json:"s"`
+		Order string `json:"o"`
+	}{true, sort, order})
+}
+
+// SearchFacets 搜索结果按标签和笔记本的分布，按数量降序
+type SearchFacets struct {
+	Tags      []*FacetCount `json:"tags"`
+	Notebooks []*FacetCount `json:"notebooks"`
+}
+
+// FacetCount 某个标签或笔记本下命中的笔记数
+type FacetCount struct {
+	ID    uint64 `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
 }
 
 // NoteTagsReq 修改笔记标签请求
@@ -201,10 +395,28 @@ type NoteAttachment struct {
 	UserID      uint64    `gorm:"index;not null" json:"user_id"`        // 所属用户
 	Filename    string    `gorm:"type:varchar(255);not null" json:"filename"` // 原始文件名
 	FileSize    int       `gorm:"not null" json:"file_size"`            // 文件大小（字节）
-	MimeType    string    `gorm:"type:varchar(100);not null" json:"mime_type"` // MIME类型
+	MimeType    string    `gorm:"type:varchar(100);not null" json:"mime_type"` // MIME类型（按内容识别）
+	Category    string    `gorm:"type:varchar(20);default:'image';index" json:"category"` // 附件类别，对应上传策略
 	StoragePath string    `gorm:"type:varchar(500);not null" json:"storage_path"` // 存储路径
+	BlobHash    string    `gorm:"type:char(64);index" json:"-"`                 // 内容 SHA-256，为空表示去重前上传的旧附件
 	URL         string    `gorm:"type:varchar(500);not null" json:"url"` // 访问URL
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// 图片信息，非图片附件为空
+	Width         int                  `gorm:"default:0" json:"width,omitempty"`
+	Height        int                  `gorm:"default:0" json:"height,omitempty"`
+	DominantColor string               `gorm:"type:varchar(7)" json:"dominant_color,omitempty"` // 主色调，用于加载前的占位
+	VariantStatus string               `gorm:"type:varchar(20);index" json:"variant_status,omitempty"` // 缩略图生成状态
+	Variants      []*AttachmentVariant `gorm:"foreignKey:AttachmentID" json:"variants,omitempty"`
+
+	TextStatus string `gorm:"type:varchar(20);index" json:"text_status,omitempty"` // 文本提取状态，不支持提取的类型为空
+
+	// 病毒扫描状态，扫描通过前不可下载；新增字段前上传的附件默认视为安全
+	ScanStatus string `gorm:"type:varchar(20);not null;default:'clean';index" json:"scan_status"`
+
+	// 是否被所属笔记的正文引用；未被引用时 UnreferencedAt 记录上传或移除引用的时间，超过保留期后删除
+	Referenced     bool       `gorm:"not null;default:false;index" json:"referenced"`
+	UnreferencedAt *time.Time `gorm:"index" json:"unreferenced_at,omitempty"`
 }
 
 // TableName 指定表名
@@ -212,8 +424,138 @@ func (NoteAttachment) TableName() string {
 	return "note_attachments"
 }
 
+// AttachmentBlob 按内容寻址的附件文件
+// 内容相同的附件共享同一个文件和缩略图，RefCount 为引用该文件的附件数，归零时删除文件
+type AttachmentBlob struct {
+	Hash        string    `gorm:"primaryKey;type:char(64)" json:"hash"` // 内容 SHA-256（十六进制）
+	Size        int64     `gorm:"not null" json:"size"`
+	MimeType    string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+	StoragePath string    `gorm:"type:varchar(500);not null" json:"-"`
+	RefCount    int       `gorm:"not null;default:0;index" json:"ref_count"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AttachmentBlob) TableName() string {
+	return "attachment_blobs"
+}
+
+// 缩略图生成状态
+const (
+	VariantStatusPending = "pending" // 等待后台生成
+	VariantStatusDone    = "done"    // 已生成
+	VariantStatusFailed  = "failed"  // 生成失败
+)
+
+// 病毒扫描状态
+const (
+	ScanStatusPending  = "pending"  // 等待扫描
+	ScanStatusClean    = "clean"    // 未检出恶意内容
+	ScanStatusInfected = "infected" // 检出恶意内容，文件已隔离
+)
+
+// 文本提取状态
+const (
+	TextStatusPending = "pending" // 等待后台提取
+	TextStatusDone    = "done"    // 已提取
+	TextStatusFailed  = "failed"  // 提取失败
+)
+
+// AttachmentText 从附件中提取的文本，参与笔记关键词搜索
+type AttachmentText struct {
+	AttachmentID uint64    `gorm:"primaryKey;autoIncrement:false" json:"attachment_id"`
+	NoteID       uint64    `gorm:"index;not null" json:"note_id"`
+	UserID       uint64    `gorm:"index;not null" json:"user_id"`
+	Content      string    `gorm:"type:longtext;index:ft_attachment_text,class:FULLTEXT,option:WITH PARSER ngram" json:"content"`
+	Source       string    `gorm:"type:varchar(20);not null" json:"source"` // 文本来源：document 或 ocr
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AttachmentText) TableName() string {
+	return "attachment_texts"
+}
+
+// 附件文本来源
+const (
+	TextSourceDocument = "document" // 从文档中直接提取
+	TextSourceOCR      = "ocr"      // 图片文字识别
+)
+
+// AttachmentMatch 关键词命中的附件
+type AttachmentMatch struct {
+	AttachmentID uint64 `json:"attachment_id"`
+	NoteID       uint64 `json:"-"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mime_type"`
+	Snippet      string `json:"snippet"` // 命中位置附近的文本片段
+}
+
+// AttachmentVariant 图片附件的缩略图
+// 原图长边小于缩略图尺寸时不生成，下载时回退到原图
+type AttachmentVariant struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"-"`
+	AttachmentID uint64    `gorm:"not null;uniqueIndex:idx_attachment_variant" json:"-"`
+	Name         string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_attachment_variant" json:"name"` // 尺寸名称，如 small、medium
+	Width        int       `gorm:"not null" json:"width"`
+	Height       int       `gorm:"not null" json:"height"`
+	FileSize     int       `gorm:"not null" json:"file_size"`
+	MimeType     string    `gorm:"type:varchar(100);not null" json:"mime_type"`
+	StoragePath  string    `gorm:"type:varchar(500);not null" json:"-"`
+	URL          string    `gorm:"-" json:"url"` // 带签名的下载地址，读取时生成
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AttachmentVariant) TableName() string {
+	return "attachment_variants"
+}
+
 // AttachmentUploadResp 附件上传响应
 type AttachmentUploadResp struct {
-	ID  uint64 `json:"id"`
-	URL string `json:"url"`
+	ID       uint64 `json:"id"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	FileSize int    `json:"file_size"`
+	MimeType string `json:"mime_type"`
+	Category string `json:"category"`
+
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	DominantColor string `json:"dominant_color,omitempty"`
+
+	ScanStatus string `json:"scan_status"` // pending 时需等待扫描完成才能下载
+	Reference  string `json:"reference"`   // 在笔记正文中引用该附件的地址，保存正文后标记为已引用
+}
+
+// AttachmentGCReport 附件垃圾回收报告
+// 计数字段在试运行时表示"将要处理"的数量
+type AttachmentGCReport struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+
+	ScannedRows     int `json:"scanned_rows"`     // 检查的附件记录数
+	ScannedVariants int `json:"scanned_variants"` // 检查的缩略图记录数
+	ScannedFiles    int `json:"scanned_files"`    // 检查的存储文件数
+
+	OrphanRows      int   `json:"orphan_rows"`      // 所属笔记已不存在的附件记录
+	MissingFiles    int   `json:"missing_files"`    // 文件已丢失的附件记录
+	MissingVariants int   `json:"missing_variants"` // 文件已丢失的缩略图记录，清理后重新生成
+	OrphanFiles     int   `json:"orphan_files"`     // 没有对应记录的存储文件
+	OrphanBlobs     int   `json:"orphan_blobs"`     // 已无附件引用的内容记录
+	OrphanTexts     int   `json:"orphan_texts"`     // 附件已不存在的提取文本
+	FreedBytes      int64 `json:"freed_bytes"`      // 释放的存储空间（字节）
+	Errors          int   `json:"errors"`           // 处理失败的条目数
+
+	// 示例条目，每类最多保留 50 个，便于排查
+	OrphanRowIDs   []uint64 `json:"orphan_row_ids,omitempty"`
+	MissingFileIDs []uint64 `json:"missing_file_ids,omitempty"`
+	OrphanFileKeys []string `json:"orphan_file_keys,omitempty"`
+
+	// 存储用量被重新计算的用户
+	AffectedUserIDs []uint64 `json:"affected_user_ids,omitempty"`
 }
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// NoteTemplate 笔记模板
+// 对应数据库 note_templates 表
+// 用模板创建笔记时，标题和正文中的占位符替换为实际的值，并使用模板的默认标签和目标笔记本
+//
+// 占位符写作 {{name}}，内置的有：
+//   - {{date}}: 当前日期，如 2026-01-02
+//   - {{time}}: 当前时间，如 15:04
+//   - {{datetime}}: 当前日期和时间，如 2026-01-02 15:04
+//   - {{weekday}}: 星期，如 星期五
+//   - {{notebook}}: 目标笔记本名称
+//
+// 其余占位符为 Variables 中声明的自定义变量，创建笔记时由用户填写
+//
+// 字段说明：
+//   - ID: 唯一标识
+//   - UserID: 所属用户 ID，系统模板为 0
+//   - IsSystem: 是否为系统模板，系统模板所有用户可用且不能修改
+//   - SystemKey: 系统模板的标识，用于启动时初始化，用户模板为空
+//   - Name、Description: 模板名称和说明
+//   - Title、Content: 笔记标题和正文的模板
+//   - NotebookID: 目标笔记本，为空时由创建笔记的请求指定（系统模板总是为空）
+//   - TagNames: 默认标签名称，不存在的标签在创建笔记时自动创建
+//   - Variables: 自定义变量
+type NoteTemplate struct {
+	ID          uint64            `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64            `gorm:"index;not null;default:0" json:"user_id"`
+	IsSystem    bool              `gorm:"default:false" json:"is_system"`
+	SystemKey   string            `gorm:"type:varchar(50);index" json:"system_key,omitempty"`
+	Name        string            `gorm:"type:varchar(255);not null" json:"name"`
+	Description string            `gorm:"type:varchar(500)" json:"description"`
+	Title       string            `gorm:"type:varchar(255)" json:"title"`
+	Content     string            `gorm:"type:longtext" json:"content"`
+	NotebookID  *uint64           `json:"notebook_id"`
+	TagNames    StringSlice       `gorm:"type:json" json:"tag_names"`
+	Variables   TemplateVariables `gorm:"type:json" json:"variables"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (NoteTemplate) TableName() string {
+	return "note_templates"
+}
+
+// 内置占位符
+const (
+	TemplateVarDate     = "date"
+	TemplateVarTime     = "time"
+	TemplateVarDatetime = "datetime"
+	TemplateVarWeekday  = "weekday"
+	TemplateVarNotebook = "notebook"
+)
+
+// BuiltinTemplateVars 内置占位符名称，自定义变量不能与之重名
+var BuiltinTemplateVars = []string{
+	TemplateVarDate, TemplateVarTime, TemplateVarDatetime, TemplateVarWeekday, TemplateVarNotebook,
+}
+
+// TemplateVariable 模板的自定义变量
+type TemplateVariable struct {
+	Name     string `json:"name" binding:"required,max=50"` // 占位符名称，字母、数字和下划线
+	Label    string `json:"label" binding:"max=100"`        // 填写时显示的提示
+	Default  string `json:"default" binding:"max=1000"`     // 未填写时使用的值
+	Required bool   `json:"required"`                       // 是否必须填写
+}
+
+// TemplateVariables 自定义变量列表，以 JSON 数组存入数据库
+type TemplateVariables []TemplateVariable
+
+// Scan 实现 sql.Scanner 接口
+func (v *TemplateVariables) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+// Value 实现 driver.Valuer 接口
+func (v TemplateVariables) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// DefaultNoteTemplates 预置的系统模板
+var DefaultNoteTemplates = []NoteTemplate{
+	{
+		SystemKey:   "daily_standup",
+		Name:        "每日站会",
+		Description: "记录昨天的进展、今天的计划和遇到的阻碍",
+		Title:       "站会 {{date}}",
+		Content:     "## 昨天完成\n\n- \n\n## 今天计划\n\n- \n\n## 阻碍\n\n- 无\n",
+		TagNames:    StringSlice{"站会"},
+	},
+	{
+		SystemKey:   "meeting_notes",
+		Name:        "会议纪要",
+		Description: "会议主题、参会人、讨论要点和待办事项",
+		Title:       "{{topic}} 会议纪要 {{date}}",
+		Content: "# {{topic}}\n\n" +
+			"- 时间：{{datetime}}\n" +
+			"- 参会人：{{attendees}}\n\n" +
+			"## 议程\n\n1. \n\n" +
+			"## 讨论要点\n\n- \n\n" +
+			"## 待办事项\n\n- [ ] \n",
+		TagNames: StringSlice{"会议"},
+		Variables: TemplateVariables{
+			{Name: "topic", Label: "会议主题", Required: true},
+			{Name: "attendees", Label: "参会人"},
+		},
+	},
+	{
+		SystemKey:   "adr",
+		Name:        "架构决策记录（ADR）",
+		Description: "记录一项架构决策的背景、决定和影响",
+		Title:       "ADR-{{number}}: {{decision}}",
+		Content: "# ADR-{{number}}: {{decision}}\n\n" +
+			"- 状态：{{status}}\n" +
+			"- 日期：{{date}}\n\n" +
+			"## 背景\n\n\n\n" +
+			"## 决定\n\n\n\n" +
+			"## 影响\n\n\n",
+		TagNames: StringSlice{"ADR"},
+		Variables: TemplateVariables{
+			{Name: "number", Label: "编号", Required: true},
+			{Name: "decision", Label: "决策标题", Required: true},
+			{Name: "status", Label: "状态", Default: "提议"},
+		},
+	},
+}
+
+// ========== 请求/响应 DTO ==========
+
+// NoteTemplateReq 创建或修改笔记模板请求
+// 用于 POST /api/v1/templates 和 PUT /api/v1/templates/:id，修改时整体替换
+type NoteTemplateReq struct {
+	Name        string             `json:"name" binding:"required,max=255"`         // 模板名称，必填
+	Description string             `json:"description" binding:"max=500"`           // 说明
+	Title       string             `json:"title" binding:"max=255"`                 // 标题模板
+	Content     string             `json:"content"`                                 // 正文模板
+	NotebookID  *uint64            `json:"notebook_id"`                             // 目标笔记本
+	TagNames    []string           `json:"tag_names" binding:"max=20,dive,max=100"` // 默认标签名称
+	Variables   []TemplateVariable `json:"variables" binding:"max=20,dive"`         // 自定义变量
+}
+
+// NoteTemplateListResp 笔记模板列表响应
+// 用于 GET /api/v1/templates，系统模板在前
+type NoteTemplateListResp struct {
+	List []*NoteTemplate `json:"list"`
+}
@@ -7,26 +7,35 @@ import (
 // Notebook 笔记本模型
 // 对应数据库 notebooks 表
 // 笔记本是笔记的容器，用于组织和分类笔记
+// 笔记本可以嵌套，如 工作/项目A/设计，同一父笔记本下名称唯一
 //
 // 字段说明：
 //   - ID: 笔记本唯一标识
 //   - UserID: 所属用户 ID，建立索引加速查询
 //   - Name: 笔记本名称
+//   - ParentID: 父笔记本 ID，为空表示顶层笔记本
 //   - IsDefault: 是否为默认笔记本（不可删除）
 //   - CreatedAt: 创建时间
 //   - UpdatedAt: 更新时间
 //   - NoteCount: 笔记数量（非数据库字段，通过查询计算）
+//   - TotalNoteCount: 含所有子笔记本的笔记数量（非数据库字段）
+//   - Path: 从顶层开始的完整路径，如 工作/项目A/设计（非数据库字段）
+//   - Children: 子笔记本，仅树形列表返回（非数据库字段）
 type Notebook struct {
 	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID    uint64    `gorm:"index;not null" json:"user_id"`
 	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	ParentID  *uint64   `gorm:"index" json:"parent_id"`
 	IsDefault bool      `gorm:"default:false" json:"is_default"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// 关联字段（非数据库字段）
 	// gorm:"-" 表示 GORM 不会将此字段映射到数据库
-	NoteCount int64 `gorm:"-" json:"note_count"`
+	NoteCount      int64       `gorm:"-" json:"note_count"`
+	TotalNoteCount int64       `gorm:"-" json:"total_note_count"`
+	Path           string      `gorm:"-" json:"path"`
+	Children       []*Notebook `gorm:"-" json:"children,omitempty"`
 }
 
 // TableName 指定表名
@@ -39,7 +48,8 @@ func (Notebook) TableName() string {
 // NotebookCreateReq 创建笔记本请求
 // 用于 POST /api/v1/notebooks
 type NotebookCreateReq struct {
-	Name string `json:"name" binding:"required,max=255"` // 笔记本名称，必填，最大 255 字符
+	Name     string  `json:"name" binding:"required,max=255"` // 笔记本名称，必填，最大 255 字符
+	ParentID *uint64 `json:"parent_id"`                       // 父笔记本 ID，为空时创建顶层笔记本
 }
 
 // NotebookUpdateReq 更新笔记本请求
@@ -48,8 +58,39 @@ type NotebookUpdateReq struct {
 	Name string `json:"name" binding:"required,max=255"` // 新的笔记本名称
 }
 
+// NotebookMoveReq 移动笔记本请求
+// 用于 POST /api/v1/notebooks/:id/move，子笔记本随之移动
+type NotebookMoveReq struct {
+	ParentID *uint64 `json:"parent_id"` // 新的父笔记本 ID，为空时移到顶层
+}
+
+// 列表形式
+const (
+	NotebookViewFlat = "flat" // 平铺列表，默认
+	NotebookViewTree = "tree" // 树形，只返回顶层笔记本，子笔记本在 children 中
+)
+
+// NotebookListReq 笔记本列表请求
+// 用于 GET /api/v1/notebooks
+type NotebookListReq struct {
+	View string `form:"view" binding:"omitempty,oneof=flat tree"` // 列表形式
+}
+
+// 删除笔记本时子笔记本的处理方式
+const (
+	NotebookChildrenDelete = "delete" // 一并删除，其中的笔记移入回收站，默认
+	NotebookChildrenLift   = "lift"   // 移到被删除笔记本的父笔记本下
+)
+
+// NotebookDeleteReq 删除笔记本请求
+// 用于 DELETE /api/v1/notebooks/:id
+type NotebookDeleteReq struct {
+	Children string `form:"children" binding:"omitempty,oneof=delete lift"` // 子笔记本的处理方式
+}
+
 // NotebookListResp 笔记本列表响应
 // 用于 GET /api/v1/notebooks
 type NotebookListResp struct {
-	List []*Notebook `json:"list"` // 笔记本列表
+	List          []*Notebook    `json:"list"`           // 笔记本列表，树形时只含顶层笔记本
+	SavedSearches []*SavedSearch `json:"saved_searches"` // 保存的搜索，与笔记本一同展示
 }
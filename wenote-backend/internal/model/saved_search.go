@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+)
+
+// SavedSearch 保存的搜索（智能笔记本）
+// 对应数据库 saved_searches 表
+// 保存一组笔记列表筛选条件，每次打开时按当前数据重新查询
+//
+// 字段说明：
+//   - ID: 唯一标识
+//   - UserID: 所属用户 ID
+//   - Name: 名称
+//   - NotebookID、TagID、IsStarred、IsPinned、Keyword: 与笔记列表的同名筛选条件一致，为空表示不限
+//   - Q: 搜索语句，相对日期（如 updated:>=-30d）在每次查询时换算
+//   - NoteCount: 当前符合条件的笔记数量（非数据库字段，通过查询计算）
+type SavedSearch struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64    `gorm:"index;not null" json:"user_id"`
+	Name       string    `gorm:"type:varchar(255);not null" json:"name"`
+	NotebookID *uint64   `json:"notebook_id"`
+	TagID      *uint64   `json:"tag_id"`
+	IsStarred  *bool     `json:"is_starred"`
+	IsPinned   *bool     `json:"is_pinned"`
+	Keyword    string    `gorm:"type:varchar(255)" json:"keyword"`
+	Q          string    `gorm:"type:varchar(1000)" json:"q"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	NoteCount int64 `gorm:"-" json:"note_count"`
+}
+
+// TableName 指定表名
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
+
+// ListReq 转换为笔记列表请求，分页等展示参数由调用方设置
+func (s *SavedSearch) ListReq() *NoteListReq {
+	return &NoteListReq{
+		NotebookID: s.NotebookID,
+		TagID:      s.TagID,
+		IsStarred:  s.IsStarred,
+		IsPinned:   s.IsPinned,
+		Keyword:    s.Keyword,
+		Q:          s.Q,
+	}
+}
+
+// ========== 请求/响应 DTO ==========
+
+// SavedSearchReq 创建或修改保存的搜索请求
+// 用于 POST /api/v1/saved-searches 和 PUT /api/v1/saved-searches/:id
+// 修改时整体替换筛选条件，未传的条件表示不限
+type SavedSearchReq struct {
+	Name       string  `json:"name" binding:"required,max=255"` // 名称，必填
+	NotebookID *uint64 `json:"notebook_id"`                     // 按笔记本筛选
+	TagID      *uint64 `json:"tag_id"`                          // 按标签筛选
+	IsStarred  *bool   `json:"is_starred"`                      // 只看星标
+	IsPinned   *bool   `json:"is_pinned"`                       // 只看置顶
+	Keyword    string  `json:"keyword" binding:"max=255"`       // 关键词
+	Q          string  `json:"q" binding:"max=1000"`            // 搜索语句
+}
+
+// SavedSearchNotesReq 查看保存的搜索结果请求
+// 用于 GET /api/v1/saved-searches/:id/notes，筛选条件来自保存的搜索
+type SavedSearchNotesReq struct {
+	Page           int    `form:"page,default=1"`
+	PageSize       int    `form:"page_size,default=20"`
+	IncludeContent *bool  `form:"include_content"`
+	Facets         bool   `form:"facets"`
+	Sort           string `form:"sort" binding:"omitempty,oneof=created_at updated_at title relevance"`
+	Order          string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor         string `form:"cursor"`
+}
+
+// SavedSearchListResp 保存的搜索列表响应
+// 用于 GET /api/v1/saved-searches
+type SavedSearchListResp struct {
+	List []*SavedSearch `json:"list"`
+}
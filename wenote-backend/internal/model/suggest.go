@@ -0,0 +1,27 @@
+package model
+
+// 输入联想的结果类型
+const (
+	SuggestTypeNote     = "note"     // 笔记标题
+	SuggestTypeTag      = "tag"      // 标签名
+	SuggestTypeNotebook = "notebook" // 笔记本名
+)
+
+// SuggestReq 输入联想请求
+// 用于 GET /api/v1/search/suggest
+type SuggestReq struct {
+	Prefix string `form:"prefix" binding:"required,max=100"`      // 已输入的文字
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"` // 返回数量，默认 10
+}
+
+// Suggestion 一条联想结果
+type Suggestion struct {
+	Type string `json:"type"` // note、tag 或 notebook
+	ID   uint64 `json:"id"`
+	Text string `json:"text"` // 笔记标题、标签名或笔记本名
+}
+
+// SuggestResp 输入联想响应，名称开头命中的在前
+type SuggestResp struct {
+	List []*Suggestion `json:"list"`
+}
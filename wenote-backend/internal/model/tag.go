@@ -1,12 +1,15 @@
 package model
 
 import (
+	"strings"
 	"time"
+	"unicode"
 )
 
 // Tag 标签模型
 // 对应数据库 tags 表
 // 标签用于对笔记进行分类和标记，支持多对多关联
+// 名称中的 / 表示层级，如 lang/go 是 lang 的子标签，按父标签筛选时包含子标签
 //
 // 字段说明：
 //   - ID: 标签唯一标识
@@ -30,6 +33,36 @@ func (Tag) TableName() string {
 	return "tags"
 }
 
+// TagSeparator 标签名称的层级分隔符
+const TagSeparator = "/"
+
+// NormalizeTagName 规范化标签名称：去掉每一级首尾的空白和空的层级
+// 如 " lang / go/ " 规范化为 "lang/go"，全部为空时返回空字符串
+func NormalizeTagName(name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, TagSeparator) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, TagSeparator)
+}
+
+// TagKey 标签的查重键：转为小写并去掉每一级中的空白和连接符（- _ .）
+// golang、Golang、go-lang 的查重键相同，视为重复标签；C++ 与 C 不同
+func TagKey(name string) string {
+	parts := strings.Split(NormalizeTagName(name), TagSeparator)
+	for i, part := range parts {
+		parts[i] = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || strings.ContainsRune("-_.", r) {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, part)
+	}
+	return strings.Join(parts, TagSeparator)
+}
+
 // NoteTag 笔记-标签关联表模型
 // 对应数据库 note_tags 表
 // 实现笔记和标签的多对多关系
@@ -70,3 +103,25 @@ type TagUpdateReq struct {
 type TagListResp struct {
 	List []*Tag `json:"list"` // 标签列表
 }
+
+// TagMergeReq 合并标签请求
+// 用于 POST /api/v1/tags/merge
+// 源标签的笔记改为关联目标标签，之后删除源标签
+type TagMergeReq struct {
+	TargetID  uint64   `json:"target_id" binding:"required"`                          // 目标标签 ID
+	SourceIDs []uint64 `json:"source_ids" binding:"required,min=1,max=100,dive,gt=0"` // 源标签 ID 列表
+}
+
+// TagDuplicate 一组疑似重复的标签及合并建议
+// 建议将 Sources 合并到 Target，Target 为其中笔记最多的标签
+type TagDuplicate struct {
+	Key     string `json:"key"`     // 查重键
+	Target  *Tag   `json:"target"`  // 建议保留的标签
+	Sources []*Tag `json:"sources"` // 建议合并到 Target 的标签
+}
+
+// TagDuplicateResp 重复标签响应
+// 用于 GET /api/v1/tags/duplicates
+type TagDuplicateResp struct {
+	List []*TagDuplicate `json:"list"`
+}
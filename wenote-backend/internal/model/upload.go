@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// 分片上传会话状态
+const (
+	UploadStatusUploading  = "uploading"  // 接收分片中
+	UploadStatusCompleting = "completing" // 正在合并分片并创建附件
+)
+
+// UploadSession 可续传的分片上传会话
+// 分片按偏移量顺序追加，客户端断线后通过 HEAD 查询已接收的偏移量继续上传
+type UploadSession struct {
+	ID        string    `gorm:"primaryKey;type:char(32)" json:"upload_id"`
+	UserID    uint64    `gorm:"index;not null" json:"-"`
+	NoteID    uint64    `gorm:"not null" json:"note_id"`
+	Filename  string    `gorm:"type:varchar(255);not null" json:"filename"`
+	Size      int64     `gorm:"not null" json:"size"`                    // 文件总大小（字节）
+	Offset    int64     `gorm:"not null;default:0" json:"offset"`        // 已接收的字节数
+	Checksum  string    `gorm:"type:char(64)" json:"checksum,omitempty"` // 整个文件的 SHA-256，完成时校验，可选
+	Status    string    `gorm:"type:varchar(20);not null" json:"status"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"` // 每次追加分片后顺延
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// QuotaReserved 创建会话时已按 Size 占用存储配额，会话结束时释放；新增该字段前创建的会话未占用
+	QuotaReserved bool `gorm:"not null;default:false" json:"-"`
+
+	// MaxChunkSize 单个分片大小上限（字节），返回给客户端用于切分文件
+	MaxChunkSize int64 `gorm:"-" json:"max_chunk_size"`
+}
+
+// TableName 指定表名
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// UploadChunk 已接收的分片，内容保存在附件存储中
+type UploadChunk struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement"`
+	SessionID   string    `gorm:"type:char(32);not null;uniqueIndex:idx_session_offset"`
+	Offset      int64     `gorm:"not null;uniqueIndex:idx_session_offset"`
+	Size        int64     `gorm:"not null"`
+	Checksum    string    `gorm:"type:char(64);not null"` // 分片 SHA-256，合并时校验
+	StoragePath string    `gorm:"type:varchar(500);not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (UploadChunk) TableName() string {
+	return "upload_chunks"
+}
+
+// UploadInitReq 创建分片上传会话请求
+type UploadInitReq struct {
+	Filename string `json:"filename" binding:"required,max=255"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	Checksum string `json:"checksum" binding:"omitempty,len=64,hexadecimal"` // 整个文件的 SHA-256（十六进制）
+}
@@ -4,17 +4,39 @@ import (
 	"time"
 )
 
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username     string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"username"`
-	PasswordHash string    `gorm:"column:password_hash;type:varchar(255);not null" json:"-"`
-	Nickname     string    `gorm:"type:varchar(100)" json:"nickname"`
-	Email        string    `gorm:"type:varchar(255);index" json:"email"`
-	Bio          string    `gorm:"type:text" json:"bio"`
-	AvatarStyle  string    `gorm:"type:varchar(50);default:'cat'" json:"avatar_style"`
-	AvatarColor  string    `gorm:"type:varchar(20);default:'#fbbf24'" json:"avatar_color"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID           uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username     string `gorm:"type:varchar(100);uniqueIndex;not null" json:"username"`
+	PasswordHash string `gorm:"column:password_hash;type:varchar(255);not null" json:"-"`
+	Nickname     string `gorm:"type:varchar(100)" json:"nickname"`
+	Email        string `gorm:"type:varchar(255);index" json:"email"`
+	Bio          string `gorm:"type:text" json:"bio"`
+	AvatarStyle  string `gorm:"type:varchar(50);default:'cat'" json:"avatar_style"`
+	AvatarColor  string `gorm:"type:varchar(20);default:'#fbbf24'" json:"avatar_color"`
+	Tier         string `gorm:"type:varchar(20);default:'free'" json:"tier"` // 用户等级，用于限流策略覆盖
+	Role         string `gorm:"type:varchar(20);default:'user';index" json:"role"`
+
+	// 管理员操作相关
+	IsLocked          bool       `gorm:"default:false" json:"is_locked"` // 是否被管理员锁定
+	LockedReason      string     `gorm:"type:varchar(255)" json:"locked_reason,omitempty"`
+	LockedAt          *time.Time `json:"locked_at,omitempty"`
+	MustResetPassword bool       `gorm:"default:false" json:"must_reset_password"` // 下次登录后必须修改密码
+
+	// 附件存储用量（字节）；StorageQuota 为 0 时使用配置的默认配额
+	StorageUsed  int64 `gorm:"default:0" json:"storage_used"`
+	StorageQuota int64 `gorm:"default:0" json:"storage_quota"`
+
+	// 账号注销：不为 nil 表示已申请注销，到期后由后台任务清除全部数据
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (User) TableName() string {
@@ -32,8 +54,9 @@ type LoginReq struct {
 }
 
 type LoginResp struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token             string `json:"token"`
+	User              *User  `json:"user"`
+	DeletionCancelled bool   `json:"deletion_cancelled,omitempty"` // 本次登录撤销了待执行的账号注销
 }
 
 type UserResp struct {
@@ -63,6 +86,26 @@ type DeleteAccountReq struct {
 	Confirm  string `json:"confirm" binding:"required,eq=DELETE"`
 }
 
+// DeleteAccountResp 注销账号响应
+type DeleteAccountResp struct {
+	Purged      bool       `json:"purged"`                 // 是否已立即清除
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"` // 计划清除时间，期间登录可撤销
+}
+
+// AccountExport 账号数据导出内容，清除账号前写入 data.json
+type AccountExport struct {
+	ExportedAt    time.Time         `json:"exported_at"`
+	User          *User             `json:"user"`
+	Notebooks     []*Notebook       `json:"notebooks"`
+	Notes         []*Note           `json:"notes"` // 含回收站中的笔记
+	Tags          []*Tag            `json:"tags"`
+	Attachments   []*NoteAttachment `json:"attachments"`
+	SavedSearches []*SavedSearch    `json:"saved_searches"`
+	NoteTemplates []*NoteTemplate   `json:"note_templates"`
+	Gamification  *UserGamification `json:"gamification,omitempty"`
+	Achievements  []UserAchievement `json:"achievements"`
+}
+
 // UserProfileResp 用户资料响应（含统计）
 type UserProfileResp struct {
 	ID            uint64    `json:"id"`
@@ -76,4 +119,65 @@ type UserProfileResp struct {
 	TotalNotes    int64     `json:"total_notes"`
 	TotalChars    int64     `json:"total_chars"`
 	CurrentStreak int       `json:"current_streak"`
+	StorageUsed   int64     `json:"storage_used"`  // 已用存储（字节）
+	StorageQuota  int64     `json:"storage_quota"` // 存储配额（字节），0 表示不限制
+}
+
+// ========== 管理后台 DTO ==========
+
+// AdminUserListReq 管理后台用户列表请求
+// 用于 GET /api/v1/admin/users
+type AdminUserListReq struct {
+	Keyword  string `form:"keyword"`              // 按用户名/昵称/邮箱模糊搜索
+	Role     string `form:"role"`                 // 按角色筛选
+	IsLocked *bool  `form:"is_locked"`            // 按锁定状态筛选
+	Page     int    `form:"page,default=1"`       // 页码，默认 1
+	PageSize int    `form:"page_size,default=20"` // 每页数量，默认 20
+}
+
+// AdminUserListResp 管理后台用户列表响应
+type AdminUserListResp struct {
+	Total int64   `json:"total"`
+	List  []*User `json:"list"`
+	Page  int     `json:"page"`
+	Size  int     `json:"size"`
+}
+
+// AdminLockUserReq 锁定用户请求
+type AdminLockUserReq struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// AdminResetPasswordResp 强制重置密码响应
+// 临时密码只在本次响应中返回一次
+type AdminResetPasswordResp struct {
+	TemporaryPassword string `json:"temporary_password"`
+}
+
+// UserUsage 用户资源用量
+type UserUsage struct {
+	UserID          uint64 `json:"user_id"`
+	NoteCount       int64  `json:"note_count"`       // 笔记数（不含回收站）
+	DeletedNotes    int64  `json:"deleted_notes"`    // 回收站笔记数
+	AttachmentCount int64  `json:"attachment_count"` // 附件数
+	StorageBytes    int64  `json:"storage_bytes"`    // 附件占用字节数
+	AIGenerated     int64  `json:"ai_generated"`     // AI 生成成功的笔记数
+	AIFailed        int64  `json:"ai_failed"`        // AI 生成失败的笔记数
+}
+
+// AdminAuditListReq 管理后台全局审计日志请求
+// 用于 GET /api/v1/admin/audit
+type AdminAuditListReq struct {
+	AuditListReq
+	UserID *uint64 `form:"user_id"` // 按用户筛选，不传则查询全部
+}
+
+// AdminCleanupReq 手动触发回收站清理请求
+type AdminCleanupReq struct {
+	Days *int `json:"days" binding:"omitempty,min=1"` // 清理多少天前删除的笔记，至少 1 天，不传使用配置值
+}
+
+// AdminAttachmentGCReq 手动触发附件垃圾回收请求
+type AdminAttachmentGCReq struct {
+	DryRun *bool `json:"dry_run"` // 只生成报告不删除，不传使用配置值
 }
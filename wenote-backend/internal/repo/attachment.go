@@ -1,7 +1,12 @@
 package repo
 
 import (
+	"errors"
+	"time"
 	"wenote-backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AttachmentRepo 附件数据访问层
@@ -27,15 +32,456 @@ func (r *AttachmentRepo) GetByID(id uint64) (*model.NoteAttachment, error) {
 	return &attachment, nil
 }
 
-// GetByNoteID 获取笔记的所有附件
+// GetByNoteID 获取笔记的所有附件（含缩略图）
 func (r *AttachmentRepo) GetByNoteID(noteID uint64) ([]*model.NoteAttachment, error) {
 	var attachments []*model.NoteAttachment
-	err := DB.Where("note_id = ?", noteID).Find(&attachments).Error
+	err := DB.Preload("Variants").Where("note_id = ?", noteID).Find(&attachments).Error
+	return attachments, err
+}
+
+// Delete 删除附件记录及其缩略图记录，并释放对内容文件的引用
+// 内容文件不再被任何附件引用时，在事务提交前调用 release 删除文件，
+// 期间文件记录被锁定，同时上传相同内容的请求会等待，不会引用到正在删除的文件
+func (r *AttachmentRepo) Delete(id uint64, release func(blob *model.AttachmentBlob)) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var attachment model.NoteAttachment
+		if err := tx.First(&attachment, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attachment_id = ?", id).Delete(&model.AttachmentVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attachment_id = ?", id).Delete(&model.AttachmentText{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.NoteAttachment{}, id).Error; err != nil {
+			return err
+		}
+		if attachment.BlobHash == "" {
+			return nil
+		}
+		return releaseBlob(tx, attachment.BlobHash, release)
+	})
+}
+
+// AcquireBlob 引用内容文件，记录不存在时创建，存在时引用计数加一
+func (r *AttachmentRepo) AcquireBlob(blob *model.AttachmentBlob) error {
+	blob.RefCount = 1
+	return DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count + 1"),
+			"updated_at": gorm.Expr("NOW()"),
+		}),
+	}).Create(blob).Error
+}
+
+// ReleaseBlob 释放对内容文件的引用，用于上传失败时回滚 AcquireBlob
+func (r *AttachmentRepo) ReleaseBlob(hash string, release func(blob *model.AttachmentBlob)) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return releaseBlob(tx, hash, release)
+	})
+}
+
+// releaseBlob 在事务内将引用计数减一，归零时删除记录并调用 release
+func releaseBlob(tx *gorm.DB, hash string, release func(blob *model.AttachmentBlob)) error {
+	var blob model.AttachmentBlob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if blob.RefCount > 1 {
+		return tx.Model(&blob).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+	}
+	if err := tx.Delete(&blob).Error; err != nil {
+		return err
+	}
+	if release != nil {
+		release(&blob)
+	}
+	return nil
+}
+
+// orphanBlobCondition 内容记录已无附件引用
+// 不依赖 ref_count：账号清除只扣减计数，按 init.sql 建表时笔记硬删除会级联删除附件而不扣减计数
+const orphanBlobCondition = "NOT EXISTS (SELECT 1 FROM note_attachments WHERE note_attachments.blob_hash = attachment_blobs.hash)"
+
+// ListOrphanBlobs 按哈希升序分批获取已无附件引用且在 before 之前更新的内容记录
+// 上传时引用会刷新 updated_at，保留时间内的记录可能属于尚未写入附件记录的上传
+func (r *AttachmentRepo) ListOrphanBlobs(afterHash string, before time.Time, limit int) ([]*model.AttachmentBlob, error) {
+	var blobs []*model.AttachmentBlob
+	err := DB.Where("hash > ? AND updated_at < ?", afterHash, before).
+		Where(orphanBlobCondition).
+		Order("hash ASC").
+		Limit(limit).
+		Find(&blobs).Error
+	return blobs, err
+}
+
+// DeleteOrphanBlob 删除无引用的内容记录，删除前加锁重新检查，成功删除时调用 release
+func (r *AttachmentRepo) DeleteOrphanBlob(hash string, before time.Time, release func(blob *model.AttachmentBlob)) (bool, error) {
+	deleted := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var blob model.AttachmentBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND updated_at < ?", hash, before).
+			Where(orphanBlobCondition).
+			First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		release(&blob)
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// ListVariantsByBlob 获取引用同一内容的其他附件已生成的缩略图，用于复用
+func (r *AttachmentRepo) ListVariantsByBlob(hash string, excludeAttachmentID uint64) ([]*model.AttachmentVariant, error) {
+	var variants []*model.AttachmentVariant
+	err := DB.Joins("JOIN note_attachments ON note_attachments.id = attachment_variants.attachment_id").
+		Where("note_attachments.blob_hash = ? AND note_attachments.id <> ?", hash, excludeAttachmentID).
+		Find(&variants).Error
+	return variants, err
+}
+
+// ListWithoutBlobAfterID 按 ID 升序分批获取去重前上传的旧附件
+func (r *AttachmentRepo) ListWithoutBlobAfterID(afterID uint64, limit int) ([]*model.NoteAttachment, error) {
+	var attachments []*model.NoteAttachment
+	err := DB.Where("id > ? AND (blob_hash = '' OR blob_hash IS NULL)", afterID).Order("id ASC").Limit(limit).Find(&attachments).Error
+	return attachments, err
+}
+
+// GetBlob 获取内容记录，不存在时返回 nil
+func (r *AttachmentRepo) GetBlob(hash string) (*model.AttachmentBlob, error) {
+	var blob model.AttachmentBlob
+	err := DB.Where("hash = ?", hash).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &blob, err
+}
+
+// SetBlob 将旧附件指向内容文件，附件已被删除或已指向内容文件时返回 gorm.ErrRecordNotFound
+func (r *AttachmentRepo) SetBlob(id uint64, hash, storagePath string) error {
+	result := DB.Model(&model.NoteAttachment{}).
+		Where("id = ? AND (blob_hash = '' OR blob_hash IS NULL)", id).
+		Updates(map[string]interface{}{
+			"blob_hash":    hash,
+			"storage_path": storagePath,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateVariantStorage 更新缩略图的存储位置
+func (r *AttachmentRepo) UpdateVariantStorage(id uint64, storagePath string) error {
+	return DB.Model(&model.AttachmentVariant{}).Where("id = ?", id).Update("storage_path", storagePath).Error
+}
+
+// UpdateVariantStatus 更新缩略图生成状态
+func (r *AttachmentRepo) UpdateVariantStatus(id uint64, status string) error {
+	return DB.Model(&model.NoteAttachment{}).Where("id = ?", id).Update("variant_status", status).Error
+}
+
+// ListIDsByVariantStatus 获取指定缩略图状态且已通过病毒扫描的附件ID
+func (r *AttachmentRepo) ListIDsByVariantStatus(status string, limit int) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.NoteAttachment{}).
+		Where("variant_status = ? AND scan_status = ?", status, model.ScanStatusClean).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SaveVariant 保存缩略图记录，同名缩略图已存在时覆盖
+func (r *AttachmentRepo) SaveVariant(variant *model.AttachmentVariant) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "attachment_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"width", "height", "file_size", "mime_type", "storage_path"}),
+	}).Create(variant).Error
+}
+
+// GetVariant 获取指定尺寸的缩略图，不存在时返回 nil
+func (r *AttachmentRepo) GetVariant(attachmentID uint64, name string) (*model.AttachmentVariant, error) {
+	var variant model.AttachmentVariant
+	err := DB.Where("attachment_id = ? AND name = ?", attachmentID, name).First(&variant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &variant, err
+}
+
+// ListVariants 获取附件的全部缩略图
+func (r *AttachmentRepo) ListVariants(attachmentID uint64) ([]*model.AttachmentVariant, error) {
+	var variants []*model.AttachmentVariant
+	err := DB.Where("attachment_id = ?", attachmentID).Find(&variants).Error
+	return variants, err
+}
+
+// SumByUserID 统计用户的附件数量和占用字节数
+func (r *AttachmentRepo) SumByUserID(userID uint64) (count int64, bytes int64, err error) {
+	var result struct {
+		Count int64
+		Bytes int64
+	}
+	err = DB.Model(&model.NoteAttachment{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("user_id = ?", userID).
+		Scan(&result).Error
+	return result.Count, result.Bytes, err
+}
+
+// ListAfterID 按 ID 升序分批获取全部附件，用于迁移等批处理任务
+func (r *AttachmentRepo) ListAfterID(afterID uint64, limit int) ([]*model.NoteAttachment, error) {
+	var attachments []*model.NoteAttachment
+	err := DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&attachments).Error
 	return attachments, err
 }
 
-// Delete 删除附件记录
-func (r *AttachmentRepo) Delete(id uint64) error {
-	return DB.Delete(&model.NoteAttachment{}, id).Error
+// UpdateStorage 更新附件的存储位置和访问地址
+func (r *AttachmentRepo) UpdateStorage(id uint64, storagePath, url string) error {
+	return DB.Model(&model.NoteAttachment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"storage_path": storagePath,
+		"url":          url,
+	}).Error
+}
+
+// ListOrphansAfterID 按 ID 升序分批获取所属笔记已不存在的附件
+// 笔记被硬删除（回收站清理、清空回收站、批量永久删除）后附件记录会残留
+func (r *AttachmentRepo) ListOrphansAfterID(afterID uint64, limit int) ([]*model.NoteAttachment, error) {
+	var attachments []*model.NoteAttachment
+	err := DB.Where("id > ?", afterID).
+		Where("NOT EXISTS (SELECT 1 FROM notes WHERE notes.id = note_attachments.note_id)").
+		Order("id ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// ListVariantsAfterID 按 ID 升序分批获取全部缩略图
+func (r *AttachmentRepo) ListVariantsAfterID(afterID uint64, limit int) ([]*model.AttachmentVariant, error) {
+	var variants []*model.AttachmentVariant
+	err := DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&variants).Error
+	return variants, err
+}
+
+// DeleteVariant 删除单个缩略图记录
+func (r *AttachmentRepo) DeleteVariant(id uint64) error {
+	return DB.Delete(&model.AttachmentVariant{}, id).Error
+}
+
+// UpdateTextStatus 更新文本提取状态
+func (r *AttachmentRepo) UpdateTextStatus(id uint64, status string) error {
+	return DB.Model(&model.NoteAttachment{}).Where("id = ?", id).Update("text_status", status).Error
 }
 
+// ListIDsByTextStatus 获取指定文本提取状态且已通过病毒扫描的附件ID
+func (r *AttachmentRepo) ListIDsByTextStatus(status string, limit int) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.NoteAttachment{}).
+		Where("text_status = ? AND scan_status = ?", status, model.ScanStatusClean).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SaveText 保存附件的提取文本，已存在时覆盖
+func (r *AttachmentRepo) SaveText(text *model.AttachmentText) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "attachment_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"note_id", "user_id", "content", "source", "updated_at"}),
+	}).Create(text).Error
+}
+
+// GetTextByBlob 获取引用同一内容的其他附件已提取的文本，用于复用，不存在时返回 nil
+func (r *AttachmentRepo) GetTextByBlob(hash string, excludeAttachmentID uint64) (*model.AttachmentText, error) {
+	var text model.AttachmentText
+	err := DB.Joins("JOIN note_attachments ON note_attachments.id = attachment_texts.attachment_id").
+		Where("note_attachments.blob_hash = ? AND note_attachments.id <> ?", hash, excludeAttachmentID).
+		First(&text).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &text, err
+}
+
+// ListTextsByNoteIDs 获取笔记的附件提取文本
+func (r *AttachmentRepo) ListTextsByNoteIDs(noteIDs []uint64) ([]*model.AttachmentText, error) {
+	var texts []*model.AttachmentText
+	err := DB.Where("note_id IN ?", noteIDs).Order("attachment_id ASC").Find(&texts).Error
+	return texts, err
+}
+
+// MatchTexts 获取指定笔记中提取文本命中关键词的附件，按相关性降序
+// 片段截取关键词首次出现位置附近的文本，关键词被分词后无法原样定位时取开头
+func (r *AttachmentRepo) MatchTexts(userID uint64, noteIDs []uint64, keyword string, snippetLen int) ([]*model.AttachmentMatch, error) {
+	var matches []*model.AttachmentMatch
+	if len(noteIDs) == 0 {
+		return matches, nil
+	}
+	err := DB.Table("attachment_texts t").
+		Select("t.attachment_id, t.note_id, a.filename, a.mime_type, "+
+			"SUBSTRING(t.content, GREATEST(LOCATE(?, t.content) - ?, 1), ?) AS snippet",
+			keyword, snippetLen/4, snippetLen).
+		Joins("JOIN note_attachments a ON a.id = t.attachment_id").
+		Where("t.user_id = ? AND t.note_id IN ?", userID, noteIDs).
+		Where("MATCH(t.content) AGAINST(? IN NATURAL LANGUAGE MODE)", keyword).
+		Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "MATCH(t.content) AGAINST(?) DESC",
+			Vars:               []interface{}{keyword},
+			WithoutParentheses: true,
+		}}).
+		Scan(&matches).Error
+	return matches, err
+}
+
+// orphanTextCondition 提取文本对应的附件已不存在
+// 按 init.sql 建表时笔记硬删除会级联删除附件，提取文本会残留
+const orphanTextCondition = "NOT EXISTS (SELECT 1 FROM note_attachments WHERE note_attachments.id = attachment_texts.attachment_id)"
+
+// CountOrphanTexts 统计附件已不存在的提取文本
+func (r *AttachmentRepo) CountOrphanTexts() (int64, error) {
+	var count int64
+	err := DB.Model(&model.AttachmentText{}).Where(orphanTextCondition).Count(&count).Error
+	return count, err
+}
+
+// DeleteOrphanTexts 删除附件已不存在的提取文本
+func (r *AttachmentRepo) DeleteOrphanTexts() (int64, error) {
+	result := DB.Where(orphanTextCondition).Delete(&model.AttachmentText{})
+	return result.RowsAffected, result.Error
+}
+
+// ListIDsByScanStatus 获取指定病毒扫描状态的附件ID
+func (r *AttachmentRepo) ListIDsByScanStatus(status string, limit int) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.NoteAttachment{}).
+		Where("scan_status = ?", status).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// UpdateScanStatus 将附件的扫描状态从 from 切换为 to，返回是否切换成功
+func (r *AttachmentRepo) UpdateScanStatus(id uint64, from, to string) (bool, error) {
+	result := DB.Model(&model.NoteAttachment{}).
+		Where("id = ? AND scan_status = ?", id, from).
+		Update("scan_status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Quarantine 将附件标记为感染并指向隔离区中的文件，返回受影响的附件
+// 去重后的附件与引用同一内容的其他附件一并隔离，同时删除它们的缩略图和提取文本
+// 内容记录的对象键保持不变：再次上传相同内容时照常写入原对象键并重新扫描，
+// 引用释放后由调用方同时删除原对象键和隔离区中的文件
+func (r *AttachmentRepo) Quarantine(attachment *model.NoteAttachment, quarantineKey string) ([]*model.NoteAttachment, error) {
+	var affected []*model.NoteAttachment
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		if attachment.BlobHash != "" {
+			query = query.Where("blob_hash = ?", attachment.BlobHash)
+		} else {
+			query = query.Where("id = ?", attachment.ID)
+		}
+		if err := query.Find(&affected).Error; err != nil {
+			return err
+		}
+		if len(affected) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, len(affected))
+		for i, a := range affected {
+			ids[i] = a.ID
+		}
+		if err := tx.Where("attachment_id IN ?", ids).Delete(&model.AttachmentVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attachment_id IN ?", ids).Delete(&model.AttachmentText{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.NoteAttachment{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"scan_status":    model.ScanStatusInfected,
+			"storage_path":   quarantineKey,
+			"variant_status": "",
+			"text_status":    "",
+		}).Error
+	})
+	return affected, err
+}
+
+// SyncReferences 按正文中引用的附件ID更新笔记附件的引用状态
+// 新被引用的附件清除未引用时间；原本被引用、现在不再被引用的附件从 now 开始计算保留期。
+// 新增字段前的旧附件（未引用且没有未引用时间）不在此处标记，正文中的旧地址可能尚未识别，误标会导致附件被清理
+func (r *AttachmentRepo) SyncReferences(noteID uint64, referencedIDs []uint64, now time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if len(referencedIDs) > 0 {
+			err := tx.Model(&model.NoteAttachment{}).
+				Where("note_id = ? AND id IN ? AND referenced = ?", noteID, referencedIDs, false).
+				Updates(map[string]interface{}{"referenced": true, "unreferenced_at": nil}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		query := tx.Model(&model.NoteAttachment{}).
+			Where("note_id = ? AND referenced = ?", noteID, true)
+		if len(referencedIDs) > 0 {
+			query = query.Where("id NOT IN ?", referencedIDs)
+		}
+		return query.Updates(map[string]interface{}{"referenced": false, "unreferenced_at": now}).Error
+	})
+}
+
+// ListByLegacyPaths 按升级前的访问地址或存储路径查找用户的附件
+func (r *AttachmentRepo) ListByLegacyPaths(userID uint64, urls, storagePaths []string) ([]*model.NoteAttachment, error) {
+	var attachments []*model.NoteAttachment
+	if len(urls) == 0 && len(storagePaths) == 0 {
+		return attachments, nil
+	}
+	err := DB.Where("user_id = ? AND (url IN ? OR storage_path IN ?)", userID, urls, storagePaths).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// ListOwnedIDs 从 ids 中筛选出属于该用户的附件ID
+func (r *AttachmentRepo) ListOwnedIDs(userID uint64, ids []uint64) ([]uint64, error) {
+	var owned []uint64
+	if len(ids) == 0 {
+		return owned, nil
+	}
+	err := DB.Model(&model.NoteAttachment{}).
+		Where("user_id = ? AND id IN ?", userID, ids).
+		Pluck("id", &owned).Error
+	return owned, err
+}
+
+// ListUnreferencedBefore 获取在 before 之前就已不被引用的附件
+func (r *AttachmentRepo) ListUnreferencedBefore(before time.Time, limit int) ([]*model.NoteAttachment, error) {
+	var attachments []*model.NoteAttachment
+	err := DB.Where("referenced = ? AND unreferenced_at < ?", false, before).
+		Order("unreferenced_at ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
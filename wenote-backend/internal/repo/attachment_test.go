@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+)
+
+// TestAttachmentRepoSyncReferencesKeepsLegacy 新增引用字段前上传的附件（未引用且没有未引用时间）
+// 不因正文中没有规范地址而被标记为未引用，否则保留期过后会被清理
+func TestAttachmentRepoSyncReferencesKeepsLegacy(t *testing.T) {
+	openTestDB(t)
+	userID := newTestUserID(t)
+	r := NewAttachmentRepo()
+
+	newAttachment := func(name string, referenced bool, unreferencedAt *time.Time) *model.NoteAttachment {
+		a := &model.NoteAttachment{
+			NoteID:         userID,
+			UserID:         userID,
+			Filename:       name,
+			MimeType:       "image/png",
+			StoragePath:    "uploads/images/user_1/" + name,
+			URL:            "/uploads/images/user_1/" + name,
+			Referenced:     referenced,
+			UnreferencedAt: unreferencedAt,
+		}
+		if err := r.Create(a); err != nil {
+			t.Fatalf("创建附件失败: %v", err)
+		}
+		return a
+	}
+	t.Cleanup(func() { DB.Where("user_id = ?", userID).Delete(&model.NoteAttachment{}) })
+
+	uploadedAt := time.Now().Add(-time.Hour)
+	legacy := newAttachment("legacy.png", false, nil)
+	referenced := newAttachment("referenced.png", true, nil)
+	kept := newAttachment("kept.png", true, nil)
+	pending := newAttachment("pending.png", false, &uploadedAt)
+
+	now := time.Now()
+	if err := r.SyncReferences(userID, []uint64{kept.ID}, now); err != nil {
+		t.Fatalf("SyncReferences 失败: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		id           uint64
+		referenced   bool
+		unreferenced bool
+	}{
+		{"旧附件保持原状", legacy.ID, false, false},
+		{"不再被引用的附件开始计算保留期", referenced.ID, false, true},
+		{"仍被引用的附件", kept.ID, true, false},
+		{"上传后未引用的附件保持上传时间", pending.ID, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := r.GetByID(tt.id)
+			if err != nil {
+				t.Fatalf("读取附件失败: %v", err)
+			}
+			if a.Referenced != tt.referenced || (a.UnreferencedAt != nil) != tt.unreferenced {
+				t.Errorf("referenced = %v, unreferenced_at = %v，期望 %v, %v", a.Referenced, a.UnreferencedAt, tt.referenced, tt.unreferenced)
+			}
+		})
+	}
+
+	// 旧地址可以按 url 或 storage_path 找到附件
+	found, err := r.ListByLegacyPaths(userID, []string{"/uploads/images/user_1/legacy.png"}, []string{"uploads/images/user_1/kept.png"})
+	if err != nil {
+		t.Fatalf("ListByLegacyPaths 失败: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("ListByLegacyPaths 返回 %d 个附件，期望 2", len(found))
+	}
+}
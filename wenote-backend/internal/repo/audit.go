@@ -12,3 +12,50 @@ func NewAuditRepo() *AuditRepo {
 func (r *AuditRepo) Create(log *model.AuditLog) error {
 	return DB.Create(log).Error
 }
+
+// CreateBatch 批量创建审计日志
+func (r *AuditRepo) CreateBatch(logs []*model.AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return DB.Create(&logs).Error
+}
+
+// ListByUserID 分页查询用户的审计日志，按时间倒序
+func (r *AuditRepo) ListByUserID(userID uint64, req *model.AuditListReq) ([]*model.AuditLog, int64, error) {
+	return r.List(&userID, req)
+}
+
+// List 分页查询审计日志，userID 为 nil 时查询全部用户
+func (r *AuditRepo) List(userID *uint64, req *model.AuditListReq) ([]*model.AuditLog, int64, error) {
+	var logs []*model.AuditLog
+	var total int64
+
+	query := DB.Model(&model.AuditLog{})
+
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.ResourceType != "" {
+		query = query.Where("resource_type = ?", req.ResourceType)
+	}
+	if req.ResourceID != nil {
+		query = query.Where("resource_id = ?", *req.ResourceID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	err := query.Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(req.PageSize).
+		Find(&logs).Error
+
+	return logs, total, err
+}
@@ -55,8 +55,16 @@ func autoMigrate() error {
 		&model.User{},
 		&model.Notebook{},
 		&model.Note{},
+		&model.NoteAttachment{},
+		&model.AttachmentVariant{},
+		&model.AttachmentBlob{},
+		&model.AttachmentText{},
+		&model.UploadSession{},
+		&model.UploadChunk{},
 		&model.Tag{},
 		&model.NoteTag{},
+		&model.SavedSearch{},
+		&model.NoteTemplate{},
 		&model.AuditLog{},
 		&model.UserGamification{},
 		&model.Achievement{},
@@ -71,6 +79,11 @@ func autoMigrate() error {
 		return fmt.Errorf("初始化成就数据失败: %w", err)
 	}
 
+	// 初始化系统笔记模板
+	if err := seedNoteTemplates(); err != nil {
+		return fmt.Errorf("初始化笔记模板失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -86,6 +99,18 @@ func seedAchievements() error {
 	return nil
 }
 
+// seedNoteTemplates 初始化系统笔记模板，按 SystemKey 判断是否已存在
+func seedNoteTemplates() error {
+	for _, template := range model.DefaultNoteTemplates {
+		template.IsSystem = true
+		result := DB.Where("is_system = ? AND system_key = ?", true, template.SystemKey).FirstOrCreate(&template)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
 func CloseDB() error {
 	if DB != nil {
 		sqlDB, err := DB.DB()
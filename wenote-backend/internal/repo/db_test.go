@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testDSNEnv 集成测试使用的 MySQL 连接串，如 root:pass@tcp(127.0.0.1:3306)/wenote_test?charset=utf8mb4&parseTime=True&loc=Local
+// 未设置时跳过依赖数据库的测试
+const testDSNEnv = "WENOTE_TEST_MYSQL_DSN"
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+
+	// testUserSeq 每个测试使用独立的用户 ID，测试之间的数据互不影响
+	testUserSeq = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+)
+
+// openTestDB 连接测试库并迁移表结构，设置全局 DB
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("未设置 %s，跳过数据库测试", testDSNEnv)
+	}
+	testDBOnce.Do(func() {
+		DB, testDBErr = gorm.Open(mysql.Open(dsn), &gorm.Config{
+			Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+		})
+		if testDBErr == nil {
+			testDBErr = autoMigrate()
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("初始化测试数据库失败: %v", testDBErr)
+	}
+}
+
+// newTestUserID 分配一个测试用户 ID，测试结束时删除该用户的笔记、标签和笔记本
+func newTestUserID(t *testing.T) uint64 {
+	t.Helper()
+	userID := atomic.AddUint64(&testUserSeq, 1)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM note_tags WHERE note_id IN (SELECT id FROM notes WHERE user_id = ?)", userID)
+		DB.Exec("DELETE FROM notes WHERE user_id = ?", userID)
+		DB.Exec("DELETE FROM tags WHERE user_id = ?", userID)
+		DB.Exec("DELETE FROM notebooks WHERE user_id = ?", userID)
+	})
+	return userID
+}
+
+// openDryRunDB 设置只生成 SQL 不执行的全局 DB，用于不依赖数据库的 SQL 检查
+func openDryRunDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("初始化 DryRun 数据库失败: %v", err)
+	}
+	prev := DB
+	DB = db
+	t.Cleanup(func() { DB = prev })
+}
@@ -2,10 +2,13 @@ package repo
 
 import (
 	"wenote-backend/internal/model"
+	"wenote-backend/pkg/query"
 	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // NoteRepo 笔记数据访问
@@ -80,73 +83,283 @@ func (r *NoteRepo) Restore(id uint64) error {
 	return DB.Model(&model.Note{}).Where("id = ?", id).Update("deleted_at", nil).Error
 }
 
-// List 获取笔记列表
-func (r *NoteRepo) List(userID uint64, req *model.NoteListReq) ([]*model.Note, int64, error) {
-	var notes []*model.Note
+// attachmentTextMatch 笔记的附件提取文本命中关键词
+const attachmentTextMatch = "EXISTS (SELECT 1 FROM attachment_texts t WHERE t.note_id = notes.id AND MATCH(t.content) AGAINST(? IN NATURAL LANGUAGE MODE))"
+
+// attachmentTextScore 笔记附件文本的最高相关性，没有附件文本时为 0
+const attachmentTextScore = "COALESCE((SELECT MAX(MATCH(t.content) AGAINST(?)) FROM attachment_texts t WHERE t.note_id = notes.id), 0)"
+
+// List 获取笔记列表，返回当页笔记、总数和下一页的游标
+// q 为 ?q= 搜索语句解析出的语法树，与其他筛选条件同时生效，为 nil 时不限制
+func (r *NoteRepo) List(userID uint64, req *model.NoteListReq, q query.Node) ([]*model.Note, int64, string, error) {
+	var total int64
+
+	query, err := r.listQuery(userID, req, q)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	// 有关键词搜索时默认按相关性排序；否则默认按更新时间排序，置顶笔记始终在前
+	// 搜索语句中的全文条件与关键词一起参与相关性计算
+	relevance := strings.TrimSpace(req.Keyword + " " + queryRelevanceText(q))
+	sort, order := req.SortOrder(relevance != "")
+	ns, err := newNoteSort(sort, order, req.CursorScope(sort, order), true, relevance)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	// 不返回正文时跳过读取；全文搜索仍需正文生成高亮片段
+	if !req.ContentIncluded() && relevance == "" {
+		query = query.Omit("content")
+	}
+
+	notes, next, err := ns.page(query, req.Cursor, (req.Page-1)*req.PageSize, req.PageSize)
+	return notes, total, next, err
+}
+
+// Facets 统计符合列表筛选条件的笔记按标签和笔记本的分布，各取数量最多的 limit 项
+func (r *NoteRepo) Facets(userID uint64, req *model.NoteListReq, q query.Node, limit int) (tags, notebooks []*model.FacetCount, err error) {
+	matched, err := r.listQuery(userID, req, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = DB.Table("note_tags").
+		Select("tag_id AS id, COUNT(*) AS count").
+		Where("note_id IN (?)", matched.Select("notes.id")).
+		Group("tag_id").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&tags).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matched, err = r.listQuery(userID, req, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = matched.Select("notes.notebook_id AS id, COUNT(*) AS count").
+		Group("notes.notebook_id").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&notebooks).Error
+	return tags, notebooks, err
+}
+
+// Count 统计符合列表筛选条件的笔记数量
+func (r *NoteRepo) Count(userID uint64, req *model.NoteListReq, q query.Node) (int64, error) {
+	query, err := r.listQuery(userID, req, q)
+	if err != nil {
+		return 0, err
+	}
 	var total int64
+	err = query.Count(&total).Error
+	return total, err
+}
+
+// CountEach 按多组筛选条件分别统计笔记数量，一次查询返回全部结果，顺序与 reqs 一致
+// qs[i] 为 reqs[i] 的搜索语句解析出的语法树，可为 nil
+func (r *NoteRepo) CountEach(userID uint64, reqs []*model.NoteListReq, qs []query.Node) ([]int64, error) {
+	counts := make([]int64, len(reqs))
+	if len(reqs) == 0 {
+		return counts, nil
+	}
+
+	query, err := r.countEachQuery(userID, reqs, qs)
+	if err != nil {
+		return nil, err
+	}
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := query.Row().Scan(dest...); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// countEachQuery 每组筛选条件对应一列条件计数
+func (r *NoteRepo) countEachQuery(userID uint64, reqs []*model.NoteListReq, qs []query.Node) (*gorm.DB, error) {
+	columns := make([]string, len(reqs))
+	var args []interface{}
+	for i, req := range reqs {
+		conds, err := listConditions(req, qs[i])
+		if err != nil {
+			return nil, err
+		}
+		where := "1 = 1"
+		if len(conds) > 0 {
+			parts := make([]string, len(conds))
+			for j, cond := range conds {
+				parts[j] = "(" + cond.SQL + ")"
+				args = append(args, cond.Vars...)
+			}
+			where = strings.Join(parts, " AND ")
+		}
+		columns[i] = "COUNT(CASE WHEN " + where + " THEN 1 END)"
+	}
+
+	return DB.Model(&model.Note{}).
+		Clauses(clause.Select{Expression: clause.Expr{SQL: strings.Join(columns, ", "), Vars: args}}).
+		Where("notes.user_id = ? AND notes.deleted_at IS NULL", userID), nil
+}
 
-	query := DB.Model(&model.Note{}).Where("user_id = ? AND deleted_at IS NULL", userID)
+// listQuery 构建笔记列表的筛选条件
+func (r *NoteRepo) listQuery(userID uint64, req *model.NoteListReq, q query.Node) (*gorm.DB, error) {
+	conds, err := listConditions(req, q)
+	if err != nil {
+		return nil, err
+	}
+	query := DB.Model(&model.Note{}).Where("notes.user_id = ? AND notes.deleted_at IS NULL", userID)
+	for _, cond := range conds {
+		query = query.Where(cond.SQL, cond.Vars...)
+	}
+	return query, nil
+}
+
+// listConditions 笔记列表请求中除用户和删除状态以外的筛选条件
+func listConditions(req *model.NoteListReq, q query.Node) ([]clause.Expr, error) {
+	var conds []clause.Expr
+	where := func(sql string, vars ...interface{}) {
+		conds = append(conds, clause.Expr{SQL: sql, Vars: vars})
+	}
 
 	// 笔记本筛选
 	if req.NotebookID != nil {
-		query = query.Where("notebook_id = ?", *req.NotebookID)
+		where("notebook_id = ?", *req.NotebookID)
 	}
 
 	// 星标筛选
 	if req.IsStarred != nil {
-		query = query.Where("is_starred = ?", *req.IsStarred)
+		where("is_starred = ?", *req.IsStarred)
 	}
 
 	// 置顶筛选
 	if req.IsPinned != nil {
-		query = query.Where("is_pinned = ?", *req.IsPinned)
+		where("is_pinned = ?", *req.IsPinned)
 	}
 
 	// 关键词搜索 - 使用MySQL全文搜索
 	if req.Keyword != "" {
 		// 使用全文搜索（IN NATURAL LANGUAGE MODE）
 		// 利用notes表的FULLTEXT INDEX ft_title_content (title, content)
-		query = query.Where(
-			"MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE)",
-			req.Keyword,
+		// 以及attachment_texts表的FULLTEXT INDEX ft_attachment_text，附件文本命中也算命中
+		where(
+			"(MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE) OR "+attachmentTextMatch+")",
+			req.Keyword, req.Keyword,
 		)
 	}
 
-	// 标签筛选
+	// 搜索语句
+	if q != nil {
+		cond, args, err := noteQueryCondition(q)
+		if err != nil {
+			return nil, err
+		}
+		where(cond, args...)
+	}
+
+	// 标签筛选，包含子标签；使用 EXISTS 子查询，笔记命中多个标签时不会重复
 	if req.TagID != nil {
-		query = query.Joins("JOIN note_tags ON notes.id = note_tags.note_id").
-			Where("note_tags.tag_id = ?", *req.TagID)
+		where(tagSubtreeCondition, []uint64{*req.TagID}, model.TagSeparator)
+	}
+	if len(req.TagIDs) > 0 {
+		if req.TagMode == model.TagModeOr {
+			where(tagSubtreeCondition, req.TagIDs, model.TagSeparator)
+		} else {
+			for _, tagID := range req.TagIDs {
+				where(tagSubtreeCondition, []uint64{tagID}, model.TagSeparator)
+			}
+		}
+	}
+	if len(req.ExcludeTagIDs) > 0 {
+		where("NOT "+tagSubtreeCondition, req.ExcludeTagIDs, model.TagSeparator)
+	}
+	if req.Untagged {
+		where(untaggedCondition)
 	}
 
-	// 获取总数
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	return conds, nil
+}
+
+// tagSubtreeCondition 笔记带有任一指定标签或其子标签，参数为标签 ID 列表和层级分隔符
+const tagSubtreeCondition = "EXISTS (SELECT 1 FROM note_tags nt JOIN tags tg ON tg.id = nt.tag_id JOIN tags parent ON parent.id IN ? " +
+	"WHERE nt.note_id = notes.id AND tg.user_id = parent.user_id AND " +
+	"(tg.id = parent.id OR LEFT(tg.name, CHAR_LENGTH(parent.name) + 1) = CONCAT(parent.name, ?)))"
+
+// untaggedCondition 笔记没有任何标签
+const untaggedCondition = "NOT EXISTS (SELECT 1 FROM note_tags nt WHERE nt.note_id = notes.id)"
+
+// ListTitlesByUserID 获取用户全部未删除笔记的 ID 和标题
+func (r *NoteRepo) ListTitlesByUserID(userID uint64) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := DB.Select("id", "title").
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		Find(&notes).Error
+	return notes, err
+}
+
+// ListByIDs 获取指定的未删除笔记（含标签），按 ids 的顺序返回，不存在或已删除的跳过
+func (r *NoteRepo) ListByIDs(ids []uint64) ([]*model.Note, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var notes []*model.Note
+	err := DB.Preload("Tags").
+		Where("id IN ? AND deleted_at IS NULL", ids).
+		Find(&notes).Error
+	if err != nil {
+		return nil, err
 	}
 
-	// 分页查询
-	offset := (req.Page - 1) * req.PageSize
-	
-	// 如果有关键词搜索，按相关性排序；否则按置顶和更新时间排序
-	var err error
-	if req.Keyword != "" {
-		// 全文搜索时按相关性排序，置顶次之
-		// 使用Expr构建MATCH表达式用于排序
-		err = query.Preload("Tags").
-			Order("is_pinned DESC").
-			Order(DB.Raw("MATCH(title, content) AGAINST(?) DESC", req.Keyword)).
-			Offset(offset).
-			Limit(req.PageSize).
-			Find(&notes).Error
-	} else {
-		// 普通查询按置顶和更新时间排序
-		err = query.Preload("Tags").
-			Order("is_pinned DESC, updated_at DESC").
-			Offset(offset).
-			Limit(req.PageSize).
-			Find(&notes).Error
-	}
-
-	return notes, total, err
+	byID := make(map[uint64]*model.Note, len(notes))
+	for _, note := range notes {
+		byID[note.ID] = note
+	}
+	ordered := make([]*model.Note, 0, len(notes))
+	for _, id := range ids {
+		if note, ok := byID[id]; ok {
+			ordered = append(ordered, note)
+		}
+	}
+	return ordered, nil
+}
+
+// ListIDsAfterID 按 ID 顺序分批获取未删除笔记的 ID，用于重建搜索索引
+func (r *NoteRepo) ListIDsAfterID(afterID uint64, limit int) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.Note{}).
+		Where("id > ? AND deleted_at IS NULL", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ListContainingAfterID 按 ID 顺序分批获取正文包含 substr 的笔记（含回收站中的），只读取 ID、用户和正文
+func (r *NoteRepo) ListContainingAfterID(afterID uint64, substr string, limit int) ([]*model.Note, error) {
+	var notes []*model.Note
+	err := DB.Select("id", "user_id", "content").
+		Where("id > ? AND content LIKE ?", afterID, "%"+escapeLike(substr)+"%").
+		Order("id ASC").
+		Limit(limit).
+		Find(&notes).Error
+	return notes, err
+}
+
+// ListIDsByNotebookIDs 获取多个笔记本下未删除笔记的 ID
+func (r *NoteRepo) ListIDsByNotebookIDs(notebookIDs []uint64) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.Note{}).
+		Where("notebook_id IN ? AND deleted_at IS NULL", notebookIDs).
+		Pluck("id", &ids).Error
+	return ids, err
 }
 
 // UpdateAIStatus 更新 AI 任务状态
@@ -178,6 +391,24 @@ func (r *NoteRepo) CountByNotebookID(notebookID uint64) (int64, error) {
 	return count, err
 }
 
+// CountGroupByNotebook 按笔记本统计用户未删除的笔记数量
+func (r *NoteRepo) CountGroupByNotebook(userID uint64) (map[uint64]int64, error) {
+	var rows []*model.FacetCount
+	err := DB.Model(&model.Note{}).
+		Select("notebook_id AS id, COUNT(*) AS count").
+		Where("user_id = ? AND deleted_at IS NULL", userID).
+		Group("notebook_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.Count
+	}
+	return counts, nil
+}
+
 // CountByUserID 统计用户的笔记数量
 func (r *NoteRepo) CountByUserID(userID uint64) (int64, error) {
 	var count int64
@@ -187,6 +418,24 @@ func (r *NoteRepo) CountByUserID(userID uint64) (int64, error) {
 	return count, err
 }
 
+// CountDeletedByUserID 统计用户回收站中的笔记数量
+func (r *NoteRepo) CountDeletedByUserID(userID uint64) (int64, error) {
+	var count int64
+	err := DB.Model(&model.Note{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountByAIStatus 统计用户指定 AI 状态的笔记数量（含回收站）
+func (r *NoteRepo) CountByAIStatus(userID uint64, status model.AIStatus) (int64, error) {
+	var count int64
+	err := DB.Model(&model.Note{}).
+		Where("user_id = ? AND ai_status = ?", userID, status).
+		Count(&count).Error
+	return count, err
+}
+
 // ReplaceNoteTags 替换笔记的所有标签
 func (r *NoteRepo) ReplaceNoteTags(noteID uint64, tagIDs []uint64) error {
 	return DB.Transaction(func(tx *gorm.DB) error {
@@ -218,27 +467,26 @@ func (r *NoteRepo) GetNoteTagIDs(noteID uint64) ([]uint64, error) {
 	return tagIDs, err
 }
 
-// ListDeleted 获取已删除的笔记列表（回收站）
-func (r *NoteRepo) ListDeleted(userID uint64, page, pageSize int) ([]*model.Note, int64, error) {
-	var notes []*model.Note
+// ListDeleted 获取回收站笔记列表，返回当页笔记、总数和下一页的游标
+func (r *NoteRepo) ListDeleted(userID uint64, req *model.NoteTrashReq) ([]*model.Note, int64, string, error) {
 	var total int64
 
 	query := DB.Unscoped().Model(&model.Note{}).Where("user_id = ? AND deleted_at IS NOT NULL", userID)
 
 	// 获取总数
 	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	// 分页查询，按删除时间倒序
-	offset := (page - 1) * pageSize
-	err := query.Preload("Tags").
-		Order("deleted_at DESC").
-		Offset(offset).
-		Limit(pageSize).
-		Find(&notes).Error
+	// 默认按删除时间倒序
+	sort, order := req.SortOrder()
+	ns, err := newNoteSort(sort, order, req.CursorScope(sort, order), false, "")
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-	return notes, total, err
+	notes, next, err := ns.page(query, req.Cursor, (req.Page-1)*req.PageSize, req.PageSize)
+	return notes, total, next, err
 }
 
 // HardDeleteOld 硬删除超过指定天数的软删除笔记
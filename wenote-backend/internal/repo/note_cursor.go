@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"fmt"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/cursor"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// noteSortColumns 可排序的字段对应的列，相关性得分单独计算
+var noteSortColumns = map[string]string{
+	model.NoteSortCreatedAt: "notes.created_at",
+	model.NoteSortUpdatedAt: "notes.updated_at",
+	model.NoteSortDeletedAt: "notes.deleted_at",
+	model.NoteSortTitle:     "notes.title",
+}
+
+// noteCursor 笔记列表的分页游标，记录上一页最后一条笔记的排序值
+type noteCursor struct {
+	Scope  string `json:"q"` // 生成游标的查询的摘要
+	Sort   string `json:"s"`
+	Order  string `json:"o"`
+	Pinned bool   `json:"p,omitempty"`
+	Key    string `json:"k"`
+	ID     uint64 `json:"id"`
+}
+
+// noteSort 笔记列表的排序
+// 依次按置顶（仅笔记列表）、排序字段、ID 排序，ID 保证顺序唯一，游标据此定位下一页的起点
+//
+// 按相关性排序时不生成游标，也不接受游标：得分随其他笔记的增删改变化，
+// 上一页最后一条的得分无法稳定定位下一页的起点，只能按页码翻页
+type noteSort struct {
+	sort   string
+	order  string
+	scope  string        // 查询的摘要，游标只能用于相同的查询
+	pinned bool          // 置顶笔记是否在前
+	expr   string        // 排序字段的 SQL 表达式
+	vars   []interface{} // expr 的参数
+}
+
+// newNoteSort 创建排序，scope 为查询的摘要，relevance 为按相关性排序时参与全文匹配的文本
+func newNoteSort(sort, order, scope string, pinned bool, relevance string) (*noteSort, error) {
+	s := &noteSort{sort: sort, order: order, scope: scope, pinned: pinned}
+	if sort == model.NoteSortRelevance {
+		// 笔记与附件文本的相关性之和
+		s.expr = "MATCH(title, content) AGAINST(?) + " + attachmentTextScore
+		s.vars = []interface{}{relevance, relevance}
+		return s, nil
+	}
+	column, ok := noteSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("不支持的排序字段 %s", sort)
+	}
+	s.expr = column
+	return s, nil
+}
+
+// page 读取一页笔记（含标签），多读一条判断是否还有下一页
+// after 为上一页的游标，为空时从 offset 处开始，兼容按页码翻页
+func (s *noteSort) page(query *gorm.DB, after string, offset, limit int) ([]*model.Note, string, error) {
+	if after != "" {
+		sql, vars, err := s.after(after)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where(sql, vars...)
+	} else if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	// Order 不接受带参数的表达式，使用 clause.Expr 构建排序
+	var notes []*model.Note
+	err := query.Preload("Tags").
+		Clauses(s.orderBy()).
+		Limit(limit + 1).
+		Find(&notes).Error
+	if err != nil || len(notes) <= limit {
+		return notes, "", err
+	}
+
+	notes = notes[:limit]
+	if s.sort == model.NoteSortRelevance {
+		return notes, "", nil
+	}
+	return notes, s.cursorOf(notes[limit-1]), nil
+}
+
+func (s *noteSort) direction() (dir, cmp string) {
+	if s.order == model.SortAsc {
+		return "ASC", ">"
+	}
+	return "DESC", "<"
+}
+
+func (s *noteSort) orderBy() clause.OrderBy {
+	dir, _ := s.direction()
+	sql := s.expr + " " + dir + ", notes.id " + dir
+	if s.pinned {
+		sql = "notes.is_pinned DESC, " + sql
+	}
+	return clause.OrderBy{Expression: clause.Expr{SQL: sql, Vars: s.vars, WithoutParentheses: true}}
+}
+
+// after 排在游标之后的记录条件
+func (s *noteSort) after(after string) (string, []interface{}, error) {
+	var c noteCursor
+	if err := cursor.Decode(after, &c); err != nil {
+		return "", nil, err
+	}
+	// 游标只在相同的查询和排序下有效，按相关性排序时不使用游标
+	if c.Scope != s.scope || c.Sort != s.sort || c.Order != s.order || s.sort == model.NoteSortRelevance {
+		return "", nil, cursor.ErrInvalid
+	}
+	key, err := s.parseKey(c.Key)
+	if err != nil {
+		return "", nil, cursor.ErrInvalid
+	}
+
+	_, cmp := s.direction()
+	sql := fmt.Sprintf("(%s %s ? OR (%s = ? AND notes.id %s ?))", s.expr, cmp, s.expr, cmp)
+	var vars []interface{}
+	vars = append(vars, s.vars...)
+	vars = append(vars, key)
+	vars = append(vars, s.vars...)
+	vars = append(vars, key, c.ID)
+	if s.pinned {
+		sql = "(notes.is_pinned < ? OR (notes.is_pinned = ? AND " + sql + "))"
+		vars = append([]interface{}{c.Pinned, c.Pinned}, vars...)
+	}
+	return sql, vars, nil
+}
+
+func (s *noteSort) parseKey(key string) (interface{}, error) {
+	switch s.sort {
+	case model.NoteSortTitle:
+		return key, nil
+	default:
+		return time.Parse(time.RFC3339Nano, key)
+	}
+}
+
+// cursorOf 以 note 为上一页最后一条生成游标
+func (s *noteSort) cursorOf(note *model.Note) string {
+	c := &noteCursor{Scope: s.scope, Sort: s.sort, Order: s.order, ID: note.ID}
+	if s.pinned {
+		c.Pinned = note.IsPinned
+	}
+
+	switch s.sort {
+	case model.NoteSortCreatedAt:
+		c.Key = note.CreatedAt.Format(time.RFC3339Nano)
+	case model.NoteSortUpdatedAt:
+		c.Key = note.UpdatedAt.Format(time.RFC3339Nano)
+	case model.NoteSortDeletedAt:
+		if note.DeletedAt != nil {
+			c.Key = note.DeletedAt.Format(time.RFC3339Nano)
+		}
+	case model.NoteSortTitle:
+		c.Key = note.Title
+	}
+	return cursor.Encode(c)
+}
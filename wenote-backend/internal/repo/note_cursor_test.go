@@ -0,0 +1,124 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/cursor"
+)
+
+func TestNoteListReqCursorScope(t *testing.T) {
+	notebookID := uint64(3)
+	base := model.NoteListReq{NotebookID: &notebookID, Q: "tag:go", TagIDs: []uint64{1, 2}}
+	scope := base.CursorScope(model.NoteSortUpdatedAt, model.SortDesc)
+
+	same := base
+	same.Page, same.PageSize, same.Cursor, same.Facets = 5, 50, "x", true
+	same.TagIDs = []uint64{2, 1}
+	same.TagMode = model.TagModeAnd
+	if got := same.CursorScope(model.NoteSortUpdatedAt, model.SortDesc); got != scope {
+		t.Error("分页参数、标签顺序和默认的 tag_mode 不应改变摘要")
+	}
+
+	changes := map[string]func(r *model.NoteListReq){
+		"笔记本":  func(r *model.NoteListReq) { other := uint64(4); r.NotebookID = &other },
+		"搜索语句": func(r *model.NoteListReq) { r.Q = "tag:rust" },
+		"关键词":  func(r *model.NoteListReq) { r.Keyword = "周报" },
+		"标签":   func(r *model.NoteListReq) { r.TagIDs = []uint64{1} },
+		"标签组合": func(r *model.NoteListReq) { r.TagMode = model.TagModeOr },
+		"排除标签": func(r *model.NoteListReq) { r.ExcludeTagIDs = []uint64{9} },
+		"没有标签": func(r *model.NoteListReq) { r.Untagged = true },
+		"星标":   func(r *model.NoteListReq) { starred := true; r.IsStarred = &starred },
+		"单个标签": func(r *model.NoteListReq) { tagID := uint64(1); r.TagID = &tagID },
+		"置顶筛选": func(r *model.NoteListReq) { pinned := false; r.IsPinned = &pinned },
+	}
+	for name, change := range changes {
+		req := base
+		change(&req)
+		if req.CursorScope(model.NoteSortUpdatedAt, model.SortDesc) == scope {
+			t.Errorf("修改%s后摘要应改变", name)
+		}
+	}
+
+	if base.CursorScope(model.NoteSortCreatedAt, model.SortDesc) == scope ||
+		base.CursorScope(model.NoteSortUpdatedAt, model.SortAsc) == scope {
+		t.Error("排序字段或方向不同时摘要应改变")
+	}
+}
+
+func TestNoteSortCursorBoundToQuery(t *testing.T) {
+	note := &model.Note{ID: 7, IsPinned: true, UpdatedAt: time.Now()}
+	s, err := newNoteSort(model.NoteSortUpdatedAt, model.SortDesc, "scope-a", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := s.cursorOf(note)
+
+	if _, _, err := s.after(next); err != nil {
+		t.Errorf("相同查询的游标应有效: %v", err)
+	}
+
+	other, _ := newNoteSort(model.NoteSortUpdatedAt, model.SortDesc, "scope-b", true, "")
+	if _, _, err := other.after(next); !errors.Is(err, cursor.ErrInvalid) {
+		t.Errorf("其他查询使用游标应返回 ErrInvalid，实际为 %v", err)
+	}
+
+	asc, _ := newNoteSort(model.NoteSortUpdatedAt, model.SortAsc, "scope-a", true, "")
+	if _, _, err := asc.after(next); !errors.Is(err, cursor.ErrInvalid) {
+		t.Errorf("排序方向不同时应返回 ErrInvalid，实际为 %v", err)
+	}
+
+	if _, _, err := s.after("not-a-cursor"); !errors.Is(err, cursor.ErrInvalid) {
+		t.Errorf("格式错误的游标应返回 ErrInvalid，实际为 %v", err)
+	}
+}
+
+func TestNoteSortRelevanceRejectsCursor(t *testing.T) {
+	s, err := newNoteSort(model.NoteSortRelevance, model.SortDesc, "scope", true, "周报")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := cursor.Encode(&noteCursor{Scope: "scope", Sort: model.NoteSortRelevance, Order: model.SortDesc, Key: "1.5", ID: 7})
+	if _, _, err := s.after(forged); !errors.Is(err, cursor.ErrInvalid) {
+		t.Errorf("按相关性排序时应拒绝游标，实际为 %v", err)
+	}
+}
+
+func TestNoteRepoListCursorPaging(t *testing.T) {
+	openTestDB(t)
+	f := newTagFixture(t)
+	noteRepo := NewNoteRepo()
+
+	req := model.NoteListReq{ExcludeTagIDs: f.tagIDs("personal"), Sort: model.NoteSortTitle, Page: 1, PageSize: 2}
+	var titles []string
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("游标翻页没有结束")
+		}
+		notes, _, next, err := noteRepo.List(f.userID, &req, nil)
+		if err != nil {
+			t.Fatalf("List 失败: %v", err)
+		}
+		for _, note := range notes {
+			titles = append(titles, note.Title)
+		}
+		if next == "" {
+			break
+		}
+		req.Cursor = next
+
+		// 游标不能用于筛选条件不同的查询
+		other := req
+		other.ExcludeTagIDs = f.tagIDs("work")
+		if _, _, _, err := noteRepo.List(f.userID, &other, nil); !errors.Is(err, cursor.ErrInvalid) {
+			t.Errorf("筛选条件不同时应返回 ErrInvalid，实际为 %v", err)
+		}
+	}
+
+	want := "both,project,untagged,work,workshop"
+	if got := strings.Join(titles, ","); got != want {
+		t.Errorf("逐页读取的结果为 %s，期望 %s", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/query"
+)
+
+// noteQueryCondition 将搜索语句的语法树转换为 notes 表上的 SQL 条件
+// 全文条件使用 BOOLEAN MODE 并整体加引号，避免用户输入被当作全文检索运算符
+func noteQueryCondition(n query.Node) (string, []interface{}, error) {
+	switch v := n.(type) {
+	case *query.And:
+		return joinConditions(v.Children, " AND ")
+	case *query.Or:
+		return joinConditions(v.Children, " OR ")
+	case *query.Not:
+		cond, args, err := noteQueryCondition(v.Child)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT " + cond, args, nil
+	case *query.Term:
+		return termCondition(v)
+	default:
+		return "", nil, fmt.Errorf("未知的查询节点 %T", n)
+	}
+}
+
+func joinConditions(children []query.Node, sep string) (string, []interface{}, error) {
+	conds := make([]string, 0, len(children))
+	var args []interface{}
+	for _, child := range children {
+		cond, childArgs, err := noteQueryCondition(child)
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, cond)
+		args = append(args, childArgs...)
+	}
+	return "(" + strings.Join(conds, sep) + ")", args, nil
+}
+
+// termCondition 单个条件对应的 SQL，结果总是带括号，可直接组合
+func termCondition(t *query.Term) (string, []interface{}, error) {
+	switch t.Field {
+	case query.FieldText:
+		against := fulltextPhrase(t.Value)
+		return "(MATCH(notes.title, notes.content) AGAINST(? IN BOOLEAN MODE) OR " +
+				"EXISTS (SELECT 1 FROM attachment_texts t WHERE t.note_id = notes.id AND MATCH(t.content) AGAINST(? IN BOOLEAN MODE)))",
+			[]interface{}{against, against}, nil
+
+	case query.FieldTitle:
+		return "(notes.title LIKE ?)", []interface{}{"%" + escapeLike(t.Value) + "%"}, nil
+
+	case query.FieldTag:
+		// 包含子标签，如 tag:lang 同时匹配 lang/go
+		return "(EXISTS (SELECT 1 FROM note_tags nt JOIN tags tg ON tg.id = nt.tag_id WHERE nt.note_id = notes.id AND (tg.name = ? OR tg.name LIKE ?)))",
+			[]interface{}{t.Value, escapeLike(t.Value) + model.TagSeparator + "%"}, nil
+
+	case query.FieldNotebook:
+		return "(EXISTS (SELECT 1 FROM notebooks nb WHERE nb.id = notes.notebook_id AND nb.name = ?))",
+			[]interface{}{t.Value}, nil
+
+	case query.FieldIs:
+		switch t.Value {
+		case query.IsStarred:
+			return "(notes.is_starred = ?)", []interface{}{true}, nil
+		case query.IsPinned:
+			return "(notes.is_pinned = ?)", []interface{}{true}, nil
+		case query.IsUntagged:
+			return "(" + untaggedCondition + ")", nil, nil
+		}
+
+	case query.FieldCreated:
+		return dateCondition("notes.created_at", t)
+
+	case query.FieldUpdated:
+		return dateCondition("notes.updated_at", t)
+	}
+	return "", nil, fmt.Errorf("不支持的搜索条件 %s:%s", t.Field, t.Value)
+}
+
+// dateCondition 日期条件按整天比较，区间含义见 query.Term.DateBounds
+func dateCondition(column string, t *query.Term) (string, []interface{}, error) {
+	from, to, err := t.DateBounds()
+	if err != nil {
+		return "", nil, err
+	}
+	switch {
+	case from != nil && to != nil:
+		return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{*from, *to}, nil
+	case from != nil:
+		return "(" + column + " >= ?)", []interface{}{*from}, nil
+	default:
+		return "(" + column + " < ?)", []interface{}{*to}, nil
+	}
+}
+
+// fulltextPhrase 将用户输入转换为 BOOLEAN MODE 下的短语，去除其中的引号
+func fulltextPhrase(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, " ") + `"`
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// queryRelevanceText 搜索语句中未被排除的全文条件，用于相关性排序
+func queryRelevanceText(n query.Node) string {
+	terms := query.TextTerms(n)
+	values := make([]string, len(terms))
+	for i, t := range terms {
+		values[i] = t.Value
+	}
+	return strings.Join(values, " ")
+}
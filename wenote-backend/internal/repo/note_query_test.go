@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/query"
+)
+
+const (
+	textSQL = "(MATCH(notes.title, notes.content) AGAINST(? IN BOOLEAN MODE) OR " +
+		"EXISTS (SELECT 1 FROM attachment_texts t WHERE t.note_id = notes.id AND MATCH(t.content) AGAINST(? IN BOOLEAN MODE)))"
+	tagSQL      = "(EXISTS (SELECT 1 FROM note_tags nt JOIN tags tg ON tg.id = nt.tag_id WHERE nt.note_id = notes.id AND (tg.name = ? OR tg.name LIKE ?)))"
+	notebookSQL = "(EXISTS (SELECT 1 FROM notebooks nb WHERE nb.id = notes.notebook_id AND nb.name = ?))"
+)
+
+func TestNoteQueryCondition(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		name string
+		q    string
+		cond string
+		args []interface{}
+	}{
+		{"全文条件整体作为短语", "go", textSQL, []interface{}{`"go"`, `"go"`}},
+		{"全文短语", `"hello world"`, textSQL, []interface{}{`"hello world"`, `"hello world"`}},
+		{"全文中的运算符不生效", "+go*", textSQL, []interface{}{`"+go*"`, `"+go*"`}},
+		{"标题转义通配符", `title:50%_off`, "(notes.title LIKE ?)", []interface{}{`%50\%\_off%`}},
+		{"标签包含子标签", "tag:go", tagSQL, []interface{}{"go", "go/%"}},
+		{"子标签前缀转义通配符", "tag:c_lang", tagSQL, []interface{}{"c_lang", `c\_lang/%`}},
+		{"笔记本", `notebook:"My Work"`, notebookSQL, []interface{}{"My Work"}},
+		{"星标", "is:starred", "(notes.is_starred = ?)", []interface{}{true}},
+		{"置顶", "is:pinned", "(notes.is_pinned = ?)", []interface{}{true}},
+		{"没有标签", "is:untagged", "(" + untaggedCondition + ")", nil},
+		{"日期等于按整天", "created:2026-01-01", "(notes.created_at >= ? AND notes.created_at < ?)", []interface{}{day(1), day(2)}},
+		{"日期大于从次日开始", "updated:>2026-01-01", "(notes.updated_at >= ?)", []interface{}{day(2)}},
+		{"日期大于等于", "updated:>=2026-01-01", "(notes.updated_at >= ?)", []interface{}{day(1)}},
+		{"日期小于", "created:<2026-01-01", "(notes.created_at < ?)", []interface{}{day(1)}},
+		{"日期小于等于包含当天", "created:<=2026-01-01", "(notes.created_at < ?)", []interface{}{day(2)}},
+		{"日期区间", "created:2026-01-01..2026-01-03", "((notes.created_at >= ?) AND (notes.created_at < ?))", []interface{}{day(1), day(4)}},
+		{"AND", "tag:a tag:b", "(" + tagSQL + " AND " + tagSQL + ")", []interface{}{"a", "a/%", "b", "b/%"}},
+		{"OR", "tag:a OR tag:b", "(" + tagSQL + " OR " + tagSQL + ")", []interface{}{"a", "a/%", "b", "b/%"}},
+		{"NOT", "-tag:a", "NOT " + tagSQL, []interface{}{"a", "a/%"}},
+		{
+			"组合按语法树嵌套",
+			"tag:a OR tag:b -is:starred",
+			"((" + tagSQL + " OR " + tagSQL + ") AND NOT (notes.is_starred = ?))",
+			[]interface{}{"a", "a/%", "b", "b/%", true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := query.Parse(tt.q)
+			if err != nil {
+				t.Fatalf("解析 %q 失败: %v", tt.q, err)
+			}
+			cond, args, err := noteQueryCondition(node)
+			if err != nil {
+				t.Fatalf("转换 %q 失败: %v", tt.q, err)
+			}
+			if cond != tt.cond {
+				t.Errorf("条件为\n  %s\n期望\n  %s", cond, tt.cond)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("参数为 %v，期望 %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestNoteQueryConditionUnsupported(t *testing.T) {
+	terms := []*query.Term{
+		{Field: "color", Value: "red"},
+		{Field: query.FieldIs, Value: "draft"},
+		{Field: query.FieldCreated, Op: "!=", Date: time.Now()},
+	}
+	for _, term := range terms {
+		if _, _, err := noteQueryCondition(term); err == nil {
+			t.Errorf("条件 %+v 应返回错误", term)
+		}
+	}
+}
+
+// TestNoteQuerySQL 不依赖数据库，检查搜索条件拼接到笔记查询后的 SQL 和参数顺序
+func TestNoteQuerySQL(t *testing.T) {
+	openDryRunDB(t)
+
+	node, err := query.Parse(`tag:go OR notebook:"工作" -title:draft`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args, err := noteQueryCondition(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notes []*model.Note
+	stmt := DB.Model(&model.Note{}).
+		Where("user_id = ? AND deleted_at IS NULL", 7).
+		Where(cond, args...).
+		Find(&notes).Statement
+
+	// 搜索条件整体加括号，其中的 OR 不会影响 user_id 等前置条件
+	wantSQL := "SELECT * FROM `notes` WHERE (user_id = ? AND deleted_at IS NULL) AND (((" +
+		tagSQL + " OR " + notebookSQL + ") AND NOT (notes.title LIKE ?)))"
+	if got := stmt.SQL.String(); got != wantSQL {
+		t.Errorf("SQL 为\n  %s\n期望\n  %s", got, wantSQL)
+	}
+	wantVars := []interface{}{7, "go", "go/%", "工作", "%draft%"}
+	if !reflect.DeepEqual(stmt.Vars, wantVars) {
+		t.Errorf("参数为 %v，期望 %v", stmt.Vars, wantVars)
+	}
+}
+
+func TestQueryRelevanceText(t *testing.T) {
+	node, err := query.Parse(`go "web api" -java tag:x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queryRelevanceText(node), "go web api"; got != want {
+		t.Errorf("相关性文本为 %q，期望 %q", got, want)
+	}
+	if got := queryRelevanceText(nil); got != "" {
+		t.Errorf("空语法树的相关性文本为 %q，期望空", got)
+	}
+}
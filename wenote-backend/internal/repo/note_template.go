@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"errors"
+	"wenote-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// NoteTemplateRepo 笔记模板数据访问
+type NoteTemplateRepo struct{}
+
+// NewNoteTemplateRepo 创建 NoteTemplateRepo 实例
+func NewNoteTemplateRepo() *NoteTemplateRepo {
+	return &NoteTemplateRepo{}
+}
+
+// Create 创建笔记模板
+func (r *NoteTemplateRepo) Create(template *model.NoteTemplate) error {
+	return DB.Create(template).Error
+}
+
+// GetAvailable 获取用户可用的模板，即用户自己的模板或系统模板
+func (r *NoteTemplateRepo) GetAvailable(id, userID uint64) (*model.NoteTemplate, error) {
+	var template model.NoteTemplate
+	err := DB.Where("id = ? AND (user_id = ? OR is_system = ?)", id, userID, true).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &template, err
+}
+
+// Update 更新笔记模板
+func (r *NoteTemplateRepo) Update(template *model.NoteTemplate) error {
+	return DB.Save(template).Error
+}
+
+// Delete 删除笔记模板
+func (r *NoteTemplateRepo) Delete(id uint64) error {
+	return DB.Delete(&model.NoteTemplate{}, id).Error
+}
+
+// ListAvailable 获取用户可用的模板，系统模板在前，其余按创建时间倒序
+func (r *NoteTemplateRepo) ListAvailable(userID uint64) ([]*model.NoteTemplate, error) {
+	var templates []*model.NoteTemplate
+	err := DB.Where("user_id = ? OR is_system = ?", userID, true).
+		Order("is_system DESC, created_at DESC, id DESC").
+		Find(&templates).Error
+	return templates, err
+}
+
+// ExistsByUserIDAndName 检查用户是否已有同名模板
+func (r *NoteTemplateRepo) ExistsByUserIDAndName(userID uint64, name string, excludeID uint64) (bool, error) {
+	var count int64
+	query := DB.Model(&model.NoteTemplate{}).Where("user_id = ? AND is_system = ? AND name = ?", userID, false, name)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
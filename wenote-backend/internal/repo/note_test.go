@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/query"
+)
+
+// tagFixture 标签筛选测试数据，按名称索引标签和笔记的 ID
+type tagFixture struct {
+	userID uint64
+	tags   map[string]uint64
+	notes  map[string]uint64
+}
+
+// newTagFixture 创建以下标签和笔记：
+//
+//	标签：work、work/project（work 的子标签）、workshop（名称以 work 开头但不是子标签）、personal
+//	笔记：
+//	  work        - work
+//	  project     - work/project
+//	  both        - work、work/project（同一子树命中两次）
+//	  mixed       - work、personal
+//	  personal    - personal
+//	  workshop    - workshop
+//	  untagged    - 无标签
+func newTagFixture(t *testing.T) *tagFixture {
+	t.Helper()
+	f := &tagFixture{
+		userID: newTestUserID(t),
+		tags:   make(map[string]uint64),
+		notes:  make(map[string]uint64),
+	}
+
+	notebook := &model.Notebook{UserID: f.userID, Name: "默认笔记本", IsDefault: true}
+	if err := DB.Create(notebook).Error; err != nil {
+		t.Fatalf("创建笔记本失败: %v", err)
+	}
+
+	for _, name := range []string{"work", "work/project", "workshop", "personal"} {
+		tag := &model.Tag{UserID: f.userID, Name: name}
+		if err := DB.Create(tag).Error; err != nil {
+			t.Fatalf("创建标签 %s 失败: %v", name, err)
+		}
+		f.tags[name] = tag.ID
+	}
+
+	noteRepo := NewNoteRepo()
+	for _, n := range []struct {
+		title string
+		tags  []string
+	}{
+		{"work", []string{"work"}},
+		{"project", []string{"work/project"}},
+		{"both", []string{"work", "work/project"}},
+		{"mixed", []string{"work", "personal"}},
+		{"personal", []string{"personal"}},
+		{"workshop", []string{"workshop"}},
+		{"untagged", nil},
+	} {
+		note := &model.Note{UserID: f.userID, NotebookID: notebook.ID, Title: n.title, AIStatus: model.AIStatusPending}
+		if err := noteRepo.Create(note); err != nil {
+			t.Fatalf("创建笔记 %s 失败: %v", n.title, err)
+		}
+		if err := noteRepo.ReplaceNoteTags(note.ID, f.tagIDs(n.tags...)); err != nil {
+			t.Fatalf("设置笔记 %s 的标签失败: %v", n.title, err)
+		}
+		f.notes[n.title] = note.ID
+	}
+	return f
+}
+
+func (f *tagFixture) tagIDs(names ...string) []uint64 {
+	var ids []uint64
+	for _, name := range names {
+		ids = append(ids, f.tags[name])
+	}
+	return ids
+}
+
+func (f *tagFixture) tagID(name string) *uint64 {
+	id := f.tags[name]
+	return &id
+}
+
+// titles 将笔记 ID 转换为排好序的标题，便于比较
+func (f *tagFixture) titles(ids []uint64) []string {
+	byID := make(map[uint64]string, len(f.notes))
+	for title, id := range f.notes {
+		byID[id] = title
+	}
+	titles := make([]string, 0, len(ids))
+	for _, id := range ids {
+		titles = append(titles, byID[id])
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func TestNoteRepoListTagFilters(t *testing.T) {
+	openTestDB(t)
+	f := newTagFixture(t)
+
+	tests := []struct {
+		name string
+		req  model.NoteListReq
+		want []string
+	}{
+		{
+			name: "单个标签包含子标签",
+			req:  model.NoteListReq{TagID: f.tagID("work")},
+			want: []string{"both", "mixed", "project", "work"},
+		},
+		{
+			name: "子标签不包含父标签",
+			req:  model.NoteListReq{TagID: f.tagID("work/project")},
+			want: []string{"both", "project"},
+		},
+		{
+			name: "同时带有全部标签",
+			req:  model.NoteListReq{TagIDs: f.tagIDs("work", "personal")},
+			want: []string{"mixed"},
+		},
+		{
+			name: "同时带有全部标签时子标签也算父标签",
+			req:  model.NoteListReq{TagIDs: f.tagIDs("work", "work/project"), TagMode: model.TagModeAnd},
+			want: []string{"both", "project"},
+		},
+		{
+			name: "带有任一标签",
+			req:  model.NoteListReq{TagIDs: f.tagIDs("work/project", "personal"), TagMode: model.TagModeOr},
+			want: []string{"both", "mixed", "personal", "project"},
+		},
+		{
+			name: "带有任一标签时命中多个标签的笔记只出现一次",
+			req:  model.NoteListReq{TagIDs: f.tagIDs("work", "work/project", "personal"), TagMode: model.TagModeOr},
+			want: []string{"both", "mixed", "personal", "project", "work"},
+		},
+		{
+			name: "排除标签",
+			req:  model.NoteListReq{ExcludeTagIDs: f.tagIDs("personal")},
+			want: []string{"both", "project", "untagged", "work", "workshop"},
+		},
+		{
+			name: "排除父标签时同时排除子标签",
+			req:  model.NoteListReq{ExcludeTagIDs: f.tagIDs("work")},
+			want: []string{"personal", "untagged", "workshop"},
+		},
+		{
+			name: "标签与排除同时生效",
+			req:  model.NoteListReq{TagIDs: f.tagIDs("work"), ExcludeTagIDs: f.tagIDs("work/project", "personal")},
+			want: []string{"work"},
+		},
+		{
+			name: "没有标签",
+			req:  model.NoteListReq{Untagged: true},
+			want: []string{"untagged"},
+		},
+		{
+			name: "没有标签与标签筛选同时生效时结果为空",
+			req:  model.NoteListReq{Untagged: true, TagIDs: f.tagIDs("work")},
+			want: []string{},
+		},
+	}
+
+	noteRepo := NewNoteRepo()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Page, req.PageSize = 1, 100
+
+			notes, total, _, err := noteRepo.List(f.userID, &req, nil)
+			if err != nil {
+				t.Fatalf("List 失败: %v", err)
+			}
+			ids := make([]uint64, len(notes))
+			for i, note := range notes {
+				ids[i] = note.ID
+			}
+			if got := f.titles(ids); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("List 返回 %v，期望 %v", got, tt.want)
+			}
+			if total != int64(len(tt.want)) {
+				t.Errorf("List 总数为 %d，期望 %d", total, len(tt.want))
+			}
+
+			count, err := noteRepo.Count(f.userID, &req, nil)
+			if err != nil {
+				t.Fatalf("Count 失败: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Errorf("Count 为 %d，期望 %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestNoteRepoListTagFiltersOtherUser(t *testing.T) {
+	openTestDB(t)
+	f := newTagFixture(t)
+
+	// 其他用户的同名子标签不属于当前用户的标签子树
+	otherUserID := newTestUserID(t)
+	other := &model.Tag{UserID: otherUserID, Name: "work/other"}
+	if err := DB.Create(other).Error; err != nil {
+		t.Fatalf("创建标签失败: %v", err)
+	}
+	if err := DB.Create(&model.NoteTag{NoteID: f.notes["untagged"], TagID: other.ID}).Error; err != nil {
+		t.Fatalf("关联标签失败: %v", err)
+	}
+
+	req := model.NoteListReq{TagID: f.tagID("work"), Page: 1, PageSize: 100}
+	count, err := NewNoteRepo().Count(f.userID, &req, nil)
+	if err != nil {
+		t.Fatalf("Count 失败: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Count 为 %d，期望 4", count)
+	}
+}
+
+// TestNoteRepoListQueryTagSQL 不依赖数据库，检查标签筛选生成的 SQL：
+// 每个条件都是针对 notes 的 EXISTS 子查询，外层查询不连接 note_tags，因此计数不会重复
+func TestNoteRepoListQueryTagSQL(t *testing.T) {
+	openDryRunDB(t)
+
+	tests := []struct {
+		name      string
+		req       model.NoteListReq
+		exists    int
+		notExists int
+	}{
+		{name: "单个标签", req: model.NoteListReq{TagID: new(uint64)}, exists: 1},
+		{name: "全部标签", req: model.NoteListReq{TagIDs: []uint64{1, 2, 3}}, exists: 3},
+		{name: "任一标签", req: model.NoteListReq{TagIDs: []uint64{1, 2, 3}, TagMode: model.TagModeOr}, exists: 1},
+		{name: "排除标签", req: model.NoteListReq{ExcludeTagIDs: []uint64{1, 2}}, notExists: 1},
+		{name: "没有标签", req: model.NoteListReq{Untagged: true}, notExists: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := NewNoteRepo().listQuery(1, &tt.req, nil)
+			if err != nil {
+				t.Fatalf("listQuery 失败: %v", err)
+			}
+			var total int64
+			stmt := query.Count(&total).Statement
+			sql := stmt.SQL.String()
+
+			outer := sql
+			if i := strings.Index(sql, " WHERE "); i >= 0 {
+				outer = sql[:i]
+			}
+			if strings.Contains(outer, "JOIN") {
+				t.Errorf("外层查询不应连接其他表: %s", sql)
+			}
+			notExists := strings.Count(sql, "NOT EXISTS")
+			if got := strings.Count(sql, "EXISTS") - notExists; got != tt.exists {
+				t.Errorf("EXISTS 条件有 %d 个，期望 %d: %s", got, tt.exists, sql)
+			}
+			if notExists != tt.notExists {
+				t.Errorf("NOT EXISTS 条件有 %d 个，期望 %d: %s", notExists, tt.notExists, sql)
+			}
+		})
+	}
+}
+
+// TestNoteRepoCountEachSQL 不依赖数据库，检查多组条件合并为一次查询，每组条件一列计数，参数顺序与条件一致
+func TestNoteRepoCountEachSQL(t *testing.T) {
+	openDryRunDB(t)
+
+	notebookID := uint64(7)
+	reqs := []*model.NoteListReq{
+		{NotebookID: &notebookID},
+		{},
+		{Untagged: true, ExcludeTagIDs: []uint64{3}},
+	}
+	db, err := NewNoteRepo().countEachQuery(1, reqs, make([]query.Node, len(reqs)))
+	if err != nil {
+		t.Fatalf("countEachQuery 失败: %v", err)
+	}
+	var rows []map[string]interface{}
+	stmt := db.Find(&rows).Statement
+	sql := stmt.SQL.String()
+
+	if got := strings.Count(sql, "COUNT(CASE WHEN"); got != len(reqs) {
+		t.Errorf("计数列有 %d 个，期望 %d: %s", got, len(reqs), sql)
+	}
+	if !strings.Contains(sql, "COUNT(CASE WHEN 1 = 1 THEN 1 END)") {
+		t.Errorf("没有筛选条件时应统计全部笔记: %s", sql)
+	}
+	if strings.Count(sql, "FROM `notes`") != 1 {
+		t.Errorf("应只查询一次 notes 表: %s", sql)
+	}
+	if len(stmt.Vars) == 0 || stmt.Vars[0] != notebookID || stmt.Vars[len(stmt.Vars)-1] != uint64(1) {
+		t.Errorf("参数顺序不正确: %v", stmt.Vars)
+	}
+}
@@ -3,8 +3,10 @@ package repo
 import (
 	"wenote-backend/internal/model"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // NotebookRepo 笔记本数据访问
@@ -49,6 +51,22 @@ func (r *NotebookRepo) ListByUserID(userID uint64) ([]*model.Notebook, error) {
 	return notebooks, err
 }
 
+// ListByIDs 根据ID列表获取笔记本
+func (r *NotebookRepo) ListByIDs(ids []uint64) ([]*model.Notebook, error) {
+	var notebooks []*model.Notebook
+	err := DB.Where("id IN ?", ids).Find(&notebooks).Error
+	return notebooks, err
+}
+
+// ListIDsByName 获取用户指定名称的笔记本 ID
+func (r *NotebookRepo) ListIDsByName(userID uint64, name string) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.Notebook{}).
+		Where("user_id = ? AND name = ?", userID, name).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
 // CountNotesByNotebookID 统计笔记本下的笔记数量（不含已删除）
 func (r *NotebookRepo) CountNotesByNotebookID(notebookID uint64) (int64, error) {
 	var count int64
@@ -58,10 +76,15 @@ func (r *NotebookRepo) CountNotesByNotebookID(notebookID uint64) (int64, error)
 	return count, err
 }
 
-// ExistsByUserIDAndName 检查用户是否已有同名笔记本
-func (r *NotebookRepo) ExistsByUserIDAndName(userID uint64, name string, excludeID uint64) (bool, error) {
+// ExistsByUserIDAndName 检查父笔记本下是否已有同名笔记本，parentID 为空表示顶层
+func (r *NotebookRepo) ExistsByUserIDAndName(userID uint64, parentID *uint64, name string, excludeID uint64) (bool, error) {
 	var count int64
 	query := DB.Model(&model.Notebook{}).Where("user_id = ? AND name = ?", userID, name)
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
 	if excludeID > 0 {
 		query = query.Where("id != ?", excludeID)
 	}
@@ -69,6 +92,69 @@ func (r *NotebookRepo) ExistsByUserIDAndName(userID uint64, name string, exclude
 	return count > 0, err
 }
 
+// UpdateParent 修改父笔记本，parentID 为空时移到顶层
+func (r *NotebookRepo) UpdateParent(id uint64, parentID *uint64) error {
+	return DB.Model(&model.Notebook{}).Where("id = ?", id).Update("parent_id", parentID).Error
+}
+
+// MoveParent 在同一事务中锁定用户的全部笔记本，check 基于锁定后的层级校验通过后再修改父笔记本
+// 并发的移动依次执行，不会出现两次移动各自通过环检查、合起来却形成环的情况
+func (r *NotebookRepo) MoveParent(userID, id uint64, parentID *uint64, check func(notebooks []*model.Notebook) error) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var notebooks []*model.Notebook
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).
+			Find(&notebooks).Error; err != nil {
+			return err
+		}
+		if err := check(notebooks); err != nil {
+			return err
+		}
+		return tx.Model(&model.Notebook{}).Where("id = ?", id).Update("parent_id", parentID).Error
+	})
+}
+
+// DeleteWithNotes 在同一事务中删除笔记本，并软删除其中的笔记（移入回收站）
+// 以这些笔记本为目标的模板改为不指定笔记本
+func (r *NotebookRepo) DeleteWithNotes(ids []uint64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Note{}).
+			Where("notebook_id IN ? AND deleted_at IS NULL", ids).
+			Update("deleted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NoteTemplate{}).
+			Where("notebook_id IN ?", ids).
+			Update("notebook_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Notebook{}, ids).Error
+	})
+}
+
+// DeleteAndLiftChildren 在同一事务中删除笔记本并软删除其中的笔记，子笔记本移到 parentID 下
+// 以该笔记本为目标的模板改为不指定笔记本
+func (r *NotebookRepo) DeleteAndLiftChildren(id uint64, parentID *uint64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Notebook{}).
+			Where("parent_id = ?", id).
+			Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Note{}).
+			Where("notebook_id = ? AND deleted_at IS NULL", id).
+			Update("deleted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NoteTemplate{}).
+			Where("notebook_id = ?", id).
+			Update("notebook_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Notebook{}, id).Error
+	})
+}
+
 // GetOrCreateDefault 获取或创建默认笔记本
 func (r *NotebookRepo) GetOrCreateDefault(userID uint64) (*model.Notebook, error) {
 	var notebook model.Notebook
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"errors"
+	"wenote-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// SavedSearchRepo 保存的搜索数据访问
+type SavedSearchRepo struct{}
+
+// NewSavedSearchRepo 创建 SavedSearchRepo 实例
+func NewSavedSearchRepo() *SavedSearchRepo {
+	return &SavedSearchRepo{}
+}
+
+// Create 创建保存的搜索
+func (r *SavedSearchRepo) Create(search *model.SavedSearch) error {
+	return DB.Create(search).Error
+}
+
+// GetByIDAndUserID 根据ID和用户ID获取保存的搜索
+func (r *SavedSearchRepo) GetByIDAndUserID(id, userID uint64) (*model.SavedSearch, error) {
+	var search model.SavedSearch
+	err := DB.Where("id = ? AND user_id = ?", id, userID).First(&search).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &search, err
+}
+
+// Update 更新保存的搜索，空的筛选条件同样写入
+func (r *SavedSearchRepo) Update(search *model.SavedSearch) error {
+	return DB.Save(search).Error
+}
+
+// Delete 删除保存的搜索
+func (r *SavedSearchRepo) Delete(id uint64) error {
+	return DB.Delete(&model.SavedSearch{}, id).Error
+}
+
+// ListByUserID 获取用户保存的搜索，按创建时间排列
+func (r *SavedSearchRepo) ListByUserID(userID uint64) ([]*model.SavedSearch, error) {
+	var searches []*model.SavedSearch
+	err := DB.Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&searches).Error
+	return searches, err
+}
+
+// ExistsByUserIDAndName 检查用户是否已有同名的保存的搜索
+func (r *SavedSearchRepo) ExistsByUserIDAndName(userID uint64, name string, excludeID uint64) (bool, error) {
+	var count int64
+	query := DB.Model(&model.SavedSearch{}).Where("user_id = ? AND name = ?", userID, name)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
@@ -63,15 +63,106 @@ func (r *TagRepo) ListByIDs(ids []uint64) ([]*model.Tag, error) {
 	return tags, err
 }
 
-// ExistsByNameAndUserID 检查用户是否已有同名标签
-func (r *TagRepo) ExistsByNameAndUserID(name string, userID uint64) (bool, error) {
-	var count int64
+// ListIDsByName 获取用户指定名称的标签及其子标签的 ID
+func (r *TagRepo) ListIDsByName(userID uint64, name string) ([]uint64, error) {
+	var ids []uint64
 	err := DB.Model(&model.Tag{}).
-		Where("name = ? AND user_id = ?", name, userID).
-		Count(&count).Error
+		Where("user_id = ? AND (name = ? OR name LIKE ?)", userID, name, escapeLike(name)+model.TagSeparator+"%").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ListSubtreeIDs 获取标签及其子标签的 ID，标签不存在时返回空
+func (r *TagRepo) ListSubtreeIDs(userID, tagID uint64) ([]uint64, error) {
+	tag, err := r.GetByIDAndUserID(tagID, userID)
+	if err != nil || tag == nil {
+		return nil, err
+	}
+	return r.ListIDsByName(userID, tag.Name)
+}
+
+// ListDescendants 获取标签的所有子标签（不含自身）
+func (r *TagRepo) ListDescendants(userID uint64, name string) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	err := DB.Where("user_id = ? AND name LIKE ?", userID, escapeLike(name)+model.TagSeparator+"%").
+		Find(&tags).Error
+	return tags, err
+}
+
+// ExistsByNameAndUserID 检查用户是否已有同名标签，excludeIDs 中的标签不计入
+func (r *TagRepo) ExistsByNameAndUserID(name string, userID uint64, excludeIDs ...uint64) (bool, error) {
+	var count int64
+	query := DB.Model(&model.Tag{}).Where("name = ? AND user_id = ?", name, userID)
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+	err := query.Count(&count).Error
 	return count > 0, err
 }
 
+// Rename 在同一事务中保存多个标签的新名称，用于父标签改名时同时修改子标签
+func (r *TagRepo) Rename(tags []*model.Tag) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, tag := range tags {
+			if err := tx.Model(&model.Tag{}).Where("id = ?", tag.ID).Update("name", tag.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountNotesGroupByTag 按标签统计用户的笔记数量
+func (r *TagRepo) CountNotesGroupByTag(userID uint64) (map[uint64]int64, error) {
+	var rows []*model.FacetCount
+	err := DB.Table("note_tags").
+		Select("note_tags.tag_id AS id, COUNT(*) AS count").
+		Joins("JOIN tags ON tags.id = note_tags.tag_id").
+		Where("tags.user_id = ?", userID).
+		Group("note_tags.tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.Count
+	}
+	return counts, nil
+}
+
+// ListNoteIDsByTagIDs 获取关联了任一标签的笔记 ID
+func (r *TagRepo) ListNoteIDsByTagIDs(tagIDs []uint64) ([]uint64, error) {
+	var ids []uint64
+	err := DB.Model(&model.NoteTag{}).
+		Where("tag_id IN ?", tagIDs).
+		Distinct().
+		Pluck("note_id", &ids).Error
+	return ids, err
+}
+
+// Merge 在同一事务中将源标签合并到目标标签
+// 源标签的笔记改为关联目标标签（已关联的不重复），引用源标签的保存的搜索改为目标标签，最后删除源标签
+func (r *TagRepo) Merge(targetID uint64, sourceIDs []uint64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			"INSERT IGNORE INTO note_tags (note_id, tag_id) SELECT DISTINCT note_id, ? FROM note_tags WHERE tag_id IN ?",
+			targetID, sourceIDs,
+		).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id IN ?", sourceIDs).Delete(&model.NoteTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.SavedSearch{}).
+			Where("tag_id IN ?", sourceIDs).
+			Update("tag_id", targetID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Tag{}, sourceIDs).Error
+	})
+}
+
 // CountNotesByTagID 统计标签下的笔记数量
 func (r *TagRepo) CountNotesByTagID(tagID uint64) (int64, error) {
 	var count int64
@@ -80,7 +171,13 @@ func (r *TagRepo) CountNotesByTagID(tagID uint64) (int64, error) {
 }
 
 // GetOrCreate 获取或创建标签
+// 名称先规范化；没有同名标签时，使用查重键相同的已有标签（如已有 golang 时 go-lang 使用 golang），避免产生重复标签
 func (r *TagRepo) GetOrCreate(userID uint64, name string) (*model.Tag, error) {
+	name = model.NormalizeTagName(name)
+	if name == "" {
+		return nil, errors.New("标签名称为空")
+	}
+
 	var tag model.Tag
 	err := DB.Where("user_id = ? AND name = ?", userID, name).First(&tag).Error
 	if err == nil {
@@ -88,6 +185,17 @@ func (r *TagRepo) GetOrCreate(userID uint64, name string) (*model.Tag, error) {
 	}
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tags, err := r.ListByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		key := model.TagKey(name)
+		for _, t := range tags {
+			if key != "" && model.TagKey(t.Name) == key {
+				return t, nil
+			}
+		}
+
 		tag = model.Tag{UserID: userID, Name: name}
 		err = DB.Create(&tag).Error
 		return &tag, err
@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"errors"
+	"time"
+	"wenote-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// UploadRepo 分片上传会话数据访问层
+type UploadRepo struct{}
+
+// NewUploadRepo 创建分片上传仓库实例
+func NewUploadRepo() *UploadRepo {
+	return &UploadRepo{}
+}
+
+// Create 创建上传会话
+func (r *UploadRepo) Create(session *model.UploadSession) error {
+	return DB.Create(session).Error
+}
+
+// GetByIDAndUserID 获取用户的上传会话，不存在时返回 nil
+func (r *UploadRepo) GetByIDAndUserID(id string, userID uint64) (*model.UploadSession, error) {
+	var session model.UploadSession
+	err := DB.Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &session, err
+}
+
+// AppendChunk 保存分片并推进会话偏移量
+// 仅当会话仍在接收分片、未过期且偏移量与 expectedOffset 一致时成功，
+// 同一偏移量的并发请求只有一个会成功，返回是否追加成功
+func (r *UploadRepo) AppendChunk(chunk *model.UploadChunk, expectedOffset int64, expiresAt time.Time) (bool, error) {
+	appended := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.UploadSession{}).
+			Where("id = ? AND status = ? AND `offset` = ? AND expires_at > ?", chunk.SessionID, model.UploadStatusUploading, expectedOffset, time.Now()).
+			Updates(map[string]interface{}{
+				"offset":     gorm.Expr("`offset` + ?", chunk.Size),
+				"expires_at": expiresAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Create(chunk).Error; err != nil {
+			return err
+		}
+		appended = true
+		return nil
+	})
+	return appended, err
+}
+
+// ListChunks 按偏移量升序获取会话的全部分片
+func (r *UploadRepo) ListChunks(sessionID string) ([]*model.UploadChunk, error) {
+	var chunks []*model.UploadChunk
+	err := DB.Where("session_id = ?", sessionID).Order("`offset` ASC").Find(&chunks).Error
+	return chunks, err
+}
+
+// ListChunksByUserID 获取用户全部上传会话的分片，用于清除账号时删除分片文件
+func (r *UploadRepo) ListChunksByUserID(userID uint64) ([]*model.UploadChunk, error) {
+	var chunks []*model.UploadChunk
+	sessionIDs := DB.Model(&model.UploadSession{}).Select("id").Where("user_id = ?", userID)
+	err := DB.Where("session_id IN (?)", sessionIDs).Find(&chunks).Error
+	return chunks, err
+}
+
+// UpdateStatus 将会话从 from 状态切换为 to 状态，返回是否切换成功
+// 用于防止同一会话被重复完成
+func (r *UploadRepo) UpdateStatus(id, from, to string) (bool, error) {
+	result := DB.Model(&model.UploadSession{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Delete 删除会话及其分片记录
+func (r *UploadRepo) Delete(id string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", id).Delete(&model.UploadChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&model.UploadSession{}).Error
+	})
+}
+
+// ListExpired 获取已过期的会话
+func (r *UploadRepo) ListExpired(now time.Time, limit int) ([]*model.UploadSession, error) {
+	var sessions []*model.UploadSession
+	err := DB.Where("expires_at <= ?", now).Order("expires_at ASC").Limit(limit).Find(&sessions).Error
+	return sessions, err
+}
@@ -2,9 +2,11 @@ package repo
 
 import (
 	"errors"
+	"time"
 	"wenote-backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserRepo 用户数据访问层
@@ -52,9 +54,99 @@ func (r *UserRepo) Update(user *model.User) error {
 	return DB.Save(user).Error
 }
 
-// UpdatePassword 更新用户密码
+// UpdatePassword 更新用户密码，同时清除强制改密标记
 func (r *UserRepo) UpdatePassword(userID uint64, passwordHash string) error {
-	return DB.Model(&model.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error
+	return DB.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash":       passwordHash,
+		"must_reset_password": false,
+	}).Error
+}
+
+// ResetPassword 管理员重置密码，并要求用户下次登录后修改
+func (r *UserRepo) ResetPassword(userID uint64, passwordHash string) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash":       passwordHash,
+		"must_reset_password": true,
+	}).Error
+}
+
+// SetLocked 设置用户锁定状态
+func (r *UserRepo) SetLocked(userID uint64, locked bool, reason string) error {
+	fields := map[string]interface{}{
+		"is_locked":     locked,
+		"locked_reason": reason,
+		"locked_at":     nil,
+	}
+	if locked {
+		fields["locked_at"] = time.Now()
+	}
+	return DB.Model(&model.User{}).Where("id = ?", userID).Updates(fields).Error
+}
+
+// List 分页查询用户列表（管理后台）
+func (r *UserRepo) List(req *model.AdminUserListReq) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	query := DB.Model(&model.User{})
+
+	if req.Keyword != "" {
+		like := "%" + req.Keyword + "%"
+		query = query.Where("username LIKE ? OR nickname LIKE ? OR email LIKE ?", like, like, like)
+	}
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+	if req.IsLocked != nil {
+		query = query.Where("is_locked = ?", *req.IsLocked)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (req.Page - 1) * req.PageSize
+	err := query.Order("id DESC").
+		Offset(offset).
+		Limit(req.PageSize).
+		Find(&users).Error
+
+	return users, total, err
+}
+
+// PromoteToAdmin 将指定用户名的用户设为管理员，返回受影响的行数
+func (r *UserRepo) PromoteToAdmin(usernames []string) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	result := DB.Model(&model.User{}).
+		Where("username IN ? AND role <> ?", usernames, model.RoleAdmin).
+		Update("role", model.RoleAdmin)
+	return result.RowsAffected, result.Error
+}
+
+// ReserveStorage 占用存储配额，超出配额时返回 false
+// quota 为 0 表示不限制；通过条件更新保证并发上传时不会超额
+func (r *UserRepo) ReserveStorage(userID uint64, bytes, quota int64) (bool, error) {
+	query := DB.Model(&model.User{}).Where("id = ?", userID)
+	if quota > 0 {
+		query = query.Where("storage_used + ? <= ?", bytes, quota)
+	}
+	result := query.Update("storage_used", gorm.Expr("storage_used + ?", bytes))
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseStorage 释放存储配额
+func (r *UserRepo) ReleaseStorage(userID uint64, bytes int64) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).
+		Update("storage_used", gorm.Expr("GREATEST(storage_used - ?, 0)", bytes)).Error
+}
+
+// RecalculateStorage 按附件记录和未完成分片上传占用的配额重新计算用户的存储用量
+func (r *UserRepo) RecalculateStorage(userID uint64) error {
+	used := DB.Raw("SELECT (SELECT COALESCE(SUM(file_size), 0) FROM note_attachments WHERE user_id = ?) + "+
+		"(SELECT COALESCE(SUM(size), 0) FROM upload_sessions WHERE user_id = ? AND quota_reserved = ?)", userID, userID, true)
+	return DB.Model(&model.User{}).Where("id = ?", userID).Update("storage_used", used).Error
 }
 
 // ExistsByEmail 检查邮箱是否已被其他用户使用
@@ -64,13 +156,118 @@ func (r *UserRepo) ExistsByEmail(email string, excludeUserID uint64) (bool, erro
 	return count > 0, err
 }
 
-// Delete 删除用户及其所有关联数据
-func (r *UserRepo) Delete(userID uint64) error {
-	return DB.Transaction(func(tx *gorm.DB) error {
+// ScheduleDeletion 标记用户在指定时间后注销
+func (r *UserRepo) ScheduleDeletion(userID uint64, at time.Time) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).Update("deletion_scheduled_at", at).Error
+}
+
+// CancelDeletion 撤销待执行的注销
+func (r *UserRepo) CancelDeletion(userID uint64) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).Update("deletion_scheduled_at", nil).Error
+}
+
+// ListDueForDeletion 获取注销宽限期已到期的用户
+func (r *UserRepo) ListDueForDeletion(now time.Time) ([]*model.User, error) {
+	var users []*model.User
+	err := DB.Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Order("deletion_scheduled_at ASC").
+		Find(&users).Error
+	return users, err
+}
+
+// LoadExport 加载用户的全部数据，用于清除前导出
+func (r *UserRepo) LoadExport(userID uint64) (*model.AccountExport, error) {
+	export := &model.AccountExport{ExportedAt: time.Now()}
+
+	user, err := r.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	export.User = user
+
+	if err := DB.Where("user_id = ?", userID).Order("id ASC").Find(&export.Notebooks).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Preload("Tags").Where("user_id = ?", userID).Order("id ASC").Find(&export.Notes).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("user_id = ?", userID).Order("id ASC").Find(&export.Tags).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("user_id = ?", userID).Order("id ASC").Find(&export.Attachments).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("user_id = ?", userID).Order("id ASC").Find(&export.SavedSearches).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("user_id = ? AND is_system = ?", userID, false).Order("id ASC").Find(&export.NoteTemplates).Error; err != nil {
+		return nil, err
+	}
+	if err := DB.Where("user_id = ?", userID).Find(&export.Achievements).Error; err != nil {
+		return nil, err
+	}
+
+	var gamification model.UserGamification
+	err = DB.Where("user_id = ?", userID).First(&gamification).Error
+	if err == nil {
+		export.Gamification = &gamification
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Purge 删除注销宽限期已到期的用户及其所有关联数据（不含存储中的文件和搜索索引）
+// 删除前锁定用户记录并重新检查注销时间，宽限期内重新登录已撤销注销时不删除，返回 false
+func (r *UserRepo) Purge(userID uint64, now time.Time) (bool, error) {
+	purged := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", userID, now).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 删除用户笔记的标签关联
+		noteIDs := tx.Model(&model.Note{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("note_id IN (?)", noteIDs).Delete(&model.NoteTag{}).Error; err != nil {
+			return err
+		}
+		// 删除用户附件的缩略图
+		attachmentIDs := tx.Model(&model.NoteAttachment{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("attachment_id IN (?)", attachmentIDs).Delete(&model.AttachmentVariant{}).Error; err != nil {
+			return err
+		}
+		// 删除用户附件的提取文本
+		if err := tx.Where("user_id = ?", userID).Delete(&model.AttachmentText{}).Error; err != nil {
+			return err
+		}
+		// 释放用户附件对内容文件的引用，共享的文件可能仍被其他用户引用，由附件垃圾回收统一删除
+		refs := tx.Model(&model.NoteAttachment{}).
+			Select("blob_hash, COUNT(*) AS refs").
+			Where("user_id = ? AND blob_hash <> ''", userID).
+			Group("blob_hash")
+		if err := tx.Exec("UPDATE attachment_blobs b JOIN (?) r ON r.blob_hash = b.hash SET b.ref_count = b.ref_count - r.refs, b.updated_at = NOW()", refs).Error; err != nil {
+			return err
+		}
 		// 删除用户的附件
 		if err := tx.Where("user_id = ?", userID).Delete(&model.NoteAttachment{}).Error; err != nil {
 			return err
 		}
+		// 删除用户未完成的分片上传
+		sessionIDs := tx.Model(&model.UploadSession{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&model.UploadChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UploadSession{}).Error; err != nil {
+			return err
+		}
 		// 删除用户的笔记
 		if err := tx.Where("user_id = ?", userID).Delete(&model.Note{}).Error; err != nil {
 			return err
@@ -83,6 +280,14 @@ func (r *UserRepo) Delete(userID uint64) error {
 		if err := tx.Where("user_id = ?", userID).Delete(&model.Tag{}).Error; err != nil {
 			return err
 		}
+		// 删除用户保存的搜索
+		if err := tx.Where("user_id = ?", userID).Delete(&model.SavedSearch{}).Error; err != nil {
+			return err
+		}
+		// 删除用户的笔记模板，系统模板不属于任何用户
+		if err := tx.Where("user_id = ? AND is_system = ?", userID, false).Delete(&model.NoteTemplate{}).Error; err != nil {
+			return err
+		}
 		// 删除用户的游戏化数据
 		if err := tx.Where("user_id = ?", userID).Delete(&model.UserGamification{}).Error; err != nil {
 			return err
@@ -91,10 +296,16 @@ func (r *UserRepo) Delete(userID uint64) error {
 		if err := tx.Where("user_id = ?", userID).Delete(&model.UserAchievement{}).Error; err != nil {
 			return err
 		}
+		// 删除用户的审计日志
+		if err := tx.Where("user_id = ?", userID).Delete(&model.AuditLog{}).Error; err != nil {
+			return err
+		}
 		// 最后删除用户
 		if err := tx.Delete(&model.User{}, userID).Error; err != nil {
 			return err
 		}
+		purged = true
 		return nil
 	})
+	return purged, err
 }
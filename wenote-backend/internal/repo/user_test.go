@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+)
+
+// TestUserRepoPurgeRechecksSchedule 清除前重新检查注销时间：读取到期列表后撤销注销的账号不删除
+func TestUserRepoPurgeRechecksSchedule(t *testing.T) {
+	openTestDB(t)
+	r := NewUserRepo()
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		scheduled *time.Time
+		want      bool
+	}{
+		{"注销已到期", &past, true},
+		{"宽限期内已撤销注销", nil, false},
+		{"注销尚未到期", &future, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{
+				Username:            fmt.Sprintf("purge_%d_%d", now.UnixNano(), i),
+				PasswordHash:        "x",
+				DeletionScheduledAt: tt.scheduled,
+			}
+			if err := DB.Create(user).Error; err != nil {
+				t.Fatalf("创建用户失败: %v", err)
+			}
+			t.Cleanup(func() { DB.Delete(&model.User{}, user.ID) })
+
+			purged, err := r.Purge(user.ID, now)
+			if err != nil {
+				t.Fatalf("Purge 失败: %v", err)
+			}
+			if purged != tt.want {
+				t.Errorf("Purge = %v，期望 %v", purged, tt.want)
+			}
+			got, err := r.GetByID(user.ID)
+			if err != nil {
+				t.Fatalf("读取用户失败: %v", err)
+			}
+			if (got == nil) != tt.want {
+				t.Errorf("清除后用户仍存在 = %v，期望 %v", got != nil, !tt.want)
+			}
+		})
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"wenote-backend/config"
 	"wenote-backend/internal/handler"
 	"wenote-backend/internal/middleware"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
+func SetupRouter(limitStore ratelimit.Store) *gin.Engine {
 	gin.SetMode(config.GlobalConfig.Server.Mode)
 
 	r := gin.New()
@@ -16,10 +18,7 @@ func SetupRouter() *gin.Engine {
 	r.Use(gin.Recovery())
 	r.Use(middleware.Logger())
 	r.Use(middleware.CORS())
-	r.Use(middleware.RateLimiter())
-
-	// 静态文件服务（图片等）
-	r.Static("/uploads", "./uploads")
+	r.Use(middleware.RateLimiter(limitStore))
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -32,13 +31,38 @@ func SetupRouter() *gin.Engine {
 	{
 		authHandler := handler.NewAuthHandler()
 		auth := v1.Group("/auth")
+		auth.Use(middleware.RateLimit(limitStore, "auth"))
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 		}
 
+		// 附件下载：接受 JWT 或签名地址，<img> 标签无法携带 Authorization 头
+		attachmentHandler := handler.NewAttachmentHandler()
+		attachmentContent := v1.Group("/attachments")
+		attachmentContent.Use(middleware.OptionalJWTAuth())
+		attachmentContent.Use(middleware.OptionalActiveUser())
+		attachmentContent.Use(middleware.RateLimitByMethod(limitStore, "read", "write"))
+		{
+			attachmentContent.GET("/:id/content", attachmentHandler.Download)
+			attachmentContent.HEAD("/:id/content", attachmentHandler.Download)
+		}
+
+		// 升级前正文中的静态文件地址，按存储路径找到附件后同样校验所有权和扫描状态
+		legacyUploads := r.Group("/uploads")
+		legacyUploads.Use(middleware.JWTAuth())
+		legacyUploads.Use(middleware.ActiveUser())
+		legacyUploads.Use(middleware.RateLimitByMethod(limitStore, "read", "write"))
+		{
+			legacyUploads.GET("/*path", attachmentHandler.LegacyDownload)
+			legacyUploads.HEAD("/*path", attachmentHandler.LegacyDownload)
+		}
+
 		authorized := v1.Group("")
+		// 限流必须在认证之后，才能拿到 userID 和用户等级
 		authorized.Use(middleware.JWTAuth())
+		authorized.Use(middleware.ActiveUser())
+		authorized.Use(middleware.RateLimitByMethod(limitStore, "read", "write"))
 		{
 			// 令牌刷新（需要认证）
 			authorized.POST("/auth/refresh", authHandler.RefreshToken)
@@ -49,6 +73,7 @@ func SetupRouter() *gin.Engine {
 				users.PATCH("/me", userHandler.UpdateProfile)
 				users.POST("/me/password", userHandler.ChangePassword)
 				users.DELETE("/me", userHandler.DeleteAccount)
+				users.GET("/me/audit", userHandler.ListAudit)
 			}
 
 			notebookHandler := handler.NewNotebookHandler()
@@ -60,9 +85,34 @@ func SetupRouter() *gin.Engine {
 				notebooks.GET("/:id", notebookHandler.GetByID)
 				notebooks.PATCH("/:id", notebookHandler.Update)
 				notebooks.DELETE("/:id", notebookHandler.Delete)
+				notebooks.POST("/:id/move", notebookHandler.Move)
+			}
+
+			// 保存的搜索（智能笔记本）路由
+			savedSearchHandler := handler.NewSavedSearchHandler()
+			savedSearches := authorized.Group("/saved-searches")
+			{
+				savedSearches.GET("", savedSearchHandler.List)
+				savedSearches.POST("", savedSearchHandler.Create)
+				savedSearches.GET("/:id", savedSearchHandler.GetByID)
+				savedSearches.PUT("/:id", savedSearchHandler.Update)
+				savedSearches.DELETE("/:id", savedSearchHandler.Delete)
+				savedSearches.GET("/:id/notes", savedSearchHandler.ListNotes)
+			}
+
+			// 笔记模板路由
+			noteTemplateHandler := handler.NewNoteTemplateHandler()
+			templates := authorized.Group("/templates")
+			{
+				templates.GET("", noteTemplateHandler.List)
+				templates.POST("", noteTemplateHandler.Create)
+				templates.GET("/:id", noteTemplateHandler.GetByID)
+				templates.PUT("/:id", noteTemplateHandler.Update)
+				templates.DELETE("/:id", noteTemplateHandler.Delete)
 			}
 
 			noteHandler := handler.NewNoteHandler()
+			uploadHandler := handler.NewUploadHandler()
 			notes := authorized.Group("/notes")
 			{
 				notes.GET("", noteHandler.List)
@@ -74,32 +124,48 @@ func SetupRouter() *gin.Engine {
 				notes.POST("/:id/restore", noteHandler.Restore)
 				notes.PUT("/:id/tags", noteHandler.UpdateTags)
 				notes.PUT("/:id/tags/apply-suggestions", noteHandler.ApplySuggestedTags)
-				notes.POST("/:id/ai/generate", noteHandler.GenerateSummaryAndTags)
+				notes.POST("/:id/ai/generate", middleware.RateLimit(limitStore, "ai"), noteHandler.GenerateSummaryAndTags)
 				notes.POST("/batch/delete", noteHandler.BatchDelete)
 				notes.POST("/batch/restore", noteHandler.BatchRestore)
 				notes.POST("/batch/move", noteHandler.BatchMove)
 				notes.DELETE("/trash", noteHandler.EmptyTrash)
 				// 附件相关路由
-				notes.POST("/:id/attachments", handler.NewAttachmentHandler().UploadImage)
+				notes.POST("/:id/attachments", handler.NewAttachmentHandler().Upload)
 				notes.GET("/:id/attachments", handler.NewAttachmentHandler().GetAttachments)
+				notes.POST("/:id/uploads", uploadHandler.Init)
 				}
 
 			// 附件删除路由
-			attachmentHandler := handler.NewAttachmentHandler()
 			attachments := authorized.Group("/attachments")
 			{
 				attachments.DELETE("/:id", attachmentHandler.DeleteAttachment)
 			}
 
+			// 大文件分片续传路由
+			uploads := authorized.Group("/uploads")
+			{
+				uploads.GET("/:id", uploadHandler.Status)
+				uploads.HEAD("/:id", uploadHandler.Status)
+				uploads.PATCH("/:id", uploadHandler.Append)
+				uploads.POST("/:id/complete", uploadHandler.Complete)
+				uploads.DELETE("/:id", uploadHandler.Abort)
+			}
+
 		tagHandler := handler.NewTagHandler()
 		tags := authorized.Group("/tags")
 		{
 			tags.GET("", tagHandler.List)
 			tags.POST("", tagHandler.Create)
+			tags.GET("/duplicates", tagHandler.Duplicates)
+			tags.POST("/merge", tagHandler.Merge)
 			tags.PATCH("/:id", tagHandler.Update)
 			tags.DELETE("/:id", tagHandler.Delete)
 		}
 
+			// 搜索输入联想，每次按键都会调用
+			suggestHandler := handler.NewSuggestHandler()
+			authorized.GET("/search/suggest", suggestHandler.Suggest)
+
 			// 统计数据路由
 			statsHandler := handler.NewStatsHandler()
 			stats := authorized.Group("/stats")
@@ -120,6 +186,23 @@ func SetupRouter() *gin.Engine {
 				gamification.GET("/report", gamificationHandler.GetReport)
 				gamification.POST("/achievements/:id/notify", gamificationHandler.MarkAchievementNotified)
 			}
+
+			// 管理后台路由
+			adminHandler := handler.NewAdminHandler()
+			admin := authorized.Group("/admin")
+			admin.Use(middleware.RequireRole(model.RoleAdmin))
+			{
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/users/:id/usage", adminHandler.GetUsage)
+				admin.POST("/users/:id/lock", adminHandler.LockUser)
+				admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+				admin.POST("/users/:id/reset-password", adminHandler.ResetPassword)
+				admin.GET("/audit", adminHandler.ListAudit)
+				admin.POST("/maintenance/cleanup-trash", adminHandler.CleanupTrash)
+				admin.POST("/maintenance/attachment-gc", adminHandler.CollectAttachmentGarbage)
+				admin.GET("/maintenance/attachment-gc", adminHandler.GetAttachmentGCReport)
+				admin.GET("/ai/breaker", adminHandler.GetAIBreaker)
+			}
 	}
 }
 
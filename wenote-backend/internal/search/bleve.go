@@ -0,0 +1,668 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/cursor"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/query"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/mapping"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+	bq "github.com/blevesearch/bleve/v2/search/query"
+)
+
+// syncBatchSize 写入索引时每批的笔记数
+const syncBatchSize = 200
+
+// 全文字段及其权重，标题命中的得分高于正文和附件文本
+var textFields = []struct {
+	name  string
+	boost float64
+}{
+	{"title", 3},
+	{"content", 1},
+	{"attachments", 0.5},
+}
+
+// document 索引中的笔记，ID 作为文档 ID，字段只建索引不存储原文
+type document struct {
+	UserID      string    `json:"user_id"`
+	NotebookID  string    `json:"notebook_id"`
+	TagIDs      []string  `json:"tag_ids"`
+	Untagged    bool      `json:"untagged"` // 没有标签，用于 is:untagged，此字段加入前建立的索引需重建
+	Title       string    `json:"title"`
+	TitleSort   string    `json:"title_sort"` // 小写的完整标题，用于按标题排序，此字段加入前建立的索引需重建
+	Content     string    `json:"content"`
+	Attachments string    `json:"attachments"` // 附件提取文本
+	IsPinned    bool      `json:"is_pinned"`
+	IsStarred   bool      `json:"is_starred"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Bleve 基于内嵌 bleve 索引的搜索
+// 文本使用 cjk 分析器（中日韩文字按二元组切分，英文按单词小写），
+// 标签和笔记本按 ID 建索引，改名无需重建
+type Bleve struct {
+	mu        sync.RWMutex
+	index     bleve.Index
+	path      string
+	fuzziness int
+	facetSize int
+
+	noteRepo       *repo.NoteRepo
+	tagRepo        *repo.TagRepo
+	notebookRepo   *repo.NotebookRepo
+	attachmentRepo *repo.AttachmentRepo
+}
+
+// OpenBleve 打开索引目录，不存在时创建空索引
+// 索引同一时间只能被一个进程打开，被占用时等待数秒后返回错误
+func OpenBleve(path string, fuzziness, facetSize int) (*Bleve, error) {
+	index, err := bleve.OpenUsing(path, map[string]interface{}{"bolt_timeout": "5s"})
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = newBleveIndex(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开搜索索引失败: %w", err)
+	}
+	return &Bleve{
+		index:          index,
+		path:           path,
+		fuzziness:      fuzziness,
+		facetSize:      facetSize,
+		noteRepo:       repo.NewNoteRepo(),
+		tagRepo:        repo.NewTagRepo(),
+		notebookRepo:   repo.NewNotebookRepo(),
+		attachmentRepo: repo.NewAttachmentRepo(),
+	}, nil
+}
+
+func newBleveIndex(path string) (bleve.Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return bleve.New(path, indexMapping())
+}
+
+// indexMapping 索引结构，未声明的字段不建索引
+func indexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = cjk.AnalyzerName
+	text.Store = false
+	text.IncludeInAll = false
+
+	keyword := bleve.NewKeywordFieldMapping()
+	keyword.Store = false
+	keyword.IncludeInAll = false
+
+	boolean := bleve.NewBooleanFieldMapping()
+	boolean.Store = false
+	boolean.IncludeInAll = false
+
+	datetime := bleve.NewDateTimeFieldMapping()
+	datetime.Store = false
+	datetime.IncludeInAll = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	for _, f := range textFields {
+		doc.AddFieldMappingsAt(f.name, text)
+	}
+	doc.AddFieldMappingsAt("user_id", keyword)
+	doc.AddFieldMappingsAt("notebook_id", keyword)
+	doc.AddFieldMappingsAt("tag_ids", keyword)
+	doc.AddFieldMappingsAt("title_sort", keyword)
+	doc.AddFieldMappingsAt("is_pinned", boolean)
+	doc.AddFieldMappingsAt("is_starred", boolean)
+	doc.AddFieldMappingsAt("untagged", boolean)
+	doc.AddFieldMappingsAt("created_at", datetime)
+	doc.AddFieldMappingsAt("updated_at", datetime)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = cjk.AnalyzerName
+	m.StoreDynamic = false
+	m.IndexDynamic = false
+	m.DocValuesDynamic = false
+	return m
+}
+
+// Name 后端名称
+func (b *Bleve) Name() string {
+	return EngineBleve
+}
+
+// bleveCursor 分页游标，After 为上一页最后一条结果的排序值
+// 与 MySQL 后端一样，游标只能用于摘要 Scope 相同的查询，按相关性排序时不使用游标
+type bleveCursor struct {
+	Scope string   `json:"q"`
+	Sort  string   `json:"s"`
+	Order string   `json:"o"`
+	After []string `json:"a"`
+}
+
+// bleveSortFields 排序字段对应的索引字段
+var bleveSortFields = map[string]string{
+	model.NoteSortCreatedAt: "created_at",
+	model.NoteSortUpdatedAt: "updated_at",
+	model.NoteSortTitle:     "title_sort",
+	model.NoteSortRelevance: "_score",
+}
+
+// Search 在索引中查出一页笔记 ID，再从数据库读取笔记
+// 置顶笔记优先，其次按请求的字段排序，默认按相关性
+func (b *Bleve) Search(ctx context.Context, userID uint64, req *model.NoteListReq, q query.Node) (*Result, error) {
+	res, next, err := b.search(ctx, userID, req, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		if id, err := strconv.ParseUint(hit.ID, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	notes, err := b.noteRepo.ListByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[uint64]bool, len(notes))
+	owned := notes[:0]
+	for _, note := range notes {
+		found[note.ID] = true
+		if note.UserID == userID {
+			owned = append(owned, note)
+		}
+	}
+	// 索引落后于数据库时（如漏掉了删除事件），从索引中移除已不存在的笔记
+	var stale []uint64
+	for _, id := range ids {
+		if !found[id] {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		go func() {
+			if err := b.Sync(context.Background(), stale...); err != nil {
+				logger.Warn("清理过期索引失败", "note_ids", stale, "error", err)
+			}
+		}()
+	}
+
+	result := &Result{Notes: owned, Total: int64(res.Total), NextCursor: next}
+	if req.Facets {
+		tags := termFacets(res.Facets["tags"])
+		notebooks := termFacets(res.Facets["notebooks"])
+		if result.Facets, err = newFacets(userID, tags, notebooks); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// search 按请求构建查询并执行，返回一页命中的文档 ID 和下一页的游标
+func (b *Bleve) search(ctx context.Context, userID uint64, req *model.NoteListReq, q query.Node) (*bleve.SearchResult, string, error) {
+	must := []bq.Query{termQuery("user_id", userID)}
+	if req.Keyword != "" {
+		must = append(must, b.textQuery(req.Keyword, false))
+	}
+	if q != nil {
+		cond, err := b.translate(userID, q)
+		if err != nil {
+			return nil, "", err
+		}
+		must = append(must, cond)
+	}
+	if req.NotebookID != nil {
+		must = append(must, termQuery("notebook_id", *req.NotebookID))
+	}
+	// 标签筛选均包含子标签
+	if req.TagID != nil {
+		ids, err := b.tagSubtreeIDs(userID, []uint64{*req.TagID})
+		if err != nil {
+			return nil, "", err
+		}
+		must = append(must, anyTerm("tag_ids", ids))
+	}
+	if len(req.TagIDs) > 0 {
+		if req.TagMode == model.TagModeOr {
+			ids, err := b.tagSubtreeIDs(userID, req.TagIDs)
+			if err != nil {
+				return nil, "", err
+			}
+			must = append(must, anyTerm("tag_ids", ids))
+		} else {
+			for _, tagID := range req.TagIDs {
+				ids, err := b.tagSubtreeIDs(userID, []uint64{tagID})
+				if err != nil {
+					return nil, "", err
+				}
+				must = append(must, anyTerm("tag_ids", ids))
+			}
+		}
+	}
+	if len(req.ExcludeTagIDs) > 0 {
+		ids, err := b.tagSubtreeIDs(userID, req.ExcludeTagIDs)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(ids) > 0 {
+			must = append(must, bq.NewBooleanQuery(nil, nil, []bq.Query{anyTerm("tag_ids", ids)}))
+		}
+	}
+	if req.Untagged {
+		must = append(must, boolQuery("untagged", true))
+	}
+	if req.IsStarred != nil {
+		must = append(must, boolQuery("is_starred", *req.IsStarred))
+	}
+	if req.IsPinned != nil {
+		must = append(must, boolQuery("is_pinned", *req.IsPinned))
+	}
+
+	// 置顶优先，其次按排序字段，最后按文档 ID 保证顺序唯一；多取一条判断是否还有下一页
+	sort, order := req.SortOrder(true)
+	field, ok := bleveSortFields[sort]
+	if !ok {
+		return nil, "", fmt.Errorf("不支持的排序字段 %s", sort)
+	}
+	if order == model.SortDesc {
+		field = "-" + field
+	}
+	idField := "_id"
+	if order == model.SortDesc {
+		idField = "-_id"
+	}
+
+	scope := req.CursorScope(sort, order)
+
+	sr := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(must...), req.PageSize+1, 0, false)
+	sr.SortBy([]string{"-is_pinned", field, idField})
+	if req.Cursor != "" {
+		var c bleveCursor
+		if err := cursor.Decode(req.Cursor, &c); err != nil {
+			return nil, "", err
+		}
+		// 得分随索引变化，上一页最后一条的得分无法稳定定位下一页，按相关性排序时只能按页码翻页
+		if c.Scope != scope || c.Sort != sort || c.Order != order || len(c.After) != len(sr.Sort) ||
+			sort == model.NoteSortRelevance {
+			return nil, "", cursor.ErrInvalid
+		}
+		sr.SetSearchAfter(c.After)
+	} else {
+		sr.From = (req.Page - 1) * req.PageSize
+	}
+	if req.Facets {
+		sr.AddFacet("tags", bleve.NewFacetRequest("tag_ids", b.facetSize))
+		sr.AddFacet("notebooks", bleve.NewFacetRequest("notebook_id", b.facetSize))
+	}
+
+	b.mu.RLock()
+	res, err := b.index.SearchInContext(ctx, sr)
+	b.mu.RUnlock()
+	if err != nil {
+		return nil, "", fmt.Errorf("搜索索引失败: %w", err)
+	}
+	if len(res.Hits) <= req.PageSize {
+		return res, "", nil
+	}
+
+	res.Hits = res.Hits[:req.PageSize]
+	if sort == model.NoteSortRelevance {
+		return res, "", nil
+	}
+	last := res.Hits[len(res.Hits)-1]
+	after := make([]string, len(last.Sort))
+	copy(after, last.Sort)
+	return res, cursor.Encode(&bleveCursor{Scope: scope, Sort: sort, Order: order, After: after}), nil
+}
+
+// textQuery 在标题、正文和附件文本中搜索
+// 短语整体匹配；否则按空白拆分，每个词都须命中，词内的中文二元组须全部出现
+func (b *Bleve) textQuery(text string, phrase bool) bq.Query {
+	if phrase {
+		fields := make([]bq.Query, len(textFields))
+		for i, f := range textFields {
+			m := bleve.NewMatchPhraseQuery(text)
+			m.SetField(f.name)
+			m.SetBoost(f.boost)
+			fields[i] = m
+		}
+		return bleve.NewDisjunctionQuery(fields...)
+	}
+
+	words := strings.Fields(text)
+	conj := make([]bq.Query, len(words))
+	for i, word := range words {
+		conj[i] = b.wordQuery(word)
+	}
+	return bleve.NewConjunctionQuery(conj...)
+}
+
+// wordQuery 单个词的匹配，较长的英文单词额外允许拼写误差，得分低于精确命中
+func (b *Bleve) wordQuery(word string) bq.Query {
+	fuzzy := b.fuzziness > 0 && isFuzzyCandidate(word)
+	var fields []bq.Query
+	for _, f := range textFields {
+		m := bleve.NewMatchQuery(word)
+		m.SetField(f.name)
+		m.SetOperator(bq.MatchQueryOperatorAnd)
+		m.SetBoost(f.boost)
+		fields = append(fields, m)
+
+		if fuzzy {
+			fm := bleve.NewMatchQuery(word)
+			fm.SetField(f.name)
+			fm.SetFuzziness(b.fuzziness)
+			fm.SetBoost(f.boost / 2)
+			fields = append(fields, fm)
+		}
+	}
+	return bleve.NewDisjunctionQuery(fields...)
+}
+
+// isFuzzyCandidate 至少 4 个字符的纯英文数字单词才做模糊匹配，
+// 中文二元组和短词的编辑距离为 1 时几乎能匹配任意内容
+func isFuzzyCandidate(word string) bool {
+	if utf8.RuneCountInString(word) < 4 {
+		return false
+	}
+	for _, r := range word {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// translate 将搜索语句的语法树转换为 bleve 查询，语义与 MySQL 后端一致
+func (b *Bleve) translate(userID uint64, n query.Node) (bq.Query, error) {
+	switch v := n.(type) {
+	case *query.And:
+		children, err := b.translateAll(userID, v.Children)
+		if err != nil {
+			return nil, err
+		}
+		return bleve.NewConjunctionQuery(children...), nil
+	case *query.Or:
+		children, err := b.translateAll(userID, v.Children)
+		if err != nil {
+			return nil, err
+		}
+		return bleve.NewDisjunctionQuery(children...), nil
+	case *query.Not:
+		child, err := b.translate(userID, v.Child)
+		if err != nil {
+			return nil, err
+		}
+		return bq.NewBooleanQuery(nil, nil, []bq.Query{child}), nil
+	case *query.Term:
+		return b.translateTerm(userID, v)
+	default:
+		return nil, fmt.Errorf("未知的查询节点 %T", n)
+	}
+}
+
+func (b *Bleve) translateAll(userID uint64, nodes []query.Node) ([]bq.Query, error) {
+	queries := make([]bq.Query, len(nodes))
+	for i, n := range nodes {
+		q, err := b.translate(userID, n)
+		if err != nil {
+			return nil, err
+		}
+		queries[i] = q
+	}
+	return queries, nil
+}
+
+func (b *Bleve) translateTerm(userID uint64, t *query.Term) (bq.Query, error) {
+	switch t.Field {
+	case query.FieldText:
+		return b.textQuery(t.Value, t.Phrase), nil
+
+	case query.FieldTitle:
+		m := bleve.NewMatchPhraseQuery(t.Value)
+		m.SetField("title")
+		return m, nil
+
+	case query.FieldTag:
+		ids, err := b.tagRepo.ListIDsByName(userID, t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return anyTerm("tag_ids", ids), nil
+
+	case query.FieldNotebook:
+		ids, err := b.notebookRepo.ListIDsByName(userID, t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return anyTerm("notebook_id", ids), nil
+
+	case query.FieldIs:
+		switch t.Value {
+		case query.IsStarred:
+			return boolQuery("is_starred", true), nil
+		case query.IsPinned:
+			return boolQuery("is_pinned", true), nil
+		case query.IsUntagged:
+			return boolQuery("untagged", true), nil
+		}
+
+	case query.FieldCreated:
+		return dateQuery("created_at", t)
+
+	case query.FieldUpdated:
+		return dateQuery("updated_at", t)
+	}
+	return nil, fmt.Errorf("不支持的搜索条件 %s:%s", t.Field, t.Value)
+}
+
+// dateQuery 日期条件，区间含义见 query.Term.DateBounds
+func dateQuery(field string, t *query.Term) (bq.Query, error) {
+	from, to, err := t.DateBounds()
+	if err != nil {
+		return nil, err
+	}
+	var start, end time.Time
+	if from != nil {
+		start = *from
+	}
+	if to != nil {
+		end = *to
+	}
+	inclusiveStart, inclusiveEnd := true, false
+	q := bleve.NewDateRangeInclusiveQuery(start, end, &inclusiveStart, &inclusiveEnd)
+	q.SetField(field)
+	return q, nil
+}
+
+// tagSubtreeIDs 获取多个标签及其子标签的 ID
+func (b *Bleve) tagSubtreeIDs(userID uint64, tagIDs []uint64) ([]uint64, error) {
+	var ids []uint64
+	for _, tagID := range tagIDs {
+		subtree, err := b.tagRepo.ListSubtreeIDs(userID, tagID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subtree...)
+	}
+	return ids, nil
+}
+
+func termQuery(field string, id uint64) bq.Query {
+	q := bleve.NewTermQuery(strconv.FormatUint(id, 10))
+	q.SetField(field)
+	return q
+}
+
+// anyTerm 字段等于任一 ID，ids 为空时不匹配任何笔记
+func anyTerm(field string, ids []uint64) bq.Query {
+	if len(ids) == 0 {
+		return bleve.NewMatchNoneQuery()
+	}
+	terms := make([]bq.Query, len(ids))
+	for i, id := range ids {
+		terms[i] = termQuery(field, id)
+	}
+	return bleve.NewDisjunctionQuery(terms...)
+}
+
+func boolQuery(field string, value bool) bq.Query {
+	q := bleve.NewBoolFieldQuery(value)
+	q.SetField(field)
+	return q
+}
+
+// termFacets 将按 ID 统计的分布转换为计数
+func termFacets(facet *bsearch.FacetResult) []*model.FacetCount {
+	if facet == nil {
+		return nil
+	}
+	var counts []*model.FacetCount
+	for _, term := range facet.Terms.Terms() {
+		id, err := strconv.ParseUint(term.Term, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts = append(counts, &model.FacetCount{ID: id, Count: int64(term.Count)})
+	}
+	return counts
+}
+
+// Sync 从数据库读取笔记、标签和附件文本写入索引，已删除或不存在的笔记从索引中移除
+func (b *Bleve) Sync(ctx context.Context, noteIDs ...uint64) error {
+	for start := 0; start < len(noteIDs); start += syncBatchSize {
+		end := start + syncBatchSize
+		if end > len(noteIDs) {
+			end = len(noteIDs)
+		}
+		if err := b.syncBatch(noteIDs[start:end]); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bleve) syncBatch(noteIDs []uint64) error {
+	notes, err := b.noteRepo.ListByIDs(noteIDs)
+	if err != nil {
+		return err
+	}
+
+	live := make([]uint64, len(notes))
+	for i, note := range notes {
+		live[i] = note.ID
+	}
+	attachments := make(map[uint64][]string)
+	if len(live) > 0 {
+		texts, err := b.attachmentRepo.ListTextsByNoteIDs(live)
+		if err != nil {
+			return err
+		}
+		for _, t := range texts {
+			attachments[t.NoteID] = append(attachments[t.NoteID], t.Content)
+		}
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	batch := b.index.NewBatch()
+	indexed := make(map[uint64]bool, len(notes))
+	for _, note := range notes {
+		tagIDs := make([]string, len(note.Tags))
+		for i, tag := range note.Tags {
+			tagIDs[i] = strconv.FormatUint(tag.ID, 10)
+		}
+		doc := document{
+			UserID:      strconv.FormatUint(note.UserID, 10),
+			NotebookID:  strconv.FormatUint(note.NotebookID, 10),
+			TagIDs:      tagIDs,
+			Untagged:    len(tagIDs) == 0,
+			Title:       note.Title,
+			TitleSort:   strings.ToLower(note.Title),
+			Content:     note.Content,
+			Attachments: strings.Join(attachments[note.ID], "\n"),
+			IsPinned:    note.IsPinned,
+			IsStarred:   note.IsStarred,
+			CreatedAt:   note.CreatedAt,
+			UpdatedAt:   note.UpdatedAt,
+		}
+		if err := batch.Index(strconv.FormatUint(note.ID, 10), doc); err != nil {
+			return fmt.Errorf("写入笔记 %d 的索引失败: %w", note.ID, err)
+		}
+		indexed[note.ID] = true
+	}
+	for _, id := range noteIDs {
+		if !indexed[id] {
+			batch.Delete(strconv.FormatUint(id, 10))
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+// Reindex 删除索引目录后重新创建，并写入全部未删除的笔记
+// 重建期间搜索结果不完整，应在停止服务后执行
+func (b *Bleve) Reindex(ctx context.Context, progress func(indexed int)) (int, error) {
+	b.mu.Lock()
+	if err := b.index.Close(); err != nil {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("关闭搜索索引失败: %w", err)
+	}
+	if err := os.RemoveAll(b.path); err != nil {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("删除搜索索引失败: %w", err)
+	}
+	index, err := newBleveIndex(b.path)
+	if err != nil {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("创建搜索索引失败: %w", err)
+	}
+	b.index = index
+	b.mu.Unlock()
+
+	var indexed int
+	var lastID uint64
+	for {
+		ids, err := b.noteRepo.ListIDsAfterID(lastID, syncBatchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(ids) == 0 {
+			return indexed, nil
+		}
+		if err := b.Sync(ctx, ids...); err != nil {
+			return indexed, err
+		}
+		lastID = ids[len(ids)-1]
+		indexed += len(ids)
+		if progress != nil {
+			progress(indexed)
+		}
+	}
+}
+
+// Close 关闭索引
+func (b *Bleve) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.index.Close()
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"context"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/query"
+)
+
+// MySQL 基于 notes 表 ngram 全文索引的搜索，索引由数据库维护
+type MySQL struct {
+	noteRepo  *repo.NoteRepo
+	facetSize int
+}
+
+// NewMySQL 创建 MySQL 搜索后端
+func NewMySQL(facetSize int) *MySQL {
+	return &MySQL{
+		noteRepo:  repo.NewNoteRepo(),
+		facetSize: facetSize,
+	}
+}
+
+// Name 后端名称
+func (m *MySQL) Name() string {
+	return EngineMySQL
+}
+
+// Search 使用 MATCH ... AGAINST 搜索，分布统计按相同条件分组计数
+func (m *MySQL) Search(ctx context.Context, userID uint64, req *model.NoteListReq, q query.Node) (*Result, error) {
+	notes, total, next, err := m.noteRepo.List(userID, req, q)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Notes: notes, Total: total, NextCursor: next}
+
+	if req.Facets {
+		tags, notebooks, err := m.noteRepo.Facets(userID, req, q, m.facetSize)
+		if err != nil {
+			return nil, err
+		}
+		if result.Facets, err = newFacets(userID, tags, notebooks); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Sync 全文索引随笔记写入自动更新，无需同步
+func (m *MySQL) Sync(ctx context.Context, noteIDs ...uint64) error {
+	return nil
+}
+
+// Reindex 全文索引由数据库维护，无需重建
+func (m *MySQL) Reindex(ctx context.Context, progress func(indexed int)) (int, error) {
+	return 0, nil
+}
+
+// Close 无需释放资源
+func (m *MySQL) Close() error {
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package search 笔记全文搜索后端
+//
+// mysql 直接查询 notes 表的 ngram 全文索引，写入笔记即可搜索；
+// bleve 使用内嵌的倒排索引，支持中文分词、模糊匹配和结果分布统计，
+// 笔记变更后需调用 Sync 增量更新，首次启用或索引损坏时用 cmd/search-reindex 重建
+package search
+
+import (
+	"context"
+	"fmt"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/query"
+)
+
+// 支持的搜索后端
+const (
+	EngineMySQL = "mysql"
+	EngineBleve = "bleve"
+)
+
+// Engine 笔记搜索后端
+type Engine interface {
+	// Name 后端名称
+	Name() string
+	// Search 按列表请求中的关键词、搜索语句和筛选条件搜索用户的笔记，按请求的排序返回一页结果
+	// 请求中的游标无效时返回 cursor.ErrInvalid
+	Search(ctx context.Context, userID uint64, req *model.NoteListReq, q query.Node) (*Result, error)
+	// Sync 同步笔记的索引：未删除的笔记写入索引，已删除或不存在的从索引中移除
+	Sync(ctx context.Context, noteIDs ...uint64) error
+	// Reindex 清空并重建全部索引，progress 在每批写入后以累计笔记数调用，返回写入的笔记数
+	Reindex(ctx context.Context, progress func(indexed int)) (int, error)
+	// Close 释放索引资源
+	Close() error
+}
+
+// Result 一页搜索结果
+type Result struct {
+	Notes      []*model.Note
+	Total      int64
+	Facets     *model.SearchFacets // 仅在请求 facets=true 时返回
+	NextCursor string              // 下一页的游标，没有更多结果时为空
+}
+
+// Options 搜索后端参数
+type Options struct {
+	Fuzziness int    // 模糊匹配允许的编辑距离，小于等于 0 时关闭
+	FacetSize int    // 标签、笔记本分布各返回的最大项数
+	BlevePath string // bleve 索引目录
+}
+
+// New 根据名称创建搜索后端
+func New(engine string, opts Options) (Engine, error) {
+	if opts.FacetSize <= 0 {
+		opts.FacetSize = 20
+	}
+	switch engine {
+	case "", EngineMySQL:
+		return NewMySQL(opts.FacetSize), nil
+	case EngineBleve:
+		return OpenBleve(opts.BlevePath, opts.Fuzziness, opts.FacetSize)
+	default:
+		return nil, fmt.Errorf("不支持的搜索后端: %s", engine)
+	}
+}
+
+// newFacets 补全标签和笔记本的名称，已删除的标签或笔记本不返回
+func newFacets(userID uint64, tags, notebooks []*model.FacetCount) (*model.SearchFacets, error) {
+	facets := &model.SearchFacets{
+		Tags:      []*model.FacetCount{},
+		Notebooks: []*model.FacetCount{},
+	}
+
+	if ids := facetIDs(tags); len(ids) > 0 {
+		list, err := repo.NewTagRepo().ListByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[uint64]string, len(list))
+		for _, tag := range list {
+			if tag.UserID == userID {
+				names[tag.ID] = tag.Name
+			}
+		}
+		facets.Tags = namedFacets(tags, names)
+	}
+
+	if ids := facetIDs(notebooks); len(ids) > 0 {
+		list, err := repo.NewNotebookRepo().ListByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[uint64]string, len(list))
+		for _, notebook := range list {
+			if notebook.UserID == userID {
+				names[notebook.ID] = notebook.Name
+			}
+		}
+		facets.Notebooks = namedFacets(notebooks, names)
+	}
+	return facets, nil
+}
+
+func facetIDs(counts []*model.FacetCount) []uint64 {
+	ids := make([]uint64, len(counts))
+	for i, c := range counts {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func namedFacets(counts []*model.FacetCount, names map[uint64]string) []*model.FacetCount {
+	named := make([]*model.FacetCount, 0, len(counts))
+	for _, c := range counts {
+		name, ok := names[c.ID]
+		if !ok {
+			continue
+		}
+		c.Name = name
+		named = append(named, c)
+	}
+	return named
+}
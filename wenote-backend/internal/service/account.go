@@ -0,0 +1,207 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+// PurgeDueAccounts 清除注销宽限期已到期的账号
+// 由定时任务调用，单个账号失败不影响其他账号，返回成功清除的数量
+func (s *UserService) PurgeDueAccounts() (int, error) {
+	users, err := s.userRepo.ListDueForDeletion(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		err := s.PurgeAccount(user)
+		if errors.Is(err, ErrDeletionCanceled) {
+			logger.Info("账号已撤销注销，跳过清除", "user_id", user.ID)
+			continue
+		}
+		if err != nil {
+			logger.Error("清除账号失败", "user_id", user.ID, "error", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// PurgeAccount 清除注销已到期账号的全部数据和附件文件
+// 开启 export_before_purge 时先导出，导出失败则放弃本次清除，等待下次重试；
+// 清除时账号已撤销注销（宽限期内重新登录）则删除导出文件并返回 ErrDeletionCanceled
+func (s *UserService) PurgeAccount(user *model.User) error {
+	cfg := config.GlobalConfig.Account
+
+	export, err := s.userRepo.LoadExport(user.ID)
+	if err != nil {
+		return fmt.Errorf("加载用户数据失败: %w", err)
+	}
+
+	var exportPath string
+	if cfg.ExportBeforePurge {
+		exportPath, err = writeAccountExport(cfg.ExportDir, export)
+		if err != nil {
+			return fmt.Errorf("导出用户数据失败: %w", err)
+		}
+	}
+
+	// 分片记录随 Purge 删除，先取出分片文件的路径
+	chunks, err := s.uploadRepo.ListChunksByUserID(user.ID)
+	if err != nil {
+		return fmt.Errorf("加载上传分片失败: %w", err)
+	}
+
+	purged, err := s.userRepo.Purge(user.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !purged {
+		if exportPath != "" {
+			os.Remove(exportPath)
+		}
+		return ErrDeletionCanceled
+	}
+	suggestInvalidate(user.ID)
+
+	// 笔记已不存在，同步时从搜索索引中移除
+	noteIDs := make([]uint64, len(export.Notes))
+	for i, note := range export.Notes {
+		noteIDs[i] = note.ID
+	}
+	syncSearchIndex(noteIDs...)
+
+	// 数据库清除成功后再删除文件，避免事务回滚后附件丢失
+	ctx := context.Background()
+	for _, attachment := range export.Attachments {
+		// 去重后的内容文件可能被其他用户共享，引用已在 Purge 中释放，由附件垃圾回收删除
+		if attachment.BlobHash != "" {
+			continue
+		}
+		key := storage.NormalizeKey(attachment.StoragePath)
+		if err := globalStorage.Delete(ctx, key); err != nil {
+			logger.Warn("删除附件文件失败", "key", key, "error", err)
+		}
+	}
+	for _, chunk := range chunks {
+		if err := globalStorage.Delete(ctx, chunk.StoragePath); err != nil {
+			logger.Warn("删除上传分片失败", "key", chunk.StoragePath, "error", err)
+		}
+	}
+	// 清理没有数据库记录的残留文件
+	for _, prefix := range userKeyPrefixes(user.ID) {
+		err := globalStorage.List(ctx, prefix, func(info storage.ObjectInfo) error {
+			return globalStorage.Delete(ctx, info.Key)
+		})
+		if err != nil {
+			logger.Warn("删除用户上传文件失败", "prefix", prefix, "error", err)
+		}
+	}
+
+	details := map[string]interface{}{
+		"username":    user.Username,
+		"notes":       len(export.Notes),
+		"attachments": len(export.Attachments),
+	}
+	if exportPath != "" {
+		details["export_path"] = exportPath
+	}
+	// 用户的审计日志已在 Purge 中删除，清除记录在其后写入才能保留下来；
+	// 记为系统操作（UserID 为 0），只通过 ResourceID 指向被清除的用户，
+	// 不会被按 user_id 删除的操作带走，也不会出现在任何用户自己的审计日志中
+	RecordAudit(&model.AuditLog{
+		UserID:       0,
+		Action:       model.AuditActionAccountPurge,
+		ResourceType: model.AuditResourceUser,
+		ResourceID:   user.ID,
+		Details:      details,
+	})
+
+	logger.Info("账号已清除", "user_id", user.ID, "username", user.Username, "export_path", exportPath)
+	return nil
+}
+
+// writeAccountExport 将用户数据写入 zip 文件，返回文件路径
+// zip 内包含 data.json 和 attachments/ 目录下的附件原文件
+func writeAccountExport(dir string, export *model.AccountExport) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("user_%d_%s.zip", export.User.ID, export.ExportedAt.Format("20060102150405"))
+	path := filepath.Join(dir, name)
+	tmpPath := path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeExportZip(f, export); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return path, nil
+}
+
+func writeExportZip(w io.Writer, export *model.AccountExport) error {
+	zw := zip.NewWriter(w)
+
+	data, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(data)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	for _, attachment := range export.Attachments {
+		if err := addExportFile(zw, attachment); err != nil {
+			// 单个附件缺失不影响整体导出
+			logger.Warn("导出附件失败", "attachment_id", attachment.ID, "error", err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func addExportFile(zw *zip.Writer, attachment *model.NoteAttachment) error {
+	src, err := globalStorage.Open(context.Background(), storage.NormalizeKey(attachment.StoragePath))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	name := fmt.Sprintf("attachments/%d_%s", attachment.ID, filepath.Base(attachment.Filename))
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
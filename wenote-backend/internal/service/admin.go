@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/ai"
+	"wenote-backend/pkg/hash"
+	"wenote-backend/pkg/logger"
+)
+
+var (
+	ErrCannotLockSelf      = errors.New("不能锁定自己的账号")
+	ErrTargetIsAdmin       = errors.New("不能对管理员账号执行此操作")
+	ErrBreakerNotSupported = errors.New("当前 AI 客户端不支持熔断器状态查询")
+)
+
+// temporaryPasswordChars 临时密码字符集（去掉易混淆的 0/O/1/l/I）
+const temporaryPasswordChars = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// AdminService 管理后台服务
+type AdminService struct {
+	userRepo          *repo.UserRepo
+	noteRepo          *repo.NoteRepo
+	attachmentRepo    *repo.AttachmentRepo
+	auditRepo         *repo.AuditRepo
+	noteService       *NoteService
+	attachmentService *AttachmentService
+}
+
+// NewAdminService 创建管理后台服务实例
+func NewAdminService() *AdminService {
+	return &AdminService{
+		userRepo:          repo.NewUserRepo(),
+		noteRepo:          repo.NewNoteRepo(),
+		attachmentRepo:    repo.NewAttachmentRepo(),
+		auditRepo:         repo.NewAuditRepo(),
+		noteService:       NewNoteService(),
+		attachmentService: NewAttachmentService(),
+	}
+}
+
+// BootstrapAdmins 将配置中的用户名提升为管理员
+// 在启动时调用，用于初始化第一个管理员账号
+func (s *AdminService) BootstrapAdmins(usernames []string) error {
+	count, err := s.userRepo.PromoteToAdmin(usernames)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logger.Info("已设置管理员账号", "count", count)
+	}
+	return nil
+}
+
+// ListUsers 分页查询用户
+func (s *AdminService) ListUsers(req *model.AdminUserListReq) (*model.AdminUserListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	if req.PageSize > 100 {
+		req.PageSize = 100
+	}
+
+	users, total, err := s.userRepo.List(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AdminUserListResp{
+		Total: total,
+		List:  users,
+		Page:  req.Page,
+		Size:  req.PageSize,
+	}, nil
+}
+
+// GetUser 获取用户详情
+func (s *AdminService) GetUser(userID uint64) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// LockUser 锁定用户，锁定后无法登录，已签发的令牌也会被拒绝
+// 不能锁定管理员账号，避免管理员之间互相锁定
+func (s *AdminService) LockUser(adminID, userID uint64, reason string) error {
+	if adminID == userID {
+		return ErrCannotLockSelf
+	}
+	if _, err := s.getNonAdminUser(userID); err != nil {
+		return err
+	}
+	return s.userRepo.SetLocked(userID, true, reason)
+}
+
+// getNonAdminUser 获取锁定、重置密码等操作的目标用户，目标为管理员时返回 ErrTargetIsAdmin
+func (s *AdminService) getNonAdminUser(userID uint64) (*model.User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == model.RoleAdmin {
+		return nil, ErrTargetIsAdmin
+	}
+	return user, nil
+}
+
+// UnlockUser 解锁用户，同时清除登录失败锁定记录
+func (s *AdminService) UnlockUser(userID uint64) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetLocked(userID, false, ""); err != nil {
+		return err
+	}
+
+	if globalLimitStore != nil {
+		if err := globalLimitStore.Reset(context.Background(), lockoutKey(user.Username)); err != nil {
+			logger.Error("清除登录失败记录失败", "user_id", userID, "error", err)
+		}
+	}
+	return nil
+}
+
+// ResetPassword 强制重置密码
+// 生成临时密码并要求用户登录后立即修改，临时密码只返回给管理员一次；
+// 不能重置管理员（包括自己）的密码，管理员修改自己的密码走普通的修改密码接口
+func (s *AdminService) ResetPassword(userID uint64) (*model.AdminResetPasswordResp, error) {
+	if _, err := s.getNonAdminUser(userID); err != nil {
+		return nil, err
+	}
+
+	tempPassword, err := generateTemporaryPassword(12)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := hash.HashPassword(tempPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.ResetPassword(userID, passwordHash); err != nil {
+		return nil, err
+	}
+
+	return &model.AdminResetPasswordResp{TemporaryPassword: tempPassword}, nil
+}
+
+// GetUsage 统计用户的存储和 AI 用量
+func (s *AdminService) GetUsage(userID uint64) (*model.UserUsage, error) {
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	usage := &model.UserUsage{UserID: userID}
+
+	var err error
+	if usage.NoteCount, err = s.noteRepo.CountByUserID(userID); err != nil {
+		return nil, err
+	}
+	if usage.DeletedNotes, err = s.noteRepo.CountDeletedByUserID(userID); err != nil {
+		return nil, err
+	}
+	if usage.AttachmentCount, usage.StorageBytes, err = s.attachmentRepo.SumByUserID(userID); err != nil {
+		return nil, err
+	}
+	if usage.AIGenerated, err = s.noteRepo.CountByAIStatus(userID, model.AIStatusDone); err != nil {
+		return nil, err
+	}
+	if usage.AIFailed, err = s.noteRepo.CountByAIStatus(userID, model.AIStatusFailed); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// ListAudit 查询全局审计日志
+func (s *AdminService) ListAudit(req *model.AdminAuditListReq) (*model.AuditListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	if req.PageSize > 100 {
+		req.PageSize = 100
+	}
+
+	logs, total, err := s.auditRepo.List(req.UserID, &req.AuditListReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuditListResp{
+		Total: total,
+		List:  logs,
+		Page:  req.Page,
+		Size:  req.PageSize,
+	}, nil
+}
+
+// CleanupTrash 手动触发回收站清理，days 为 nil 时使用配置值
+func (s *AdminService) CleanupTrash(days *int) (int64, int, error) {
+	d := config.GlobalConfig.Cleanup.Days
+	if days != nil {
+		d = *days
+	}
+	count, err := s.noteService.CleanupDeletedNotes(d)
+	return count, d, err
+}
+
+// CollectAttachmentGarbage 手动触发附件垃圾回收，dryRun 为 nil 时使用配置值
+func (s *AdminService) CollectAttachmentGarbage(dryRun *bool) (*model.AttachmentGCReport, error) {
+	d := config.GlobalConfig.Attachment.GC.DryRun
+	if dryRun != nil {
+		d = *dryRun
+	}
+	return s.attachmentService.CollectGarbage(context.Background(), d)
+}
+
+// AIBreakerStatus 获取 AI 客户端熔断器状态
+func (s *AdminService) AIBreakerStatus() (*ai.BreakerStatus, error) {
+	reporter, ok := globalAIClient.(ai.BreakerReporter)
+	if !ok {
+		return nil, ErrBreakerNotSupported
+	}
+	status := reporter.BreakerStatus()
+	return &status, nil
+}
+
+// generateTemporaryPassword 生成随机临时密码
+func generateTemporaryPassword(length int) (string, error) {
+	buf := make([]byte, length)
+	max := big.NewInt(int64(len(temporaryPasswordChars)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = temporaryPasswordChars[n.Int64()]
+	}
+	return string(buf), nil
+}
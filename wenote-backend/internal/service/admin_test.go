@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+)
+
+// TestAdminCannotTargetAdmins 管理员不能锁定自己或其他管理员，也不能重置管理员的密码
+func TestAdminCannotTargetAdmins(t *testing.T) {
+	openTestDB(t)
+	s := &AdminService{userRepo: repo.NewUserRepo()}
+	now := time.Now().UnixNano()
+
+	newUser := func(name, role string) *model.User {
+		t.Helper()
+		user := &model.User{Username: fmt.Sprintf("%s_%d", name, now), PasswordHash: "x", Role: role}
+		if err := repo.DB.Create(user).Error; err != nil {
+			t.Fatalf("创建用户失败: %v", err)
+		}
+		t.Cleanup(func() { repo.DB.Exec("DELETE FROM users WHERE id = ?", user.ID) })
+		return user
+	}
+	admin := newUser("admin", model.RoleAdmin)
+	other := newUser("other_admin", model.RoleAdmin)
+	user := newUser("user", model.RoleUser)
+
+	tests := []struct {
+		name     string
+		targetID uint64
+		lockErr  error
+		resetErr error
+	}{
+		{"自己", admin.ID, ErrCannotLockSelf, ErrTargetIsAdmin},
+		{"其他管理员", other.ID, ErrTargetIsAdmin, ErrTargetIsAdmin},
+		{"普通用户", user.ID, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.LockUser(admin.ID, tt.targetID, "test"); err != tt.lockErr {
+				t.Errorf("LockUser 错误 = %v，期望 %v", err, tt.lockErr)
+			}
+			if _, err := s.ResetPassword(tt.targetID); err != tt.resetErr {
+				t.Errorf("ResetPassword 错误 = %v，期望 %v", err, tt.resetErr)
+			}
+
+			got, err := s.userRepo.GetByID(tt.targetID)
+			if err != nil || got == nil {
+				t.Fatalf("读取用户失败: %v", err)
+			}
+			if got.IsLocked != (tt.lockErr == nil) {
+				t.Errorf("IsLocked = %v，期望 %v", got.IsLocked, tt.lockErr == nil)
+			}
+			if got.MustResetPassword != (tt.resetErr == nil) {
+				t.Errorf("MustResetPassword = %v，期望 %v", got.MustResetPassword, tt.resetErr == nil)
+			}
+		})
+	}
+}
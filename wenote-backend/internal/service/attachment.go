@@ -1,20 +1,46 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"os"
-	"path/filepath"
+	"net/url"
+	"path"
+	"strings"
 	"time"
+	"wenote-backend/config"
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/signedurl"
+	"wenote-backend/pkg/storage"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	ErrAttachmentNotFound    = errors.New("附件不存在")
+	ErrAttachmentForbidden   = errors.New("无权限访问该附件")
+	ErrFileTypeNotAllowed    = errors.New("不支持的文件类型")
+	ErrFileTooLarge          = errors.New("文件大小超过限制")
+	ErrStorageQuotaExceeded  = errors.New("存储空间不足")
+	ErrInvalidImage          = errors.New("图片已损坏或格式不正确")
+	ErrAttachmentScanPending = errors.New("附件正在进行安全扫描，请稍后再试")
+	ErrAttachmentInfected    = errors.New("附件包含恶意内容，已被隔离")
 )
 
 // AttachmentService 附件服务
 type AttachmentService struct {
 	repo     *repo.AttachmentRepo
 	noteRepo *repo.NoteRepo
+	userRepo *repo.UserRepo
+	storage  storage.Storage
+	signer   *signedurl.Signer
 }
 
 // NewAttachmentService 创建附件服务实例
@@ -22,99 +48,343 @@ func NewAttachmentService() *AttachmentService {
 	return &AttachmentService{
 		repo:     repo.NewAttachmentRepo(),
 		noteRepo: repo.NewNoteRepo(),
+		userRepo: repo.NewUserRepo(),
+		storage:  globalStorage,
+		signer:   signedurl.NewSigner(config.GlobalConfig.Storage.SigningKey),
 	}
 }
 
 const (
-	// 上传目录配置
-	UploadBasePath = "./uploads/images"
-	MaxFileSize    = 5 * 1024 * 1024 // 5MB
+	// BlobKeyPrefix 内容文件对象键前缀，完整键为 blobs/<哈希前两位>/<哈希第三四位>/<哈希>
+	BlobKeyPrefix = "blobs"
+	// AttachmentKeyPrefix 去重前的附件对象键前缀，完整键为 attachments/user_<id>/<文件名>
+	AttachmentKeyPrefix = "attachments"
+	// LegacyImageKeyPrefix 早期只支持图片时使用的前缀
+	LegacyImageKeyPrefix = "images"
+	// QuarantineKeyPrefix 检出恶意内容的文件移入的隔离区前缀，完整键为 quarantine/<原对象键>
+	QuarantineKeyPrefix = "quarantine"
 )
 
-// AllowedImageTypes 允许的图片类型
-var AllowedImageTypes = map[string]bool{
-	"image/jpeg": true,
-	"image/png":  true,
-	"image/gif":  true,
-	"image/webp": true,
+// BlobKey 内容文件的对象键，按哈希前缀分目录避免单个目录文件过多
+func BlobKey(hash string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", BlobKeyPrefix, hash[0:2], hash[2:4], hash)
 }
 
-// UploadImage 上传图片
-func (s *AttachmentService) UploadImage(userID uint64, noteID uint64, file *multipart.FileHeader) (*model.AttachmentUploadResp, error) {
-	// 1. 验证笔记所有权
+// VariantKey 缩略图的对象键，与原文件放在同一目录
+func VariantKey(key, name, mimeType string) string {
+	ext := ".jpg"
+	if mimeType == "image/png" {
+		ext = ".png"
+	}
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + name + ext
+}
+
+// userKeyPrefixes 用户附件的全部对象键前缀（含旧前缀）
+func userKeyPrefixes(userID uint64) []string {
+	return []string{
+		fmt.Sprintf("%s/user_%d/", AttachmentKeyPrefix, userID),
+		fmt.Sprintf("%s/user_%d/", LegacyImageKeyPrefix, userID),
+	}
+}
+
+// Upload 上传附件
+// 文件类型通过内容识别，按类别策略校验大小，并占用用户的存储配额
+func (s *AttachmentService) Upload(userID uint64, noteID uint64, file *multipart.FileHeader) (*model.AttachmentUploadResp, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer src.Close()
+
+	return s.createAttachment(userID, noteID, file.Filename, src, file.Size)
+}
+
+// checkNoteOwner 验证笔记归属于该用户
+func (s *AttachmentService) checkNoteOwner(userID, noteID uint64) error {
 	note, err := s.noteRepo.GetByID(noteID)
 	if err != nil {
-		return nil, fmt.Errorf("笔记不存在")
+		return fmt.Errorf("笔记不存在")
 	}
 	if note.UserID != userID {
-		return nil, fmt.Errorf("无权限访问该笔记")
+		return fmt.Errorf("无权限访问该笔记")
 	}
+	return nil
+}
 
-	// 2. 验证文件大小
-	if file.Size > MaxFileSize {
-		return nil, fmt.Errorf("文件大小超过限制（最大5MB）")
+// createAttachment 校验文件并创建附件，普通上传和分片上传完成时共用
+func (s *AttachmentService) createAttachment(userID, noteID uint64, filename string, src io.ReadSeeker, fileSize int64) (*model.AttachmentUploadResp, error) {
+	// 1. 验证笔记所有权
+	if err := s.checkNoteOwner(userID, noteID); err != nil {
+		return nil, err
 	}
 
-	// 3. 验证文件类型
-	if !AllowedImageTypes[file.Header.Get("Content-Type")] {
-		return nil, fmt.Errorf("不支持的文件类型")
+	// 2. 按内容识别文件类型
+	detected, err := mimetype.DetectReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
 	}
 
-	// 4. 生成存储路径
-	timestamp := time.Now().UnixNano()
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d_%d%s", timestamp, noteID, ext)
-	userDir := filepath.Join(UploadBasePath, fmt.Sprintf("user_%d", userID))
-	storagePath := filepath.Join(userDir, filename)
+	// 3. 匹配上传策略并验证文件大小
+	contentType, category, policy, ok := matchPolicy(detected)
+	if !ok {
+		return nil, ErrFileTypeNotAllowed
+	}
+	if policy.MaxSize > 0 && fileSize > policy.MaxSize*1024*1024 {
+		return nil, fmt.Errorf("%w（最大%dMB）", ErrFileTooLarge, policy.MaxSize)
+	}
 
-	// 5. 确保目录存在
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建目录失败: %v", err)
+	attachment := &model.NoteAttachment{
+		NoteID:   noteID,
+		UserID:   userID,
+		Filename: filename,
+		MimeType: contentType,
+		Category: category,
 	}
 
-	// 6. 保存文件
-	src, err := file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("打开文件失败: %v", err)
+	// 4. 图片去除 EXIF 等元数据并记录尺寸，处理后的内容替换原文件
+	var content io.ReadSeeker = src
+	size := fileSize
+	if processed, err := sanitizeImage(src, contentType); err != nil {
+		return nil, err
+	} else if processed != nil {
+		content = bytes.NewReader(processed.Data)
+		size = int64(len(processed.Data))
+		attachment.MimeType = processed.MimeType
+		attachment.Width = processed.Width
+		attachment.Height = processed.Height
+		attachment.DominantColor = processed.DominantColor
+		attachment.VariantStatus = model.VariantStatusPending
+	}
+	attachment.FileSize = int(size)
+	// 上传后尚未写入正文，从上传时开始计算未引用保留期
+	now := time.Now()
+	attachment.UnreferencedAt = &now
+	if canExtractText(attachment.MimeType) {
+		attachment.TextStatus = model.TextStatusPending
+	}
+	attachment.ScanStatus = model.ScanStatusClean
+	if globalScanner != nil {
+		attachment.ScanStatus = model.ScanStatusPending
 	}
-	defer src.Close()
 
-	dst, err := os.Create(storagePath)
+	// 5. 按内容哈希生成对象键，内容相同的附件共享同一个文件
+	hash, err := contentHash(content)
 	if err != nil {
-		return nil, fmt.Errorf("创建文件失败: %v", err)
+		return nil, fmt.Errorf("读取文件失败: %v", err)
 	}
-	defer dst.Close()
+	key := BlobKey(hash)
+	attachment.BlobHash = hash
+	attachment.StoragePath = key
 
-	if _, err = io.Copy(dst, src); err != nil {
-		return nil, fmt.Errorf("保存文件失败: %v", err)
+	// 6. 占用存储配额，配额按引用计算，重复上传相同内容同样占用
+	if err := s.reserveStorage(userID, size); err != nil {
+		return nil, err
 	}
 
-	// 7. 生成访问URL（相对路径）
-	url := fmt.Sprintf("/uploads/images/user_%d/%s", userID, filename)
-
-	// 8. 保存数据库记录
-	attachment := &model.NoteAttachment{
-		NoteID:      noteID,
-		UserID:      userID,
-		Filename:    file.Filename,
-		FileSize:    int(file.Size),
-		MimeType:    file.Header.Get("Content-Type"),
-		StoragePath: storagePath,
-		URL:         url,
+	// 7. 引用内容文件，文件不存在时写入存储
+	ctx := context.Background()
+	blob := &model.AttachmentBlob{
+		Hash:        hash,
+		Size:        size,
+		MimeType:    attachment.MimeType,
+		StoragePath: key,
+	}
+	if err := s.storeBlob(ctx, blob, content); err != nil {
+		s.releaseStorage(userID, size)
+		return nil, err
 	}
 
+	// 8. 保存数据库记录
 	if err := s.repo.Create(attachment); err != nil {
-		// 删除已保存的文件
-		os.Remove(storagePath)
+		s.releaseBlob(ctx, hash)
+		s.releaseStorage(userID, size)
 		return nil, fmt.Errorf("保存附件记录失败: %v", err)
 	}
 
+	// 9. 记录下载地址（不含签名），返回给前端的是带签名的地址
+	if err := s.repo.UpdateStorage(attachment.ID, key, ContentPath(attachment.ID)); err != nil {
+		logger.Warn("更新附件地址失败", "attachment_id", attachment.ID, "error", err)
+	}
+	s.resolveURL(attachment)
+
+	// 10. 病毒扫描、缩略图和文本提取交给后台处理，扫描通过后才生成缩略图和提取文本
+	if attachment.ScanStatus == model.ScanStatusPending {
+		enqueueScan(attachment.ID)
+	} else {
+		enqueueProcessing(attachment)
+	}
+
 	return &model.AttachmentUploadResp{
-		ID:  attachment.ID,
-		URL: url,
+		ID:            attachment.ID,
+		URL:           attachment.URL,
+		Filename:      attachment.Filename,
+		FileSize:      attachment.FileSize,
+		MimeType:      attachment.MimeType,
+		Category:      attachment.Category,
+		Width:         attachment.Width,
+		Height:        attachment.Height,
+		DominantColor: attachment.DominantColor,
+		ScanStatus:    attachment.ScanStatus,
+		Reference:     ContentPath(attachment.ID),
 	}, nil
 }
 
+// contentHash 计算内容的 SHA-256，读取前后都将位置重置到开头
+func contentHash(content io.ReadSeeker) (string, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storeBlob 引用内容文件，文件不存在时写入存储
+// 先引用再检查文件：与删除并发时删除方持有记录锁，引用会等到删除完成后重新创建记录并写入文件
+func (s *AttachmentService) storeBlob(ctx context.Context, blob *model.AttachmentBlob, content io.Reader) error {
+	if err := s.repo.AcquireBlob(blob); err != nil {
+		return fmt.Errorf("保存附件记录失败: %v", err)
+	}
+
+	_, err := s.storage.Stat(ctx, blob.StoragePath)
+	if errors.Is(err, storage.ErrNotExist) {
+		err = s.storage.Put(ctx, blob.StoragePath, content, blob.Size, blob.MimeType)
+	}
+	if err != nil {
+		s.releaseBlob(ctx, blob.Hash)
+		return fmt.Errorf("保存文件失败: %v", err)
+	}
+	return nil
+}
+
+// releaseBlob 释放对内容文件的引用，失败只记录日志，残留的引用由垃圾回收处理
+func (s *AttachmentService) releaseBlob(ctx context.Context, hash string) {
+	err := s.repo.ReleaseBlob(hash, func(blob *model.AttachmentBlob) {
+		s.deleteBlobFiles(ctx, blob)
+	})
+	if err != nil {
+		logger.Error("释放附件内容引用失败", "hash", hash, "error", err)
+	}
+}
+
+// deleteBlobFiles 删除内容文件、隔离区中的副本及其全部尺寸的缩略图
+// 删除失败的文件会在垃圾回收时作为无记录文件清理
+func (s *AttachmentService) deleteBlobFiles(ctx context.Context, blob *model.AttachmentBlob) {
+	for _, key := range blobFileKeys(blob) {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			logger.Warn("删除附件文件失败", "hash", blob.Hash, "key", key, "error", err)
+		}
+	}
+}
+
+// blobFileKeys 内容记录对应的全部对象键
+// 隔离只改写附件记录，内容记录仍指向原对象键；早期版本隔离时改写过内容记录，按原对象键还原
+func blobFileKeys(blob *model.AttachmentBlob) []string {
+	key := strings.TrimPrefix(blob.StoragePath, QuarantineKeyPrefix+"/")
+	return append(fileKeys(key), QuarantineKey(key))
+}
+
+// fileKeys 文件及其全部可能存在的缩略图的对象键
+func fileKeys(key string) []string {
+	keys := []string{key}
+	for name := range config.GlobalConfig.Attachment.Image.Thumbnails {
+		keys = append(keys, VariantKey(key, name, "image/jpeg"), VariantKey(key, name, "image/png"))
+	}
+	return keys
+}
+
+// removeAttachment 删除附件记录和文件
+// 去重前的旧附件独占文件，直接删除；去重后的附件只在内容不再被引用时删除文件
+func (s *AttachmentService) removeAttachment(ctx context.Context, attachment *model.NoteAttachment) error {
+	variants, err := s.repo.ListVariants(attachment.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(attachment.ID, func(blob *model.AttachmentBlob) {
+		s.deleteBlobFiles(ctx, blob)
+	})
+	if err != nil {
+		return err
+	}
+	// 提取的文本随附件删除，不再参与笔记搜索
+	if attachment.TextStatus == model.TextStatusDone {
+		syncSearchIndex(attachment.NoteID)
+	}
+	if attachment.BlobHash != "" {
+		return nil
+	}
+
+	// 记录删除成功后再删除文件，删除失败的文件由垃圾回收清理
+	keys := []string{storage.NormalizeKey(attachment.StoragePath)}
+	for _, variant := range variants {
+		keys = append(keys, variant.StoragePath)
+	}
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			logger.Warn("删除附件文件失败", "attachment_id", attachment.ID, "key", key, "error", err)
+		}
+	}
+	return nil
+}
+
+// matchPolicy 按识别出的类型匹配上传策略
+// 从具体类型逐级向上查找父类型，例如 docx 先匹配自身，再匹配 application/zip
+func matchPolicy(detected *mimetype.MIME) (string, string, config.AttachmentPolicy, bool) {
+	cfg := config.GlobalConfig.Attachment
+	for m := detected; m != nil; m = m.Parent() {
+		mediaType, _, _ := strings.Cut(m.String(), ";")
+		mediaType = strings.TrimSpace(mediaType)
+		if category, policy, ok := cfg.Match(mediaType); ok {
+			return mediaType, category, policy, true
+		}
+	}
+	return "", "", config.AttachmentPolicy{}, false
+}
+
+// reserveStorage 占用用户存储配额
+func (s *AttachmentService) reserveStorage(userID uint64, size int64) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	ok, err := s.userRepo.ReserveStorage(userID, size, EffectiveStorageQuota(user))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// releaseStorage 释放用户存储配额，失败只记录日志
+func (s *AttachmentService) releaseStorage(userID uint64, size int64) {
+	if err := s.userRepo.ReleaseStorage(userID, size); err != nil {
+		logger.Error("释放存储配额失败", "user_id", userID, "bytes", size, "error", err)
+	}
+}
+
+// EffectiveStorageQuota 用户实际生效的存储配额（字节），0 表示不限制
+func EffectiveStorageQuota(user *model.User) int64 {
+	if user.StorageQuota > 0 {
+		return user.StorageQuota
+	}
+	return config.GlobalConfig.Attachment.DefaultQuota * 1024 * 1024
+}
+
 // GetAttachments 获取笔记的附件列表
 func (s *AttachmentService) GetAttachments(userID uint64, noteID uint64) ([]*model.NoteAttachment, error) {
 	// 验证笔记所有权
@@ -126,11 +396,144 @@ func (s *AttachmentService) GetAttachments(userID uint64, noteID uint64) ([]*mod
 		return nil, fmt.Errorf("无权限访问该笔记")
 	}
 
-	return s.repo.GetByNoteID(noteID)
+	attachments, err := s.repo.GetByNoteID(noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	// URL 在读取时生成带签名的下载地址，<img> 标签无需携带令牌即可访问
+	for _, attachment := range attachments {
+		s.resolveURL(attachment)
+	}
+	return attachments, nil
+}
+
+// ContentPath 附件下载接口路径（不含签名）
+func ContentPath(attachmentID uint64) string {
+	return fmt.Sprintf("/api/v1/attachments/%d/content", attachmentID)
+}
+
+// contentResource 附件下载签名的资源标识，包含尺寸，修改 ?size= 后签名失效
+// size 为空表示原图
+func contentResource(attachmentID uint64, size string) string {
+	if size == "" {
+		return fmt.Sprintf("attachment:%d", attachmentID)
+	}
+	return fmt.Sprintf("attachment:%d:%s", attachmentID, size)
+}
+
+// resolveURL 为附件及其缩略图生成带签名的下载地址
+// 每个地址的签名绑定附件和尺寸，只能访问签发时的那一个尺寸
+func (s *AttachmentService) resolveURL(attachment *model.NoteAttachment) {
+	attachment.URL = s.signer.SignURL(ContentPath(attachment.ID), contentResource(attachment.ID, ""), urlExpiry())
+	for _, variant := range attachment.Variants {
+		path := ContentPath(attachment.ID) + "?size=" + url.QueryEscape(variant.Name)
+		variant.URL = s.signer.SignURL(path, contentResource(attachment.ID, variant.Name), urlExpiry())
+	}
+}
+
+// urlExpiry 签名地址有效期
+func urlExpiry() time.Duration {
+	return time.Duration(config.GlobalConfig.Storage.URLExpiry) * time.Minute
+}
+
+// OpenContent 以登录用户身份打开附件内容，校验所有权
+// size 不为空时返回对应尺寸的缩略图，缩略图不存在时回退到原图
+// 调用方负责关闭返回的对象
+func (s *AttachmentService) OpenContent(userID, attachmentID uint64, size string) (*model.NoteAttachment, storage.Object, error) {
+	attachment, err := s.repo.GetByID(attachmentID)
+	if err != nil {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if attachment.UserID != userID {
+		return nil, nil, ErrAttachmentForbidden
+	}
+	return s.open(attachment, size)
+}
+
+// OpenSignedContent 通过签名地址打开附件内容
+// 签名无效（包括 size 与签发时不同）时返回 signedurl.ErrInvalidSignature，过期时返回 signedurl.ErrExpired；
+// 签名地址不经过 ActiveUser，所有者已被锁定或已申请注销时返回 ErrAttachmentForbidden，已签发的地址随之失效
+func (s *AttachmentService) OpenSignedContent(attachmentID uint64, size string, expires int64, sig string) (*model.NoteAttachment, storage.Object, error) {
+	if err := s.signer.Verify(contentResource(attachmentID, size), expires, sig); err != nil {
+		return nil, nil, err
+	}
+	attachment, err := s.repo.GetByID(attachmentID)
+	if err != nil {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err := s.checkOwnerActive(attachment.UserID); err != nil {
+		return nil, nil, err
+	}
+	return s.open(attachment, size)
+}
+
+// checkOwnerActive 与 ActiveUser 中间件相同，拒绝不存在、已被锁定或已申请注销的账号
+func (s *AttachmentService) checkOwnerActive(userID uint64) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrAttachmentNotFound
+	}
+	if user.IsLocked || user.DeletionScheduledAt != nil {
+		return ErrAttachmentForbidden
+	}
+	return nil
+}
+
+// OpenLegacyContent 以登录用户身份按升级前的对象键（如 images/user_1/xxx.png）打开附件原图
+func (s *AttachmentService) OpenLegacyContent(userID uint64, key string) (*model.NoteAttachment, storage.Object, error) {
+	ids, err := legacyAttachmentIDs(s.repo, userID, []string{key})
+	if err != nil {
+		return nil, nil, err
+	}
+	id, ok := ids[key]
+	if !ok {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	return s.OpenContent(userID, id, "")
+}
+
+func (s *AttachmentService) open(attachment *model.NoteAttachment, size string) (*model.NoteAttachment, storage.Object, error) {
+	switch attachment.ScanStatus {
+	case model.ScanStatusPending:
+		return nil, nil, ErrAttachmentScanPending
+	case model.ScanStatusInfected:
+		return nil, nil, ErrAttachmentInfected
+	}
+
+	key := storage.NormalizeKey(attachment.StoragePath)
+
+	if size != "" {
+		variant, err := s.repo.GetVariant(attachment.ID, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		if variant != nil {
+			// 缩略图沿用原附件的文件名和权限，只替换内容类型和大小
+			served := *attachment
+			served.MimeType = variant.MimeType
+			served.FileSize = variant.FileSize
+			attachment = &served
+			key = variant.StoragePath
+		}
+	}
+
+	obj, err := s.storage.Open(context.Background(), key)
+	if err != nil {
+		if err == storage.ErrNotExist {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return attachment, obj, nil
 }
 
 // DeleteAttachment 删除附件
-func (s *AttachmentService) DeleteAttachment(userID uint64, attachmentID uint64) error {
+// 附件仍被笔记正文引用时返回 ErrAttachmentReferenced，force 为 true 时强制删除并从正文中移除引用
+func (s *AttachmentService) DeleteAttachment(userID uint64, attachmentID uint64, force bool) error {
 	// 1. 获取附件信息
 	attachment, err := s.repo.GetByID(attachmentID)
 	if err != nil {
@@ -142,13 +545,45 @@ func (s *AttachmentService) DeleteAttachment(userID uint64, attachmentID uint64)
 		return fmt.Errorf("无权限删除该附件")
 	}
 
-	// 3. 删除文件
-	if err := os.Remove(attachment.StoragePath); err != nil {
-		// 文件可能已被删除，只记录日志，不影响数据库删除
-		fmt.Printf("删除文件失败: %v\n", err)
+	// 3. 仍被正文引用时需确认
+	if attachment.Referenced && !force {
+		return ErrAttachmentReferenced
+	}
+
+	// 4. 删除数据库记录和文件（含缩略图），共享的内容文件仍被引用时保留
+	if err := s.removeAttachment(context.Background(), attachment); err != nil {
+		return err
 	}
 
-	// 4. 删除数据库记录
-	return s.repo.Delete(attachmentID)
+	// 5. 释放存储配额
+	s.releaseStorage(userID, int64(attachment.FileSize))
+
+	// 6. 从正文中移除引用，避免留下失效的链接
+	if attachment.Referenced {
+		s.removeNoteRefs(userID, attachment)
+	}
+	return nil
+}
+
+// removeNoteRefs 从附件所属笔记（含回收站中的笔记）的正文中移除对该附件的引用，失败只记录日志
+func (s *AttachmentService) removeNoteRefs(userID uint64, attachment *model.NoteAttachment) {
+	note, err := s.noteRepo.GetByIDAndUserID(attachment.NoteID, userID)
+	if err == nil && note == nil {
+		note, err = s.noteRepo.GetDeletedByIDAndUserID(attachment.NoteID, userID)
+	}
+	if err != nil || note == nil {
+		if err != nil {
+			logger.Error("移除正文中的附件引用失败", "attachment_id", attachment.ID, "error", err)
+		}
+		return
+	}
+
+	content := removeAttachmentRefs(note.Content, attachment.ID)
+	if content == note.Content {
+		return
+	}
+	if err := s.noteRepo.UpdateFields(note.ID, map[string]interface{}{"content": content}); err != nil {
+		logger.Error("移除正文中的附件引用失败", "attachment_id", attachment.ID, "note_id", note.ID, "error", err)
+	}
 }
 
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+var ErrAttachmentGCRunning = errors.New("附件垃圾回收正在执行")
+
+const (
+	gcBatchSize   = 500
+	gcSampleLimit = 50
+)
+
+var (
+	// attachmentGCMu 保证同一时间只有一个回收任务（定时任务与管理员手动触发互斥）
+	attachmentGCMu sync.Mutex
+
+	lastGCReportMu sync.RWMutex
+	lastGCReport   *model.AttachmentGCReport
+)
+
+// LastAttachmentGCReport 最近一次附件垃圾回收的报告，尚未执行过时返回 nil
+func LastAttachmentGCReport() *model.AttachmentGCReport {
+	lastGCReportMu.RLock()
+	defer lastGCReportMu.RUnlock()
+	return lastGCReport
+}
+
+// CollectGarbage 对账附件记录与存储文件
+//  1. 所属笔记已不存在的附件：删除文件和记录
+//  2. 文件已丢失的附件：删除记录；丢失的缩略图删除记录后重新生成
+//  3. 已无附件引用的内容文件：删除文件和记录（账号清除后由此回收）
+//  4. 没有对应记录的文件：超过保留时间后删除（上传中途失败会留下这类文件）
+//
+// 受影响用户的存储用量按剩余记录重新计算。dryRun 为 true 时只统计，不做任何修改
+func (s *AttachmentService) CollectGarbage(ctx context.Context, dryRun bool) (*model.AttachmentGCReport, error) {
+	if !attachmentGCMu.TryLock() {
+		return nil, ErrAttachmentGCRunning
+	}
+	defer attachmentGCMu.Unlock()
+
+	gc := &attachmentGC{
+		service:  s,
+		ctx:      ctx,
+		report:   &model.AttachmentGCReport{DryRun: dryRun, StartedAt: time.Now()},
+		known:    make(map[string]struct{}),
+		affected: make(map[uint64]struct{}),
+	}
+
+	steps := []func() error{gc.collectOrphanRows, gc.collectOrphanTexts, gc.collectMissingFiles, gc.collectMissingVariants, gc.collectOrphanBlobs, gc.collectOrphanFiles}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	gc.recalculateStorage()
+
+	report := gc.report
+	report.FinishedAt = time.Now()
+	report.DurationMs = report.FinishedAt.Sub(report.StartedAt).Milliseconds()
+
+	lastGCReportMu.Lock()
+	lastGCReport = report
+	lastGCReportMu.Unlock()
+
+	logger.Info("附件垃圾回收完成",
+		"dry_run", dryRun,
+		"scanned_rows", report.ScannedRows,
+		"scanned_files", report.ScannedFiles,
+		"orphan_rows", report.OrphanRows,
+		"orphan_texts", report.OrphanTexts,
+		"missing_files", report.MissingFiles,
+		"missing_variants", report.MissingVariants,
+		"orphan_files", report.OrphanFiles,
+		"orphan_blobs", report.OrphanBlobs,
+		"freed_bytes", report.FreedBytes,
+		"errors", report.Errors,
+		"duration_ms", report.DurationMs,
+	)
+	return report, nil
+}
+
+// attachmentGC 单次垃圾回收的状态
+type attachmentGC struct {
+	service *AttachmentService
+	ctx     context.Context
+	report  *model.AttachmentGCReport
+
+	// known 数据库中仍被引用的对象键，用于识别无记录文件
+	known map[string]struct{}
+	// affected 需要重新计算存储用量的用户
+	affected map[uint64]struct{}
+	// removed 本次已删除（或试运行时将删除）的附件，后续步骤跳过
+	removed map[uint64]struct{}
+}
+
+// collectOrphanRows 清理所属笔记已不存在的附件
+func (gc *attachmentGC) collectOrphanRows() error {
+	s := gc.service
+	gc.removed = make(map[uint64]struct{})
+
+	var afterID uint64
+	for {
+		attachments, err := s.repo.ListOrphansAfterID(afterID, gcBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(attachments) == 0 {
+			return nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+
+		for _, attachment := range attachments {
+			gc.report.OrphanRows++
+			// 共享的内容文件在引用归零时统计
+			if attachment.BlobHash == "" {
+				gc.report.FreedBytes += int64(attachment.FileSize)
+			}
+			if len(gc.report.OrphanRowIDs) < gcSampleLimit {
+				gc.report.OrphanRowIDs = append(gc.report.OrphanRowIDs, attachment.ID)
+			}
+			gc.removed[attachment.ID] = struct{}{}
+			gc.affected[attachment.UserID] = struct{}{}
+			if gc.report.DryRun {
+				continue
+			}
+
+			if err := gc.service.removeAttachment(gc.ctx, attachment); err != nil {
+				logger.Error("清理孤立附件失败", "attachment_id", attachment.ID, "error", err)
+				gc.report.Errors++
+				delete(gc.removed, attachment.ID)
+			}
+		}
+	}
+}
+
+// collectOrphanTexts 清理附件已不存在的提取文本
+func (gc *attachmentGC) collectOrphanTexts() error {
+	var (
+		count int64
+		err   error
+	)
+	if gc.report.DryRun {
+		count, err = gc.service.repo.CountOrphanTexts()
+	} else {
+		count, err = gc.service.repo.DeleteOrphanTexts()
+	}
+	if err != nil {
+		return err
+	}
+	gc.report.OrphanTexts = int(count)
+	return nil
+}
+
+// collectMissingFiles 清理文件已丢失的附件记录，同时收集仍被引用的对象键
+func (gc *attachmentGC) collectMissingFiles() error {
+	s := gc.service
+
+	var afterID uint64
+	for {
+		attachments, err := s.repo.ListAfterID(afterID, gcBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(attachments) == 0 {
+			return nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+
+		for _, attachment := range attachments {
+			if _, ok := gc.removed[attachment.ID]; ok {
+				continue
+			}
+			gc.report.ScannedRows++
+
+			key := storage.NormalizeKey(attachment.StoragePath)
+			_, err := s.storage.Stat(gc.ctx, key)
+			if err == nil {
+				gc.known[key] = struct{}{}
+				continue
+			}
+			if !errors.Is(err, storage.ErrNotExist) {
+				// 存储暂时不可用时保留记录，同时保护该文件不被当作无记录文件删除
+				logger.Warn("检查附件文件失败", "attachment_id", attachment.ID, "key", key, "error", err)
+				gc.report.Errors++
+				gc.known[key] = struct{}{}
+				continue
+			}
+
+			gc.report.MissingFiles++
+			if len(gc.report.MissingFileIDs) < gcSampleLimit {
+				gc.report.MissingFileIDs = append(gc.report.MissingFileIDs, attachment.ID)
+			}
+			gc.removed[attachment.ID] = struct{}{}
+			gc.affected[attachment.UserID] = struct{}{}
+			if gc.report.DryRun {
+				continue
+			}
+
+			if err := gc.service.removeAttachment(gc.ctx, attachment); err != nil {
+				logger.Error("清理丢失文件的附件记录失败", "attachment_id", attachment.ID, "error", err)
+				gc.report.Errors++
+			}
+		}
+	}
+}
+
+// collectMissingVariants 清理文件已丢失的缩略图记录，并重新生成缩略图
+func (gc *attachmentGC) collectMissingVariants() error {
+	s := gc.service
+	regenerate := make(map[uint64]struct{})
+
+	var afterID uint64
+	for {
+		variants, err := s.repo.ListVariantsAfterID(afterID, gcBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(variants) == 0 {
+			break
+		}
+		afterID = variants[len(variants)-1].ID
+
+		for _, variant := range variants {
+			if _, ok := gc.removed[variant.AttachmentID]; ok {
+				continue
+			}
+			gc.report.ScannedVariants++
+
+			_, err := s.storage.Stat(gc.ctx, variant.StoragePath)
+			if err == nil {
+				gc.known[variant.StoragePath] = struct{}{}
+				continue
+			}
+			if !errors.Is(err, storage.ErrNotExist) {
+				logger.Warn("检查缩略图文件失败", "attachment_id", variant.AttachmentID, "key", variant.StoragePath, "error", err)
+				gc.report.Errors++
+				gc.known[variant.StoragePath] = struct{}{}
+				continue
+			}
+
+			gc.report.MissingVariants++
+			if gc.report.DryRun {
+				continue
+			}
+			if err := s.repo.DeleteVariant(variant.ID); err != nil {
+				logger.Error("清理丢失文件的缩略图记录失败", "attachment_id", variant.AttachmentID, "variant", variant.Name, "error", err)
+				gc.report.Errors++
+				continue
+			}
+			regenerate[variant.AttachmentID] = struct{}{}
+		}
+	}
+
+	for attachmentID := range regenerate {
+		if err := s.repo.UpdateVariantStatus(attachmentID, model.VariantStatusPending); err != nil {
+			logger.Error("更新缩略图状态失败", "attachment_id", attachmentID, "error", err)
+			continue
+		}
+		enqueueVariants(attachmentID)
+	}
+	return nil
+}
+
+// collectOrphanBlobs 删除已无附件引用的内容文件
+// 只处理超过保留时间的记录，删除前加锁重新检查引用，避免与上传相同内容的请求冲突
+func (gc *attachmentGC) collectOrphanBlobs() error {
+	s := gc.service
+	cutoff := time.Now().Add(-time.Duration(config.GlobalConfig.Attachment.GC.OrphanGrace) * time.Minute)
+
+	var afterHash string
+	for {
+		blobs, err := s.repo.ListOrphanBlobs(afterHash, cutoff, gcBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(blobs) == 0 {
+			return nil
+		}
+		afterHash = blobs[len(blobs)-1].Hash
+
+		for _, blob := range blobs {
+			if gc.report.DryRun {
+				gc.report.OrphanBlobs++
+				gc.report.FreedBytes += blob.Size
+				// 试运行时文件不会被删除，避免再作为无记录文件重复统计
+				for _, key := range blobFileKeys(blob) {
+					gc.known[key] = struct{}{}
+				}
+				continue
+			}
+
+			deleted, err := s.repo.DeleteOrphanBlob(blob.Hash, cutoff, func(blob *model.AttachmentBlob) {
+				s.deleteBlobFiles(gc.ctx, blob)
+			})
+			if err != nil {
+				logger.Error("清理无引用内容失败", "hash", blob.Hash, "error", err)
+				gc.report.Errors++
+				continue
+			}
+			if deleted {
+				gc.report.OrphanBlobs++
+				gc.report.FreedBytes += blob.Size
+			}
+		}
+	}
+}
+
+// collectOrphanFiles 删除没有对应记录的文件
+// 只处理超过保留时间的文件，避免删除已写入存储但记录尚未提交的上传
+func (gc *attachmentGC) collectOrphanFiles() error {
+	s := gc.service
+	cutoff := time.Now().Add(-time.Duration(config.GlobalConfig.Attachment.GC.OrphanGrace) * time.Minute)
+
+	var orphans []storage.ObjectInfo
+	for _, prefix := range []string{BlobKeyPrefix + "/", AttachmentKeyPrefix + "/", LegacyImageKeyPrefix + "/", QuarantineKeyPrefix + "/"} {
+		err := s.storage.List(gc.ctx, prefix, func(info storage.ObjectInfo) error {
+			gc.report.ScannedFiles++
+			if _, ok := gc.known[info.Key]; ok {
+				return nil
+			}
+			if info.LastModified.After(cutoff) {
+				return nil
+			}
+			orphans = append(orphans, info)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// 遍历结束后再删除，避免边遍历边修改目录
+	for _, info := range orphans {
+		gc.report.OrphanFiles++
+		gc.report.FreedBytes += info.Size
+		if len(gc.report.OrphanFileKeys) < gcSampleLimit {
+			gc.report.OrphanFileKeys = append(gc.report.OrphanFileKeys, info.Key)
+		}
+		if gc.report.DryRun {
+			continue
+		}
+		if err := s.storage.Delete(gc.ctx, info.Key); err != nil {
+			logger.Error("删除无记录文件失败", "key", info.Key, "error", err)
+			gc.report.Errors++
+		}
+	}
+	return nil
+}
+
+// recalculateStorage 按剩余附件记录重新计算受影响用户的存储用量
+func (gc *attachmentGC) recalculateStorage() {
+	for userID := range gc.affected {
+		gc.report.AffectedUserIDs = append(gc.report.AffectedUserIDs, userID)
+		if gc.report.DryRun {
+			continue
+		}
+		if err := gc.service.userRepo.RecalculateStorage(userID); err != nil {
+			logger.Error("重新计算存储用量失败", "user_id", userID, "error", err)
+			gc.report.Errors++
+		}
+	}
+}
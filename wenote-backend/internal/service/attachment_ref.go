@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+// ErrAttachmentReferenced 附件仍被笔记正文引用
+var ErrAttachmentReferenced = errors.New("附件仍被笔记正文引用，确认删除请使用 force=true")
+
+// attachmentRefPattern 正文中的附件引用，即附件下载地址
+// 可带域名和任意查询参数（签名、尺寸等），保存时统一转换为不含签名的站内路径
+var attachmentRefPattern = regexp.MustCompile(`(?:https?://[^/\s"'()<>]+)?/api/v1/attachments/(\d+)/content(?:\?[^\s"'()<>]*)?`)
+
+// legacyAttachmentRefPattern 升级前上传接口写入正文的本地文件地址，如 /uploads/images/user_1/xxx.png
+// 子匹配为对象键 images/user_1/xxx.png
+var legacyAttachmentRefPattern = regexp.MustCompile(`(?:https?://[^/\s"'()<>]+)?/uploads/(images/user_\d+/[^/\s"'()<>?#]+)(?:\?[^\s"'()<>]*)?`)
+
+// legacyRefMarker 正文中旧版附件地址的公共部分，用于筛选需要改写的笔记
+const legacyRefMarker = "/uploads/images/"
+
+// attachmentRef 正文中的一处附件引用
+type attachmentRef struct {
+	id   uint64
+	size string // 缩略图尺寸，原图为空
+}
+
+// parseAttachmentRef 解析单个引用，匹配结果不是合法引用时返回 false
+func parseAttachmentRef(match string) (attachmentRef, bool) {
+	sub := attachmentRefPattern.FindStringSubmatch(match)
+	if sub == nil {
+		return attachmentRef{}, false
+	}
+	id, err := strconv.ParseUint(sub[1], 10, 64)
+	if err != nil {
+		return attachmentRef{}, false
+	}
+	ref := attachmentRef{id: id}
+	if u, err := url.Parse(match); err == nil {
+		ref.size = u.Query().Get("size")
+	}
+	return ref, true
+}
+
+// path 引用的规范形式：不含域名和签名，仅保留尺寸参数
+func (r attachmentRef) path() string {
+	if r.size == "" {
+		return ContentPath(r.id)
+	}
+	return ContentPath(r.id) + "?size=" + url.QueryEscape(r.size)
+}
+
+// canonicalizeAttachmentRefs 将正文中的附件引用转换为规范形式，返回转换后的正文和引用的附件ID（去重）
+// 前端可直接粘贴上传接口返回的签名地址，签名过期后正文中的地址不会失效
+func canonicalizeAttachmentRefs(content string) (string, []uint64) {
+	seen := make(map[uint64]bool)
+	var ids []uint64
+	content = attachmentRefPattern.ReplaceAllStringFunc(content, func(match string) string {
+		ref, ok := parseAttachmentRef(match)
+		if !ok {
+			return match
+		}
+		if !seen[ref.id] {
+			seen[ref.id] = true
+			ids = append(ids, ref.id)
+		}
+		return ref.path()
+	})
+	return content, ids
+}
+
+// legacyAttachmentIDs 按对象键查找用户的旧附件，返回对象键到附件ID的映射
+// 旧附件的 url 为 /uploads/ 加对象键；迁移存储后 url 被改写，storage_path 为对象键或带 uploads/ 前缀的本地路径
+func legacyAttachmentIDs(attachmentRepo *repo.AttachmentRepo, userID uint64, keys []string) (map[string]uint64, error) {
+	wanted := make(map[string]bool, len(keys))
+	var urls, storagePaths []string
+	for _, key := range keys {
+		if wanted[key] {
+			continue
+		}
+		wanted[key] = true
+		urls = append(urls, "/uploads/"+key)
+		storagePaths = append(storagePaths, key, "uploads/"+key, "./uploads/"+key)
+	}
+
+	attachments, err := attachmentRepo.ListByLegacyPaths(userID, urls, storagePaths)
+	if err != nil {
+		return nil, err
+	}
+	return matchLegacyAttachments(attachments, wanted), nil
+}
+
+// matchLegacyAttachments 按旧版访问地址或存储路径将附件对应到对象键
+func matchLegacyAttachments(attachments []*model.NoteAttachment, wanted map[string]bool) map[string]uint64 {
+	ids := make(map[string]uint64, len(attachments))
+	for _, attachment := range attachments {
+		if key := strings.TrimPrefix(attachment.URL, "/uploads/"); wanted[key] {
+			ids[key] = attachment.ID
+		}
+		if key := storage.NormalizeKey(attachment.StoragePath); wanted[key] {
+			ids[key] = attachment.ID
+		}
+	}
+	return ids
+}
+
+// resolveLegacyAttachmentRefs 将正文中属于该用户的旧版附件地址替换为规范形式，无法识别的地址保持不变
+func resolveLegacyAttachmentRefs(attachmentRepo *repo.AttachmentRepo, userID uint64, content string) (string, error) {
+	keys := legacyAttachmentKeys(content)
+	if len(keys) == 0 {
+		return content, nil
+	}
+	ids, err := legacyAttachmentIDs(attachmentRepo, userID, keys)
+	if err != nil {
+		return "", err
+	}
+	return replaceLegacyAttachmentRefs(content, ids), nil
+}
+
+// legacyAttachmentKeys 正文中旧版附件地址对应的对象键
+func legacyAttachmentKeys(content string) []string {
+	matches := legacyAttachmentRefPattern.FindAllStringSubmatch(content, -1)
+	keys := make([]string, len(matches))
+	for i, sub := range matches {
+		keys[i] = sub[1]
+	}
+	return keys
+}
+
+// replaceLegacyAttachmentRefs 按对象键到附件ID的映射将旧版地址替换为规范形式
+func replaceLegacyAttachmentRefs(content string, ids map[string]uint64) string {
+	return legacyAttachmentRefPattern.ReplaceAllStringFunc(content, func(match string) string {
+		sub := legacyAttachmentRefPattern.FindStringSubmatch(match)
+		if id, ok := ids[sub[1]]; ok {
+			return ContentPath(id)
+		}
+		return match
+	})
+}
+
+// canonicalizeContent 识别正文中的旧版附件地址后转换为规范形式，返回转换后的正文
+func (s *NoteService) canonicalizeContent(userID uint64, content string) (string, error) {
+	content, err := resolveLegacyAttachmentRefs(s.attachmentRepo, userID, content)
+	if err != nil {
+		return "", err
+	}
+	content, _ = canonicalizeAttachmentRefs(content)
+	return content, nil
+}
+
+// RewriteLegacyAttachmentRefs 将笔记正文中升级前的附件地址改写为规范形式，并标记这些附件被引用
+// 附件去重会将旧附件的 storage_path 改为内容键，之后旧地址无法再识别，
+// 因此在服务启动和去重前执行；可重复执行，返回改写的笔记数
+func (s *NoteService) RewriteLegacyAttachmentRefs() (int, error) {
+	var rewritten int
+	var lastID uint64
+	for {
+		notes, err := s.noteRepo.ListContainingAfterID(lastID, legacyRefMarker, 200)
+		if err != nil {
+			return rewritten, err
+		}
+		if len(notes) == 0 {
+			return rewritten, nil
+		}
+
+		for _, note := range notes {
+			lastID = note.ID
+			content, err := resolveLegacyAttachmentRefs(s.attachmentRepo, note.UserID, note.Content)
+			if err != nil {
+				return rewritten, err
+			}
+			if content == note.Content {
+				continue
+			}
+			if err := s.noteRepo.UpdateFieldsWithoutTime(note.ID, map[string]interface{}{"content": content}); err != nil {
+				return rewritten, err
+			}
+			if err := s.attachmentRepo.SyncReferences(note.ID, referencedAttachmentIDs(content), time.Now()); err != nil {
+				return rewritten, err
+			}
+			rewritten++
+		}
+	}
+}
+
+// referencedAttachmentIDs 正文中引用的附件ID（去重）
+func referencedAttachmentIDs(content string) []uint64 {
+	_, ids := canonicalizeAttachmentRefs(content)
+	return ids
+}
+
+// removeAttachmentRefs 从正文中移除对指定附件的引用
+// Markdown 图片和链接语法、HTML 图片标签整体移除，其余位置只移除地址
+func removeAttachmentRefs(content string, attachmentID uint64) string {
+	ref := `(?:https?://[^/\s"'()<>]+)?/api/v1/attachments/` + strconv.FormatUint(attachmentID, 10) + `/content(?:\?[^\s"'()<>]*)?`
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`!?\[[^\]\n]*\]\(\s*` + ref + `(?:\s+"[^"\n]*")?\s*\)`),
+		regexp.MustCompile(`<img\b[^>]*\bsrc\s*=\s*["']` + ref + `["'][^>]*>`),
+		regexp.MustCompile(ref),
+	}
+	for _, p := range patterns {
+		content = p.ReplaceAllString(content, "")
+	}
+	return content
+}
+
+// syncAttachmentRefs 按正文更新笔记附件的引用状态，失败只记录日志
+func (s *NoteService) syncAttachmentRefs(noteID uint64, content string) {
+	if err := s.attachmentRepo.SyncReferences(noteID, referencedAttachmentIDs(content), time.Now()); err != nil {
+		logger.Error("更新附件引用状态失败", "note_id", noteID, "error", err)
+	}
+}
+
+// signAttachmentRefs 将正文中的附件引用替换为带签名的下载地址，供 <img> 等直接加载
+// 只为属于该用户的附件签名，避免在正文中写入他人附件ID获取访问权限
+func (s *NoteService) signAttachmentRefs(userID uint64, notes ...*model.Note) error {
+	var ids []uint64
+	for _, note := range notes {
+		if note == nil {
+			continue
+		}
+		// 尚未改写的旧版地址同样换成签名地址，旧的静态文件路径已不再提供
+		content, err := resolveLegacyAttachmentRefs(s.attachmentRepo, userID, note.Content)
+		if err != nil {
+			return err
+		}
+		note.Content = content
+		ids = append(ids, referencedAttachmentIDs(note.Content)...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ownedIDs, err := s.attachmentRepo.ListOwnedIDs(userID, ids)
+	if err != nil {
+		return err
+	}
+	owned := make(map[uint64]bool, len(ownedIDs))
+	for _, id := range ownedIDs {
+		owned[id] = true
+	}
+
+	expiry := urlExpiry()
+	for _, note := range notes {
+		if note == nil {
+			continue
+		}
+		note.Content = attachmentRefPattern.ReplaceAllStringFunc(note.Content, func(match string) string {
+			ref, ok := parseAttachmentRef(match)
+			if !ok || !owned[ref.id] {
+				return match
+			}
+			return s.signer.SignURL(ref.path(), contentResource(ref.id, ref.size), expiry)
+		})
+	}
+	return nil
+}
+
+// CleanupUnreferencedAttachments 删除超过保留期仍未被笔记正文引用的附件
+func (s *AttachmentService) CleanupUnreferencedAttachments() (int64, error) {
+	cfg := config.GlobalConfig.Attachment.Refs
+	if !cfg.ExpireUnreferenced {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(cfg.Grace) * time.Hour)
+	ctx := context.Background()
+
+	var deleted int64
+	for {
+		attachments, err := s.repo.ListUnreferencedBefore(cutoff, 100)
+		if err != nil {
+			return deleted, err
+		}
+		if len(attachments) == 0 {
+			return deleted, nil
+		}
+
+		for _, attachment := range attachments {
+			if err := s.removeAttachment(ctx, attachment); err != nil {
+				return deleted, fmt.Errorf("删除未引用附件 %d 失败: %w", attachment.ID, err)
+			}
+			s.releaseStorage(attachment.UserID, int64(attachment.FileSize))
+			deleted++
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"wenote-backend/internal/model"
+)
+
+func TestLegacyAttachmentRefs(t *testing.T) {
+	content := "![a](/uploads/images/user_1/100_7.png)\n" +
+		`<img src="https://note.example.com/uploads/images/user_1/200_7.jpg?v=1" alt="b">` + "\n" +
+		"![c](/uploads/images/user_1/300_7.png)\n" +
+		"![d](/api/v1/attachments/5/content)"
+
+	keys := legacyAttachmentKeys(content)
+	want := []string{"images/user_1/100_7.png", "images/user_1/200_7.jpg", "images/user_1/300_7.png"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("legacyAttachmentKeys = %v，期望 %v", keys, want)
+	}
+
+	// 300_7.png 找不到对应的附件，保持原样
+	got := replaceLegacyAttachmentRefs(content, map[string]uint64{
+		"images/user_1/100_7.png": 11,
+		"images/user_1/200_7.jpg": 12,
+	})
+	wantContent := "![a](/api/v1/attachments/11/content)\n" +
+		`<img src="/api/v1/attachments/12/content" alt="b">` + "\n" +
+		"![c](/uploads/images/user_1/300_7.png)\n" +
+		"![d](/api/v1/attachments/5/content)"
+	if got != wantContent {
+		t.Errorf("replaceLegacyAttachmentRefs =\n%s\n期望\n%s", got, wantContent)
+	}
+
+	// 改写后旧附件与新附件一样被识别为正文引用，不会被标记为未引用
+	if ids := referencedAttachmentIDs(got); !reflect.DeepEqual(ids, []uint64{11, 12, 5}) {
+		t.Errorf("referencedAttachmentIDs = %v，期望 [11 12 5]", ids)
+	}
+}
+
+func TestMatchLegacyAttachments(t *testing.T) {
+	wanted := map[string]bool{
+		"images/user_1/a.png": true,
+		"images/user_1/b.png": true,
+		"images/user_1/c.png": true,
+	}
+	attachments := []*model.NoteAttachment{
+		// 升级前的记录
+		{ID: 1, URL: "/uploads/images/user_1/a.png", StoragePath: "uploads/images/user_1/a.png"},
+		// 迁移存储后 url 已改写，storage_path 为对象键
+		{ID: 2, URL: "/api/v1/attachments/2/content", StoragePath: "images/user_1/b.png"},
+		// 早期以 ./uploads 开头的本地路径
+		{ID: 3, URL: "/api/v1/attachments/3/content", StoragePath: "./uploads/images/user_1/c.png"},
+		// 与任何旧地址无关
+		{ID: 4, URL: "/api/v1/attachments/4/content", StoragePath: "blobs/ab/abcd"},
+	}
+
+	got := matchLegacyAttachments(attachments, wanted)
+	want := map[string]uint64{
+		"images/user_1/a.png": 1,
+		"images/user_1/b.png": 2,
+		"images/user_1/c.png": 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matchLegacyAttachments = %v，期望 %v", got, want)
+	}
+}
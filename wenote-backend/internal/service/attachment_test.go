@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/signedurl"
+	"wenote-backend/pkg/storage"
+)
+
+func TestDeleteBlobFilesRemovesQuarantinedCopy(t *testing.T) {
+	prev := config.GlobalConfig
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.Attachment.Image.Thumbnails = map[string]int{"small": 160}
+	t.Cleanup(func() { config.GlobalConfig = prev })
+
+	hash := strings.Repeat("ab", 32)
+	key := BlobKey(hash)
+
+	tests := []struct {
+		name        string
+		storagePath string
+	}{
+		{"内容记录指向原对象键", key},
+		{"早期版本隔离时改写过内容记录", QuarantineKey(key)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStorage()
+			s := &AttachmentService{storage: store}
+			ctx := context.Background()
+
+			// 隔离后再次上传相同内容，原对象键和隔离区中同时存在文件
+			keys := []string{key, QuarantineKey(key), VariantKey(key, "small", "image/jpeg")}
+			for _, k := range keys {
+				if err := store.Put(ctx, k, strings.NewReader("x"), 1, "image/jpeg"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s.deleteBlobFiles(ctx, &model.AttachmentBlob{Hash: hash, StoragePath: tt.storagePath})
+
+			for _, k := range keys {
+				if _, err := store.Stat(ctx, k); !errors.Is(err, storage.ErrNotExist) {
+					t.Errorf("%s 应被删除，Stat 返回 %v", k, err)
+				}
+			}
+		})
+	}
+}
+
+// TestOpenSignedContentOwnerInactive 所有者被锁定或已申请注销后，已签发的下载地址不再可用
+func TestOpenSignedContentOwnerInactive(t *testing.T) {
+	openTestDB(t)
+
+	prev := config.GlobalConfig
+	config.GlobalConfig = &config.Config{}
+	t.Cleanup(func() { config.GlobalConfig = prev })
+
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	s := &AttachmentService{
+		repo:     repo.NewAttachmentRepo(),
+		userRepo: repo.NewUserRepo(),
+		storage:  store,
+		signer:   signedurl.NewSigner("test-key"),
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		locked    bool
+		scheduled *time.Time
+		want      error
+	}{
+		{"正常账号", false, nil, nil},
+		{"已被锁定", true, nil, ErrAttachmentForbidden},
+		{"已申请注销", false, &now, ErrAttachmentForbidden},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{
+				Username:            fmt.Sprintf("signed_owner_%d_%d", now.UnixNano(), i),
+				PasswordHash:        "x",
+				IsLocked:            tt.locked,
+				DeletionScheduledAt: tt.scheduled,
+			}
+			if err := repo.DB.Create(user).Error; err != nil {
+				t.Fatalf("创建用户失败: %v", err)
+			}
+			t.Cleanup(func() {
+				repo.DB.Exec("DELETE FROM note_attachments WHERE user_id = ?", user.ID)
+				repo.DB.Exec("DELETE FROM users WHERE id = ?", user.ID)
+			})
+
+			key := fmt.Sprintf("files/user_%d/test.txt", user.ID)
+			if err := store.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+				t.Fatal(err)
+			}
+			attachment := &model.NoteAttachment{
+				NoteID:      user.ID,
+				UserID:      user.ID,
+				Filename:    "test.txt",
+				FileSize:    5,
+				MimeType:    "text/plain",
+				Category:    "file",
+				StoragePath: key,
+				URL:         "/",
+				ScanStatus:  model.ScanStatusClean,
+			}
+			if err := s.repo.Create(attachment); err != nil {
+				t.Fatalf("创建附件失败: %v", err)
+			}
+
+			expires, sig := s.signer.Sign(contentResource(attachment.ID, ""), time.Minute)
+			_, obj, err := s.OpenSignedContent(attachment.ID, "", expires, sig)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("OpenSignedContent 错误 = %v，期望 %v", err, tt.want)
+			}
+			if obj != nil {
+				obj.Close()
+			}
+		})
+	}
+}
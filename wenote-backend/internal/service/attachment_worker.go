@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/logger"
+)
+
+// 附件后台任务类型
+const (
+	taskScan     = "scan"     // 病毒扫描
+	taskVariants = "variants" // 生成缩略图
+	taskExtract  = "extract"  // 提取文本
+)
+
+// attachmentTask 附件后台任务
+type attachmentTask struct {
+	kind         string
+	attachmentID uint64
+}
+
+// attachmentWorker 附件后台处理器，负责病毒扫描、生成缩略图和提取文本
+// 上传请求只负责入队，避免大图缩放、文档解析拖慢上传接口
+type attachmentWorker struct {
+	tasks   chan attachmentTask
+	quit    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+	timeout time.Duration
+	service *AttachmentService
+}
+
+var globalAttachmentWorker *attachmentWorker
+
+// StartAttachmentWorker 启动附件后台处理协程
+// 启动时会重新入队上次停机前未完成的任务；返回的 stop 函数等待进行中的任务完成后返回，
+// 队列中尚未开始的任务保持 pending 状态，下次启动时继续
+func StartAttachmentWorker(workers, queueSize int, timeout time.Duration) (stop func()) {
+	if workers <= 0 {
+		workers = 2
+	}
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	w := &attachmentWorker{
+		tasks:   make(chan attachmentTask, queueSize),
+		quit:    make(chan struct{}),
+		timeout: timeout,
+		service: NewAttachmentService(),
+	}
+	globalAttachmentWorker = w
+
+	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+
+	go w.resumePending(queueSize)
+
+	return func() {
+		w.once.Do(func() { close(w.quit) })
+		w.wg.Wait()
+	}
+}
+
+// enqueueScan 提交病毒扫描任务，队列已满时保持 pending 状态，等待重试
+func enqueueScan(attachmentID uint64) {
+	enqueueAttachmentTask(attachmentTask{kind: taskScan, attachmentID: attachmentID})
+}
+
+// enqueueVariants 提交缩略图生成任务，队列已满时保持 pending 状态，等待下次启动时重试
+func enqueueVariants(attachmentID uint64) {
+	enqueueAttachmentTask(attachmentTask{kind: taskVariants, attachmentID: attachmentID})
+}
+
+// enqueueExtract 提交文本提取任务，队列已满时保持 pending 状态，等待下次启动时重试
+func enqueueExtract(attachmentID uint64) {
+	enqueueAttachmentTask(attachmentTask{kind: taskExtract, attachmentID: attachmentID})
+}
+
+func enqueueAttachmentTask(task attachmentTask) {
+	w := globalAttachmentWorker
+	if w == nil {
+		return
+	}
+
+	select {
+	case <-w.quit:
+	case w.tasks <- task:
+	default:
+		logger.Warn("附件任务队列已满，稍后重试", "kind", task.kind, "attachment_id", task.attachmentID)
+	}
+}
+
+// RetryPendingScans 重新提交待扫描的附件，用于扫描器临时不可用后的定期重试
+func RetryPendingScans() {
+	w := globalAttachmentWorker
+	if w == nil || globalScanner == nil {
+		return
+	}
+	w.resumeScans(cap(w.tasks))
+}
+
+func (w *attachmentWorker) resumeScans(limit int) {
+	if globalScanner == nil {
+		return
+	}
+	ids, err := w.service.repo.ListIDsByScanStatus(model.ScanStatusPending, limit)
+	if err != nil {
+		logger.Error("查询待扫描附件失败", "error", err)
+		return
+	}
+	for _, id := range ids {
+		enqueueScan(id)
+	}
+	if len(ids) > 0 {
+		logger.Info("已重新提交病毒扫描任务", "count", len(ids))
+	}
+}
+
+func (w *attachmentWorker) resumePending(limit int) {
+	w.resumeScans(limit)
+
+	ids, err := w.service.repo.ListIDsByVariantStatus(model.VariantStatusPending, limit)
+	if err != nil {
+		logger.Error("查询待生成缩略图失败", "error", err)
+		return
+	}
+	for _, id := range ids {
+		enqueueVariants(id)
+	}
+	if len(ids) > 0 {
+		logger.Info("已重新提交缩略图任务", "count", len(ids))
+	}
+
+	ids, err = w.service.repo.ListIDsByTextStatus(model.TextStatusPending, limit)
+	if err != nil {
+		logger.Error("查询待提取文本的附件失败", "error", err)
+		return
+	}
+	for _, id := range ids {
+		enqueueExtract(id)
+	}
+	if len(ids) > 0 {
+		logger.Info("已重新提交文本提取任务", "count", len(ids))
+	}
+}
+
+func (w *attachmentWorker) run() {
+	defer w.wg.Done()
+	for {
+		var task attachmentTask
+		select {
+		case <-w.quit:
+			return
+		case task = <-w.tasks:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		switch task.kind {
+		case taskScan:
+			if err := w.service.ScanAttachment(ctx, task.attachmentID); err != nil {
+				logger.Error("扫描附件失败", "attachment_id", task.attachmentID, "error", err)
+			}
+		case taskVariants:
+			w.generateVariants(ctx, task.attachmentID)
+		case taskExtract:
+			w.extractText(ctx, task.attachmentID)
+		}
+		cancel()
+	}
+}
+
+func (w *attachmentWorker) generateVariants(ctx context.Context, id uint64) {
+	status := model.VariantStatusDone
+	if err := w.service.GenerateVariants(ctx, id); err != nil {
+		status = model.VariantStatusFailed
+		logger.Error("生成缩略图失败", "attachment_id", id, "error", err)
+	}
+	if err := w.service.repo.UpdateVariantStatus(id, status); err != nil {
+		logger.Error("更新缩略图状态失败", "attachment_id", id, "error", err)
+	}
+}
+
+func (w *attachmentWorker) extractText(ctx context.Context, id uint64) {
+	status := model.TextStatusDone
+	if err := w.service.ExtractText(ctx, id); err != nil {
+		status = model.TextStatusFailed
+		logger.Error("提取附件文本失败", "attachment_id", id, "error", err)
+	}
+	if err := w.service.repo.UpdateTextStatus(id, status); err != nil {
+		logger.Error("更新文本提取状态失败", "attachment_id", id, "error", err)
+	}
+}
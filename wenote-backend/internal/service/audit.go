@@ -0,0 +1,84 @@
+package service
+
+import (
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/batch"
+	"wenote-backend/pkg/logger"
+)
+
+// auditBatchSize 后台写入时单批最多合并的日志条数
+const auditBatchSize = 100
+
+// globalAuditWriter 审计日志异步写入器
+// 请求路径只负责入队，由后台协程批量落库，避免审计拖慢请求
+var globalAuditWriter *batch.Worker[*model.AuditLog]
+
+// StartAuditWriter 启动审计日志后台写入协程
+// 返回的 stop 函数会停止接收新日志，并在写完队列中剩余日志后返回
+func StartAuditWriter(queueSize int) (stop func()) {
+	auditRepo := repo.NewAuditRepo()
+	w := batch.Start(queueSize, auditBatchSize, func(entries []*model.AuditLog) {
+		if err := auditRepo.CreateBatch(entries); err != nil {
+			logger.Error("批量写入审计日志失败", "count", len(entries), "error", err)
+		}
+	})
+	globalAuditWriter = w
+	return w.Close
+}
+
+// RecordAudit 记录审计日志（异步，不阻塞调用方）
+// 队列已满时丢弃并记录告警；写入器未启动时（如命令行工具）同步写入
+func RecordAudit(entry *model.AuditLog) {
+	w := globalAuditWriter
+	if w == nil {
+		if err := repo.NewAuditRepo().Create(entry); err != nil {
+			logger.Error("写入审计日志失败", "action", entry.Action, "error", err)
+		}
+		return
+	}
+
+	switch w.Add(entry) {
+	case batch.ErrClosed:
+		logger.Warn("审计写入器已关闭，丢弃日志", "action", entry.Action, "user_id", entry.UserID)
+	case batch.ErrFull:
+		logger.Warn("审计日志队列已满，丢弃日志", "action", entry.Action, "user_id", entry.UserID)
+	}
+}
+
+// AuditService 审计日志查询服务
+type AuditService struct {
+	auditRepo *repo.AuditRepo
+}
+
+// NewAuditService 创建审计日志服务实例
+func NewAuditService() *AuditService {
+	return &AuditService{
+		auditRepo: repo.NewAuditRepo(),
+	}
+}
+
+// ListByUser 查询用户自己的审计日志
+func (s *AuditService) ListByUser(userID uint64, req *model.AuditListReq) (*model.AuditListResp, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	if req.PageSize > 100 {
+		req.PageSize = 100
+	}
+
+	logs, total, err := s.auditRepo.ListByUserID(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuditListResp{
+		Total: total,
+		List:  logs,
+		Page:  req.Page,
+		Size:  req.PageSize,
+	}, nil
+}
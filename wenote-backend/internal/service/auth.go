@@ -1,15 +1,16 @@
 package service
 
 import (
+	"context"
 	"wenote-backend/config"
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/repo"
 	"wenote-backend/pkg/hash"
 	"wenote-backend/pkg/jwt"
 	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/ratelimit"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -18,25 +19,28 @@ var (
 	ErrUsernameExists    = errors.New("用户名已存在")
 	ErrPasswordIncorrect = errors.New("密码错误")
 	ErrUserCreateFailed  = errors.New("用户创建失败")
+	ErrUserLocked        = errors.New("账号已被锁定")
 )
 
-type LoginAttempt struct {
-	Count       int
-	LockedUntil time.Time
-}
-
-var loginAttempts = &sync.Map{}
-
 type AuthService struct {
-	userRepo   *repo.UserRepo
-	jwtManager *jwt.JWTManager
+	userRepo      *repo.UserRepo
+	jwtManager    *jwt.JWTManager
+	lockout       ratelimit.Lockout
+	lockoutPolicy ratelimit.LockoutPolicy
 }
 
 func NewAuthService() *AuthService {
 	cfg := config.GlobalConfig.JWT
+	lockoutCfg := config.GlobalConfig.Lockout
 	return &AuthService{
 		userRepo:   repo.NewUserRepo(),
 		jwtManager: jwt.NewJWTManager(cfg.Secret, cfg.Expire),
+		lockout:    globalLimitStore,
+		lockoutPolicy: ratelimit.LockoutPolicy{
+			MaxAttempts: lockoutCfg.MaxAttempts,
+			Window:      time.Duration(lockoutCfg.Window) * time.Minute,
+			Duration:    time.Duration(lockoutCfg.Duration) * time.Minute,
+		},
 	}
 }
 
@@ -66,14 +70,23 @@ func (s *AuthService) Register(req *model.RegisterReq) (*model.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) Login(req *model.LoginReq) (*model.LoginResp, error) {
-	attemptVal, found := loginAttempts.Load(req.Username)
-	if found {
-		attempt := attemptVal.(*LoginAttempt)
-		if time.Now().Before(attempt.LockedUntil) {
-			remainingSeconds := int(time.Until(attempt.LockedUntil).Seconds())
-			return nil, fmt.Errorf("账号已锁定，请 %d 秒后重试", remainingSeconds)
+// Login 登录
+// clientIP 仅用于审计日志
+func (s *AuthService) Login(req *model.LoginReq, clientIP string) (*model.LoginResp, error) {
+	ctx := context.Background()
+
+	lockedFor, err := s.lockout.LockedFor(ctx, lockoutKey(req.Username))
+	if err != nil {
+		// 存储不可用时放行，避免因 Redis 故障导致所有用户无法登录
+		logger.Error("查询登录锁定状态失败", "username", req.Username, "error", err)
+	} else if lockedFor > 0 {
+		remainingSeconds := int(lockedFor.Seconds())
+		var lockedUserID uint64
+		if u, _ := s.userRepo.GetByUsername(req.Username); u != nil {
+			lockedUserID = u.ID
 		}
+		s.auditLogin(lockedUserID, req.Username, clientIP, model.AuditActionLoginFailure, "locked")
+		return nil, fmt.Errorf("账号已锁定，请 %d 秒后重试", remainingSeconds)
 	}
 
 	user, err := s.userRepo.GetByUsername(req.Username)
@@ -82,41 +95,100 @@ func (s *AuthService) Login(req *model.LoginReq) (*model.LoginResp, error) {
 	}
 	if user == nil {
 		s.recordLoginFailure(req.Username)
+		s.auditLogin(0, req.Username, clientIP, model.AuditActionLoginFailure, "user_not_found")
 		return nil, ErrUserNotFound
 	}
 
 	if !hash.CheckPassword(req.Password, user.PasswordHash) {
 		s.recordLoginFailure(req.Username)
+		s.auditLogin(user.ID, req.Username, clientIP, model.AuditActionLoginFailure, "password_incorrect")
 		return nil, ErrPasswordIncorrect
 	}
 
-	loginAttempts.Delete(req.Username)
+	// 管理员锁定的账号即使密码正确也不允许登录
+	if user.IsLocked {
+		s.auditLogin(user.ID, req.Username, clientIP, model.AuditActionLoginFailure, "locked_by_admin")
+		return nil, ErrUserLocked
+	}
 
-	token, err := s.jwtManager.GenerateToken(user.ID, user.Username)
+	if err := s.lockout.Reset(ctx, lockoutKey(req.Username)); err != nil {
+		logger.Error("重置登录失败记录失败", "username", req.Username, "error", err)
+	}
+
+	token, err := s.jwtManager.GenerateToken(user.ID, user.Username, user.Tier)
 	if err != nil {
 		return nil, err
 	}
 
+	s.auditLogin(user.ID, req.Username, clientIP, model.AuditActionLoginSuccess, "")
+
+	// 宽限期内重新登录视为撤销注销
+	deletionCancelled := false
+	if user.DeletionScheduledAt != nil {
+		if err := s.userRepo.CancelDeletion(user.ID); err != nil {
+			return nil, err
+		}
+		s.auditLogin(user.ID, req.Username, clientIP, model.AuditActionAccountDeleteCancel, "")
+		user.DeletionScheduledAt = nil
+		deletionCancelled = true
+	}
+
 	return &model.LoginResp{
-		Token: token,
-		User:  user,
+		Token:             token,
+		User:              user,
+		DeletionCancelled: deletionCancelled,
 	}, nil
 }
 
 func (s *AuthService) recordLoginFailure(username string) {
-	now := time.Now()
-
-	val, _ := loginAttempts.LoadOrStore(username, &LoginAttempt{})
-	att := val.(*LoginAttempt)
+	_, lockedFor, err := s.lockout.RecordFailure(context.Background(), lockoutKey(username), s.lockoutPolicy)
+	if err != nil {
+		logger.Error("记录登录失败次数失败", "username", username, "error", err)
+		return
+	}
 
-	att.Count++
+	if lockedFor > 0 {
+		logger.Warn("账号已锁定", "username", username, "locked_until", time.Now().Add(lockedFor))
+	}
+}
 
-	if att.Count >= 5 {
-		att.LockedUntil = now.Add(15 * time.Minute)
-		logger.Warn("账号已锁定", "username", username, "locked_until", att.LockedUntil)
+// auditLogin 记录登录审计日志
+// 用户不存在或已锁定时 userID 为 0，通过 details 中的用户名追溯
+func (s *AuthService) auditLogin(userID uint64, username, clientIP, action, reason string) {
+	details := map[string]interface{}{
+		"username": username,
 	}
+	if reason != "" {
+		details["reason"] = reason
+	}
+
+	RecordAudit(&model.AuditLog{
+		UserID:       userID,
+		Action:       action,
+		ResourceType: model.AuditResourceUser,
+		ResourceID:   userID,
+		Details:      details,
+		IPAddress:    clientIP,
+	})
 }
 
-func (s *AuthService) RefreshToken(userID uint64, username string) (string, error) {
-	return s.jwtManager.GenerateToken(userID, username)
+// lockoutKey 登录锁定在共享存储中的键
+func lockoutKey(username string) string {
+	return "login:" + username
+}
+
+// RefreshToken 刷新令牌
+// 重新读取用户信息，使等级变更在刷新后生效
+func (s *AuthService) RefreshToken(userID uint64) (string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", ErrUserNotFound
+	}
+	if user.IsLocked {
+		return "", ErrUserLocked
+	}
+	return s.jwtManager.GenerateToken(user.ID, user.Username, user.Tier)
 }
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testDSNEnv 集成测试使用的 MySQL 连接串，与 repo 包的测试相同，未设置时跳过依赖数据库的测试
+const testDSNEnv = "WENOTE_TEST_MYSQL_DSN"
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+
+	// testUserSeq 每个测试使用独立的用户 ID，测试之间的数据互不影响
+	testUserSeq = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+)
+
+// openTestDB 连接测试库并迁移服务测试用到的表，设置 repo.DB
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("未设置 %s，跳过数据库测试", testDSNEnv)
+	}
+	testDBOnce.Do(func() {
+		repo.DB, testDBErr = gorm.Open(mysql.Open(dsn), &gorm.Config{
+			Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+		})
+		if testDBErr == nil {
+			testDBErr = repo.DB.AutoMigrate(
+				&model.User{},
+				&model.Note{},
+				&model.NoteAttachment{},
+				&model.AttachmentVariant{},
+				&model.AttachmentBlob{},
+				&model.AttachmentText{},
+				&model.UploadSession{},
+				&model.UploadChunk{},
+				&model.AuditLog{},
+			)
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("初始化测试数据库失败: %v", testDBErr)
+	}
+}
+
+// newTestUserID 分配一个测试用户 ID，测试结束时删除该用户的附件、上传会话和审计日志
+func newTestUserID(t *testing.T) uint64 {
+	t.Helper()
+	userID := atomic.AddUint64(&testUserSeq, 1)
+	t.Cleanup(func() {
+		repo.DB.Exec("DELETE FROM attachment_blobs WHERE hash IN (SELECT blob_hash FROM note_attachments WHERE user_id = ?)", userID)
+		repo.DB.Exec("DELETE FROM note_attachments WHERE user_id = ?", userID)
+		repo.DB.Exec("DELETE FROM upload_chunks WHERE session_id IN (SELECT id FROM upload_sessions WHERE user_id = ?)", userID)
+		repo.DB.Exec("DELETE FROM upload_sessions WHERE user_id = ?", userID)
+		repo.DB.Exec("DELETE FROM audit_logs WHERE user_id = ?", userID)
+		repo.DB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+	return userID
+}
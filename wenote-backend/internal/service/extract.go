@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/extract"
+	"wenote-backend/pkg/storage"
+)
+
+// snippetLength 搜索结果中附件命中片段的长度（字符数）
+const snippetLength = 120
+
+// canExtractText 附件类型是否需要提取文本
+// 图片仅在配置了 OCR 后端时识别
+func canExtractText(mimeType string) bool {
+	if !config.GlobalConfig.Attachment.Extract.Enabled {
+		return false
+	}
+	if extract.Supported(mimeType) {
+		return true
+	}
+	return globalOCR != nil && strings.HasPrefix(mimeType, "image/")
+}
+
+// ExtractText 提取附件中的文本并保存，用于笔记关键词搜索
+// 相同内容的其他附件已提取的文本直接复用；没有文本层的文档保存为空文本
+func (s *AttachmentService) ExtractText(ctx context.Context, attachmentID uint64) error {
+	attachment, err := s.repo.GetByID(attachmentID)
+	if err != nil {
+		return ErrAttachmentNotFound
+	}
+
+	text := &model.AttachmentText{
+		AttachmentID: attachment.ID,
+		NoteID:       attachment.NoteID,
+		UserID:       attachment.UserID,
+	}
+
+	if attachment.BlobHash != "" {
+		shared, err := s.repo.GetTextByBlob(attachment.BlobHash, attachment.ID)
+		if err != nil {
+			return err
+		}
+		if shared != nil {
+			text.Content = shared.Content
+			text.Source = shared.Source
+			return s.saveText(text)
+		}
+	}
+
+	obj, err := s.storage.Open(ctx, storage.NormalizeKey(attachment.StoragePath))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return err
+	}
+
+	text.Source = model.TextSourceDocument
+	text.Content, err = extract.Text(data, attachment.MimeType)
+	if errors.Is(err, extract.ErrUnsupported) && globalOCR != nil && strings.HasPrefix(attachment.MimeType, "image/") {
+		text.Source = model.TextSourceOCR
+		text.Content, err = globalOCR.Recognize(ctx, data, attachment.MimeType)
+	}
+	if err != nil {
+		return err
+	}
+
+	text.Content = extract.Truncate(text.Content, config.GlobalConfig.Attachment.Extract.MaxTextLength)
+	return s.saveText(text)
+}
+
+// saveText 保存提取的文本，并更新所属笔记的搜索索引
+func (s *AttachmentService) saveText(text *model.AttachmentText) error {
+	if err := s.repo.SaveText(text); err != nil {
+		return err
+	}
+	syncSearchIndex(text.NoteID)
+	return nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/imaging"
+	"wenote-backend/pkg/storage"
+)
+
+// sanitizeImage 处理上传的图片：按 EXIF 方向校正、去除元数据、限制尺寸
+// 非图片或不支持处理的图片类型返回 nil
+func sanitizeImage(src io.Reader, contentType string) (*imaging.Result, error) {
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	result, err := imaging.Sanitize(data, contentType, config.GlobalConfig.Attachment.Image.MaxDimension)
+	if err == imaging.ErrUnsupported {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return result, nil
+}
+
+// GenerateVariants 为图片附件生成配置中的各尺寸缩略图
+// 原图长边不超过缩略图尺寸时跳过，下载该尺寸时回退到原图；
+// 相同内容的其他附件已生成的缩略图直接复用
+func (s *AttachmentService) GenerateVariants(ctx context.Context, attachmentID uint64) error {
+	attachment, err := s.repo.GetByID(attachmentID)
+	if err != nil {
+		return ErrAttachmentNotFound
+	}
+
+	existing := make(map[string]*model.AttachmentVariant)
+	if attachment.BlobHash != "" {
+		shared, err := s.repo.ListVariantsByBlob(attachment.BlobHash, attachment.ID)
+		if err != nil {
+			return err
+		}
+		for _, variant := range shared {
+			existing[variant.Name] = variant
+		}
+	}
+
+	key := storage.NormalizeKey(attachment.StoragePath)
+	var data []byte
+
+	sizes := config.GlobalConfig.Attachment.Image.Thumbnails
+	names := make([]string, 0, len(sizes))
+	for name := range sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		dimension := sizes[name]
+		if attachment.Width <= dimension && attachment.Height <= dimension {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if shared, ok := existing[name]; ok {
+			variant := *shared
+			variant.ID = 0
+			variant.AttachmentID = attachment.ID
+			variant.CreatedAt = time.Time{}
+			if err := s.repo.SaveVariant(&variant); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 只有需要生成时才读取原图
+		if data == nil {
+			obj, err := s.storage.Open(ctx, key)
+			if err != nil {
+				return err
+			}
+			data, err = io.ReadAll(obj)
+			obj.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		thumb, err := imaging.Thumbnail(data, attachment.MimeType, dimension)
+		if err != nil {
+			return err
+		}
+
+		variantKey := VariantKey(key, name, thumb.MimeType)
+		if err := s.storage.Put(ctx, variantKey, bytes.NewReader(thumb.Data), int64(len(thumb.Data)), thumb.MimeType); err != nil {
+			return err
+		}
+
+		err = s.repo.SaveVariant(&model.AttachmentVariant{
+			AttachmentID: attachment.ID,
+			Name:         name,
+			Width:        thumb.Width,
+			Height:       thumb.Height,
+			FileSize:     len(thumb.Data),
+			MimeType:     thumb.MimeType,
+			StoragePath:  variantKey,
+		})
+		if err != nil {
+			// 共享的缩略图文件可能已被其他附件引用，不能删除
+			if attachment.BlobHash == "" {
+				s.storage.Delete(ctx, variantKey)
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"os"
+	"testing"
+	"wenote-backend/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("release")
+	os.Exit(m.Run())
+}
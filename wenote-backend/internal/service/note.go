@@ -5,22 +5,47 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
+	"wenote-backend/config"
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/repo"
+	"wenote-backend/internal/search"
 	"wenote-backend/pkg/ai"
+	"wenote-backend/pkg/cursor"
+	"wenote-backend/pkg/extract"
+	"wenote-backend/pkg/query"
+	"wenote-backend/pkg/ratelimit"
+	"wenote-backend/pkg/scanner"
+	"wenote-backend/pkg/signedurl"
+	"wenote-backend/pkg/storage"
 )
 
 var (
-	ErrNoteNotFound = errors.New("笔记不存在")
+	ErrNoteNotFound  = errors.New("笔记不存在")
+	ErrInvalidQuery  = errors.New("搜索语句有误")
+	ErrInvalidCursor = errors.New("分页游标无效或与当前查询不匹配")
 )
 
 // 全局依赖(由 main.go 初始化)
-var globalAIClient ai.Client
+var (
+	globalAIClient     ai.Client
+	globalLimitStore   ratelimit.Store
+	globalStorage      storage.Storage
+	globalOCR          extract.OCR
+	globalScanner      scanner.Scanner
+	globalSearchEngine search.Engine
+)
 
 // InitGlobalDeps 初始化全局依赖
-func InitGlobalDeps(client ai.Client) {
+// ocr 为 nil 时不识别图片中的文字，virusScanner 为 nil 时不扫描附件，searchEngine 为 nil 时使用 MySQL 全文索引
+func InitGlobalDeps(client ai.Client, limitStore ratelimit.Store, store storage.Storage, ocr extract.OCR, virusScanner scanner.Scanner, searchEngine search.Engine) {
 	globalAIClient = client
+	globalLimitStore = limitStore
+	globalStorage = store
+	globalOCR = ocr
+	globalScanner = virusScanner
+	globalSearchEngine = searchEngine
 }
 
 // NoteService 笔记服务
@@ -28,7 +53,10 @@ type NoteService struct {
 	noteRepo            *repo.NoteRepo
 	notebookRepo        *repo.NotebookRepo
 	tagRepo             *repo.TagRepo
+	attachmentRepo      *repo.AttachmentRepo
+	templateRepo        *repo.NoteTemplateRepo
 	gamificationService *GamificationService
+	signer              *signedurl.Signer
 }
 
 // NewNoteService 创建笔记服务实例
@@ -37,19 +65,62 @@ func NewNoteService() *NoteService {
 		noteRepo:            repo.NewNoteRepo(),
 		notebookRepo:        repo.NewNotebookRepo(),
 		tagRepo:             repo.NewTagRepo(),
+		attachmentRepo:      repo.NewAttachmentRepo(),
+		templateRepo:        repo.NewNoteTemplateRepo(),
 		gamificationService: NewGamificationService(),
+		signer:              signedurl.NewSigner(config.GlobalConfig.Storage.SigningKey),
 	}
 }
 
 // Create 创建笔记
+// 指定模板时，未传的标题和内容使用模板渲染的结果，并关联模板的默认标签；
+// 未传笔记本时使用模板的目标笔记本，模板也未指定时使用默认笔记本
 func (s *NoteService) Create(userID uint64, req *model.NoteCreateReq) (*model.Note, error) {
-	// 目的：确保笔记创建时指定的笔记本存在，且该笔记本归属当前用户，防止用户在不存在或非本人拥有的笔记本下创建笔记。
-	notebook, err := s.notebookRepo.GetByIDAndUserID(req.NotebookID, userID)
-	if err != nil {
-		return nil, err
+	var template *model.NoteTemplate
+	var err error
+	if req.TemplateID != nil {
+		if template, err = s.templateRepo.GetAvailable(*req.TemplateID, userID); err != nil {
+			return nil, err
+		}
+		if template == nil {
+			return nil, ErrTemplateNotFound
+		}
 	}
-	if notebook == nil {
-		return nil, ErrNotebookNotFound
+
+	notebookID := req.NotebookID
+	if notebookID == 0 && template != nil && template.NotebookID != nil {
+		notebookID = *template.NotebookID
+	}
+
+	var notebook *model.Notebook
+	if notebookID == 0 {
+		if notebook, err = s.notebookRepo.GetOrCreateDefault(userID); err != nil {
+			return nil, err
+		}
+		suggestPut(userID, model.SuggestTypeNotebook, notebook.ID, notebook.Name)
+	} else {
+		// 目的：确保笔记创建时指定的笔记本存在，且该笔记本归属当前用户，防止用户在不存在或非本人拥有的笔记本下创建笔记。
+		notebook, err = s.notebookRepo.GetByIDAndUserID(notebookID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if notebook == nil {
+			return nil, ErrNotebookNotFound
+		}
+	}
+
+	title, rawContent := req.Title, req.Content
+	if template != nil {
+		renderedTitle, renderedContent, err := renderTemplate(template, notebook, req.Variables, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if title == "" {
+			title = truncateRunes(renderedTitle, 255)
+		}
+		if rawContent == "" {
+			rawContent = renderedContent
+		}
 	}
 
 	// 设置默认摘要长度
@@ -61,12 +132,18 @@ func (s *NoteService) Create(userID uint64, req *model.NoteCreateReq) (*model.No
 		summaryLen = 500
 	}
 
+	// 正文中的附件地址统一转换为不含签名的规范形式
+	content, err := s.canonicalizeContent(userID, rawContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建笔记
 	note := &model.Note{
 		UserID:     userID,
-		NotebookID: req.NotebookID,
-		Title:      req.Title,
-		Content:    req.Content,
+		NotebookID: notebook.ID,
+		Title:      title,
+		Content:    content,
 		SummaryLen: summaryLen,
 		AIStatus:   model.AIStatusPending,
 	}
@@ -75,19 +152,36 @@ func (s *NoteService) Create(userID uint64, req *model.NoteCreateReq) (*model.No
 		return nil, err
 	}
 
-	// 如果有标签，更新标签关联
-	if len(req.TagIDs) > 0 {
-		if err := s.noteRepo.ReplaceNoteTags(note.ID, req.TagIDs); err != nil {
+	// 如果有标签，更新标签关联；模板的默认标签不存在时自动创建
+	tagIDs := req.TagIDs
+	if template != nil {
+		for _, name := range template.TagNames {
+			tag, err := s.tagRepo.GetOrCreate(userID, name)
+			if err != nil {
+				return nil, err
+			}
+			suggestPut(userID, model.SuggestTypeTag, tag.ID, tag.Name)
+			tagIDs = append(tagIDs, tag.ID)
+		}
+	}
+	if len(tagIDs) > 0 {
+		if err := s.noteRepo.ReplaceNoteTags(note.ID, uniqueIDs(tagIDs)); err != nil {
 			return nil, err
 		}
 	}
 
+	syncSearchIndex(note.ID)
+	suggestPut(userID, model.SuggestTypeNote, note.ID, note.Title)
+
 	// 更新游戏化数据（字符数）
-	charCount := int64(len([]rune(req.Content)))
+	charCount := int64(len([]rune(rawContent)))
 	if charCount > 0 {
 		s.gamificationService.UpdateActivity(userID, charCount)
 	}
 
+	if err := s.signAttachmentRefs(userID, note); err != nil {
+		return nil, err
+	}
 	return note, nil
 }
 
@@ -100,6 +194,9 @@ func (s *NoteService) GetByID(userID, noteID uint64) (*model.Note, error) {
 	if note == nil {
 		return nil, ErrNoteNotFound
 	}
+	if err := s.signAttachmentRefs(userID, note); err != nil {
+		return nil, err
+	}
 	return note, nil
 }
 
@@ -145,7 +242,11 @@ func (s *NoteService) Update(userID, noteID uint64, req *model.NoteUpdateReq) (*
 
 	// 3. 如果正文有变，更新并标记内容变化
 	if req.Content != nil {
-		note.Content = *req.Content
+		content, err := s.canonicalizeContent(userID, *req.Content)
+		if err != nil {
+			return nil, err
+		}
+		note.Content = content
 		contentChanged = true
 	}
 
@@ -215,7 +316,16 @@ func (s *NoteService) Update(userID, noteID uint64, req *model.NoteUpdateReq) (*
 		}
 	}
 
-	// 10. 更新游戏化数据（如果内容有变化）
+	// 10. 按新正文更新附件的引用状态，并更新搜索索引
+	if req.Content != nil {
+		s.syncAttachmentRefs(note.ID, note.Content)
+	}
+	syncSearchIndex(note.ID)
+	if req.Title != nil {
+		suggestPut(userID, model.SuggestTypeNote, note.ID, note.Title)
+	}
+
+	// 11. 更新游戏化数据（如果内容有变化）
 	if req.Content != nil {
 		newContentLen := len([]rune(*req.Content))
 		charsDelta := int64(newContentLen - oldContentLen)
@@ -224,10 +334,17 @@ func (s *NoteService) Update(userID, noteID uint64, req *model.NoteUpdateReq) (*
 		}
 	}
 
-	// 11. 返回这条笔记的完整信息（含最新标签/AI字段等）
+	// 12. 返回这条笔记的完整信息（含最新标签/AI字段等）
 	// 是的，这里已经更新到数据库，
 	// 然后通过ID再次查询最新的笔记返回前端
-	return s.noteRepo.GetByID(note.ID)
+	updated, err := s.noteRepo.GetByID(note.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.signAttachmentRefs(userID, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
 }
 
 // Delete 软删除笔记
@@ -240,7 +357,12 @@ func (s *NoteService) Delete(userID, noteID uint64) error {
 		return ErrNoteNotFound
 	}
 
-	return s.noteRepo.SoftDelete(noteID)
+	if err := s.noteRepo.SoftDelete(noteID); err != nil {
+		return err
+	}
+	syncSearchIndex(noteID)
+	suggestRemove(userID, model.SuggestTypeNote, noteID)
+	return nil
 }
 
 // Restore 恢复已删除的笔记
@@ -256,8 +378,17 @@ func (s *NoteService) Restore(userID, noteID uint64) (*model.Note, error) {
 	if err := s.noteRepo.Restore(noteID); err != nil {
 		return nil, err
 	}
+	syncSearchIndex(noteID)
+	suggestPut(userID, model.SuggestTypeNote, note.ID, note.Title)
 
-	return s.noteRepo.GetByID(noteID)
+	restored, err := s.noteRepo.GetByID(noteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.signAttachmentRefs(userID, restored); err != nil {
+		return nil, err
+	}
+	return restored, nil
 }
 
 // List 获取笔记列表
@@ -273,19 +404,156 @@ func (s *NoteService) List(userID uint64, req *model.NoteListReq) (*model.NoteLi
 		req.PageSize = 100
 	}
 
-	notes, total, err := s.noteRepo.List(userID, req)
+	q, err := query.Parse(req.Q)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w，%v", ErrInvalidQuery, err)
+	}
+
+	// 带关键词或搜索语句时交给搜索后端，普通列表直接查询数据库
+	var notes []*model.Note
+	var total int64
+	var facets *model.SearchFacets
+	var next string
+	if req.Keyword != "" || q != nil {
+		result, err := searchEngine().Search(context.Background(), userID, req, q)
+		if err != nil {
+			return nil, cursorError(err)
+		}
+		notes, total, facets, next = result.Notes, result.Total, result.Facets, result.NextCursor
+	} else {
+		notes, total, next, err = s.noteRepo.List(userID, req, q)
+		if err != nil {
+			return nil, cursorError(err)
+		}
+	}
+
+	// 在签名前生成高亮片段，片段中的附件地址保持规范形式
+	highlightNotes(notes, req.Keyword, q)
+	if req.ContentIncluded() {
+		if err := s.signAttachmentRefs(userID, notes...); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, note := range notes {
+			note.Content = ""
+		}
+	}
+
+	// 关键词搜索时标注命中的附件，搜索语句中的全文条件同样参与
+	if text := searchText(req.Keyword, q); text != "" {
+		if err := s.fillMatchedAttachments(userID, notes, text); err != nil {
+			return nil, err
+		}
 	}
 
 	return &model.NoteListResp{
-		Total: total,
-		List:  notes,
-		Page:  req.Page,
-		Size:  req.PageSize,
+		Total:      total,
+		List:       notes,
+		Page:       req.Page,
+		Size:       req.PageSize,
+		Facets:     facets,
+		NextCursor: next,
 	}, nil
 }
 
+// cursorError 将游标解码错误转换为服务层错误
+func cursorError(err error) error {
+	if errors.Is(err, cursor.ErrInvalid) {
+		return ErrInvalidCursor
+	}
+	return err
+}
+
+// Count 统计符合列表筛选条件的笔记数量，与 List 的总数一致
+func (s *NoteService) Count(userID uint64, req *model.NoteListReq) (int64, error) {
+	q, err := query.Parse(req.Q)
+	if err != nil {
+		return 0, fmt.Errorf("%w，%v", ErrInvalidQuery, err)
+	}
+
+	if req.Keyword != "" || q != nil {
+		countReq := *req
+		countReq.Page, countReq.PageSize, countReq.Facets, countReq.Cursor = 1, 1, false, ""
+		result, err := searchEngine().Search(context.Background(), userID, &countReq, q)
+		if err != nil {
+			return 0, err
+		}
+		return result.Total, nil
+	}
+	return s.noteRepo.Count(userID, req, q)
+}
+
+// CountEach 分别统计多组筛选条件的笔记数量，顺序与 reqs 一致
+// 数据库能直接计数的条件合并为一次查询，需要 bleve 全文搜索的逐条统计；
+// 搜索语句无法解析的条件数量记为 0
+func (s *NoteService) CountEach(userID uint64, reqs []*model.NoteListReq) ([]int64, error) {
+	counts := make([]int64, len(reqs))
+	engine := searchEngine()
+
+	var batchIdx []int
+	var batchReqs []*model.NoteListReq
+	var batchQs []query.Node
+	for i, req := range reqs {
+		q, err := query.Parse(req.Q)
+		if err != nil {
+			continue
+		}
+		if (req.Keyword != "" || q != nil) && engine.Name() != search.EngineMySQL {
+			if counts[i], err = s.Count(userID, req); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		batchIdx = append(batchIdx, i)
+		batchReqs = append(batchReqs, req)
+		batchQs = append(batchQs, q)
+	}
+
+	batch, err := s.noteRepo.CountEach(userID, batchReqs, batchQs)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range batchIdx {
+		counts[i] = batch[j]
+	}
+	return counts, nil
+}
+
+// searchText 关键词与搜索语句中未被排除的全文条件
+func searchText(keyword string, q query.Node) string {
+	values := []string{keyword}
+	for _, term := range query.TextTerms(q) {
+		values = append(values, term.Value)
+	}
+	return strings.TrimSpace(strings.Join(values, " "))
+}
+
+// fillMatchedAttachments 为搜索结果填充提取文本命中关键词的附件
+func (s *NoteService) fillMatchedAttachments(userID uint64, notes []*model.Note, keyword string) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	noteIDs := make([]uint64, len(notes))
+	for i, note := range notes {
+		noteIDs[i] = note.ID
+	}
+
+	matches, err := s.attachmentRepo.MatchTexts(userID, noteIDs, keyword, snippetLength)
+	if err != nil {
+		return err
+	}
+
+	byNote := make(map[uint64][]*model.AttachmentMatch)
+	for _, m := range matches {
+		m.Snippet = strings.Join(strings.Fields(m.Snippet), " ")
+		byNote[m.NoteID] = append(byNote[m.NoteID], m)
+	}
+	for _, note := range notes {
+		note.MatchedAttachments = byNote[note.ID]
+	}
+	return nil
+}
+
 // UpdateTags 更新笔记标签
 func (s *NoteService) UpdateTags(userID, noteID uint64, tagIDs []uint64) (*model.Note, error) {
 	// 1. 验证笔记归属
@@ -317,6 +585,7 @@ func (s *NoteService) UpdateTags(userID, noteID uint64, tagIDs []uint64) (*model
 	if err := s.noteRepo.ReplaceNoteTags(noteID, tagIDs); err != nil {
 		return nil, err
 	}
+	syncSearchIndex(noteID)
 
 	// 4. 返回更新后的笔记
 	return s.GetByID(userID, noteID)
@@ -344,6 +613,7 @@ func (s *NoteService) ApplySuggestedTags(userID, noteID uint64) error {
 		if err != nil {
 			continue
 		}
+		suggestPut(userID, model.SuggestTypeTag, tag.ID, tag.Name)
 		tagIDs = append(tagIDs, tag.ID)
 	}
 
@@ -351,6 +621,7 @@ func (s *NoteService) ApplySuggestedTags(userID, noteID uint64) error {
 	if err := s.noteRepo.ReplaceNoteTags(noteID, tagIDs); err != nil {
 		return err
 	}
+	syncSearchIndex(noteID)
 
 	// 4. 清空 suggested_tags 避免重复应用
 	return s.noteRepo.ClearSuggestedTags(noteID)
@@ -367,7 +638,13 @@ func (s *NoteService) BatchHardDelete(noteIDs []uint64, userID uint64) (int64, e
 		return 0, errors.New("无有效笔记可删除")
 	}
 
-	return s.noteRepo.BatchHardDelete(validNoteIDs)
+	count, err := s.noteRepo.BatchHardDelete(validNoteIDs)
+	if err != nil {
+		return 0, err
+	}
+	syncSearchIndex(validNoteIDs...)
+	suggestRemove(userID, model.SuggestTypeNote, validNoteIDs...)
+	return count, nil
 }
 
 // BatchRestore 批量恢复笔记
@@ -382,7 +659,13 @@ func (s *NoteService) BatchRestore(noteIDs []uint64, userID uint64) (int64, erro
 		return 0, errors.New("无有效笔记可恢复")
 	}
 
-	return s.noteRepo.BatchRestore(validNoteIDs)
+	count, err := s.noteRepo.BatchRestore(validNoteIDs)
+	if err != nil {
+		return 0, err
+	}
+	syncSearchIndex(validNoteIDs...)
+	suggestInvalidate(userID)
+	return count, nil
 }
 
 // EmptyTrash 清空回收站
@@ -411,32 +694,41 @@ func (s *NoteService) BatchMove(noteIDs []uint64, notebookID, userID uint64) (in
 		return 0, errors.New("无有效笔记可移动")
 	}
 
-	return s.noteRepo.BatchUpdateNotebook(validNoteIDs, notebookID)
+	count, err := s.noteRepo.BatchUpdateNotebook(validNoteIDs, notebookID)
+	if err != nil {
+		return 0, err
+	}
+	syncSearchIndex(validNoteIDs...)
+	return count, nil
 }
 
 // ListDeleted 获取回收站笔记列表
-func (s *NoteService) ListDeleted(userID uint64, page, pageSize int) (*model.NoteListResp, error) {
+func (s *NoteService) ListDeleted(userID uint64, req *model.NoteTrashReq) (*model.NoteListResp, error) {
 	// 设置默认分页参数
-	if page <= 0 {
-		page = 1
+	if req.Page <= 0 {
+		req.Page = 1
 	}
-	if pageSize <= 0 {
-		pageSize = 20
+	if req.PageSize <= 0 {
+		req.PageSize = 20
 	}
-	if pageSize > 100 {
-		pageSize = 100
+	if req.PageSize > 100 {
+		req.PageSize = 100
 	}
 
-	notes, total, err := s.noteRepo.ListDeleted(userID, page, pageSize)
+	notes, total, next, err := s.noteRepo.ListDeleted(userID, req)
 	if err != nil {
+		return nil, cursorError(err)
+	}
+	if err := s.signAttachmentRefs(userID, notes...); err != nil {
 		return nil, err
 	}
 
 	return &model.NoteListResp{
-		Total: total,
-		List:  notes,
-		Page:  page,
-		Size:  pageSize,
+		Total:      total,
+		List:       notes,
+		Page:       req.Page,
+		Size:       req.PageSize,
+		NextCursor: next,
 	}, nil
 }
 
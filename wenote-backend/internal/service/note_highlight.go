@@ -0,0 +1,53 @@
+package service
+
+import (
+	"strings"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/highlight"
+	"wenote-backend/pkg/query"
+)
+
+// maxSnippets 每篇笔记最多返回的正文片段数
+const maxSnippets = 3
+
+// highlightNotes 标出搜索结果中标题和正文的命中位置
+// 关键词按空白拆分为多个词，搜索语句中的短语整体匹配，title: 条件只用于标题
+func highlightNotes(notes []*model.Note, keyword string, q query.Node) {
+	terms := strings.Fields(keyword)
+	for _, term := range query.TextTerms(q) {
+		terms = append(terms, term.Value)
+	}
+	titleTerms := append([]string{}, terms...)
+	for _, term := range query.FieldTerms(q, query.FieldTitle) {
+		titleTerms = append(titleTerms, term.Value)
+	}
+	if len(titleTerms) == 0 {
+		return
+	}
+
+	for _, note := range notes {
+		var h model.NoteHighlight
+		if matches := highlight.Find(note.Title, titleTerms); len(matches) > 0 {
+			h.Title = newSnippet(highlight.Fragment{Text: note.Title, Matches: matches})
+		}
+		for _, f := range highlight.Fragments(note.Content, terms, snippetLength, maxSnippets) {
+			h.Snippets = append(h.Snippets, newSnippet(f))
+		}
+		if h.Title != nil || len(h.Snippets) > 0 {
+			note.Highlight = &h
+		}
+	}
+}
+
+func newSnippet(f highlight.Fragment) *model.Snippet {
+	matches := make([]model.MatchRange, len(f.Matches))
+	for i, m := range f.Matches {
+		matches[i] = model.MatchRange{Start: m.Start, End: m.End}
+	}
+	return &model.Snippet{
+		Text:        f.Text,
+		Offset:      f.Offset,
+		Matches:     matches,
+		Highlighted: highlight.Mark(f.Text, f.Matches),
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/placeholder"
+)
+
+var (
+	ErrTemplateNotFound        = errors.New("模板不存在")
+	ErrTemplateReadOnly        = errors.New("系统模板不能修改或删除")
+	ErrTemplateNameDuplicate   = errors.New("模板名称已存在")
+	ErrTemplateVariableInvalid = errors.New("模板变量无效")
+	ErrTemplateVariableMissing = errors.New("缺少模板变量")
+)
+
+// weekdayNames {{weekday}} 的值，按 time.Weekday 的顺序
+var weekdayNames = []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// NoteTemplateService 笔记模板服务
+type NoteTemplateService struct {
+	templateRepo *repo.NoteTemplateRepo
+	notebookRepo *repo.NotebookRepo
+}
+
+// NewNoteTemplateService 创建笔记模板服务实例
+func NewNoteTemplateService() *NoteTemplateService {
+	return &NoteTemplateService{
+		templateRepo: repo.NewNoteTemplateRepo(),
+		notebookRepo: repo.NewNotebookRepo(),
+	}
+}
+
+// Create 创建笔记模板
+func (s *NoteTemplateService) Create(userID uint64, req *model.NoteTemplateReq) (*model.NoteTemplate, error) {
+	if err := s.validate(userID, req, 0); err != nil {
+		return nil, err
+	}
+
+	template := &model.NoteTemplate{UserID: userID}
+	applyTemplateReq(template, req)
+	if err := s.templateRepo.Create(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// GetByID 获取笔记模板详情，可以是用户自己的模板或系统模板
+func (s *NoteTemplateService) GetByID(userID, templateID uint64) (*model.NoteTemplate, error) {
+	template, err := s.templateRepo.GetAvailable(templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+// Update 修改笔记模板，整体替换
+func (s *NoteTemplateService) Update(userID, templateID uint64, req *model.NoteTemplateReq) (*model.NoteTemplate, error) {
+	template, err := s.GetByID(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if template.IsSystem {
+		return nil, ErrTemplateReadOnly
+	}
+	if err := s.validate(userID, req, templateID); err != nil {
+		return nil, err
+	}
+
+	applyTemplateReq(template, req)
+	if err := s.templateRepo.Update(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Delete 删除笔记模板
+func (s *NoteTemplateService) Delete(userID, templateID uint64) error {
+	template, err := s.GetByID(userID, templateID)
+	if err != nil {
+		return err
+	}
+	if template.IsSystem {
+		return ErrTemplateReadOnly
+	}
+	return s.templateRepo.Delete(templateID)
+}
+
+// List 获取用户可用的笔记模板，系统模板在前
+func (s *NoteTemplateService) List(userID uint64) ([]*model.NoteTemplate, error) {
+	return s.templateRepo.ListAvailable(userID)
+}
+
+// validate 检查名称是否重复、目标笔记本是否存在，以及自定义变量的名称
+func (s *NoteTemplateService) validate(userID uint64, req *model.NoteTemplateReq, excludeID uint64) error {
+	exists, err := s.templateRepo.ExistsByUserIDAndName(userID, req.Name, excludeID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrTemplateNameDuplicate
+	}
+
+	if req.NotebookID != nil {
+		notebook, err := s.notebookRepo.GetByIDAndUserID(*req.NotebookID, userID)
+		if err != nil {
+			return err
+		}
+		if notebook == nil {
+			return ErrNotebookNotFound
+		}
+	}
+
+	seen := make(map[string]bool, len(req.Variables))
+	for _, name := range model.BuiltinTemplateVars {
+		seen[name] = true
+	}
+	for _, v := range req.Variables {
+		if !placeholder.ValidName(v.Name) {
+			return fmt.Errorf("%w: %s 只能包含字母、数字和下划线，且不能以数字开头", ErrTemplateVariableInvalid, v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("%w: %s 与内置变量或其他变量重名", ErrTemplateVariableInvalid, v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+func applyTemplateReq(template *model.NoteTemplate, req *model.NoteTemplateReq) {
+	template.Name = req.Name
+	template.Description = req.Description
+	template.Title = req.Title
+	template.Content = req.Content
+	template.NotebookID = req.NotebookID
+	template.Variables = model.TemplateVariables(req.Variables)
+
+	// 标签名称规范化并去重
+	template.TagNames = nil
+	seen := make(map[string]bool, len(req.TagNames))
+	for _, name := range req.TagNames {
+		name = model.NormalizeTagName(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			template.TagNames = append(template.TagNames, name)
+		}
+	}
+}
+
+// renderTemplate 用内置变量和 vars 渲染模板的标题和正文
+// 必填的自定义变量未填写时返回 ErrTemplateVariableMissing，未声明的变量忽略
+func renderTemplate(template *model.NoteTemplate, notebook *model.Notebook, vars map[string]string, now time.Time) (title, content string, err error) {
+	values := map[string]string{
+		model.TemplateVarDate:     now.Format("2006-01-02"),
+		model.TemplateVarTime:     now.Format("15:04"),
+		model.TemplateVarDatetime: now.Format("2006-01-02 15:04"),
+		model.TemplateVarWeekday:  weekdayNames[now.Weekday()],
+		model.TemplateVarNotebook: notebook.Name,
+	}
+	for _, v := range template.Variables {
+		value, ok := vars[v.Name]
+		if !ok || value == "" {
+			if v.Required && v.Default == "" {
+				return "", "", fmt.Errorf("%w: %s", ErrTemplateVariableMissing, v.Name)
+			}
+			value = v.Default
+		}
+		values[v.Name] = value
+	}
+	return placeholder.Render(template.Title, values), placeholder.Render(template.Content, values), nil
+}
+
+// truncateRunes 截取前 n 个字符，避免渲染后的标题超出字段长度
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -10,6 +10,8 @@ var (
 	ErrNotebookNotFound      = errors.New("笔记本不存在")
 	ErrCannotDeleteDefault   = errors.New("默认笔记本不能删除")
 	ErrNotebookNameDuplicate = errors.New("笔记本名称已存在")
+	ErrNotebookParentInvalid = errors.New("父笔记本不存在")
+	ErrNotebookCycle         = errors.New("不能将笔记本移动到自身或其子笔记本下")
 )
 
 // NotebookService 笔记本服务
@@ -28,8 +30,18 @@ func NewNotebookService() *NotebookService {
 
 // Create 创建笔记本
 func (s *NotebookService) Create(userID uint64, req *model.NotebookCreateReq) (*model.Notebook, error) {
-	// 检查同名笔记本
-	exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, req.Name, 0)
+	if req.ParentID != nil {
+		parent, err := s.notebookRepo.GetByIDAndUserID(*req.ParentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, ErrNotebookParentInvalid
+		}
+	}
+
+	// 检查同一父笔记本下的同名笔记本
+	exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, req.ParentID, req.Name, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -38,19 +50,34 @@ func (s *NotebookService) Create(userID uint64, req *model.NotebookCreateReq) (*
 	}
 
 	notebook := &model.Notebook{
-		UserID: userID,
-		Name:   req.Name,
+		UserID:   userID,
+		Name:     req.Name,
+		ParentID: req.ParentID,
 	}
 
 	if err := s.notebookRepo.Create(notebook); err != nil {
 		return nil, err
 	}
+	suggestPut(userID, model.SuggestTypeNotebook, notebook.ID, notebook.Name)
 
 	return notebook, nil
 }
 
-// GetByID 获取笔记本详情
+// GetByID 获取笔记本详情，含路径、子笔记本和汇总的笔记数量
 func (s *NotebookService) GetByID(userID, notebookID uint64) (*model.Notebook, error) {
+	tree, err := s.loadTree(userID)
+	if err != nil {
+		return nil, err
+	}
+	notebook, ok := tree.byID[notebookID]
+	if !ok {
+		return nil, ErrNotebookNotFound
+	}
+	return notebook, nil
+}
+
+// Update 更新笔记本
+func (s *NotebookService) Update(userID, notebookID uint64, req *model.NotebookUpdateReq) (*model.Notebook, error) {
 	notebook, err := s.notebookRepo.GetByIDAndUserID(notebookID, userID)
 	if err != nil {
 		return nil, err
@@ -59,17 +86,27 @@ func (s *NotebookService) GetByID(userID, notebookID uint64) (*model.Notebook, e
 		return nil, ErrNotebookNotFound
 	}
 
-	count, err := s.noteRepo.CountByNotebookID(notebookID)
+	// 检查同一父笔记本下的同名笔记本（排除自己）
+	exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, notebook.ParentID, req.Name, notebookID)
 	if err != nil {
 		return nil, err
 	}
-	notebook.NoteCount = count
+	if exists {
+		return nil, ErrNotebookNameDuplicate
+	}
+
+	notebook.Name = req.Name
+
+	if err := s.notebookRepo.Update(notebook); err != nil {
+		return nil, err
+	}
+	suggestPut(userID, model.SuggestTypeNotebook, notebook.ID, notebook.Name)
 
 	return notebook, nil
 }
 
-// Update 更新笔记本
-func (s *NotebookService) Update(userID, notebookID uint64, req *model.NotebookUpdateReq) (*model.Notebook, error) {
+// Move 移动笔记本到 parentID 下，子笔记本随之移动，parentID 为空时移到顶层
+func (s *NotebookService) Move(userID, notebookID uint64, req *model.NotebookMoveReq) (*model.Notebook, error) {
 	notebook, err := s.notebookRepo.GetByIDAndUserID(notebookID, userID)
 	if err != nil {
 		return nil, err
@@ -78,8 +115,7 @@ func (s *NotebookService) Update(userID, notebookID uint64, req *model.NotebookU
 		return nil, ErrNotebookNotFound
 	}
 
-	// 检查同名笔记本（排除自己）
-	exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, req.Name, notebookID)
+	exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, req.ParentID, notebook.Name, notebookID)
 	if err != nil {
 		return nil, err
 	}
@@ -87,56 +123,117 @@ func (s *NotebookService) Update(userID, notebookID uint64, req *model.NotebookU
 		return nil, ErrNotebookNameDuplicate
 	}
 
-	notebook.Name = req.Name
-
-	if err := s.notebookRepo.Update(notebook); err != nil {
+	// 环检查与修改在同一事务中进行，检查基于锁定后的最新层级
+	err = s.notebookRepo.MoveParent(userID, notebookID, req.ParentID, func(notebooks []*model.Notebook) error {
+		return checkMove(notebooks, notebookID, req.ParentID)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return s.GetByID(userID, notebookID)
+}
 
-	return notebook, nil
+// checkMove 检查笔记本能否移到 parentID 下：新的父笔记本不能是自身或子孙笔记本，否则形成环
+// 向上查找时记录已访问的笔记本，已有数据成环时不会死循环
+func checkMove(notebooks []*model.Notebook, notebookID uint64, parentID *uint64) error {
+	byID := make(map[uint64]*model.Notebook, len(notebooks))
+	for _, notebook := range notebooks {
+		byID[notebook.ID] = notebook
+	}
+	if _, ok := byID[notebookID]; !ok {
+		return ErrNotebookNotFound
+	}
+	if parentID == nil {
+		return nil
+	}
+	if _, ok := byID[*parentID]; !ok {
+		return ErrNotebookParentInvalid
+	}
+
+	visited := make(map[uint64]bool)
+	for id := parentID; id != nil && !visited[*id]; {
+		if *id == notebookID {
+			return ErrNotebookCycle
+		}
+		visited[*id] = true
+		p, ok := byID[*id]
+		if !ok {
+			break
+		}
+		id = p.ParentID
+	}
+	return nil
 }
 
 // Delete 删除笔记本
-func (s *NotebookService) Delete(userID, notebookID uint64) error {
+// children 为 lift 时子笔记本移到被删除笔记本的父笔记本下，
+// 否则子孙笔记本一并删除，其中的笔记与被删除笔记本的笔记一样移入回收站
+func (s *NotebookService) Delete(userID, notebookID uint64, children string) error {
 	// 步骤1: 权限校验
-	notebook, err := s.notebookRepo.GetByIDAndUserID(notebookID, userID)
+	tree, err := s.loadTree(userID)
 	if err != nil {
 		return err
 	}
-	if notebook == nil {
+	notebook, ok := tree.byID[notebookID]
+	if !ok {
 		return ErrNotebookNotFound
 	}
 
-	// 步骤2: 禁止删除默认笔记本
-	if notebook.IsDefault {
-		return ErrCannotDeleteDefault
+	// 步骤2: 确定删除范围，禁止删除默认笔记本
+	deleteIDs := []uint64{notebookID}
+	if children == model.NotebookChildrenLift {
+		// 子笔记本移到上一级后不能与那里的笔记本重名
+		for _, child := range notebook.Children {
+			exists, err := s.notebookRepo.ExistsByUserIDAndName(userID, notebook.ParentID, child.Name, notebookID)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrNotebookNameDuplicate
+			}
+		}
+	} else {
+		deleteIDs = tree.descendantIDs(notebook, deleteIDs)
+	}
+	for _, id := range deleteIDs {
+		if tree.byID[id].IsDefault {
+			return ErrCannotDeleteDefault
+		}
 	}
 
-	// 步骤3: 软删除笔记本下的所有笔记（移入回收站）
-	if _, err := s.noteRepo.SoftDeleteByNotebookID(notebookID); err != nil {
+	// 步骤3: 删除笔记本并软删除其中的笔记（移入回收站），从搜索索引中移除
+	noteIDs, err := s.noteRepo.ListIDsByNotebookIDs(deleteIDs)
+	if err != nil {
 		return err
 	}
-
-	// 步骤4: 删除笔记本
-	return s.notebookRepo.Delete(notebookID)
+	if children == model.NotebookChildrenLift {
+		err = s.notebookRepo.DeleteAndLiftChildren(notebookID, notebook.ParentID)
+	} else {
+		err = s.notebookRepo.DeleteWithNotes(deleteIDs)
+	}
+	if err != nil {
+		return err
+	}
+	syncSearchIndex(noteIDs...)
+	suggestRemove(userID, model.SuggestTypeNote, noteIDs...)
+	suggestRemove(userID, model.SuggestTypeNotebook, deleteIDs...)
+	return nil
 }
 
 // List 获取笔记本列表
-func (s *NotebookService) List(userID uint64) ([]*model.Notebook, error) {
-	notebooks, err := s.notebookRepo.ListByUserID(userID)
+// 平铺时返回全部笔记本，树形时只返回顶层笔记本，子笔记本在 Children 中
+func (s *NotebookService) List(userID uint64, view string) ([]*model.Notebook, error) {
+	tree, err := s.loadTree(userID)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, notebook := range notebooks {
-		count, err := s.noteRepo.CountByNotebookID(notebook.ID)
-		if err != nil {
-			return nil, err
-		}
-		notebook.NoteCount = count
+	if view == model.NotebookViewTree {
+		return tree.roots, nil
 	}
-
-	return notebooks, nil
+	for _, notebook := range tree.all {
+		notebook.Children = nil
+	}
+	return tree.all, nil
 }
 
 // GetOrCreateDefault 获取或创建默认笔记本
@@ -145,6 +242,7 @@ func (s *NotebookService) GetOrCreateDefault(userID uint64) (*model.Notebook, er
 	if err != nil {
 		return nil, err
 	}
+	suggestPut(userID, model.SuggestTypeNotebook, notebook.ID, notebook.Name)
 	count, err := s.noteRepo.CountByNotebookID(notebook.ID)
 	if err != nil {
 		return nil, err
@@ -152,3 +250,90 @@ func (s *NotebookService) GetOrCreateDefault(userID uint64) (*model.Notebook, er
 	notebook.NoteCount = count
 	return notebook, nil
 }
+
+// notebookTree 用户的全部笔记本及其层级关系
+type notebookTree struct {
+	all   []*model.Notebook
+	roots []*model.Notebook
+	byID  map[uint64]*model.Notebook
+	seen  map[uint64]bool // aggregate 已访问的笔记本
+}
+
+// loadTree 读取用户的全部笔记本，建立层级并计算路径和笔记数量
+// 父笔记本不存在时视为顶层笔记本
+func (s *NotebookService) loadTree(userID uint64) (*notebookTree, error) {
+	notebooks, err := s.notebookRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.noteRepo.CountGroupByNotebook(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &notebookTree{
+		all:  notebooks,
+		byID: make(map[uint64]*model.Notebook, len(notebooks)),
+		seen: make(map[uint64]bool, len(notebooks)),
+	}
+	for _, notebook := range notebooks {
+		notebook.NoteCount = counts[notebook.ID]
+		tree.byID[notebook.ID] = notebook
+	}
+	for _, notebook := range notebooks {
+		if parent := tree.parent(notebook); parent != nil {
+			parent.Children = append(parent.Children, notebook)
+		} else {
+			tree.roots = append(tree.roots, notebook)
+		}
+	}
+	for _, root := range tree.roots {
+		tree.aggregate(root, "")
+	}
+	// 已有数据成环时环上的笔记本从顶层无法到达，作为顶层笔记本返回，避免在列表中消失
+	for _, notebook := range notebooks {
+		if !tree.seen[notebook.ID] {
+			tree.roots = append(tree.roots, notebook)
+			tree.aggregate(notebook, "")
+		}
+	}
+	return tree, nil
+}
+
+func (t *notebookTree) parent(notebook *model.Notebook) *model.Notebook {
+	if notebook.ParentID == nil {
+		return nil
+	}
+	return t.byID[*notebook.ParentID]
+}
+
+// aggregate 自顶向下计算路径，自底向上汇总笔记数量，已访问过的笔记本不再重复计算
+func (t *notebookTree) aggregate(notebook *model.Notebook, prefix string) int64 {
+	if t.seen[notebook.ID] {
+		return 0
+	}
+	t.seen[notebook.ID] = true
+	notebook.Path = prefix + notebook.Name
+	notebook.TotalNoteCount = notebook.NoteCount
+	for _, child := range notebook.Children {
+		notebook.TotalNoteCount += t.aggregate(child, notebook.Path+"/")
+	}
+	return notebook.TotalNoteCount
+}
+
+// descendantIDs 将 notebook 所有子孙笔记本的 ID 追加到 ids，已有数据成环时每个笔记本只追加一次
+func (t *notebookTree) descendantIDs(notebook *model.Notebook, ids []uint64) []uint64 {
+	return t.appendDescendants(notebook, ids, map[uint64]bool{notebook.ID: true})
+}
+
+func (t *notebookTree) appendDescendants(notebook *model.Notebook, ids []uint64, visited map[uint64]bool) []uint64 {
+	for _, child := range notebook.Children {
+		if visited[child.ID] {
+			continue
+		}
+		visited[child.ID] = true
+		ids = append(ids, child.ID)
+		ids = t.appendDescendants(child, ids, visited)
+	}
+	return ids
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"wenote-backend/internal/model"
+)
+
+// newTestNotebooks 按 id -> parentID 创建笔记本，parentID 为 0 表示顶层
+func newTestNotebooks(parents map[uint64]uint64) []*model.Notebook {
+	notebooks := make([]*model.Notebook, 0, len(parents))
+	for id, parentID := range parents {
+		notebook := &model.Notebook{ID: id, Name: "nb", NoteCount: 1}
+		if parentID != 0 {
+			p := parentID
+			notebook.ParentID = &p
+		}
+		notebooks = append(notebooks, notebook)
+	}
+	sort.Slice(notebooks, func(i, j int) bool { return notebooks[i].ID < notebooks[j].ID })
+	return notebooks
+}
+
+func TestCheckMove(t *testing.T) {
+	// 1 -> 2 -> 3 为一条链，4 为顶层，5 和 6 已互为父笔记本（历史数据成环）
+	notebooks := newTestNotebooks(map[uint64]uint64{1: 0, 2: 1, 3: 2, 4: 0, 5: 6, 6: 5})
+	id := func(v uint64) *uint64 { return &v }
+
+	tests := []struct {
+		name       string
+		notebookID uint64
+		parentID   *uint64
+		want       error
+	}{
+		{"移到顶层", 3, nil, nil},
+		{"移到其他笔记本下", 4, id(3), nil},
+		{"移到自身下", 2, id(2), ErrNotebookCycle},
+		{"移到子笔记本下", 1, id(2), ErrNotebookCycle},
+		{"移到孙笔记本下", 1, id(3), ErrNotebookCycle},
+		{"笔记本不存在", 9, nil, ErrNotebookNotFound},
+		{"父笔记本不存在", 1, id(9), ErrNotebookParentInvalid},
+		{"移到已成环的笔记本下", 4, id(5), nil},
+		{"环上的笔记本移到环内", 5, id(6), ErrNotebookCycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMove(notebooks, tt.notebookID, tt.parentID)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("checkMove = %v，期望 %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotebookTreeCycle(t *testing.T) {
+	// 1 为顶层，2 -> 3 -> 2 成环
+	notebooks := newTestNotebooks(map[uint64]uint64{1: 0, 2: 3, 3: 2})
+	tree := &notebookTree{
+		all:  notebooks,
+		byID: make(map[uint64]*model.Notebook),
+		seen: make(map[uint64]bool),
+	}
+	for _, notebook := range notebooks {
+		tree.byID[notebook.ID] = notebook
+	}
+	for _, notebook := range notebooks {
+		if parent := tree.parent(notebook); parent != nil {
+			parent.Children = append(parent.Children, notebook)
+		}
+	}
+
+	// 从环上任一笔记本出发都应结束，每个笔记本只计算一次
+	if got := tree.aggregate(tree.byID[2], ""); got != 2 {
+		t.Errorf("aggregate = %d，期望 2", got)
+	}
+	if got := tree.descendantIDs(tree.byID[2], nil); len(got) != 1 || got[0] != 3 {
+		t.Errorf("descendantIDs = %v，期望 [3]", got)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/query"
+)
+
+var (
+	ErrSavedSearchNotFound      = errors.New("保存的搜索不存在")
+	ErrSavedSearchNameDuplicate = errors.New("保存的搜索名称已存在")
+)
+
+// SavedSearchService 保存的搜索服务
+type SavedSearchService struct {
+	savedSearchRepo *repo.SavedSearchRepo
+	noteService     *NoteService
+}
+
+// NewSavedSearchService 创建保存的搜索服务实例
+func NewSavedSearchService() *SavedSearchService {
+	return &SavedSearchService{
+		savedSearchRepo: repo.NewSavedSearchRepo(),
+		noteService:     NewNoteService(),
+	}
+}
+
+// Create 创建保存的搜索
+func (s *SavedSearchService) Create(userID uint64, req *model.SavedSearchReq) (*model.SavedSearch, error) {
+	if err := s.validate(userID, req, 0); err != nil {
+		return nil, err
+	}
+
+	search := &model.SavedSearch{UserID: userID}
+	applySavedSearchReq(search, req)
+	if err := s.savedSearchRepo.Create(search); err != nil {
+		return nil, err
+	}
+
+	count, err := s.noteService.Count(userID, search.ListReq())
+	if err != nil {
+		return nil, err
+	}
+	search.NoteCount = count
+	return search, nil
+}
+
+// GetByID 获取保存的搜索，附带当前符合条件的笔记数量
+func (s *SavedSearchService) GetByID(userID, searchID uint64) (*model.SavedSearch, error) {
+	search, err := s.savedSearchRepo.GetByIDAndUserID(searchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if search == nil {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	count, err := s.noteService.Count(userID, search.ListReq())
+	if err != nil {
+		return nil, err
+	}
+	search.NoteCount = count
+	return search, nil
+}
+
+// Update 修改保存的搜索，整体替换名称和筛选条件
+func (s *SavedSearchService) Update(userID, searchID uint64, req *model.SavedSearchReq) (*model.SavedSearch, error) {
+	search, err := s.savedSearchRepo.GetByIDAndUserID(searchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if search == nil {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err := s.validate(userID, req, searchID); err != nil {
+		return nil, err
+	}
+
+	applySavedSearchReq(search, req)
+	if err := s.savedSearchRepo.Update(search); err != nil {
+		return nil, err
+	}
+
+	count, err := s.noteService.Count(userID, search.ListReq())
+	if err != nil {
+		return nil, err
+	}
+	search.NoteCount = count
+	return search, nil
+}
+
+// Delete 删除保存的搜索，不影响其中的笔记
+func (s *SavedSearchService) Delete(userID, searchID uint64) error {
+	search, err := s.savedSearchRepo.GetByIDAndUserID(searchID, userID)
+	if err != nil {
+		return err
+	}
+	if search == nil {
+		return ErrSavedSearchNotFound
+	}
+	return s.savedSearchRepo.Delete(searchID)
+}
+
+// List 获取用户保存的搜索，附带当前符合条件的笔记数量
+// 语法规则调整后已保存的搜索语句无法解析时，数量记为 0，不影响其他条目
+func (s *SavedSearchService) List(userID uint64) ([]*model.SavedSearch, error) {
+	searches, err := s.savedSearchRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*model.NoteListReq, len(searches))
+	for i, search := range searches {
+		reqs[i] = search.ListReq()
+	}
+	counts, err := s.noteService.CountEach(userID, reqs)
+	if err != nil {
+		return nil, err
+	}
+	for i, search := range searches {
+		search.NoteCount = counts[i]
+	}
+	return searches, nil
+}
+
+// ListNotes 按保存的筛选条件查询笔记，相对日期按当前时间换算
+func (s *SavedSearchService) ListNotes(userID, searchID uint64, req *model.SavedSearchNotesReq) (*model.NoteListResp, error) {
+	search, err := s.savedSearchRepo.GetByIDAndUserID(searchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if search == nil {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	listReq := search.ListReq()
+	listReq.Page = req.Page
+	listReq.PageSize = req.PageSize
+	listReq.IncludeContent = req.IncludeContent
+	listReq.Facets = req.Facets
+	listReq.Sort = req.Sort
+	listReq.Order = req.Order
+	listReq.Cursor = req.Cursor
+	return s.noteService.List(userID, listReq)
+}
+
+// validate 检查名称是否重复、搜索语句能否解析
+func (s *SavedSearchService) validate(userID uint64, req *model.SavedSearchReq, excludeID uint64) error {
+	exists, err := s.savedSearchRepo.ExistsByUserIDAndName(userID, req.Name, excludeID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrSavedSearchNameDuplicate
+	}
+
+	if _, err := query.Parse(req.Q); err != nil {
+		return fmt.Errorf("%w，%v", ErrInvalidQuery, err)
+	}
+	return nil
+}
+
+func applySavedSearchReq(search *model.SavedSearch, req *model.SavedSearchReq) {
+	search.Name = req.Name
+	search.NotebookID = req.NotebookID
+	search.TagID = req.TagID
+	search.IsStarred = req.IsStarred
+	search.IsPinned = req.IsPinned
+	search.Keyword = req.Keyword
+	search.Q = req.Q
+}
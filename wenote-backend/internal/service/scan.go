@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wenote-backend/internal/model"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/scanner"
+	"wenote-backend/pkg/storage"
+)
+
+// QuarantineKey 隔离区中的对象键
+func QuarantineKey(key string) string {
+	return QuarantineKeyPrefix + "/" + key
+}
+
+// ScanAttachment 扫描待扫描的附件
+// 未检出时标记为安全并提交缩略图和文本提取任务；检出时隔离文件。扫描结果写入审计日志。
+// 扫描器不可用时返回错误，附件保持 pending，等待下次重试
+func (s *AttachmentService) ScanAttachment(ctx context.Context, attachmentID uint64) error {
+	if globalScanner == nil {
+		return nil
+	}
+
+	attachment, err := s.repo.GetByID(attachmentID)
+	if err != nil {
+		return ErrAttachmentNotFound
+	}
+	if attachment.ScanStatus != model.ScanStatusPending {
+		return nil
+	}
+
+	obj, err := s.storage.Open(ctx, storage.NormalizeKey(attachment.StoragePath))
+	if err != nil {
+		return err
+	}
+	result, err := globalScanner.Scan(ctx, obj)
+	obj.Close()
+	if err != nil {
+		return fmt.Errorf("病毒扫描失败: %w", err)
+	}
+
+	if result.Infected {
+		return s.quarantine(ctx, attachment, result)
+	}
+
+	ok, err := s.repo.UpdateScanStatus(attachment.ID, model.ScanStatusPending, model.ScanStatusClean)
+	if err != nil || !ok {
+		return err
+	}
+	recordScanResult(attachment, result)
+	enqueueProcessing(attachment)
+	return nil
+}
+
+// quarantine 将检出恶意内容的文件移入隔离区
+// 内容相同的附件共享文件，一并标记为感染；原文件及其缩略图在记录更新后删除
+func (s *AttachmentService) quarantine(ctx context.Context, attachment *model.NoteAttachment, result *scanner.Result) error {
+	key := storage.NormalizeKey(attachment.StoragePath)
+	quarantineKey := key
+	if !strings.HasPrefix(key, QuarantineKeyPrefix+"/") {
+		quarantineKey = QuarantineKey(key)
+		if err := s.copyObject(ctx, key, quarantineKey); err != nil {
+			return fmt.Errorf("隔离文件失败: %w", err)
+		}
+	}
+
+	affected, err := s.repo.Quarantine(attachment, quarantineKey)
+	if err != nil {
+		return err
+	}
+	if quarantineKey == key {
+		return nil
+	}
+	if len(affected) == 0 {
+		// 附件已在隔离前被删除
+		s.storage.Delete(ctx, quarantineKey)
+		return nil
+	}
+
+	for _, a := range affected {
+		recordScanResult(a, result)
+		// 隔离时删除了提取的文本，不再参与笔记搜索
+		if a.TextStatus == model.TextStatusDone {
+			syncSearchIndex(a.NoteID)
+		}
+	}
+	for _, k := range fileKeys(key) {
+		if err := s.storage.Delete(ctx, k); err != nil {
+			logger.Warn("删除已隔离的原文件失败", "key", k, "error", err)
+		}
+	}
+	logger.Warn("附件检出恶意内容，已隔离",
+		"attachment_id", attachment.ID,
+		"signature", result.Signature,
+		"affected", len(affected),
+	)
+	return nil
+}
+
+// copyObject 在同一存储内复制对象
+func (s *AttachmentService) copyObject(ctx context.Context, src, dst string) error {
+	obj, err := s.storage.Open(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	info := obj.Info()
+	return s.storage.Put(ctx, dst, obj, info.Size, info.ContentType)
+}
+
+// recordScanResult 将扫描结果写入附件所有者的审计日志
+func recordScanResult(attachment *model.NoteAttachment, result *scanner.Result) {
+	status := model.ScanStatusClean
+	if result.Infected {
+		status = model.ScanStatusInfected
+	}
+	details := map[string]interface{}{
+		"result":   status,
+		"scanner":  globalScanner.Name(),
+		"filename": attachment.Filename,
+		"note_id":  attachment.NoteID,
+	}
+	if result.Signature != "" {
+		details["signature"] = result.Signature
+	}
+	RecordAudit(&model.AuditLog{
+		UserID:       attachment.UserID,
+		Action:       model.AuditActionAttachmentScan,
+		ResourceType: model.AuditResourceAttachment,
+		ResourceID:   attachment.ID,
+		Details:      details,
+	})
+}
+
+// enqueueProcessing 提交通过扫描的附件的缩略图和文本提取任务
+func enqueueProcessing(attachment *model.NoteAttachment) {
+	if attachment.VariantStatus == model.VariantStatusPending {
+		enqueueVariants(attachment.ID)
+	}
+	if attachment.TextStatus == model.TextStatusPending {
+		enqueueExtract(attachment.ID)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/scanner"
+	"wenote-backend/pkg/storage"
+)
+
+// TestScanAttachment 使用模拟扫描器检查扫描流程：
+// 扫描前禁止下载；检出 EICAR 时标记为感染并移入隔离区，仍禁止下载；未检出时标记为安全后可下载
+func TestScanAttachment(t *testing.T) {
+	openTestDB(t)
+	userID := newTestUserID(t)
+
+	prevConfig, prevScanner := config.GlobalConfig, globalScanner
+	config.GlobalConfig = &config.Config{}
+	globalScanner = scanner.NewFake()
+	t.Cleanup(func() { config.GlobalConfig, globalScanner = prevConfig, prevScanner })
+
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	s := &AttachmentService{repo: repo.NewAttachmentRepo(), storage: store}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"检出病毒", scanner.EICAR + fmt.Sprint(userID), model.ScanStatusInfected},
+		{"安全文件", "hello " + fmt.Sprint(userID), model.ScanStatusClean},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := sha256.Sum256([]byte(tt.content))
+			hash := hex.EncodeToString(sum[:])
+			key := BlobKey(hash)
+			if err := store.Put(ctx, key, strings.NewReader(tt.content), int64(len(tt.content)), "text/plain"); err != nil {
+				t.Fatal(err)
+			}
+			blob := &model.AttachmentBlob{Hash: hash, Size: int64(len(tt.content)), MimeType: "text/plain", StoragePath: key}
+			if err := s.repo.AcquireBlob(blob); err != nil {
+				t.Fatalf("创建内容记录失败: %v", err)
+			}
+			attachment := &model.NoteAttachment{
+				NoteID:      userID,
+				UserID:      userID,
+				Filename:    "test.txt",
+				FileSize:    len(tt.content),
+				MimeType:    "text/plain",
+				Category:    "file",
+				StoragePath: key,
+				BlobHash:    hash,
+				URL:         "/",
+				ScanStatus:  model.ScanStatusPending,
+			}
+			if err := s.repo.Create(attachment); err != nil {
+				t.Fatalf("创建附件失败: %v", err)
+			}
+
+			if _, _, err := s.OpenContent(userID, attachment.ID, ""); err != ErrAttachmentScanPending {
+				t.Fatalf("扫描前下载应返回 ErrAttachmentScanPending，得到 %v", err)
+			}
+
+			if err := s.ScanAttachment(ctx, attachment.ID); err != nil {
+				t.Fatalf("ScanAttachment 失败: %v", err)
+			}
+			scanned, err := s.repo.GetByID(attachment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scanned.ScanStatus != tt.want {
+				t.Fatalf("扫描后状态为 %s，期望 %s", scanned.ScanStatus, tt.want)
+			}
+
+			_, obj, err := s.OpenContent(userID, attachment.ID, "")
+			if tt.want == model.ScanStatusInfected {
+				if err != ErrAttachmentInfected {
+					t.Errorf("感染文件下载应返回 ErrAttachmentInfected，得到 %v", err)
+				}
+				if _, err := store.Stat(ctx, key); err != storage.ErrNotExist {
+					t.Errorf("原文件应已删除: %v", err)
+				}
+				if _, err := store.Stat(ctx, QuarantineKey(key)); err != nil {
+					t.Errorf("隔离区中应有文件: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("安全文件应可下载: %v", err)
+			}
+			defer obj.Close()
+			data, err := io.ReadAll(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.content {
+				t.Errorf("下载内容为 %q，期望 %q", data, tt.content)
+			}
+		})
+	}
+}
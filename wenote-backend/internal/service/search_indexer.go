@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"wenote-backend/internal/search"
+	"wenote-backend/pkg/batch"
+	"wenote-backend/pkg/logger"
+)
+
+// searchSyncBatchSize 后台同步索引时单批最多合并的笔记数
+const searchSyncBatchSize = 100
+
+// globalSearchIndexer 搜索索引异步更新器
+// 笔记创建、修改、删除、恢复后只负责入队，由后台协程批量同步到搜索后端
+var globalSearchIndexer *batch.Worker[uint64]
+
+// StartSearchIndexer 启动搜索索引后台同步协程
+// 返回的 stop 函数会停止接收新的变更，并在同步完队列中剩余的笔记后返回
+func StartSearchIndexer(queueSize int) (stop func()) {
+	engine := searchEngine()
+	w := batch.Start(queueSize, searchSyncBatchSize, func(ids []uint64) {
+		// 同一笔记的多次修改只同步一次
+		if err := engine.Sync(context.Background(), uniqueIDs(ids)...); err != nil {
+			logger.Error("批量更新搜索索引失败", "count", len(ids), "error", err)
+		}
+	})
+	globalSearchIndexer = w
+	return w.Close
+}
+
+// searchEngine 当前使用的搜索后端，未初始化时（如命令行工具）使用 MySQL 全文索引
+func searchEngine() search.Engine {
+	if globalSearchEngine != nil {
+		return globalSearchEngine
+	}
+	return search.NewMySQL(0)
+}
+
+// syncSearchIndex 笔记变更后更新搜索索引（异步，不阻塞调用方）
+// 队列已满时丢弃并记录告警，可通过重建索引补齐；同步器未启动时同步执行
+func syncSearchIndex(noteIDs ...uint64) {
+	if len(noteIDs) == 0 {
+		return
+	}
+
+	w := globalSearchIndexer
+	if w == nil {
+		if err := searchEngine().Sync(context.Background(), noteIDs...); err != nil {
+			logger.Error("更新搜索索引失败", "note_ids", noteIDs, "error", err)
+		}
+		return
+	}
+
+	for _, id := range noteIDs {
+		switch w.Add(id) {
+		case batch.ErrClosed:
+			logger.Warn("搜索索引同步器已关闭，丢弃变更", "note_id", id)
+		case batch.ErrFull:
+			logger.Warn("搜索索引队列已满，丢弃变更", "note_id", id)
+		}
+	}
+}
+
+// uniqueIDs 去除重复的 ID，保持首次出现的顺序
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	unique := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/broadcast"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/suggest"
+)
+
+// suggestCache 各用户的输入联想索引
+// 首次查询时从数据库加载，之后由笔记、标签、笔记本的写操作增量更新；
+// 多副本部署时通过 broadcaster 通知其他实例丢弃该用户的索引，下次查询重新加载；
+// 超过有效期后在后台重新加载，期间仍使用旧索引，作为通知丢失（如与 Redis 断开）时的兜底；
+// 最多保留 maxUsers 个用户，超出时淘汰最久未使用的
+type suggestCache struct {
+	mu       sync.Mutex
+	users    map[uint64]*list.Element // 值为 *suggestEntry
+	lru      *list.List               // 最近使用的在前
+	maxUsers int
+	ttl      time.Duration
+
+	broadcaster broadcast.Broadcaster // 为 nil 时不通知其他实例（单实例部署）
+	instanceID  string                // 本实例标识，忽略自己发出的通知
+}
+
+type suggestEntry struct {
+	userID     uint64
+	index      *suggest.Index
+	loadedAt   time.Time
+	ready      chan struct{} // 首次加载完成后关闭
+	err        error
+	version    int  // 增量更新次数，后台重新加载期间有更新时放弃加载结果
+	refreshing bool // 是否正在后台重新加载
+}
+
+var globalSuggestCache *suggestCache
+
+// InitSuggestCache 初始化输入联想索引缓存，未初始化时（如命令行工具）写操作不更新索引
+// b 不为 nil 时，本实例的修改通知其他实例，并接收其他实例的通知
+func InitSuggestCache(maxUsers int, ttl time.Duration, b broadcast.Broadcaster) {
+	if maxUsers <= 0 {
+		maxUsers = 10000
+	}
+	c := &suggestCache{
+		users:       make(map[uint64]*list.Element),
+		lru:         list.New(),
+		maxUsers:    maxUsers,
+		ttl:         ttl,
+		broadcaster: b,
+		instanceID:  newInstanceID(),
+	}
+	if b != nil {
+		b.Subscribe(c.receive)
+	}
+	globalSuggestCache = c
+}
+
+// newInstanceID 生成随机的实例标识
+func newInstanceID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// notify 通知其他实例丢弃用户的索引，消息为 "<实例标识>:<用户 ID>"
+// 异步发送，不阻塞写操作；发送失败时其他实例在索引过期后才能看到修改
+func (c *suggestCache) notify(userID uint64) {
+	if c.broadcaster == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		msg := c.instanceID + ":" + strconv.FormatUint(userID, 10)
+		if err := c.broadcaster.Publish(ctx, msg); err != nil {
+			logger.Warn("发送联想索引失效通知失败", "user_id", userID, "error", err)
+		}
+	}()
+}
+
+// receive 处理其他实例发来的通知，丢弃对应用户的索引
+func (c *suggestCache) receive(msg string) {
+	instanceID, rawUserID, ok := strings.Cut(msg, ":")
+	if !ok || instanceID == c.instanceID {
+		return
+	}
+	userID, err := strconv.ParseUint(rawUserID, 10, 64)
+	if err != nil {
+		logger.Warn("无效的联想索引失效通知", "message", msg)
+		return
+	}
+	c.invalidate(userID)
+}
+
+// invalidate 丢弃本实例中用户的索引
+func (c *suggestCache) invalidate(userID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.users[userID]; ok {
+		c.removeElement(el)
+	}
+}
+
+// index 返回用户的索引，不存在时加载，同一用户并发请求只加载一次
+// 已过期的索引照常返回，同时在后台重新加载
+func (c *suggestCache) index(userID uint64) (*suggest.Index, error) {
+	c.mu.Lock()
+	if el, ok := c.users[userID]; ok {
+		e := el.Value.(*suggestEntry)
+		select {
+		case <-e.ready:
+			if e.err == nil {
+				if c.ttl > 0 && time.Since(e.loadedAt) >= c.ttl && !e.refreshing {
+					e.refreshing = true
+					go c.refresh(e)
+				}
+				c.lru.MoveToFront(el)
+				c.mu.Unlock()
+				return e.index, nil
+			}
+			c.removeElement(el)
+		default:
+			// 其他请求正在加载
+			c.mu.Unlock()
+			<-e.ready
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return e.index, e.err
+		}
+	}
+
+	e := &suggestEntry{userID: userID, index: suggest.NewIndex(), ready: make(chan struct{})}
+	c.users[userID] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxUsers {
+		c.removeElement(c.lru.Back())
+	}
+	c.mu.Unlock()
+
+	items, err := loadSuggestItems(userID)
+	if err == nil {
+		e.index.Load(items)
+	}
+	e.err = err
+	e.loadedAt = time.Now()
+	close(e.ready)
+	return e.index, err
+}
+
+// refresh 在后台重新加载已过期的索引
+// 加载期间有增量更新时放弃结果，下次查询再重试，避免覆盖更新
+func (c *suggestCache) refresh(e *suggestEntry) {
+	c.mu.Lock()
+	version := e.version
+	c.mu.Unlock()
+
+	// 在锁外建立新索引，加载完成后替换，正在进行的查询继续使用旧索引
+	items, err := loadSuggestItems(e.userID)
+	fresh := suggest.NewIndex()
+	if err == nil {
+		fresh.Load(items)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refreshing = false
+	if err != nil {
+		logger.Warn("重新加载联想索引失败", "user_id", e.userID, "error", err)
+		return
+	}
+	if e.version != version {
+		return
+	}
+	e.index = fresh
+	e.loadedAt = time.Now()
+}
+
+// update 在已加载的索引上执行增量更新
+// 索引正在加载时丢弃该索引，下次查询重新加载，避免加载结果覆盖本次修改
+func (c *suggestCache) update(userID uint64, fn func(*suggest.Index)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.users[userID]
+	if !ok {
+		return
+	}
+	e := el.Value.(*suggestEntry)
+	select {
+	case <-e.ready:
+		if e.err == nil {
+			fn(e.index)
+			e.version++
+			return
+		}
+	default:
+	}
+	c.removeElement(el)
+}
+
+func (c *suggestCache) removeElement(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.users, el.Value.(*suggestEntry).userID)
+}
+
+// loadSuggestItems 从数据库读取用户未删除的笔记标题、标签名和笔记本名
+func loadSuggestItems(userID uint64) ([]*suggest.Item, error) {
+	notes, err := repo.NewNoteRepo().ListTitlesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := repo.NewTagRepo().ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	notebooks, err := repo.NewNotebookRepo().ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*suggest.Item, 0, len(notes)+len(tags)+len(notebooks))
+	for _, n := range notes {
+		if n.Title != "" {
+			items = append(items, &suggest.Item{Type: model.SuggestTypeNote, ID: n.ID, Text: n.Title})
+		}
+	}
+	for _, t := range tags {
+		items = append(items, &suggest.Item{Type: model.SuggestTypeTag, ID: t.ID, Text: t.Name})
+	}
+	for _, nb := range notebooks {
+		items = append(items, &suggest.Item{Type: model.SuggestTypeNotebook, ID: nb.ID, Text: nb.Name})
+	}
+	return items, nil
+}
+
+// suggestPut 名称新增或修改后更新用户的联想索引
+func suggestPut(userID uint64, typ string, id uint64, text string) {
+	if globalSuggestCache == nil {
+		return
+	}
+	globalSuggestCache.update(userID, func(index *suggest.Index) {
+		index.Put(&suggest.Item{Type: typ, ID: id, Text: text})
+	})
+	globalSuggestCache.notify(userID)
+}
+
+// suggestRemove 删除后从用户的联想索引中移除
+func suggestRemove(userID uint64, typ string, ids ...uint64) {
+	if globalSuggestCache == nil {
+		return
+	}
+	globalSuggestCache.update(userID, func(index *suggest.Index) {
+		for _, id := range ids {
+			index.Remove(typ, id)
+		}
+	})
+	globalSuggestCache.notify(userID)
+}
+
+// suggestInvalidate 批量变更后丢弃用户的联想索引，下次查询时重新加载
+func suggestInvalidate(userID uint64) {
+	if globalSuggestCache == nil {
+		return
+	}
+	globalSuggestCache.invalidate(userID)
+	globalSuggestCache.notify(userID)
+}
+
+// SuggestService 输入联想服务
+type SuggestService struct{}
+
+// NewSuggestService 创建输入联想服务实例
+func NewSuggestService() *SuggestService {
+	return &SuggestService{}
+}
+
+// Suggest 返回名称中有位置以 prefix 开头的笔记标题、标签和笔记本
+func (s *SuggestService) Suggest(userID uint64, req *model.SuggestReq) (*model.SuggestResp, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var index *suggest.Index
+	if globalSuggestCache != nil {
+		var err error
+		if index, err = globalSuggestCache.index(userID); err != nil {
+			return nil, err
+		}
+	} else {
+		items, err := loadSuggestItems(userID)
+		if err != nil {
+			return nil, err
+		}
+		index = suggest.NewIndex()
+		index.Load(items)
+	}
+
+	items := index.Search(req.Prefix, limit)
+	list := make([]*model.Suggestion, len(items))
+	for i, item := range items {
+		list[i] = &model.Suggestion{Type: item.Type, ID: item.ID, Text: item.Text}
+	}
+	return &model.SuggestResp{List: list}, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"wenote-backend/pkg/suggest"
+)
+
+// localBroadcaster 进程内的广播，模拟多个实例共用同一个 Redis 频道
+type localBroadcaster struct {
+	mu       sync.Mutex
+	handlers []func(string)
+}
+
+func (b *localBroadcaster) Publish(ctx context.Context, msg string) error {
+	b.mu.Lock()
+	handlers := append([]func(string){}, b.handlers...)
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(msg)
+	}
+	return nil
+}
+
+func (b *localBroadcaster) Subscribe(handler func(string)) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+}
+
+func (b *localBroadcaster) Close() error { return nil }
+
+// newLoadedSuggestCache 创建缓存并放入已加载完成的用户索引，不访问数据库
+func newLoadedSuggestCache(b *localBroadcaster, userIDs ...uint64) *suggestCache {
+	InitSuggestCache(10, time.Hour, b)
+	c := globalSuggestCache
+	for _, userID := range userIDs {
+		e := &suggestEntry{userID: userID, index: suggest.NewIndex(), ready: make(chan struct{}), loadedAt: time.Now()}
+		close(e.ready)
+		c.users[userID] = c.lru.PushFront(e)
+	}
+	return c
+}
+
+func (c *suggestCache) cached(userID uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.users[userID]
+	return ok
+}
+
+func TestSuggestCacheInvalidatesOtherInstances(t *testing.T) {
+	prev := globalSuggestCache
+	t.Cleanup(func() { globalSuggestCache = prev })
+
+	b := &localBroadcaster{}
+	other := newLoadedSuggestCache(b, 1, 2)
+	local := newLoadedSuggestCache(b, 1, 2)
+
+	// 本实例的增量更新保留自己的索引，其他实例丢弃该用户的索引
+	suggestPut(1, "note", 10, "周报")
+	waitFor(t, func() bool { return !other.cached(1) })
+	if !local.cached(1) {
+		t.Error("本实例不应丢弃自己增量更新过的索引")
+	}
+	if !other.cached(2) {
+		t.Error("其他用户的索引不应被丢弃")
+	}
+
+	suggestInvalidate(2)
+	waitFor(t, func() bool { return !other.cached(2) })
+	if local.cached(2) {
+		t.Error("本实例应丢弃批量变更的用户索引")
+	}
+}
+
+func TestSuggestCacheIgnoresInvalidMessages(t *testing.T) {
+	prev := globalSuggestCache
+	t.Cleanup(func() { globalSuggestCache = prev })
+
+	c := newLoadedSuggestCache(&localBroadcaster{}, 1)
+	for _, msg := range []string{"", "1", "other:abc", c.instanceID + ":1"} {
+		c.receive(msg)
+	}
+	if !c.cached(1) {
+		t.Error("无效或自己发出的通知不应丢弃索引")
+	}
+	c.receive("other:1")
+	if c.cached(1) {
+		t.Error("其他实例的通知应丢弃索引")
+	}
+}
+
+// waitFor 等待异步发送的通知生效
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
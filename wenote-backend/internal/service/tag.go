@@ -4,11 +4,15 @@ import (
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/repo"
 	"errors"
+	"sort"
+	"strings"
 )
 
 var (
 	ErrTagNotFound   = errors.New("标签不存在")
 	ErrTagNameExists = errors.New("标签名称已存在")
+	ErrTagNameEmpty  = errors.New("标签名称不能为空")
+	ErrTagMergeSelf  = errors.New("不能将标签合并到自身")
 )
 
 // TagService 标签服务
@@ -25,8 +29,13 @@ func NewTagService() *TagService {
 
 // Create 创建标签
 func (s *TagService) Create(userID uint64, req *model.TagCreateReq) (*model.Tag, error) {
+	name := model.NormalizeTagName(req.Name)
+	if name == "" {
+		return nil, ErrTagNameEmpty
+	}
+
 	// 检查名称是否已存在
-	exists, err := s.tagRepo.ExistsByNameAndUserID(req.Name, userID)
+	exists, err := s.tagRepo.ExistsByNameAndUserID(name, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -41,13 +50,14 @@ func (s *TagService) Create(userID uint64, req *model.TagCreateReq) (*model.Tag,
 
 	tag := &model.Tag{
 		UserID: userID,
-		Name:   req.Name,
+		Name:   name,
 		Color:  color,
 	}
 
 	if err := s.tagRepo.Create(tag); err != nil {
 		return nil, err
 	}
+	suggestPut(userID, model.SuggestTypeTag, tag.ID, tag.Name)
 
 	return tag, nil
 }
@@ -82,10 +92,20 @@ func (s *TagService) Delete(userID, tagID uint64) error {
 		return ErrTagNotFound
 	}
 
-	return s.tagRepo.Delete(tagID)
+	// 删除前记录关联的笔记，删除后同步搜索索引中的标签
+	noteIDs, err := s.tagRepo.ListNoteIDsByTagIDs([]uint64{tagID})
+	if err != nil {
+		return err
+	}
+	if err := s.tagRepo.Delete(tagID); err != nil {
+		return err
+	}
+	syncSearchIndex(noteIDs...)
+	suggestRemove(userID, model.SuggestTypeTag, tagID)
+	return nil
 }
 
-// Update 更新标签
+// Update 更新标签，改名时子标签的前缀随之修改
 func (s *TagService) Update(userID, tagID uint64, req *model.TagUpdateReq) (*model.Tag, error) {
 	tag, err := s.tagRepo.GetByIDAndUserID(tagID, userID)
 	if err != nil {
@@ -95,28 +115,70 @@ func (s *TagService) Update(userID, tagID uint64, req *model.TagUpdateReq) (*mod
 		return nil, ErrTagNotFound
 	}
 
+	var renamed []*model.Tag
 	if req.Name != nil {
-		// 检查新名称是否与其他标签重复
-		exists, err := s.tagRepo.ExistsByNameAndUserID(*req.Name, userID)
-		if err != nil {
-			return nil, err
+		name := model.NormalizeTagName(*req.Name)
+		if name == "" {
+			return nil, ErrTagNameEmpty
 		}
-		if exists && *req.Name != tag.Name {
-			return nil, ErrTagNameExists
+		if name != tag.Name {
+			if renamed, err = s.rename(userID, tag, name); err != nil {
+				return nil, err
+			}
 		}
-		tag.Name = *req.Name
 	}
 	if req.Color != nil {
 		tag.Color = *req.Color
 	}
 
+	// 标签与子标签在同一事务中改名
+	if len(renamed) > 0 {
+		if err := s.tagRepo.Rename(renamed); err != nil {
+			return nil, err
+		}
+	}
 	if err := s.tagRepo.Update(tag); err != nil {
 		return nil, err
 	}
+	if len(renamed) == 0 {
+		renamed = []*model.Tag{tag}
+	}
+	for _, t := range renamed {
+		suggestPut(userID, model.SuggestTypeTag, t.ID, t.Name)
+	}
 
 	return tag, nil
 }
 
+// rename 将 tag 改名为 name，子标签的前缀随之修改，如 lang 改为 language 时 lang/go 改为 language/go
+// 返回改名后的标签，第一个为 tag 本身；任一新名称与其他标签重复时返回 ErrTagNameExists
+func (s *TagService) rename(userID uint64, tag *model.Tag, name string) ([]*model.Tag, error) {
+	descendants, err := s.tagRepo.ListDescendants(userID, tag.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := append([]*model.Tag{tag}, descendants...)
+	excludeIDs := make([]uint64, len(renamed))
+	for i, t := range renamed {
+		excludeIDs[i] = t.ID
+	}
+
+	oldName := tag.Name
+	for _, t := range renamed {
+		newName := name + strings.TrimPrefix(t.Name, oldName)
+		exists, err := s.tagRepo.ExistsByNameAndUserID(newName, userID, excludeIDs...)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrTagNameExists
+		}
+		t.Name = newName
+	}
+	return renamed, nil
+}
+
 // List 获取标签列表
 func (s *TagService) List(userID uint64) ([]*model.Tag, error) {
 	tags, err := s.tagRepo.ListByUserID(userID)
@@ -135,3 +197,97 @@ func (s *TagService) List(userID uint64) ([]*model.Tag, error) {
 
 	return tags, nil
 }
+
+// Duplicates 查找查重键相同的标签，如 golang、Golang、go-lang，并给出合并建议
+// 每组中笔记最多的标签作为建议保留的目标，数量相同时保留较早创建的
+func (s *TagService) Duplicates(userID uint64) ([]*model.TagDuplicate, error) {
+	tags, err := s.tagRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.tagRepo.CountNotesGroupByTag(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*model.Tag)
+	var keys []string
+	for _, tag := range tags {
+		tag.NoteCount = counts[tag.ID]
+		key := model.TagKey(tag.Name)
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], tag)
+	}
+	sort.Strings(keys)
+
+	list := make([]*model.TagDuplicate, 0)
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].NoteCount != group[j].NoteCount {
+				return group[i].NoteCount > group[j].NoteCount
+			}
+			return group[i].CreatedAt.Before(group[j].CreatedAt)
+		})
+		list = append(list, &model.TagDuplicate{Key: key, Target: group[0], Sources: group[1:]})
+	}
+	return list, nil
+}
+
+// Merge 将源标签合并到目标标签，返回合并后的目标标签
+// 源标签的子标签不受影响
+func (s *TagService) Merge(userID uint64, req *model.TagMergeReq) (*model.Tag, error) {
+	target, err := s.tagRepo.GetByIDAndUserID(req.TargetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrTagNotFound
+	}
+
+	seen := make(map[uint64]bool, len(req.SourceIDs))
+	var sourceIDs []uint64
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			return nil, ErrTagMergeSelf
+		}
+		if !seen[id] {
+			seen[id] = true
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+	sources, err := s.tagRepo.ListByIDs(sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	owned := 0
+	for _, tag := range sources {
+		if tag.UserID == userID {
+			owned++
+		}
+	}
+	if owned != len(sourceIDs) {
+		return nil, ErrTagNotFound
+	}
+
+	// 合并前记录受影响的笔记，合并后同步搜索索引中的标签
+	noteIDs, err := s.tagRepo.ListNoteIDsByTagIDs(sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tagRepo.Merge(target.ID, sourceIDs); err != nil {
+		return nil, err
+	}
+	syncSearchIndex(noteIDs...)
+	suggestRemove(userID, model.SuggestTypeTag, sourceIDs...)
+
+	return s.GetByID(userID, target.ID)
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/logger"
+	"wenote-backend/pkg/storage"
+)
+
+var (
+	ErrUploadNotFound         = errors.New("上传会话不存在或已过期")
+	ErrUploadOffsetMismatch   = errors.New("分片偏移量与已上传的进度不一致")
+	ErrUploadChecksumMismatch = errors.New("校验和不一致，数据可能已损坏")
+	ErrUploadChunkTooLarge    = errors.New("分片大小超过限制")
+	ErrUploadChunkEmpty       = errors.New("分片内容为空")
+	ErrUploadExceedsSize      = errors.New("上传内容超过声明的文件大小")
+	ErrUploadIncomplete       = errors.New("文件尚未上传完成")
+	ErrUploadCompleting       = errors.New("上传会话正在完成，请勿重复提交")
+)
+
+// UploadKeyPrefix 分片对象键前缀，完整键为 uploads/<会话ID>/<偏移量>_<随机串>
+const UploadKeyPrefix = "uploads"
+
+// UploadService 分片续传服务
+// 分片写入附件存储，多实例部署时任意实例都能续传；完成时合并为临时文件，
+// 与普通上传走同一套类型识别、图片处理和配额校验
+type UploadService struct {
+	repo              *repo.UploadRepo
+	userRepo          *repo.UserRepo
+	attachmentService *AttachmentService
+	storage           storage.Storage
+}
+
+// NewUploadService 创建分片续传服务实例
+func NewUploadService() *UploadService {
+	return &UploadService{
+		repo:              repo.NewUploadRepo(),
+		userRepo:          repo.NewUserRepo(),
+		attachmentService: NewAttachmentService(),
+		storage:           globalStorage,
+	}
+}
+
+// Init 创建上传会话
+// 文件类型要等内容上传完成后才能识别，这里只按最宽松的策略预先拒绝明显超限的文件
+func (s *UploadService) Init(userID, noteID uint64, req *model.UploadInitReq) (*model.UploadSession, error) {
+	if err := s.attachmentService.checkNoteOwner(userID, noteID); err != nil {
+		return nil, err
+	}
+
+	if maxSize := maxPolicySize(); maxSize > 0 && req.Size > maxSize {
+		return nil, fmt.Errorf("%w（最大%dMB）", ErrFileTooLarge, maxSize/1024/1024)
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按声明的大小占用配额，避免同时开启多个会话绕过配额；会话结束时释放
+	if err := s.attachmentService.reserveStorage(userID, req.Size); err != nil {
+		return nil, err
+	}
+	session := &model.UploadSession{
+		ID:            id,
+		UserID:        userID,
+		NoteID:        noteID,
+		Filename:      req.Filename,
+		Size:          req.Size,
+		Checksum:      req.Checksum,
+		Status:        model.UploadStatusUploading,
+		QuotaReserved: true,
+		ExpiresAt:     time.Now().Add(uploadExpire()),
+	}
+	if err := s.repo.Create(session); err != nil {
+		s.attachmentService.releaseStorage(userID, req.Size)
+		return nil, err
+	}
+	session.MaxChunkSize = maxChunkSize()
+	return session, nil
+}
+
+// Get 获取上传会话，用于客户端断线后查询续传位置
+func (s *UploadService) Get(userID uint64, id string) (*model.UploadSession, error) {
+	session, err := s.repo.GetByIDAndUserID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.ExpiresAt.Before(time.Now()) {
+		return nil, ErrUploadNotFound
+	}
+	session.MaxChunkSize = maxChunkSize()
+	return session, nil
+}
+
+// Append 在 offset 处追加一个分片
+// offset 必须等于已接收的字节数；checksum 为分片的 SHA-256（十六进制），为空时不校验。
+// 偏移量不一致时返回 ErrUploadOffsetMismatch 和最新的会话，客户端据此调整续传位置
+func (s *UploadService) Append(userID uint64, id string, offset int64, checksum string, body io.Reader) (*model.UploadSession, error) {
+	session, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != model.UploadStatusUploading {
+		return session, ErrUploadCompleting
+	}
+	if offset != session.Offset {
+		return session, ErrUploadOffsetMismatch
+	}
+
+	// 多读一个字节用于判断是否超限
+	limit := session.MaxChunkSize
+	if remaining := session.Size - session.Offset; remaining < limit {
+		limit = remaining
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return session, fmt.Errorf("读取分片失败: %v", err)
+	}
+	if len(data) == 0 {
+		return session, ErrUploadChunkEmpty
+	}
+	if int64(len(data)) > limit {
+		if limit < session.MaxChunkSize {
+			return session, ErrUploadExceedsSize
+		}
+		return session, fmt.Errorf("%w（最大%dMB）", ErrUploadChunkTooLarge, session.MaxChunkSize/1024/1024)
+	}
+
+	sum := sha256.Sum256(data)
+	chunkChecksum := hex.EncodeToString(sum[:])
+	if checksum != "" && checksum != chunkChecksum {
+		return session, ErrUploadChecksumMismatch
+	}
+
+	// 键中带随机串，同一偏移量的并发请求不会互相覆盖
+	suffix, err := randomHex(4)
+	if err != nil {
+		return session, err
+	}
+	key := fmt.Sprintf("%s/%s/%016d_%s", UploadKeyPrefix, id, offset, suffix)
+
+	ctx := context.Background()
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+		return session, fmt.Errorf("保存分片失败: %v", err)
+	}
+
+	chunk := &model.UploadChunk{
+		SessionID:   id,
+		Offset:      offset,
+		Size:        int64(len(data)),
+		Checksum:    chunkChecksum,
+		StoragePath: key,
+	}
+	expiresAt := time.Now().Add(uploadExpire())
+	appended, err := s.repo.AppendChunk(chunk, offset, expiresAt)
+	if err != nil || !appended {
+		s.storage.Delete(ctx, key)
+		if err != nil {
+			return session, err
+		}
+		// 并发请求已抢先追加，返回最新进度
+		if latest, getErr := s.Get(userID, id); getErr == nil {
+			session = latest
+		}
+		return session, ErrUploadOffsetMismatch
+	}
+
+	session.Offset += chunk.Size
+	session.ExpiresAt = expiresAt
+	return session, nil
+}
+
+// Complete 合并分片并创建附件
+// 文件类型不允许、超限或校验失败时会话被丢弃；配额不足等可恢复的错误保留会话，客户端可重试
+func (s *UploadService) Complete(userID uint64, id string) (*model.AttachmentUploadResp, error) {
+	session, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Offset != session.Size {
+		return nil, ErrUploadIncomplete
+	}
+
+	ok, err := s.repo.UpdateStatus(id, model.UploadStatusUploading, model.UploadStatusCompleting)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUploadCompleting
+	}
+
+	resp, err := s.complete(session)
+	switch {
+	case err == nil:
+		// 会话占用的配额已转为附件占用
+		if removeErr := s.remove(session); removeErr != nil {
+			logger.Warn("清理上传会话失败", "upload_id", id, "error", removeErr)
+		}
+	case errors.Is(err, ErrUploadChecksumMismatch),
+		errors.Is(err, ErrFileTypeNotAllowed),
+		errors.Is(err, ErrFileTooLarge),
+		errors.Is(err, ErrInvalidImage):
+		if discardErr := s.discard(session); discardErr != nil {
+			logger.Warn("清理上传会话失败", "upload_id", id, "error", discardErr)
+		}
+	default:
+		if _, revertErr := s.repo.UpdateStatus(id, model.UploadStatusCompleting, model.UploadStatusUploading); revertErr != nil {
+			logger.Error("恢复上传会话状态失败", "upload_id", id, "error", revertErr)
+		}
+	}
+	return resp, err
+}
+
+// complete 将分片按顺序合并到临时文件，逐片校验后创建附件
+func (s *UploadService) complete(session *model.UploadSession) (*model.AttachmentUploadResp, error) {
+	chunks, err := s.repo.ListChunks(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "wenote-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	ctx := context.Background()
+	fileHash := sha256.New()
+	var written int64
+	for _, chunk := range chunks {
+		if chunk.Offset != written {
+			return nil, fmt.Errorf("%w：分片不连续", ErrUploadChecksumMismatch)
+		}
+		if err := appendChunk(ctx, s.storage, chunk, io.MultiWriter(tmp, fileHash)); err != nil {
+			return nil, err
+		}
+		written += chunk.Size
+	}
+	if written != session.Size {
+		return nil, ErrUploadIncomplete
+	}
+	if session.Checksum != "" && hex.EncodeToString(fileHash.Sum(nil)) != session.Checksum {
+		return nil, ErrUploadChecksumMismatch
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// 创建附件时按处理后的实际大小重新占用配额，先释放会话占用的部分，避免重复计算；
+	// 创建失败时恢复会话的占用，此前已占用，不再检查配额
+	if session.QuotaReserved {
+		s.attachmentService.releaseStorage(session.UserID, session.Size)
+	}
+	resp, err := s.attachmentService.createAttachment(session.UserID, session.NoteID, session.Filename, tmp, session.Size)
+	if err != nil && session.QuotaReserved {
+		if _, reserveErr := s.userRepo.ReserveStorage(session.UserID, session.Size, 0); reserveErr != nil {
+			logger.Error("恢复上传会话占用的配额失败", "upload_id", session.ID, "error", reserveErr)
+		}
+	}
+	return resp, err
+}
+
+// appendChunk 读取分片写入 w，并校验分片的 SHA-256
+func appendChunk(ctx context.Context, store storage.Storage, chunk *model.UploadChunk, w io.Writer) error {
+	obj, err := store.Open(ctx, chunk.StoragePath)
+	if err != nil {
+		return fmt.Errorf("读取分片失败: %v", err)
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), obj); err != nil {
+		return fmt.Errorf("读取分片失败: %v", err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != chunk.Checksum {
+		return ErrUploadChecksumMismatch
+	}
+	return nil
+}
+
+// Abort 取消上传并删除已接收的分片
+func (s *UploadService) Abort(userID uint64, id string) error {
+	session, err := s.Get(userID, id)
+	if err != nil {
+		return err
+	}
+	if session.Status != model.UploadStatusUploading {
+		return ErrUploadCompleting
+	}
+	return s.discard(session)
+}
+
+// discard 删除会话的分片文件和记录，并释放会话占用的配额
+func (s *UploadService) discard(session *model.UploadSession) error {
+	if err := s.remove(session); err != nil {
+		return err
+	}
+	if session.QuotaReserved {
+		s.attachmentService.releaseStorage(session.UserID, session.Size)
+	}
+	return nil
+}
+
+// remove 删除会话的分片文件和记录
+// 先删文件再删记录，文件删除失败时保留记录，由过期清理重试
+func (s *UploadService) remove(session *model.UploadSession) error {
+	chunks, err := s.repo.ListChunks(session.ID)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, chunk := range chunks {
+		if err := s.storage.Delete(ctx, chunk.StoragePath); err != nil {
+			return err
+		}
+	}
+	return s.repo.Delete(session.ID)
+}
+
+// CleanupExpiredUploads 清理已过期的上传会话，由每日维护任务调用，返回清理的数量
+func (s *UploadService) CleanupExpiredUploads() (int, error) {
+	cleaned := 0
+	for {
+		sessions, err := s.repo.ListExpired(time.Now(), 100)
+		if err != nil {
+			return cleaned, err
+		}
+
+		progressed := false
+		for _, session := range sessions {
+			if err := s.discard(session); err != nil {
+				logger.Warn("清理过期上传会话失败", "upload_id", session.ID, "error", err)
+				continue
+			}
+			cleaned++
+			progressed = true
+		}
+		// 本批全部失败时停止，避免反复处理同一批会话
+		if len(sessions) < 100 || !progressed {
+			return cleaned, nil
+		}
+	}
+}
+
+// maxPolicySize 所有上传策略中最大的文件大小上限（字节），存在不限制的策略时返回 0
+func maxPolicySize() int64 {
+	var max int64
+	for _, policy := range config.GlobalConfig.Attachment.Policies {
+		if policy.MaxSize <= 0 {
+			return 0
+		}
+		if size := policy.MaxSize * 1024 * 1024; size > max {
+			max = size
+		}
+	}
+	return max
+}
+
+// maxChunkSize 单个分片大小上限（字节）
+func maxChunkSize() int64 {
+	return config.GlobalConfig.Attachment.Upload.MaxChunkSize * 1024 * 1024
+}
+
+// uploadExpire 上传会话无新分片后的过期时间
+func uploadExpire() time.Duration {
+	return time.Duration(config.GlobalConfig.Attachment.Upload.Expire) * time.Hour
+}
+
+// randomHex 生成 n 字节随机数的十六进制串
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"wenote-backend/config"
+	"wenote-backend/internal/model"
+	"wenote-backend/internal/repo"
+	"wenote-backend/pkg/storage"
+)
+
+// newTestUploadService 创建使用内存存储的分片上传服务，分片上限 1MB，会话 1 小时后过期
+func newTestUploadService(t *testing.T) (*UploadService, *storage.MemoryStorage, uint64) {
+	t.Helper()
+	openTestDB(t)
+	userID := newTestUserID(t)
+
+	prev := config.GlobalConfig
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.Attachment.Upload.MaxChunkSize = 1
+	config.GlobalConfig.Attachment.Upload.Expire = 1
+	t.Cleanup(func() { config.GlobalConfig = prev })
+
+	store := storage.NewMemoryStorage()
+	s := &UploadService{
+		repo:              repo.NewUploadRepo(),
+		userRepo:          repo.NewUserRepo(),
+		attachmentService: &AttachmentService{repo: repo.NewAttachmentRepo(), storage: store},
+		storage:           store,
+	}
+	return s, store, userID
+}
+
+// newTestSession 为 content 创建上传会话，记录整个文件的校验和
+func newTestSession(t *testing.T, s *UploadService, userID uint64, content string) *model.UploadSession {
+	t.Helper()
+	id, err := randomHex(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &model.UploadSession{
+		ID:        id,
+		UserID:    userID,
+		NoteID:    userID,
+		Filename:  "test.txt",
+		Size:      int64(len(content)),
+		Checksum:  sha256Hex(content),
+		Status:    model.UploadStatusUploading,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := s.repo.Create(session); err != nil {
+		t.Fatalf("创建上传会话失败: %v", err)
+	}
+	return session
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// countChunks 存储中会话的分片文件数
+func countChunks(t *testing.T, store storage.Storage, sessionID string) int {
+	t.Helper()
+	n := 0
+	err := store.List(context.Background(), UploadKeyPrefix+"/"+sessionID+"/", func(storage.ObjectInfo) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestUploadAppendOffsetMismatch(t *testing.T) {
+	s, store, userID := newTestUploadService(t)
+	session := newTestSession(t, s, userID, "0123456789")
+
+	// 跳过尚未上传的部分，返回 409 对应的错误和当前进度
+	latest, err := s.Append(userID, session.ID, 5, "", strings.NewReader("56789"))
+	if !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Fatalf("偏移量超前应返回 ErrUploadOffsetMismatch，得到 %v", err)
+	}
+	if latest == nil || latest.Offset != 0 {
+		t.Errorf("应返回当前进度 0，得到 %+v", latest)
+	}
+
+	if _, err := s.Append(userID, session.ID, 0, "", strings.NewReader("01234")); err != nil {
+		t.Fatalf("追加分片失败: %v", err)
+	}
+	// 重复发送已接收的分片
+	latest, err = s.Append(userID, session.ID, 0, "", strings.NewReader("01234"))
+	if !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Fatalf("重复的偏移量应返回 ErrUploadOffsetMismatch，得到 %v", err)
+	}
+	if latest.Offset != 5 {
+		t.Errorf("应返回当前进度 5，得到 %d", latest.Offset)
+	}
+	if n := countChunks(t, store, session.ID); n != 1 {
+		t.Errorf("存储中有 %d 个分片，期望 1", n)
+	}
+}
+
+func TestUploadAppendChecksumMismatch(t *testing.T) {
+	s, store, userID := newTestUploadService(t)
+	session := newTestSession(t, s, userID, "0123456789")
+
+	latest, err := s.Append(userID, session.ID, 0, sha256Hex("other"), strings.NewReader("01234"))
+	if !errors.Is(err, ErrUploadChecksumMismatch) {
+		t.Fatalf("分片校验和不一致应返回 ErrUploadChecksumMismatch，得到 %v", err)
+	}
+	if latest.Offset != 0 {
+		t.Errorf("校验失败的分片不应计入进度，得到 %d", latest.Offset)
+	}
+	if n := countChunks(t, store, session.ID); n != 0 {
+		t.Errorf("校验失败的分片不应写入存储，存储中有 %d 个", n)
+	}
+
+	// 校验和正确时接收
+	latest, err = s.Append(userID, session.ID, 0, sha256Hex("01234"), strings.NewReader("01234"))
+	if err != nil {
+		t.Fatalf("追加分片失败: %v", err)
+	}
+	if latest.Offset != 5 {
+		t.Errorf("进度为 %d，期望 5", latest.Offset)
+	}
+}
+
+func TestUploadResumeAfterStatus(t *testing.T) {
+	s, _, userID := newTestUploadService(t)
+	session := newTestSession(t, s, userID, "0123456789")
+
+	if _, err := s.Append(userID, session.ID, 0, "", strings.NewReader("0123")); err != nil {
+		t.Fatalf("追加分片失败: %v", err)
+	}
+
+	// 断线后通过 HEAD 查询进度，从返回的偏移量继续
+	status, err := s.Get(userID, session.ID)
+	if err != nil {
+		t.Fatalf("查询进度失败: %v", err)
+	}
+	if status.Offset != 4 || status.Size != 10 || status.MaxChunkSize != 1024*1024 {
+		t.Fatalf("进度为 %+v，期望 offset=4 size=10", status)
+	}
+	latest, err := s.Append(userID, session.ID, status.Offset, "", strings.NewReader("456789"))
+	if err != nil {
+		t.Fatalf("续传失败: %v", err)
+	}
+	if latest.Offset != latest.Size {
+		t.Errorf("续传后进度为 %d，期望 %d", latest.Offset, latest.Size)
+	}
+
+	// 超出声明大小的内容被拒绝
+	other := newTestSession(t, s, userID, "01234")
+	if _, err := s.Append(userID, other.ID, 0, "", strings.NewReader("0123456789")); !errors.Is(err, ErrUploadExceedsSize) {
+		t.Errorf("超出声明大小应返回 ErrUploadExceedsSize，得到 %v", err)
+	}
+
+	// 其他用户无法查询或续传
+	if _, err := s.Get(userID+1000000, session.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("其他用户查询应返回 ErrUploadNotFound，得到 %v", err)
+	}
+}
+
+func TestUploadCompleteIncomplete(t *testing.T) {
+	s, store, userID := newTestUploadService(t)
+	session := newTestSession(t, s, userID, "0123456789")
+
+	if _, err := s.Append(userID, session.ID, 0, "", strings.NewReader("01234")); err != nil {
+		t.Fatalf("追加分片失败: %v", err)
+	}
+	if _, err := s.Complete(userID, session.ID); !errors.Is(err, ErrUploadIncomplete) {
+		t.Fatalf("缺少内容时完成应返回 ErrUploadIncomplete，得到 %v", err)
+	}
+
+	// 会话保留，可继续上传
+	latest, err := s.Get(userID, session.ID)
+	if err != nil {
+		t.Fatalf("会话应保留: %v", err)
+	}
+	if latest.Status != model.UploadStatusUploading || latest.Offset != 5 {
+		t.Errorf("会话状态为 %s 进度 %d，期望 uploading 5", latest.Status, latest.Offset)
+	}
+	if n := countChunks(t, store, session.ID); n != 1 {
+		t.Errorf("存储中有 %d 个分片，期望 1", n)
+	}
+}
+
+func TestUploadCompleteCorruptedChunk(t *testing.T) {
+	s, store, userID := newTestUploadService(t)
+	session := newTestSession(t, s, userID, "0123456789")
+
+	if _, err := s.Append(userID, session.ID, 0, "", strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("追加分片失败: %v", err)
+	}
+
+	// 存储中的分片在合并前被改写
+	chunks, err := s.repo.ListChunks(session.ID)
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("读取分片失败: %v", err)
+	}
+	if err := store.Put(context.Background(), chunks[0].StoragePath, strings.NewReader("xxxxxxxxxx"), 10, "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Complete(userID, session.ID); !errors.Is(err, ErrUploadChecksumMismatch) {
+		t.Fatalf("分片损坏时完成应返回 ErrUploadChecksumMismatch，得到 %v", err)
+	}
+	// 损坏的会话被丢弃
+	if _, err := s.Get(userID, session.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("损坏的会话应被丢弃，得到 %v", err)
+	}
+	if n := countChunks(t, store, session.ID); n != 0 {
+		t.Errorf("存储中仍有 %d 个分片", n)
+	}
+}
+
+// TestUploadInitReservesQuota 创建会话时按声明大小占用配额，取消后释放
+func TestUploadInitReservesQuota(t *testing.T) {
+	s, _, _ := newTestUploadService(t)
+	s.attachmentService.noteRepo = repo.NewNoteRepo()
+	s.attachmentService.userRepo = s.userRepo
+
+	user := &model.User{
+		Username:     fmt.Sprintf("upload_quota_%d", time.Now().UnixNano()),
+		PasswordHash: "x",
+		StorageQuota: 15,
+	}
+	if err := repo.DB.Create(user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	note := &model.Note{UserID: user.ID, NotebookID: 1, Title: "quota"}
+	if err := repo.DB.Create(note).Error; err != nil {
+		t.Fatalf("创建笔记失败: %v", err)
+	}
+	t.Cleanup(func() {
+		repo.DB.Exec("DELETE FROM upload_sessions WHERE user_id = ?", user.ID)
+		repo.DB.Exec("DELETE FROM notes WHERE user_id = ?", user.ID)
+		repo.DB.Exec("DELETE FROM users WHERE id = ?", user.ID)
+	})
+
+	storageUsed := func() int64 {
+		t.Helper()
+		got, err := s.userRepo.GetByID(user.ID)
+		if err != nil || got == nil {
+			t.Fatalf("读取用户失败: %v", err)
+		}
+		return got.StorageUsed
+	}
+
+	session, err := s.Init(user.ID, note.ID, &model.UploadInitReq{Filename: "a.txt", Size: 10})
+	if err != nil {
+		t.Fatalf("创建上传会话失败: %v", err)
+	}
+	if used := storageUsed(); used != 10 {
+		t.Errorf("创建会话后 storage_used = %d，期望 10", used)
+	}
+
+	// 未完成的会话已占用配额，再开一个会话超出配额
+	if _, err := s.Init(user.ID, note.ID, &model.UploadInitReq{Filename: "b.txt", Size: 10}); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Fatalf("超出配额时应返回 ErrStorageQuotaExceeded，得到 %v", err)
+	}
+
+	if err := s.Abort(user.ID, session.ID); err != nil {
+		t.Fatalf("取消上传失败: %v", err)
+	}
+	if used := storageUsed(); used != 0 {
+		t.Errorf("取消会话后 storage_used = %d，期望 0", used)
+	}
+
+	// 按附件和未完成会话重新计算时计入会话占用的配额
+	if _, err := s.Init(user.ID, note.ID, &model.UploadInitReq{Filename: "c.txt", Size: 10}); err != nil {
+		t.Fatalf("释放配额后创建会话失败: %v", err)
+	}
+	if err := s.userRepo.RecalculateStorage(user.ID); err != nil {
+		t.Fatalf("重新计算存储用量失败: %v", err)
+	}
+	if used := storageUsed(); used != 10 {
+		t.Errorf("重新计算后 storage_used = %d，期望 10", used)
+	}
+}
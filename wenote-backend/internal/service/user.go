@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"regexp"
+	"time"
+	"wenote-backend/config"
 	"wenote-backend/internal/model"
 	"wenote-backend/internal/repo"
 	"wenote-backend/pkg/hash"
@@ -14,6 +16,9 @@ var (
 	ErrConfirmMismatch = errors.New("确认信息不匹配")
 	ErrInvalidAvatar   = errors.New("无效的头像样式")
 	ErrInvalidEmail    = errors.New("邮箱格式不正确")
+
+	// ErrDeletionCanceled 清除时账号已撤销注销或尚未到期
+	ErrDeletionCanceled = errors.New("账号注销已撤销")
 )
 
 // 邮箱格式验证正则
@@ -38,6 +43,7 @@ type UserService struct {
 	userRepo         *repo.UserRepo
 	noteRepo         *repo.NoteRepo
 	gamificationRepo *repo.GamificationRepo
+	uploadRepo       *repo.UploadRepo
 }
 
 // NewUserService 创建用户服务实例
@@ -46,6 +52,7 @@ func NewUserService() *UserService {
 		userRepo:         repo.NewUserRepo(),
 		noteRepo:         repo.NewNoteRepo(),
 		gamificationRepo: repo.NewGamificationRepo(),
+		uploadRepo:       repo.NewUploadRepo(),
 	}
 }
 
@@ -81,6 +88,8 @@ func (s *UserService) GetProfile(userID uint64) (*model.UserProfileResp, error)
 		TotalNotes:    totalNotes,
 		TotalChars:    totalChars,
 		CurrentStreak: currentStreak,
+		StorageUsed:   user.StorageUsed,
+		StorageQuota:  EffectiveStorageQuota(user),
 	}, nil
 }
 
@@ -146,7 +155,7 @@ func (s *UserService) UpdateProfile(userID uint64, req *model.UpdateProfileReq)
 	return s.GetProfile(userID)
 }
 
-// ChangePassword 修改密码
+// ChangePassword 修改密码，成功后清除管理员重置密码设置的强制改密标记
 func (s *UserService) ChangePassword(userID uint64, req *model.ChangePasswordReq) error {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -176,24 +185,42 @@ func (s *UserService) ChangePassword(userID uint64, req *model.ChangePasswordReq
 }
 
 // DeleteAccount 注销账号
-func (s *UserService) DeleteAccount(userID uint64, req *model.DeleteAccountReq) error {
+// 配置了宽限期时只标记注销时间，期间重新登录可撤销，到期后由定时任务清除；
+// 宽限期为 0 时立即清除
+func (s *UserService) DeleteAccount(userID uint64, req *model.DeleteAccountReq) (*model.DeleteAccountResp, error) {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if user == nil {
-		return ErrUserNotFound
+		return nil, ErrUserNotFound
 	}
 
 	// 验证密码
 	if !hash.CheckPassword(req.Password, user.PasswordHash) {
-		return ErrPasswordIncorrect
+		return nil, ErrPasswordIncorrect
 	}
 
 	// 验证确认信息
 	if req.Confirm != "DELETE" {
-		return ErrConfirmMismatch
+		return nil, ErrConfirmMismatch
 	}
 
-	return s.userRepo.Delete(userID)
+	graceDays := config.GlobalConfig.Account.DeletionGraceDays
+	if graceDays <= 0 {
+		// 清除只处理已到期的注销，立即清除时先将注销时间记为当前时间（取整到秒，避免数据库舍入后晚于当前时间）
+		if err := s.userRepo.ScheduleDeletion(userID, time.Now().Truncate(time.Second)); err != nil {
+			return nil, err
+		}
+		if err := s.PurgeAccount(user); err != nil {
+			return nil, err
+		}
+		return &model.DeleteAccountResp{Purged: true}, nil
+	}
+
+	scheduledAt := time.Now().AddDate(0, 0, graceDays)
+	if err := s.userRepo.ScheduleDeletion(userID, scheduledAt); err != nil {
+		return nil, err
+	}
+	return &model.DeleteAccountResp{ScheduledAt: &scheduledAt}, nil
 }
@@ -12,3 +12,19 @@ type SummaryResult struct {
 type Client interface {
 	GenerateSummaryAndTags(ctx context.Context, content string, summaryLen int) (*SummaryResult, error)
 }
+
+// BreakerStatus 熔断器状态快照
+type BreakerStatus struct {
+	Name                 string `json:"name"`
+	State                string `json:"state"` // closed, half-open, open
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"total_successes"`
+	TotalFailures        uint32 `json:"total_failures"`
+	ConsecutiveSuccesses uint32 `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32 `json:"consecutive_failures"`
+}
+
+// BreakerReporter 可报告熔断器状态的客户端
+type BreakerReporter interface {
+	BreakerStatus() BreakerStatus
+}
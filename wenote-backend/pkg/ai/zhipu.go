@@ -75,6 +75,20 @@ func NewZhipuClient(config ZhipuConfig) *ZhipuClient {
 	}
 }
 
+// BreakerStatus 返回熔断器当前状态，实现 BreakerReporter
+func (c *ZhipuClient) BreakerStatus() BreakerStatus {
+	counts := c.breaker.Counts()
+	return BreakerStatus{
+		Name:                 c.breaker.Name(),
+		State:                c.breaker.State().String(),
+		Requests:             counts.Requests,
+		TotalSuccesses:       counts.TotalSuccesses,
+		TotalFailures:        counts.TotalFailures,
+		ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+		ConsecutiveFailures:  counts.ConsecutiveFailures,
+	}
+}
+
 // 智谱 API 请求结构
 type zhipuRequest struct {
 	Model    string         `json:"model"`
@@ -0,0 +1,99 @@
+// Package batch 异步批量处理
+//
+// 调用方只负责入队，由后台协程取出队列中已就绪的元素合并成批，交给处理函数，
+// 用于审计日志落库、搜索索引同步等不需要阻塞请求的写操作
+package batch
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrClosed = errors.New("批量处理器已关闭")
+	ErrFull   = errors.New("批量处理队列已满")
+)
+
+// Worker 批量处理器
+type Worker[T any] struct {
+	mu        sync.RWMutex
+	queue     chan T
+	closed    bool
+	done      chan struct{}
+	batchSize int
+	flush     func([]T)
+}
+
+// Start 创建批量处理器并启动后台协程
+// queueSize 为队列容量，队满时 Add 返回 ErrFull；batchSize 为单批最多合并的元素数；
+// flush 在后台协程中依次调用，传入的切片在调用返回后会被复用
+func Start[T any](queueSize, batchSize int, flush func([]T)) *Worker[T] {
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	w := &Worker[T]{
+		queue:     make(chan T, queueSize),
+		done:      make(chan struct{}),
+		batchSize: batchSize,
+		flush:     flush,
+	}
+	go w.run()
+	return w
+}
+
+// Add 入队，不阻塞；已关闭时返回 ErrClosed，队列已满时返回 ErrFull
+func (w *Worker[T]) Add(item T) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	select {
+	case w.queue <- item:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Close 停止接收新元素，处理完队列中剩余的元素后返回，可重复调用
+func (w *Worker[T]) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+func (w *Worker[T]) run() {
+	defer close(w.done)
+
+	batch := make([]T, 0, w.batchSize)
+	for item := range w.queue {
+		batch = append(batch, item)
+
+		// 合并队列中已就绪的元素
+	drain:
+		for len(batch) < w.batchSize {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
@@ -0,0 +1,16 @@
+// Package broadcast 在多个服务实例之间广播消息，用于同步各实例内存中的缓存
+//
+// 消息只保证尽力送达：实例与 Redis 断开期间的消息会丢失，调用方需要有兜底，如缓存有效期
+package broadcast
+
+import "context"
+
+// Broadcaster 消息广播
+type Broadcaster interface {
+	// Publish 向所有订阅的实例发送消息，包括发送方自己
+	Publish(ctx context.Context, msg string) error
+	// Subscribe 注册消息处理函数，在后台协程中按收到的顺序调用
+	Subscribe(handler func(msg string))
+	// Close 停止订阅并释放连接
+	Close() error
+}
@@ -0,0 +1,76 @@
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig Redis 广播配置
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Channel  string // 发布订阅的频道名
+}
+
+// Redis 基于 Redis 发布订阅的广播，断线后由客户端自动重连并重新订阅
+type Redis struct {
+	client  *redis.Client
+	channel string
+
+	mu   sync.Mutex
+	subs []*redis.PubSub
+}
+
+// NewRedis 连接 Redis 并创建广播
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
+	}
+
+	return &Redis{client: client, channel: cfg.Channel}, nil
+}
+
+// Publish 实现 Broadcaster
+func (r *Redis) Publish(ctx context.Context, msg string) error {
+	return r.client.Publish(ctx, r.channel, msg).Err()
+}
+
+// Subscribe 实现 Broadcaster
+func (r *Redis) Subscribe(handler func(msg string)) {
+	ps := r.client.Subscribe(context.Background(), r.channel)
+
+	r.mu.Lock()
+	r.subs = append(r.subs, ps)
+	r.mu.Unlock()
+
+	go func() {
+		for m := range ps.Channel() {
+			handler(m.Payload)
+		}
+	}()
+}
+
+// Close 实现 Broadcaster
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	for _, ps := range r.subs {
+		ps.Close()
+	}
+	r.subs = nil
+	r.mu.Unlock()
+	return r.client.Close()
+}
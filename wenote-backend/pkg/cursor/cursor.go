@@ -0,0 +1,35 @@
+// Package cursor 列表分页游标的编码与解码
+//
+// 游标对客户端是不透明的字符串，内容为 JSON 经 base64url 编码，
+// 由生成游标的一方定义结构，客户端只需原样传回
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalid 游标格式错误或与当前查询不匹配
+var ErrInvalid = errors.New("无效的分页游标")
+
+// Encode 将游标内容编码为字符串
+func Encode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode 将游标字符串解码到 v，格式错误时返回 ErrInvalid
+func Decode(s string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package extract 从附件中提取纯文本，用于全文搜索
+// 支持纯文本、PDF 和 Office Open XML（docx/xlsx/pptx），图片文字识别见 OCR
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ErrUnsupported 不支持提取文本的文件类型
+var ErrUnsupported = errors.New("不支持提取文本的文件类型")
+
+// Office Open XML 的 MIME 类型
+const (
+	MimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+)
+
+// maxUncompressed 解压 Office 文档时单个部件的读取上限，防止压缩炸弹
+const maxUncompressed = 64 << 20
+
+// Supported 是否支持从该类型中提取文本（不含 OCR）
+func Supported(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return true
+	case mimeType == "application/pdf", mimeType == MimeDOCX, mimeType == MimeXLSX, mimeType == MimePPTX:
+		return true
+	}
+	return false
+}
+
+// Text 从文档中提取纯文本，连续空白折叠为单个空格或换行
+// 不支持的类型返回 ErrUnsupported
+func Text(data []byte, mimeType string) (string, error) {
+	var (
+		text string
+		err  error
+	)
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		text = decodeText(data)
+	case mimeType == "application/pdf":
+		text, err = pdfText(data)
+	case mimeType == MimeDOCX:
+		text, err = docxText(data)
+	case mimeType == MimeXLSX:
+		text, err = xlsxText(data)
+	case mimeType == MimePPTX:
+		text, err = pptxText(data)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return Normalize(text), nil
+}
+
+// decodeText 将纯文本解码为 UTF-8
+// 非 UTF-8 内容按 GB18030 解码，兼容 Windows 下保存的中文文本
+func decodeText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil {
+		return string(decoded)
+	}
+	return strings.ToValidUTF8(string(data), "")
+}
+
+// Normalize 折叠连续空白：行内空白合并为一个空格，空行合并为一个换行，并去除控制字符
+func Normalize(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	pendingSpace, pendingNewline := false, false
+	for _, r := range text {
+		switch {
+		case r == '\n' || r == '\r':
+			pendingNewline = true
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		case unicode.IsControl(r) || r == utf8.RuneError:
+			continue
+		default:
+			if b.Len() > 0 {
+				if pendingNewline {
+					b.WriteByte('\n')
+				} else if pendingSpace {
+					b.WriteByte(' ')
+				}
+			}
+			pendingSpace, pendingNewline = false, false
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Truncate 截断为最多 maxRunes 个字符，maxRunes <= 0 时不截断
+func Truncate(text string, maxRunes int) string {
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxRunes])
+}
@@ -0,0 +1,65 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// OCR 图片文字识别后端
+type OCR interface {
+	// Recognize 识别图片中的文字
+	Recognize(ctx context.Context, data []byte, mimeType string) (string, error)
+}
+
+// NewOCR 根据驱动名创建 OCR 后端，driver 为空或 none 时返回 nil（不识别图片）
+func NewOCR(driver, command, languages string) (OCR, error) {
+	switch driver {
+	case "", "none":
+		return nil, nil
+	case "tesseract":
+		t, err := NewTesseract(command, languages)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("不支持的 OCR 驱动: %s", driver)
+	}
+}
+
+// Tesseract 调用本地 tesseract 命令行识别图片
+type Tesseract struct {
+	command   string
+	languages string
+}
+
+// NewTesseract 创建 tesseract 后端，命令不存在时返回错误
+func NewTesseract(command, languages string) (*Tesseract, error) {
+	if command == "" {
+		command = "tesseract"
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("找不到 tesseract 命令: %w", err)
+	}
+	if languages == "" {
+		languages = "eng"
+	}
+	return &Tesseract{command: path, languages: languages}, nil
+}
+
+// Recognize 通过标准输入传入图片，从标准输出读取识别结果
+func (t *Tesseract) Recognize(ctx context.Context, data []byte, mimeType string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command, "stdin", "stdout", "-l", t.languages)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract 识别失败: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return Normalize(stdout.String()), nil
+}
@@ -0,0 +1,133 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// docxText 提取 Word 文档正文，每个段落一行
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("解析 docx 失败: %w", err)
+	}
+	return partsText(zr, []string{"word/document.xml"}, "t", "p", "br", "tab")
+}
+
+// xlsxText 提取 Excel 单元格中的文本
+// 文本单元格保存在共享字符串表中，内联字符串保存在各工作表中
+func xlsxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("解析 xlsx 失败: %w", err)
+	}
+	parts := append([]string{"xl/sharedStrings.xml"}, numberedParts(zr, "xl/worksheets/sheet")...)
+	return partsText(zr, parts, "t", "si", "is")
+}
+
+// pptxText 提取 PowerPoint 各页幻灯片中的文本，按页码顺序
+func pptxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("解析 pptx 失败: %w", err)
+	}
+	return partsText(zr, numberedParts(zr, "ppt/slides/slide"), "t", "p", "br")
+}
+
+// numberedParts 按编号排序返回形如 prefix1.xml、prefix2.xml 的部件名
+func numberedParts(zr *zip.Reader, prefix string) []string {
+	type part struct {
+		name string
+		n    int
+	}
+	var parts []part
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || path.Ext(f.Name) != ".xml" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), ".xml"))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, part{f.Name, n})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].n < parts[j].n })
+
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+// partsText 依次提取多个 XML 部件中的文本，不存在的部件跳过
+func partsText(zr *zip.Reader, names []string, textElem string, breakElems ...string) (string, error) {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		err = xmlText(io.LimitReader(rc, maxUncompressed), &b, textElem, breakElems)
+		rc.Close()
+		if err != nil {
+			return "", fmt.Errorf("解析 %s 失败: %w", name, err)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// xmlText 收集名为 textElem 的元素中的文本，breakElems 中的元素结束时换行
+// 按本地名匹配，忽略命名空间前缀（w:t、a:t 等）
+func xmlText(r io.Reader, b *strings.Builder, textElem string, breakElems []string) error {
+	dec := xml.NewDecoder(r)
+	inText := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == textElem {
+				inText++
+			}
+		case xml.EndElement:
+			if t.Name.Local == textElem && inText > 0 {
+				inText--
+			}
+			for _, e := range breakElems {
+				if t.Name.Local == e {
+					b.WriteByte('\n')
+					break
+				}
+			}
+		case xml.CharData:
+			if inText > 0 {
+				b.Write(t)
+			}
+		}
+	}
+}
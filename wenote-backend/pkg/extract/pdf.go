@@ -0,0 +1,33 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfText 提取 PDF 文本层的内容，扫描件没有文本层时返回空字符串
+// 解析库遇到损坏的文件可能 panic，这里统一转换为错误
+func pdfText(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("解析 pdf 失败: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("解析 pdf 失败: %w", err)
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("解析 pdf 失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(plain, maxUncompressed)); err != nil {
+		return "", fmt.Errorf("解析 pdf 失败: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,179 @@
+// Package highlight 在文本中定位搜索词，生成搜索结果的高亮片段
+// 位置均以字符（rune）为单位，匹配时忽略大小写
+package highlight
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Range 命中位置，区间为 [Start, End)
+type Range struct {
+	Start int
+	End   int
+}
+
+// Fragment 命中位置附近的文本片段
+type Fragment struct {
+	Text    string  // 片段文本，换行和制表符替换为空格
+	Offset  int     // 片段在原文中的起始位置
+	Matches []Range // 命中位置，相对于 Text
+}
+
+// Find 返回所有搜索词在文本中的命中位置，按位置排序并合并重叠的区间
+func Find(text string, terms []string) []Range {
+	return find(lowerRunes(text), terms)
+}
+
+func find(lower []rune, terms []string) []Range {
+	var ranges []Range
+	for _, term := range terms {
+		needle := lowerRunes(strings.TrimSpace(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if lower[i] != needle[0] || !hasPrefix(lower[i:], needle) {
+				continue
+			}
+			ranges = append(ranges, Range{Start: i, End: i + len(needle)})
+		}
+	}
+	return merge(ranges)
+}
+
+// Fragments 为命中位置截取长度约为 size 的片段，最多 max 个
+// 命中位置尽量位于片段中间，落在同一片段内的命中合并，没有命中时返回 nil
+func Fragments(text string, terms []string, size, max int) []Fragment {
+	runes := []rune(text)
+	var fragments []Fragment
+	start, end := 0, 0 // 当前片段的范围，end 为 0 表示还没有片段
+	var matches []Range
+	flush := func() {
+		fragments = append(fragments, Fragment{Text: plain(runes[start:end]), Offset: start, Matches: matches})
+	}
+
+	for _, m := range find(lowerRunes(text), terms) {
+		if end > 0 && m.Start < end {
+			// 命中跨越片段末尾时延长片段，保证命中不被截断
+			if m.End > end {
+				end = m.End
+			}
+			matches = append(matches, Range{m.Start - start, m.End - start})
+			continue
+		}
+		if end > 0 {
+			flush()
+			if len(fragments) >= max {
+				return fragments
+			}
+		}
+
+		prevEnd := end
+		start = m.Start - (size-(m.End-m.Start))/2
+		if start < prevEnd {
+			start = prevEnd
+		}
+		if start < 0 {
+			start = 0
+		}
+		end = start + size
+		if end > len(runes) {
+			// 靠近文本末尾时向前补足长度
+			end = len(runes)
+			if start = end - size; start < prevEnd {
+				start = prevEnd
+			}
+			if start < 0 {
+				start = 0
+			}
+		}
+		if end < m.End {
+			end = m.End
+		}
+		matches = []Range{{m.Start - start, m.End - start}}
+	}
+	if end > 0 {
+		flush()
+	}
+	return fragments
+}
+
+// Mark 将文本转义为 HTML，并用 <mark> 标出命中位置
+func Mark(text string, matches []Range) string {
+	runes := []rune(text)
+	var b strings.Builder
+	pos := 0
+	for _, m := range matches {
+		if m.Start < pos || m.End > len(runes) {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.Start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.Start:m.End])))
+		b.WriteString("</mark>")
+		pos = m.End
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:])))
+	return b.String()
+}
+
+// lowerRunes 逐字符转小写，保持字符数不变，使位置与原文一一对应
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// merge 排序并合并重叠或相邻的区间
+func merge(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Start != ranges[j].Start {
+			return ranges[i].Start < ranges[j].Start
+		}
+		return ranges[i].End > ranges[j].End
+	})
+	merged := []Range{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// plain 将换行、制表符等空白替换为空格，字符数不变
+func plain(runes []rune) string {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			r = ' '
+		}
+		out[i] = r
+	}
+	return string(out)
+}
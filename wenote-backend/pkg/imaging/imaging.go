@@ -0,0 +1,186 @@
+// Package imaging 附件图片处理：去除元数据、按 EXIF 方向校正、缩放和生成缩略图
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// ErrUnsupported 不支持处理的图片格式
+var ErrUnsupported = errors.New("不支持的图片格式")
+
+// JPEGQuality 重新编码 JPEG 时的质量
+var JPEGQuality = 88
+
+// Result 图片处理结果
+type Result struct {
+	Data          []byte
+	MimeType      string
+	Width         int
+	Height        int
+	DominantColor string // 形如 "#a1b2c3"，用于图片加载前的占位背景
+}
+
+// Sanitize 去除图片中的元数据（EXIF/GPS 等）
+//
+//   - JPEG/PNG：按 EXIF 方向校正后重新编码，编码器不会写出任何元数据；
+//     长边超过 maxDimension 时等比缩小（maxDimension <= 0 表示不限制）
+//   - WebP：纯 Go 无法编码，直接删除 EXIF/XMP 数据块
+//   - GIF：可能是动图，保持原样
+func Sanitize(data []byte, mimeType string, maxDimension int) (*Result, error) {
+	switch mimeType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		img = applyOrientation(img, jpegOrientation(data))
+		img = fit(img, maxDimension)
+		return encode(img, "image/jpeg")
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		img = fit(img, maxDimension)
+		return encode(img, "image/png")
+
+	case "image/webp":
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		stripped, err := stripWebPMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		return describe(img, stripped, mimeType), nil
+
+	case "image/gif":
+		img, err := gif.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("解码图片失败: %w", err)
+		}
+		return describe(img, data, mimeType), nil
+
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Thumbnail 生成长边不超过 maxDimension 的缩略图
+// 不透明图片输出 JPEG，带透明通道的输出 PNG
+func Thumbnail(data []byte, mimeType string, maxDimension int) (*Result, error) {
+	img, err := decode(data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	thumb := fit(img, maxDimension)
+	if isOpaque(thumb) {
+		return encode(thumb, "image/jpeg")
+	}
+	return encode(thumb, "image/png")
+}
+
+func decode(data []byte, mimeType string) (image.Image, error) {
+	var img image.Image
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	case "image/webp":
+		img, err = webp.Decode(bytes.NewReader(data))
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	return img, nil
+}
+
+func encode(img image.Image, mimeType string) (*Result, error) {
+	var buf bytes.Buffer
+	var err error
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码图片失败: %w", err)
+	}
+	return describe(img, buf.Bytes(), mimeType), nil
+}
+
+func describe(img image.Image, data []byte, mimeType string) *Result {
+	b := img.Bounds()
+	return &Result{
+		Data:          data,
+		MimeType:      mimeType,
+		Width:         b.Dx(),
+		Height:        b.Dy(),
+		DominantColor: dominantColor(img),
+	}
+}
+
+// fit 等比缩放到长边不超过 maxDimension，已经足够小时原样返回
+func fit(img image.Image, maxDimension int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxDimension/w)
+		w = maxDimension
+	} else {
+		w = max(1, w*maxDimension/h)
+		h = maxDimension
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// dominantColor 计算图片的平均颜色
+func dominantColor(img image.Image) string {
+	small := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var r, g, b, a uint64
+	for i := 0; i < len(small.Pix); i += 4 {
+		// Pix 为预乘 alpha，直接累加后除以总 alpha 得到加权平均
+		r += uint64(small.Pix[i])
+		g += uint64(small.Pix[i+1])
+		b += uint64(small.Pix[i+2])
+		a += uint64(small.Pix[i+3])
+	}
+	if a == 0 {
+		return "#ffffff"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r*255/a, g*255/a, b*255/a)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidWebP = errors.New("无效的 WebP 文件")
+
+// stripWebPMetadata 删除 WebP 中的 EXIF 和 XMP 数据块，并清除 VP8X 头中对应的标志位
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	pos := 12
+	for pos+8 <= len(data) {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2 // 数据块按偶数字节对齐
+		if size < 0 || pos+8+size > len(data) {
+			return nil, errInvalidWebP
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// 丢弃
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if size > 0 {
+				// 第一个字节的标志位：0x08 = EXIF，0x04 = XMP
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
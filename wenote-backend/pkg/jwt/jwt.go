@@ -17,6 +17,7 @@ var (
 type CustomClaims struct {
 	UserID   uint64 `json:"user_id"`
 	Username string `json:"username"`
+	Tier     string `json:"tier,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -32,10 +33,11 @@ func NewJWTManager(secret string, expireHour int) *JWTManager {
 	}
 }
 
-func (j *JWTManager) GenerateToken(userID uint64, username string) (string, error) {
+func (j *JWTManager) GenerateToken(userID uint64, username, tier string) (string, error) {
 	claims := CustomClaims{
 		UserID:   userID,
 		Username: username,
+		Tier:     tier,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(j.ExpireHour) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,34 @@
+// Package placeholder 文本中 {{name}} 形式占位符的查找与替换
+//
+// 名称由字母、数字和下划线组成且不以数字开头，两侧可以有空格，如 {{ date }}；
+// 没有提供值的占位符原样保留，正文中其他用途的 {{ }} 不受影响
+package placeholder
+
+import (
+	"regexp"
+	"strings"
+)
+
+// namePattern 占位符名称
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// ValidName 名称是否可用作占位符
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Render 将文本中的占位符替换为 values 中的值，values 中没有的占位符原样保留
+func Render(text string, values map[string]string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,136 @@
+// Package query 笔记搜索语句的解析
+//
+// 语法示例：tag:go notebook:"工作" is:starred created:>2026-01-01 -draft "exact phrase" title:api
+//
+//   - 空格分隔的条件同时满足（也可显式写 AND），OR 表示任一满足且优先级高于 AND，括号用于分组
+//   - 条件前加 - 或 NOT 表示排除
+//   - 双引号内为短语，可包含空格；字段值同样可以加引号，如 notebook:"My Work"
+//   - 日期字段支持 >、>=、<、<=，以及 created:2026-01-01..2026-03-31 形式的闭区间
+//   - 日期可以写成相对日期，在解析时换算：today、yesterday、-30d、-2w、-3m、-1y，
+//     如 updated:>=-30d 表示最近 30 天内修改过
+package query
+
+import (
+	"fmt"
+	"time"
+)
+
+// 支持的字段
+const (
+	FieldText     = ""         // 全文（标题、正文和附件文本）
+	FieldTag      = "tag"      // 标签名
+	FieldNotebook = "notebook" // 笔记本名
+	FieldIs       = "is"       // 状态：starred、pinned、untagged
+	FieldCreated  = "created"  // 创建日期
+	FieldUpdated  = "updated"  // 更新日期
+	FieldTitle    = "title"    // 标题包含
+)
+
+// fieldNames 字段名，按在错误提示中出现的顺序排列
+var fieldNames = []string{FieldTag, FieldNotebook, FieldIs, FieldCreated, FieldUpdated, FieldTitle}
+
+// is: 字段支持的值
+const (
+	IsStarred  = "starred"
+	IsPinned   = "pinned"
+	IsUntagged = "untagged" // 没有标签
+)
+
+// DateLayout 日期字段的格式
+const DateLayout = "2006-01-02"
+
+// Node 语法树节点，为 *And、*Or、*Not、*Term 之一
+type Node interface {
+	node()
+}
+
+// And 全部子条件同时满足
+type And struct {
+	Children []Node
+}
+
+// Or 任一子条件满足
+type Or struct {
+	Children []Node
+}
+
+// Not 排除子条件
+type Not struct {
+	Child Node
+}
+
+// Term 单个条件
+type Term struct {
+	Field  string    // 字段名，全文条件为空
+	Op     string    // 比较运算符，仅日期字段使用：=、>、>=、<、<=
+	Value  string    // 条件值，已去除引号
+	Phrase bool      // 是否为引号括起的短语
+	Date   time.Time // 日期字段解析后的日期（本地时区零点），相对日期按解析时间换算
+	Pos    int       // 在查询语句中的位置（字符序号，从 0 开始）
+}
+
+// DateBounds 日期条件对应的时间区间 [from, to)，按整天比较，无下界或上界时为 nil
+// 如 >2026-01-01 表示 1 月 2 日零点及以后，<=2026-01-01 包含 1 月 1 日当天
+func (t *Term) DateBounds() (from, to *time.Time, err error) {
+	start := t.Date
+	end := start.AddDate(0, 0, 1)
+	switch t.Op {
+	case ">":
+		return &end, nil, nil
+	case ">=":
+		return &start, nil, nil
+	case "<":
+		return nil, &start, nil
+	case "<=":
+		return nil, &end, nil
+	case "=", "":
+		return &start, &end, nil
+	}
+	return nil, nil, fmt.Errorf("不支持的日期运算符 %s", t.Op)
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+// Error 查询语法错误
+type Error struct {
+	Pos int    // 出错位置（字符序号，从 0 开始）
+	Msg string // 错误说明
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("第 %d 个字符附近：%s", e.Pos+1, e.Msg)
+}
+
+// TextTerms 返回未被排除的全文条件，用于相关性排序和结果高亮
+func TextTerms(n Node) []*Term {
+	return FieldTerms(n, FieldText)
+}
+
+// FieldTerms 返回指定字段上未被排除的条件
+func FieldTerms(n Node, field string) []*Term {
+	var terms []*Term
+	var walk func(n Node)
+	walk = func(n Node) {
+		switch v := n.(type) {
+		case *And:
+			for _, c := range v.Children {
+				walk(c)
+			}
+		case *Or:
+			for _, c := range v.Children {
+				walk(c)
+			}
+		case *Term:
+			if v.Field == field {
+				terms = append(terms, v)
+			}
+		}
+	}
+	if n != nil {
+		walk(n)
+	}
+	return terms
+}
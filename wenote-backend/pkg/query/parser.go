@@ -0,0 +1,373 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenLParen           // (
+	tokenRParen           // )
+	tokenNot              // - 或 NOT
+	tokenAnd              // AND
+	tokenOr               // OR
+	tokenTerm             // 条件
+)
+
+type token struct {
+	kind tokenKind
+	node Node // tokenTerm 的条件，日期区间为 *And
+	pos  int
+}
+
+// Parse 解析查询语句，空语句返回 nil
+// 语法错误返回 *Error，包含出错位置和说明
+func Parse(input string) (Node, error) {
+	tokens, err := lex([]rune(input))
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		// parseAnd 只会在右括号或结尾处停止
+		return nil, &Error{Pos: tok.pos, Msg: "多余的右括号"}
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+	return tok
+}
+
+// parseAnd and := or (["AND"] or)*
+// 与常见搜索引擎一致，OR 的优先级高于 AND："a OR b c" 等价于 "(a OR b) c"
+func (p *parser) parseAnd() (Node, error) {
+	var children []Node
+	for {
+		tok := p.peek()
+		if tok.kind == tokenEOF || tok.kind == tokenRParen {
+			break
+		}
+		if tok.kind == tokenAnd {
+			p.next()
+			if k := p.peek().kind; k == tokenEOF || k == tokenRParen || k == tokenOr || k == tokenAnd {
+				return nil, &Error{Pos: tok.pos, Msg: "AND 后缺少搜索条件"}
+			}
+			continue
+		}
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 0 {
+		tok := p.peek()
+		msg := "缺少搜索条件"
+		if tok.kind == tokenRParen {
+			msg = "括号内缺少搜索条件"
+		}
+		return nil, &Error{Pos: tok.pos, Msg: msg}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &And{Children: children}, nil
+}
+
+// parseOr or := unary ("OR" unary)*
+func (p *parser) parseOr() (Node, error) {
+	if tok := p.peek(); tok.kind == tokenOr {
+		return nil, &Error{Pos: tok.pos, Msg: "OR 前缺少搜索条件"}
+	}
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for p.peek().kind == tokenOr {
+		tok := p.next()
+		if k := p.peek().kind; k == tokenEOF || k == tokenRParen || k == tokenOr || k == tokenAnd {
+			return nil, &Error{Pos: tok.pos, Msg: "OR 后缺少搜索条件"}
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Or{Children: children}, nil
+}
+
+// parseUnary unary := ("-" | "NOT") unary | primary
+func (p *parser) parseUnary() (Node, error) {
+	tok := p.peek()
+	if tok.kind != tokenNot {
+		return p.parsePrimary()
+	}
+	p.next()
+	if k := p.peek().kind; k == tokenEOF || k == tokenRParen || k == tokenOr || k == tokenAnd {
+		return nil, &Error{Pos: tok.pos, Msg: "排除符号后缺少搜索条件"}
+	}
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &Not{Child: child}, nil
+}
+
+// parsePrimary primary := "(" and ")" | term
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenTerm:
+		return tok.node, nil
+	case tokenLParen:
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, &Error{Pos: tok.pos, Msg: "括号没有闭合"}
+		}
+		p.next()
+		return node, nil
+	default:
+		return nil, &Error{Pos: tok.pos, Msg: "缺少搜索条件"}
+	}
+}
+
+// lex 将查询语句切分为词法单元，并解析字段条件，结尾追加 tokenEOF
+func lex(input []rune) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		r := input[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: i})
+			i++
+		case r == '-' && i+1 < len(input) && !unicode.IsSpace(input[i+1]) && input[i+1] != ')':
+			tokens = append(tokens, token{kind: tokenNot, pos: i})
+			i++
+		case r == '"':
+			value, end, err := readPhrase(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenTerm, pos: i, node: &Term{Value: value, Phrase: true, Pos: i}})
+			i = end
+		default:
+			start := i
+			for i < len(input) && !unicode.IsSpace(input[i]) && input[i] != '(' && input[i] != ')' && input[i] != '"' {
+				i++
+			}
+			word := string(input[start:i])
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd, pos: start})
+				continue
+			case "OR", "|":
+				tokens = append(tokens, token{kind: tokenOr, pos: start})
+				continue
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot, pos: start})
+				continue
+			}
+
+			field, value, ok := strings.Cut(word, ":")
+			if !ok || !isFieldName(field) {
+				// 只有标点的词无法被全文索引匹配，忽略
+				if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+					continue
+				}
+				tokens = append(tokens, token{kind: tokenTerm, pos: start, node: &Term{Value: word, Pos: start}})
+				continue
+			}
+
+			// 字段值可以加引号：notebook:"My Work"
+			phrase := false
+			if value == "" && i < len(input) && input[i] == '"' {
+				var err error
+				value, i, err = readPhrase(input, i)
+				if err != nil {
+					return nil, err
+				}
+				phrase = true
+			}
+			node, err := fieldTerm(strings.ToLower(field), value, phrase, start)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenTerm, pos: start, node: node})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// readPhrase 读取从 start 处引号开始的短语，返回短语内容和结束引号之后的位置
+func readPhrase(input []rune, start int) (string, int, error) {
+	end := start + 1
+	for end < len(input) && input[end] != '"' {
+		end++
+	}
+	if end >= len(input) {
+		return "", 0, &Error{Pos: start, Msg: "引号没有闭合"}
+	}
+	value := strings.TrimSpace(string(input[start+1 : end]))
+	if value == "" {
+		return "", 0, &Error{Pos: start, Msg: "引号内缺少内容"}
+	}
+	return value, end + 1, nil
+}
+
+// isFieldName 冒号前的部分是否为字段名
+// 未知的纯字母前缀视为写错的字段名，在 fieldTerm 中报错；其余（如时间 12:30）按普通文本处理
+func isFieldName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldTerm 解析字段条件并校验取值
+func fieldTerm(field, value string, phrase bool, pos int) (Node, error) {
+	known := false
+	for _, name := range fieldNames {
+		if name == field {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, &Error{Pos: pos, Msg: "未知的搜索字段 " + field + "，可用字段：" + strings.Join(fieldNames, "、") + "；如需搜索包含冒号的文本请加引号"}
+	}
+	if value == "" {
+		return nil, &Error{Pos: pos, Msg: field + ": 后缺少值"}
+	}
+
+	switch field {
+	case FieldIs:
+		value = strings.ToLower(value)
+		if value != IsStarred && value != IsPinned && value != IsUntagged {
+			return nil, &Error{Pos: pos, Msg: "is: 只支持 starred、pinned、untagged"}
+		}
+	case FieldCreated, FieldUpdated:
+		return dateTerm(field, value, pos)
+	}
+	return &Term{Field: field, Value: value, Phrase: phrase, Pos: pos}, nil
+}
+
+// dateTerm 解析日期条件：[op]日期 或 日期..日期，日期可以是相对日期
+// 区间展开为 >= 起始日期 且 <= 结束日期
+func dateTerm(field, value string, pos int) (Node, error) {
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		fromTerm, err := dateValue(field, ">=", from, pos)
+		if err != nil {
+			return nil, err
+		}
+		toTerm, err := dateValue(field, "<=", to, pos)
+		if err != nil {
+			return nil, err
+		}
+		if toTerm.Date.Before(fromTerm.Date) {
+			return nil, &Error{Pos: pos, Msg: field + ": 区间的结束日期早于开始日期"}
+		}
+		return &And{Children: []Node{fromTerm, toTerm}}, nil
+	}
+
+	op := "="
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, candidate) {
+			op = candidate
+			value = value[len(candidate):]
+			break
+		}
+	}
+	return dateValue(field, op, value, pos)
+}
+
+func dateValue(field, op, value string, pos int) (*Term, error) {
+	date, ok := relativeDate(value, time.Now())
+	if !ok {
+		var err error
+		date, err = time.ParseInLocation(DateLayout, value, time.Local)
+		if err != nil {
+			return nil, &Error{Pos: pos, Msg: field + ": 的日期格式应为 YYYY-MM-DD 或 today、-30d 等相对日期，如 " + field + ":>2026-01-01"}
+		}
+	}
+	return &Term{Field: field, Op: op, Value: value, Date: date, Pos: pos}, nil
+}
+
+// relativeDate 解析相对日期，以 now 所在日的零点为基准
+// 支持 today、yesterday，以及 -Nd、-Nw、-Nm、-Ny（N 天、周、月、年前）
+func relativeDate(value string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch strings.ToLower(value) {
+	case "today":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	}
+
+	if len(value) < 3 || value[0] != '-' {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	switch unicode.ToLower(rune(value[len(value)-1])) {
+	case 'd':
+		return today.AddDate(0, 0, -n), true
+	case 'w':
+		return today.AddDate(0, 0, -7*n), true
+	case 'm':
+		return today.AddDate(0, -n, 0), true
+	case 'y':
+		return today.AddDate(-n, 0, 0), true
+	}
+	return time.Time{}, false
+}
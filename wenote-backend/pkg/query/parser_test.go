@@ -0,0 +1,303 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// render 将语法树输出为便于比较的前缀表达式，如 (AND a (OR b c))
+// 条件写作 field:op"value"，短语加引号，全文条件省略字段名
+func render(n Node) string {
+	switch v := n.(type) {
+	case nil:
+		return "<nil>"
+	case *And:
+		return renderList("AND", v.Children)
+	case *Or:
+		return renderList("OR", v.Children)
+	case *Not:
+		return "(NOT " + render(v.Child) + ")"
+	case *Term:
+		s := v.Value
+		if v.Phrase {
+			s = `"` + s + `"`
+		}
+		s = v.Op + s
+		if v.Field != FieldText {
+			s = v.Field + ":" + s
+		}
+		return s
+	}
+	return "?"
+}
+
+func renderList(op string, children []Node) string {
+	parts := []string{op}
+	for _, c := range children {
+		parts = append(parts, render(c))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"空语句", "", "<nil>"},
+		{"只有空白", "   ", "<nil>"},
+		{"只有标点", "- , .", "<nil>"},
+		{"单个词", "golang", "golang"},
+		{"空格表示同时满足", "a b c", "(AND a b c)"},
+		{"显式 AND", "a AND b", "(AND a b)"},
+		{"OR", "a OR b", "(OR a b)"},
+		{"竖线等同于 OR", "a | b", "(OR a b)"},
+		{"OR 优先级高于 AND", "a OR b c", "(AND (OR a b) c)"},
+		{"OR 优先级高于显式 AND", "a AND b OR c", "(AND a (OR b c))"},
+		{"连续 OR", "a OR b OR c", "(OR a b c)"},
+		{"括号分组", "(a b) OR c", "(OR (AND a b) c)"},
+		{"嵌套括号", "((a))", "a"},
+		{"减号排除", "a -b", "(AND a (NOT b))"},
+		{"NOT 排除", "NOT a", "(NOT a)"},
+		{"排除作用于括号", "-(a OR b)", "(NOT (OR a b))"},
+		{"排除优先级高于 OR", "-a OR b", "(OR (NOT a) b)"},
+		{"连续排除", "NOT -a", "(NOT (NOT a))"},
+		{"词中的减号不是排除", "e-mail", "e-mail"},
+		{"小写的 and 和 or 是普通词", "a and or b", "(AND a and or b)"},
+		{"短语", `"hello world"`, `"hello world"`},
+		{"短语去除首尾空白", `" hello "`, `"hello"`},
+		{"短语与词相邻", `a"b c"d`, `(AND a "b c" d)`},
+		{"排除短语", `-"exact phrase"`, `(NOT "exact phrase")`},
+		{"短语中的运算符不生效", `"a OR b"`, `"a OR b"`},
+		{"字段条件", "tag:go", "tag:go"},
+		{"字段名不区分大小写", "TAG:go", "tag:go"},
+		{"字段值加引号", `notebook:"My Work"`, `notebook:"My Work"`},
+		{"标题条件", "title:api", "title:api"},
+		{"is 的值转为小写", "is:Starred", "is:starred"},
+		{"is:pinned", "is:pinned", "is:pinned"},
+		{"is:untagged", "is:untagged", "is:untagged"},
+		{"字段与全文组合", "tag:go -draft", "(AND tag:go (NOT draft))"},
+		{"时间按普通文本处理", "12:30", "12:30"},
+		{"日期等于", "created:2026-01-01", "created:=2026-01-01"},
+		{"日期大于", "created:>2026-01-01", "created:>2026-01-01"},
+		{"日期大于等于", "updated:>=2026-01-01", "updated:>=2026-01-01"},
+		{"日期小于", "updated:<2026-01-01", "updated:<2026-01-01"},
+		{"日期小于等于", "created:<=2026-01-01", "created:<=2026-01-01"},
+		{"日期区间", "created:2026-01-01..2026-03-31", "(AND created:>=2026-01-01 created:<=2026-03-31)"},
+		{"单日区间", "created:2026-01-01..2026-01-01", "(AND created:>=2026-01-01 created:<=2026-01-01)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) 返回错误: %v", tt.input, err)
+			}
+			if got := render(node); got != tt.want {
+				t.Errorf("Parse(%q) = %s，期望 %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTermPos(t *testing.T) {
+	node, err := Parse(`ab -"c d" tag:x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	and := node.(*And)
+	positions := []int{
+		and.Children[0].(*Term).Pos,
+		and.Children[1].(*Not).Child.(*Term).Pos,
+		and.Children[2].(*Term).Pos,
+	}
+	if positions[0] != 0 || positions[1] != 4 || positions[2] != 10 {
+		t.Errorf("条件位置为 %v，期望 [0 4 10]", positions)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	node, err := Parse("created:2026-03-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 3, 5, 0, 0, 0, 0, time.Local)
+	if got := node.(*Term).Date; !got.Equal(want) {
+		t.Errorf("日期为 %v，期望本地时区零点 %v", got, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{"OR 开头", "OR a", 0, "OR 前缺少搜索条件"},
+		{"OR 结尾", "a OR", 2, "OR 后缺少搜索条件"},
+		{"连续 OR", "a OR OR b", 2, "OR 后缺少搜索条件"},
+		{"AND 结尾", "a AND", 2, "AND 后缺少搜索条件"},
+		{"AND 后接 OR", "a AND OR b", 2, "AND 后缺少搜索条件"},
+		{"NOT 结尾", "a NOT", 2, "排除符号后缺少搜索条件"},
+		{"排除右括号", "(a NOT)", 3, "排除符号后缺少搜索条件"},
+		{"括号没有闭合", "a (b c", 2, "括号没有闭合"},
+		{"多余的右括号", "a b)", 3, "多余的右括号"},
+		{"空括号", "a ()", 3, "括号内缺少搜索条件"},
+		{"引号没有闭合", `a "bc`, 2, "引号没有闭合"},
+		{"空引号", `a ""`, 2, "引号内缺少内容"},
+		{"未知字段", "a foo:bar", 2, "未知的搜索字段 foo"},
+		{"字段缺少值", "tag:", 0, "tag: 后缺少值"},
+		{"字段值引号没有闭合", `notebook:"work`, 9, "引号没有闭合"},
+		{"is 不支持的值", "is:draft", 0, "is: 只支持"},
+		{"日期格式错误", "x created:2026/01/01", 2, "created: 的日期格式应为"},
+		{"日期区间结束早于开始", "updated:2026-03-01..2026-01-01", 0, "区间的结束日期早于开始日期"},
+		{"位置按字符计算", "标签 OR", 3, "OR 后缺少搜索条件"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("Parse(%q) = %s, %v，期望 *Error", tt.input, render(node), err)
+			}
+			if qerr.Pos != tt.pos {
+				t.Errorf("Parse(%q) 错误位置为 %d，期望 %d（%s）", tt.input, qerr.Pos, tt.pos, qerr.Msg)
+			}
+			if !strings.Contains(qerr.Msg, tt.msg) {
+				t.Errorf("Parse(%q) 错误说明为 %q，期望包含 %q", tt.input, qerr.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Pos: 4, Msg: "括号没有闭合"}
+	if got, want := err.Error(), "第 5 个字符附近：括号没有闭合"; got != want {
+		t.Errorf("Error() = %q，期望 %q", got, want)
+	}
+}
+
+func TestTextTerms(t *testing.T) {
+	node, err := Parse(`go (rust OR "c lang") -java tag:x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, term := range TextTerms(node) {
+		values = append(values, term.Value)
+	}
+	if got, want := strings.Join(values, ","), "go,rust,c lang"; got != want {
+		t.Errorf("TextTerms = %s，期望 %s（排除的条件和字段条件不参与）", got, want)
+	}
+	if TextTerms(nil) != nil {
+		t.Error("空语法树应返回 nil")
+	}
+}
+
+func TestRelativeDate(t *testing.T) {
+	now := time.Date(2026, 3, 31, 15, 4, 5, 0, time.Local)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"today", day(2026, 3, 31), true},
+		{"TODAY", day(2026, 3, 31), true},
+		{"yesterday", day(2026, 3, 30), true},
+		{"-0d", day(2026, 3, 31), true},
+		{"-30d", day(2026, 3, 1), true},
+		{"-2w", day(2026, 3, 17), true},
+		{"-1m", day(2026, 3, 3), true}, // 2 月没有 31 日，按 AddDate 顺延
+		{"-3M", day(2025, 12, 31), true},
+		{"-1y", day(2025, 3, 31), true},
+		{"2026-01-01", time.Time{}, false},
+		{"30d", time.Time{}, false},
+		{"-d", time.Time{}, false},
+		{"--1d", time.Time{}, false},
+		{"-1h", time.Time{}, false},
+		{"tomorrow", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := relativeDate(tt.value, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("relativeDate(%q) = %v, %v，期望 %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseRelativeDate(t *testing.T) {
+	node, err := Parse("updated:>=-7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	term := node.(*Term)
+	now := time.Now()
+	want := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -7)
+	if term.Op != ">=" || term.Value != "-7d" || !term.Date.Equal(want) {
+		t.Errorf("解析结果为 %s %s %v，期望 >= -7d %v", term.Op, term.Value, term.Date, want)
+	}
+
+	if _, err := Parse("created:yesterday..today"); err != nil {
+		t.Errorf("相对日期区间应能解析: %v", err)
+	}
+	if _, err := Parse("created:today..-1w"); err == nil {
+		t.Error("结束日期早于开始日期的相对区间应报错")
+	}
+}
+
+func TestTermDateBounds(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	next := date.AddDate(0, 0, 1)
+
+	tests := []struct {
+		op       string
+		from, to *time.Time
+	}{
+		{"=", &date, &next},
+		{"", &date, &next},
+		{">", &next, nil},
+		{">=", &date, nil},
+		{"<", nil, &date},
+		{"<=", nil, &next},
+	}
+	for _, tt := range tests {
+		from, to, err := (&Term{Field: FieldCreated, Op: tt.op, Date: date}).DateBounds()
+		if err != nil {
+			t.Errorf("运算符 %q 返回错误: %v", tt.op, err)
+			continue
+		}
+		if !sameTime(from, tt.from) || !sameTime(to, tt.to) {
+			t.Errorf("运算符 %q 的区间为 [%v, %v)，期望 [%v, %v)", tt.op, from, to, tt.from, tt.to)
+		}
+	}
+
+	if _, _, err := (&Term{Field: FieldCreated, Op: "!=", Date: date}).DateBounds(); err == nil {
+		t.Error("不支持的运算符应返回错误")
+	}
+
+	// 跨夏令时的日期仍按自然日计算，而不是固定 24 小时
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("加载时区失败: %v", err)
+	}
+	dst := time.Date(2026, 3, 8, 0, 0, 0, 0, loc)
+	_, to, _ := (&Term{Op: "<=", Date: dst}).DateBounds()
+	if want := time.Date(2026, 3, 9, 0, 0, 0, 0, loc); !to.Equal(want) {
+		t.Errorf("夏令时切换日的结束时间为 %v，期望 %v", to, want)
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 进程内存储实现
+// 适用于单实例部署，后台协程定期淘汰已回满的令牌桶和已过期的锁定记录
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*memoryBucket
+	attempts map[string]*memoryAttempt
+	stop     chan struct{}
+	once     sync.Once
+	now      func() time.Time // 当前时间，测试中可替换
+}
+
+type memoryBucket struct {
+	tokens    float64
+	last      time.Time
+	expiresAt time.Time // 此时间后令牌桶已回满，可淘汰
+}
+
+type memoryAttempt struct {
+	count       int
+	windowEnd   time.Time
+	lockedUntil time.Time
+}
+
+// NewMemoryStore 创建内存存储，cleanupInterval 为淘汰扫描间隔
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		buckets:  make(map[string]*memoryBucket),
+		attempts: make(map[string]*memoryAttempt),
+		stop:     make(chan struct{}),
+		now:      time.Now,
+	}
+	go s.janitor(cleanupInterval)
+	return s
+}
+
+// Allow 实现 Limiter
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (*Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = refill(b.tokens, now.Sub(b.last), limit)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.expiresAt = now.Add(fullAfter(b.tokens, limit))
+
+	return bucketResult(allowed, b.tokens, limit), nil
+}
+
+// LockedFor 实现 Lockout
+func (s *MemoryStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	att, ok := s.attempts[key]
+	if !ok {
+		return 0, nil
+	}
+	if remaining := att.lockedUntil.Sub(s.now()); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// RecordFailure 实现 Lockout
+func (s *MemoryStore) RecordFailure(_ context.Context, key string, policy LockoutPolicy) (int, time.Duration, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	att, ok := s.attempts[key]
+	if !ok || now.After(att.windowEnd) {
+		att = &memoryAttempt{windowEnd: now.Add(policy.Window)}
+		s.attempts[key] = att
+	}
+
+	att.count++
+	if att.count < policy.MaxAttempts {
+		return att.count, 0, nil
+	}
+
+	// 达到阈值：锁定并重新开始计数
+	count := att.count
+	att.count = 0
+	att.lockedUntil = now.Add(policy.Duration)
+	att.windowEnd = att.lockedUntil
+	return count, policy.Duration, nil
+}
+
+// Reset 实现 Lockout
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.attempts, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Close 停止后台淘汰协程
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+// janitor 定期淘汰过期条目，避免内存无限增长
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evict(now)
+		}
+	}
+}
+
+func (s *MemoryStore) evict(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if now.After(b.expiresAt) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, att := range s.attempts {
+		if now.After(att.windowEnd) && now.After(att.lockedUntil) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestStore 创建使用手动时钟的内存存储，淘汰由测试直接调用 evict
+func newTestStore(t *testing.T) (*MemoryStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore(time.Hour)
+	s.now = clock.Now
+	t.Cleanup(func() { s.Close() })
+	return s, clock
+}
+
+func TestMemoryStoreAllow(t *testing.T) {
+	s, clock := newTestStore(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+
+	steps := []struct {
+		name       string
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}{
+		{name: "首次请求桶为满", allowed: true, remaining: 2, resetAfter: time.Second},
+		{name: "突发第二个", allowed: true, remaining: 1, resetAfter: 2 * time.Second},
+		{name: "突发第三个", allowed: true, remaining: 0, resetAfter: 3 * time.Second},
+		{name: "令牌耗尽", allowed: false, remaining: 0, retryAfter: time.Second, resetAfter: 3 * time.Second},
+		{name: "补充半个令牌仍不足", advance: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, resetAfter: 2500 * time.Millisecond},
+		{name: "补满一个令牌", advance: 500 * time.Millisecond, allowed: true, remaining: 0, resetAfter: 3 * time.Second},
+		{name: "长时间空闲不超过桶容量", advance: time.Hour, allowed: true, remaining: 2, resetAfter: time.Second},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		res, err := s.Allow(ctx, "k", limit)
+		if err != nil {
+			t.Fatalf("%s: Allow 失败: %v", step.name, err)
+		}
+		if res.Allowed != step.allowed || res.Remaining != step.remaining || res.Limit != limit.Burst {
+			t.Errorf("%s: allowed=%v remaining=%d limit=%d，期望 allowed=%v remaining=%d limit=%d",
+				step.name, res.Allowed, res.Remaining, res.Limit, step.allowed, step.remaining, limit.Burst)
+		}
+		if res.RetryAfter != step.retryAfter || res.ResetAfter != step.resetAfter {
+			t.Errorf("%s: retryAfter=%v resetAfter=%v，期望 %v %v",
+				step.name, res.RetryAfter, res.ResetAfter, step.retryAfter, step.resetAfter)
+		}
+	}
+
+	// 不同的 key 使用独立的令牌桶
+	res, err := s.Allow(ctx, "other", limit)
+	if err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("其他 key 应有独立的令牌桶: %+v, %v", res, err)
+	}
+}
+
+func TestMemoryStoreLockout(t *testing.T) {
+	s, clock := newTestStore(t)
+	ctx := context.Background()
+	policy := LockoutPolicy{MaxAttempts: 3, Window: time.Minute, Duration: 5 * time.Minute}
+
+	for i := 1; i < policy.MaxAttempts; i++ {
+		count, lock, err := s.RecordFailure(ctx, "u", policy)
+		if err != nil || count != i || lock != 0 {
+			t.Fatalf("第 %d 次失败: count=%d lock=%v err=%v，期望未锁定", i, count, lock, err)
+		}
+	}
+	count, lock, err := s.RecordFailure(ctx, "u", policy)
+	if err != nil || count != policy.MaxAttempts || lock != policy.Duration {
+		t.Fatalf("达到阈值: count=%d lock=%v err=%v，期望 %d %v", count, lock, err, policy.MaxAttempts, policy.Duration)
+	}
+
+	lockedFor := func() time.Duration {
+		d, err := s.LockedFor(ctx, "u")
+		if err != nil {
+			t.Fatalf("LockedFor 失败: %v", err)
+		}
+		return d
+	}
+	if d := lockedFor(); d != 5*time.Minute {
+		t.Errorf("锁定剩余 %v，期望 5m", d)
+	}
+	clock.Advance(2 * time.Minute)
+	if d := lockedFor(); d != 3*time.Minute {
+		t.Errorf("锁定剩余 %v，期望 3m", d)
+	}
+	if d, _ := s.LockedFor(ctx, "other"); d != 0 {
+		t.Errorf("其他 key 不应被锁定: %v", d)
+	}
+
+	// 锁定到期后解除，失败次数重新计数
+	clock.Advance(3*time.Minute + time.Second)
+	if d := lockedFor(); d != 0 {
+		t.Errorf("锁定到期后剩余 %v，期望 0", d)
+	}
+	if count, lock, _ := s.RecordFailure(ctx, "u", policy); count != 1 || lock != 0 {
+		t.Errorf("锁定到期后: count=%d lock=%v，期望重新计数", count, lock)
+	}
+}
+
+func TestMemoryStoreLockoutWindowExpiry(t *testing.T) {
+	s, clock := newTestStore(t)
+	ctx := context.Background()
+	policy := LockoutPolicy{MaxAttempts: 3, Window: time.Minute, Duration: 5 * time.Minute}
+
+	s.RecordFailure(ctx, "u", policy)
+	s.RecordFailure(ctx, "u", policy)
+
+	// 窗口结束后之前的失败不再计入
+	clock.Advance(time.Minute + time.Second)
+	count, lock, err := s.RecordFailure(ctx, "u", policy)
+	if err != nil || count != 1 || lock != 0 {
+		t.Errorf("窗口过期后: count=%d lock=%v err=%v，期望 1 0", count, lock, err)
+	}
+}
+
+func TestMemoryStoreReset(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+	policy := LockoutPolicy{MaxAttempts: 2, Window: time.Minute, Duration: 5 * time.Minute}
+
+	// 登录成功清除失败次数
+	s.RecordFailure(ctx, "u", policy)
+	if err := s.Reset(ctx, "u"); err != nil {
+		t.Fatal(err)
+	}
+	if count, lock, _ := s.RecordFailure(ctx, "u", policy); count != 1 || lock != 0 {
+		t.Errorf("重置后: count=%d lock=%v，期望 1 0", count, lock)
+	}
+
+	// 重置同时解除锁定
+	if _, lock, _ := s.RecordFailure(ctx, "u", policy); lock == 0 {
+		t.Fatal("达到阈值应锁定")
+	}
+	s.Reset(ctx, "u")
+	if d, _ := s.LockedFor(ctx, "u"); d != 0 {
+		t.Errorf("重置后仍锁定 %v", d)
+	}
+}
+
+func TestMemoryStoreEvict(t *testing.T) {
+	s, clock := newTestStore(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	policy := LockoutPolicy{MaxAttempts: 1, Window: time.Minute, Duration: 5 * time.Minute}
+
+	s.Allow(ctx, "bucket", limit)
+	s.Allow(ctx, "bucket", limit)
+	s.RecordFailure(ctx, "locked", policy)
+	s.RecordFailure(ctx, "counting", LockoutPolicy{MaxAttempts: 3, Window: time.Minute, Duration: time.Minute})
+
+	size := func() (int, int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.buckets), len(s.attempts)
+	}
+
+	// 令牌桶回满前、统计窗口和锁定未结束时保留
+	clock.Advance(time.Second)
+	s.evict(clock.Now())
+	if buckets, attempts := size(); buckets != 1 || attempts != 2 {
+		t.Fatalf("未过期时: buckets=%d attempts=%d，期望 1 2", buckets, attempts)
+	}
+
+	// 令牌桶回满、统计窗口结束后淘汰，锁定中的记录保留
+	clock.Advance(time.Minute)
+	s.evict(clock.Now())
+	if buckets, attempts := size(); buckets != 0 || attempts != 1 {
+		t.Fatalf("窗口结束后: buckets=%d attempts=%d，期望 0 1", buckets, attempts)
+	}
+
+	// 锁定结束后淘汰
+	clock.Advance(5 * time.Minute)
+	s.evict(clock.Now())
+	if _, attempts := size(); attempts != 0 {
+		t.Fatalf("锁定结束后: attempts=%d，期望 0", attempts)
+	}
+}
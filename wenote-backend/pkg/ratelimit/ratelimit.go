@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit 令牌桶参数
+//   - Rate: 每秒补充的令牌数
+//   - Burst: 桶容量（允许的突发请求数）
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result 单次限流判定结果
+type Result struct {
+	Allowed    bool          // 是否放行
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+	ResetAfter time.Duration // 令牌桶恢复满额所需时间
+}
+
+// LockoutPolicy 登录失败锁定策略
+//   - MaxAttempts: 窗口期内允许的最大失败次数
+//   - Window: 失败次数统计窗口
+//   - Duration: 触发锁定后的锁定时长
+type LockoutPolicy struct {
+	MaxAttempts int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+// Limiter 限流存储接口
+type Limiter interface {
+	// Allow 消耗 key 对应令牌桶中的一个令牌
+	Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+}
+
+// Lockout 登录锁定存储接口
+type Lockout interface {
+	// LockedFor 返回 key 剩余的锁定时长，未锁定时返回 0
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// RecordFailure 记录一次失败，返回当前失败次数和触发的锁定时长（未锁定为 0）
+	RecordFailure(ctx context.Context, key string, policy LockoutPolicy) (int, time.Duration, error)
+	// Reset 清除 key 的失败记录和锁定状态
+	Reset(ctx context.Context, key string) error
+}
+
+// Store 同时提供限流和登录锁定能力的共享存储
+type Store interface {
+	Limiter
+	Lockout
+	Close() error
+}
+
+// bucketResult 根据令牌桶剩余令牌数构造判定结果
+// 内存实现和 Redis 实现共用，保证两者语义一致
+func bucketResult(allowed bool, tokens float64, limit Limit) *Result {
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+	}
+	if limit.Rate > 0 {
+		res.ResetAfter = secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate)
+		if !allowed {
+			res.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+		}
+	}
+	return res
+}
+
+// refill 按流逝时间补充令牌，返回新的令牌数
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * limit.Rate
+	}
+	return math.Min(float64(limit.Burst), tokens)
+}
+
+// fullAfter 计算令牌桶回满所需时间，回满后条目可安全淘汰
+func fullAfter(tokens float64, limit Limit) time.Duration {
+	if limit.Rate <= 0 {
+		return 0
+	}
+	return secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
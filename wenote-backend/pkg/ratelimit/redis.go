@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig Redis 连接配置
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	Prefix   string
+}
+
+// RedisStore 基于 Redis 的共享存储实现
+// 多副本部署时所有实例共享同一份限流和锁定状态
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// tokenBucketScript 原子地补充并消耗令牌
+// 使用 Redis 服务器时间，避免各副本时钟不一致
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts) / 1000
+tokens = math.min(burst, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+local ttl = 1000
+if rate > 0 then
+  ttl = math.ceil((burst - tokens) / rate * 1000) + 1000
+end
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return {allowed, tostring(tokens)}
+`)
+
+// recordFailureScript 原子地累加失败次数，达到阈值时写入锁定键
+var recordFailureScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if count >= tonumber(ARGV[2]) then
+  redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+  redis.call('DEL', KEYS[1])
+  return {count, 1}
+end
+return {count, 0}
+`)
+
+// NewRedisStore 创建 Redis 存储并检查连通性
+func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "wenote:"
+	}
+
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+// Allow 实现 Limiter
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	vals, err := tokenBucketScript.Run(ctx, s.client,
+		[]string{s.prefix + "rl:" + key},
+		limit.Rate, limit.Burst,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != 2 {
+		return nil, fmt.Errorf("令牌桶脚本返回值异常: %v", vals)
+	}
+
+	allowed, _ := vals[0].(int64)
+	tokensStr, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析令牌数失败: %w", err)
+	}
+
+	return bucketResult(allowed == 1, tokens, limit), nil
+}
+
+// LockedFor 实现 Lockout
+func (s *RedisStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, s.lockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// 键不存在时 PTTL 返回负值
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RecordFailure 实现 Lockout
+func (s *RedisStore) RecordFailure(ctx context.Context, key string, policy LockoutPolicy) (int, time.Duration, error) {
+	vals, err := recordFailureScript.Run(ctx, s.client,
+		[]string{s.failKey(key), s.lockKey(key)},
+		policy.Window.Milliseconds(), policy.MaxAttempts, policy.Duration.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(vals) != 2 {
+		return 0, 0, fmt.Errorf("锁定脚本返回值异常: %v", vals)
+	}
+
+	if vals[1] == 1 {
+		return int(vals[0]), policy.Duration, nil
+	}
+	return int(vals[0]), 0, nil
+}
+
+// Reset 实现 Lockout
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.failKey(key), s.lockKey(key)).Err()
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisStore) failKey(key string) string {
+	return s.prefix + "lockout:fail:" + key
+}
+
+func (s *RedisStore) lockKey(key string) string {
+	return s.prefix + "lockout:lock:" + key
+}
@@ -20,6 +20,7 @@ const (
 	CodeUnauthorized    = 401
 	CodeForbidden       = 403
 	CodeNotFound        = 404
+	CodeConflict        = 409
 	CodeTooManyRequests = 429
 	CodeInternalError   = 500
 )
@@ -30,6 +31,7 @@ var codeMessages = map[int]string{
 	CodeUnauthorized:    "未授权，请先登录",
 	CodeForbidden:       "禁止访问",
 	CodeNotFound:        "资源不存在",
+	CodeConflict:        "资源状态冲突",
 	CodeTooManyRequests: "请求过于频繁",
 	CodeInternalError:   "服务器内部错误",
 }
@@ -58,6 +60,8 @@ func Fail(c *gin.Context, code int, message string) {
 		httpStatus = http.StatusForbidden
 	} else if code == CodeNotFound {
 		httpStatus = http.StatusNotFound
+	} else if code == CodeConflict {
+		httpStatus = http.StatusConflict
 	} else if code == CodeTooManyRequests {
 		httpStatus = http.StatusTooManyRequests
 	} else if code == CodeInternalError {
@@ -84,6 +88,13 @@ func Unauthorized(c *gin.Context, message string) {
 	Fail(c, CodeUnauthorized, message)
 }
 
+func Forbidden(c *gin.Context, message string) {
+	if message == "" {
+		message = codeMessages[CodeForbidden]
+	}
+	Fail(c, CodeForbidden, message)
+}
+
 func NotFound(c *gin.Context, message string) {
 	if message == "" {
 		message = codeMessages[CodeNotFound]
@@ -91,6 +102,13 @@ func NotFound(c *gin.Context, message string) {
 	Fail(c, CodeNotFound, message)
 }
 
+func Conflict(c *gin.Context, message string) {
+	if message == "" {
+		message = codeMessages[CodeConflict]
+	}
+	Fail(c, CodeConflict, message)
+}
+
 func InternalError(c *gin.Context, message string) {
 	if message == "" {
 		message = codeMessages[CodeInternalError]
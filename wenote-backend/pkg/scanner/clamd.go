@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize INSTREAM 每个数据块的大小，需小于 clamd 的 StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+// Clamd ClamAV 守护进程客户端，通过 INSTREAM 命令流式发送内容
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd 创建 clamd 客户端
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	if address == "" {
+		return nil, errors.New("未配置 clamd 地址")
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}, nil
+}
+
+// Name 扫描器名称
+func (c *Clamd) Name() string { return "clamd" }
+
+// Scan 通过 INSTREAM 发送内容并解析扫描结果
+// 协议：发送 zINSTREAM\0，随后是若干个"4 字节大端长度 + 数据"的块，以长度为 0 的块结束；
+// 响应为 "stream: OK"、"stream: <特征名> FOUND" 或 "<原因> ERROR"，以 \0 结尾
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("发送扫描请求失败: %w", err)
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd 超出大小限制时会提前返回错误并关闭连接，尝试读取原因
+				if reply, rerr := readReply(conn); rerr == nil {
+					return parseReply(reply)
+				}
+				return nil, fmt.Errorf("发送扫描内容失败: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("读取扫描内容失败: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("发送扫描内容失败: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return nil, fmt.Errorf("读取扫描结果失败: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// readReply 读取以 \0 结尾的响应
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+// parseReply 解析 clamd 响应
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd 返回错误: %s", reply)
+	}
+}
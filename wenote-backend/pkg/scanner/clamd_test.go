@@ -0,0 +1,196 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{name: "安全", reply: "stream: OK"},
+		{name: "检出", reply: "stream: Eicar-Test-Signature FOUND", infected: true, signature: "Eicar-Test-Signature"},
+		{name: "特征名含空格", reply: "stream: Win.Test EICAR_HDB-1 FOUND", infected: true, signature: "Win.Test EICAR_HDB-1"},
+		{name: "超出大小限制", reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{name: "扫描出错", reply: "stream: Can't allocate memory ERROR", wantErr: true},
+		{name: "空响应", reply: "", wantErr: true},
+		{name: "未知响应", reply: "UNKNOWN COMMAND", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseReply(tt.reply)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseReply(%q) 应返回错误，得到 %+v", tt.reply, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReply(%q) 失败: %v", tt.reply, err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("parseReply(%q) = %+v，期望 infected=%v signature=%q", tt.reply, result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"unix:///run/clamd.sock", "unix", "/run/clamd.sock"},
+		{"/run/clamd.sock", "unix", "/run/clamd.sock"},
+	}
+	for _, tt := range tests {
+		c, err := NewClamd(tt.address, 0)
+		if err != nil {
+			t.Fatalf("NewClamd(%q) 失败: %v", tt.address, err)
+		}
+		if c.network != tt.network || c.address != tt.addr {
+			t.Errorf("NewClamd(%q) = %s %s，期望 %s %s", tt.address, c.network, c.address, tt.network, tt.addr)
+		}
+	}
+	if _, err := NewClamd("", 0); err == nil {
+		t.Error("未配置地址时应返回错误")
+	}
+}
+
+// fakeClamd 在本地端口模拟 clamd 的 INSTREAM 协议
+// 收到的内容超过 limit 字节时与 clamd 一样提前返回大小超限错误并关闭连接，limit 为 0 表示不限制
+func fakeClamd(t *testing.T, limit int) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听本地端口: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, limit)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func serveClamd(conn net.Conn, limit int) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var data []byte
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return
+		}
+		data = append(data, chunk...)
+		if limit > 0 && len(data) > limit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	if bytes.Contains(data, []byte(EICAR)) {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamdScan(t *testing.T) {
+	c, err := NewClamd(fakeClamd(t, 0), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		infected  bool
+		signature string
+	}{
+		{"安全内容", "hello", false, ""},
+		{"空内容", "", false, ""},
+		{"EICAR", EICAR, true, "Eicar-Test-Signature"},
+		// 超过一个数据块，特征串跨越块边界
+		{"多个数据块", strings.Repeat("a", clamdChunkSize-10) + EICAR, true, "Eicar-Test-Signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := c.Scan(context.Background(), strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Scan 失败: %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("Scan = %+v，期望 infected=%v signature=%q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestClamdScanSizeLimit(t *testing.T) {
+	c, err := NewClamd(fakeClamd(t, clamdChunkSize), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超出大小限制时返回错误，附件保持待扫描而不是被视为安全
+	content := strings.Repeat("a", 8*clamdChunkSize)
+	result, err := c.Scan(context.Background(), strings.NewReader(content))
+	if err == nil {
+		t.Fatalf("超出大小限制时应返回错误，得到 %+v", result)
+	}
+	if !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("错误信息不正确: %v", err)
+	}
+}
+
+func TestClamdScanUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听本地端口: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c, err := NewClamd(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Scan(context.Background(), strings.NewReader("hello")); err == nil {
+		t.Error("clamd 不可用时应返回错误")
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		driver  string
+		name    string
+		wantErr bool
+	}{
+		{driver: "", name: ""},
+		{driver: "none", name: ""},
+		{driver: "noop", name: "noop"},
+		{driver: "fake", name: "fake"},
+		{driver: "clamd", wantErr: true}, // 未配置地址
+		{driver: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := New(tt.driver, "", 0)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) 应返回错误", tt.driver)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("New(%q) 失败: %v", tt.driver, err)
+		}
+		name := ""
+		if s != nil {
+			name = s.Name()
+		}
+		if name != tt.name {
+			t.Errorf("New(%q) 扫描器为 %q，期望 %q", tt.driver, name, tt.name)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	f.Signatures["MALWARE-MARKER"] = "Test.Marker"
+
+	tests := []struct {
+		name      string
+		content   string
+		signature string
+	}{
+		{"安全内容", "hello world", ""},
+		{"EICAR", EICAR, "Eicar-Test-Signature"},
+		{"嵌入在其他内容中", "prefix " + EICAR + " suffix", "Eicar-Test-Signature"},
+		{"自定义特征", "xx MALWARE-MARKER xx", "Test.Marker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.Scan(context.Background(), strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Scan 失败: %v", err)
+			}
+			if result.Infected != (tt.signature != "") || result.Signature != tt.signature {
+				t.Errorf("Scan = %+v，期望 signature=%q", result, tt.signature)
+			}
+		})
+	}
+}
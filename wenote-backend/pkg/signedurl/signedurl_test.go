@@ -0,0 +1,92 @@
+package signedurl
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseSigned 从签名地址中取出 expires 和 sig
+func parseSigned(t *testing.T, signed string) (int64, string) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("解析签名地址失败: %v", err)
+	}
+	expires, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil {
+		t.Fatalf("无效的 expires: %v", err)
+	}
+	return expires, u.Query().Get("sig")
+}
+
+func TestSignURL(t *testing.T) {
+	s := NewSigner("secret")
+
+	signed := s.SignURL("/api/v1/attachments/1/content", "attachment:1", time.Minute)
+	if !strings.HasPrefix(signed, "/api/v1/attachments/1/content?") {
+		t.Errorf("签名地址格式不正确: %s", signed)
+	}
+	expires, sig := parseSigned(t, signed)
+	if err := s.Verify("attachment:1", expires, sig); err != nil {
+		t.Errorf("有效的签名地址校验失败: %v", err)
+	}
+
+	// 已带查询参数的地址追加签名参数，原参数保留
+	signed = s.SignURL("/api/v1/attachments/1/content?size=small", "attachment:1:small", time.Minute)
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("size") != "small" || u.Query().Get("sig") == "" {
+		t.Errorf("签名参数未正确追加: %s", signed)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := NewSigner("secret")
+	expires, sig := s.Sign("attachment:1:small", time.Minute)
+	pastExpires, pastSig := s.Sign("attachment:1:small", -time.Minute)
+
+	tests := []struct {
+		name     string
+		resource string
+		expires  int64
+		sig      string
+		want     error
+	}{
+		{"有效", "attachment:1:small", expires, sig, nil},
+		{"已过期", "attachment:1:small", pastExpires, pastSig, ErrExpired},
+		{"篡改签名", "attachment:1:small", expires, tamper(sig), ErrInvalidSignature},
+		{"空签名", "attachment:1:small", expires, "", ErrInvalidSignature},
+		{"延长过期时间", "attachment:1:small", expires + 3600, sig, ErrInvalidSignature},
+		{"过期地址改为未过期", "attachment:1:small", expires, pastSig, ErrInvalidSignature},
+		{"尺寸不同", "attachment:1:medium", expires, sig, ErrInvalidSignature},
+		{"缩略图签名访问原图", "attachment:1", expires, sig, ErrInvalidSignature},
+		{"其他附件", "attachment:2:small", expires, sig, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Verify(tt.resource, tt.expires, tt.sig); err != tt.want {
+				t.Errorf("Verify = %v，期望 %v", err, tt.want)
+			}
+		})
+	}
+
+	// 不同密钥签发的地址无效
+	if err := NewSigner("other").Verify("attachment:1:small", expires, sig); err != ErrInvalidSignature {
+		t.Errorf("其他密钥校验 = %v，期望 ErrInvalidSignature", err)
+	}
+}
+
+// tamper 修改签名的最后一个字符
+func tamper(sig string) string {
+	last := "0"
+	if strings.HasSuffix(sig, "0") {
+		last = "1"
+	}
+	return sig[:len(sig)-1] + last
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage 内存存储，用于测试和本地调试，进程退出后数据丢失
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	data []byte
+	info ObjectInfo
+}
+
+// NewMemoryStorage 创建内存存储
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = &memoryEntry{
+		data: data,
+		info: ObjectInfo{
+			Key:          key,
+			Size:         int64(len(data)),
+			ContentType:  contentType,
+			LastModified: time.Now(),
+		},
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, key string) (Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return &memoryObject{Reader: bytes.NewReader(entry.data), info: entry.info}, nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	info := entry.info
+	return &info, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+// URL 返回 memory:// 形式的伪地址，带上过期时间便于测试断言
+func (s *MemoryStorage) URL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u := "memory://" + key
+	if expiry > 0 {
+		u += "?expires=" + url.QueryEscape(time.Now().Add(expiry).UTC().Format(time.RFC3339))
+	}
+	return u, nil
+}
+
+func (s *MemoryStorage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	s.mu.RLock()
+	infos := make([]ObjectInfo, 0, len(s.objects))
+	for key, entry := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, entry.info)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memoryObject struct {
+	*bytes.Reader
+	info ObjectInfo
+}
+
+func (o *memoryObject) Close() error {
+	return nil
+}
+
+func (o *memoryObject) Info() ObjectInfo {
+	return o.info
+}
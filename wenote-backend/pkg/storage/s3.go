@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config S3 兼容存储配置（AWS S3 / MinIO / 阿里云 OSS 等）
+type S3Config struct {
+	Endpoint  string // 如 "s3.amazonaws.com"、"localhost:9000"
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+	Prefix    string // 对象键前缀，多个环境共用一个桶时使用
+	// PublicBaseURL 不为空时视为公开读桶，直接拼接访问地址；为空时返回预签名地址
+	PublicBaseURL string
+}
+
+// S3Storage S3 兼容对象存储
+type S3Storage struct {
+	client *minio.Client
+	cfg    S3Config
+}
+
+// NewS3Storage 创建 S3 兼容存储，并校验桶是否存在
+func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 客户端失败: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("检查存储桶失败: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("存储桶不存在: %s", cfg.Bucket)
+	}
+
+	return &S3Storage{client: client, cfg: cfg}, nil
+}
+
+func (s *S3Storage) objectName(key string) string {
+	return s.cfg.Prefix + strings.TrimPrefix(key, "/")
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.cfg.Bucket, s.objectName(key), r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (Object, error) {
+	obj, err := s.client.GetObject(ctx, s.cfg.Bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertS3Error(err)
+	}
+	// GetObject 是惰性请求，通过 Stat 确认对象存在
+	st, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, convertS3Error(err)
+	}
+	return &s3Object{Object: obj, info: s3Info(key, st)}, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	st, err := s.client.StatObject(ctx, s.cfg.Bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		return nil, convertS3Error(err)
+	}
+	info := s3Info(key, st)
+	return &info, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.cfg.Bucket, s.objectName(key), minio.RemoveObjectOptions{})
+}
+
+func (s *S3Storage) URL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if s.cfg.PublicBaseURL != "" {
+		return strings.TrimSuffix(s.cfg.PublicBaseURL, "/") + "/" + s.objectName(key), nil
+	}
+	if expiry <= 0 {
+		expiry = 15 * time.Minute
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.cfg.Bucket, s.objectName(key), expiry, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	opts := minio.ListObjectsOptions{Prefix: s.objectName(prefix), Recursive: true}
+	for obj := range s.client.ListObjects(ctx, s.cfg.Bucket, opts) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		key := strings.TrimPrefix(obj.Key, s.cfg.Prefix)
+		if err := fn(s3Info(key, obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func s3Info(key string, st minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         st.Size,
+		ContentType:  st.ContentType,
+		LastModified: st.LastModified,
+	}
+}
+
+func convertS3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotExist
+	}
+	return err
+}
+
+type s3Object struct {
+	*minio.Object
+	info ObjectInfo
+}
+
+func (o *s3Object) Info() ObjectInfo {
+	return o.info
+}
@@ -0,0 +1,90 @@
+// Package storage 附件对象存储抽象
+//
+// 业务层只通过对象键（如 "images/user_1/xxx.png"）访问文件，
+// 具体落在本地磁盘还是 S3 兼容存储由配置决定，便于多副本部署和容器重建
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("对象不存在")
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Object 可随机读取的对象内容，支持 HTTP Range 请求
+type Object interface {
+	io.ReadSeekCloser
+	Info() ObjectInfo
+}
+
+// Storage 对象存储接口
+type Storage interface {
+	// Put 写入对象，size 未知时传 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 打开对象用于读取，调用方负责关闭
+	Open(ctx context.Context, key string) (Object, error)
+	// Stat 获取对象元信息，不存在时返回 ErrNotExist
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 获取对象的访问地址；私有存储返回有效期为 expiry 的预签名地址
+	URL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// List 遍历指定前缀下的所有对象
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// NormalizeKey 将旧版本地存储路径转换为对象键
+// 旧数据的 storage_path 形如 "uploads/images/user_1/xxx.png"
+func NormalizeKey(path string) string {
+	key := strings.ReplaceAll(path, "\\", "/")
+	key = strings.TrimPrefix(key, "./")
+	key = strings.TrimPrefix(key, "uploads/")
+	return strings.TrimPrefix(key, "/")
+}
+
+// Copy 将对象从 src 复制到 dst
+func Copy(ctx context.Context, src, dst Storage, key string) error {
+	obj, err := src.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	info := obj.Info()
+	return dst.Put(ctx, key, obj, info.Size, info.ContentType)
+}
+
+// Config 存储后端配置
+type Config struct {
+	Driver       string // local | s3 | memory
+	LocalRoot    string
+	LocalBaseURL string
+	S3           S3Config
+}
+
+// New 根据配置创建存储后端
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalRoot, cfg.LocalBaseURL)
+	case "s3":
+		return NewS3Storage(ctx, cfg.S3)
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,182 @@
+// Package suggest 输入联想的前缀索引
+//
+// 每个名称按以下位置建立键，输入的前缀与任一键的开头相同即命中：
+//   - 名称开头，如 "Go 并发编程" 可由 "go" 命中
+//   - 每个单词的开头，如 "并发" 之前的空格后、"Kubernetes-Guide" 中的 "guide"
+//   - 每个汉字，中文没有空格分词，"并发编程" 可由 "编程" 命中
+//
+// 键统一转为小写，保存在有序切片中，查询时二分查找前缀的起点
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxScan 单次查询最多检查的键数，避免单个字符的前缀扫描整个索引
+const maxScan = 1000
+
+// Item 索引项
+type Item struct {
+	Type string // 类型，如 note、tag、notebook
+	ID   uint64
+	Text string // 原始名称
+}
+
+type itemKey struct {
+	typ string
+	id  uint64
+}
+
+// entry 有序切片中的一个键，key 为小写名称从某个位置开始的后缀
+type entry struct {
+	key  string
+	item itemKey
+	head bool // 是否从名称开头开始
+}
+
+// Index 单个用户的前缀索引，可并发读写
+type Index struct {
+	mu      sync.RWMutex
+	entries []entry // 按 key 升序
+	items   map[itemKey]*Item
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{items: make(map[itemKey]*Item)}
+}
+
+// Load 用 items 替换索引的全部内容
+func (x *Index) Load(items []*Item) {
+	entries := make([]entry, 0, len(items)*2)
+	byKey := make(map[itemKey]*Item, len(items))
+	for _, item := range items {
+		k := itemKey{item.Type, item.ID}
+		byKey[k] = item
+		entries = appendEntries(entries, k, item.Text)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	x.mu.Lock()
+	x.entries = entries
+	x.items = byKey
+	x.mu.Unlock()
+}
+
+// Put 新增或更新一项，名称为空时等同于 Remove
+func (x *Index) Put(item *Item) {
+	k := itemKey{item.Type, item.ID}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if old, ok := x.items[k]; ok {
+		if old.Text == item.Text {
+			return
+		}
+		x.remove(k)
+	}
+	if strings.TrimSpace(item.Text) == "" {
+		return
+	}
+
+	x.items[k] = item
+	for _, e := range appendEntries(nil, k, item.Text) {
+		i := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].key >= e.key })
+		x.entries = append(x.entries, entry{})
+		copy(x.entries[i+1:], x.entries[i:])
+		x.entries[i] = e
+	}
+}
+
+// Remove 删除一项，不存在时忽略
+func (x *Index) Remove(typ string, id uint64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(itemKey{typ, id})
+}
+
+func (x *Index) remove(k itemKey) {
+	if _, ok := x.items[k]; !ok {
+		return
+	}
+	delete(x.items, k)
+	kept := x.entries[:0]
+	for _, e := range x.entries {
+		if e.item != k {
+			kept = append(kept, e)
+		}
+	}
+	x.entries = kept
+}
+
+// Len 索引项数量
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.items)
+}
+
+// Search 返回名称中有位置以 prefix 开头的项，最多 limit 项
+// 名称开头命中的排在前面，其次名称较短的在前，同一项只返回一次
+func (x *Index) Search(prefix string, limit int) []*Item {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" || limit <= 0 {
+		return nil
+	}
+
+	type hit struct {
+		item *Item
+		head bool
+	}
+
+	x.mu.RLock()
+	start := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].key >= prefix })
+	seen := make(map[itemKey]int)
+	var hits []hit
+	for i := start; i < len(x.entries) && i-start < maxScan; i++ {
+		e := x.entries[i]
+		if !strings.HasPrefix(e.key, prefix) {
+			break
+		}
+		if j, ok := seen[e.item]; ok {
+			hits[j].head = hits[j].head || e.head
+			continue
+		}
+		seen[e.item] = len(hits)
+		hits = append(hits, hit{item: x.items[e.item], head: e.head})
+	}
+	x.mu.RUnlock()
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].head != hits[j].head {
+			return hits[i].head
+		}
+		return len([]rune(hits[i].item.Text)) < len([]rune(hits[j].item.Text))
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	items := make([]*Item, len(hits))
+	for i, h := range hits {
+		items[i] = h.item
+	}
+	return items
+}
+
+// appendEntries 为名称的开头、每个单词的开头和每个汉字建立键
+func appendEntries(entries []entry, k itemKey, text string) []entry {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	prevWord := false
+	for i, r := range lower {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if i == 0 || (word && !prevWord) || unicode.Is(unicode.Han, r) {
+			entries = append(entries, entry{key: lower[i:], item: k, head: i == 0})
+		}
+		prevWord = word
+	}
+	return entries
+}
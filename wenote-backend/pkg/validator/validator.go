@@ -62,7 +62,8 @@ var customMessages = map[string]map[string]string{
 		"eq":       "请输入 DELETE 确认注销",
 	},
 	"notebook_id": {
-		"required": "请选择笔记本",
+		"required":         "请选择笔记本",
+		"required_without": "请选择笔记本或模板",
 	},
 	"note_ids": {
 		"required": "请选择要操作的笔记",